@@ -0,0 +1,223 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAnalyticsWindow  = 7 * 24 * time.Hour
+	defaultTimeSeriesWindow = 30 * 24 * time.Hour
+	defaultRealtimeWindow   = 5 * time.Minute
+)
+
+type ZeroResultsResponse struct {
+	From    time.Time                `json:"from"`
+	To      time.Time                `json:"to"`
+	Queries []models.ZeroResultQuery `json:"queries"`
+}
+
+func RegisterAnalyticsRoutes(r *gin.Engine, dbRepo database.SearchLogRepository, clickRepo database.ClickRepository, transitionRepo database.TransitionRepository, timeSeriesSrv service.TimeSeriesService, realtimeTopSrv service.RealtimeTopService, middleware ...gin.HandlerFunc) {
+	analytics := r.Group("/analytics", middleware...)
+
+	analytics.GET("/zero-results", func(c *gin.Context) {
+		from, to, err := parseTimeWindow(c, defaultAnalyticsWindow)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit, err := parseLimit(c, 50)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		queries, err := dbRepo.TopZeroResultQueries(c.Request.Context(), from, to, limit)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, ZeroResultsResponse{From: from, To: to, Queries: queries})
+	})
+
+	// With a query, returns its CTR, mean click position and most clicked results. Without one, ranks the most clicked queries.
+	analytics.GET("/clicks", func(c *gin.Context) {
+		limit, err := parseLimit(c, 20)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		queryText := c.Query("query")
+		if queryText == "" {
+			stats, err := clickRepo.TopClickedQueries(c.Request.Context(), limit)
+			if err != nil {
+				abortWithInternalError(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, stats)
+			return
+		}
+
+		stats, err := clickRepo.GetQueryClickStats(c.Request.Context(), queryText, limit)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		if stats == nil {
+			abortWithProblem(c, http.StatusNotFound, "query not found")
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+
+	analytics.GET("/reformulations", func(c *gin.Context) {
+		queryText := c.Query("query")
+		if queryText == "" {
+			abortWithProblem(c, http.StatusBadRequest, "query is required")
+			return
+		}
+		limit, err := parseLimit(c, 20)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		stats, err := transitionRepo.GetReformulations(c.Request.Context(), queryText, limit)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		if stats == nil {
+			abortWithProblem(c, http.StatusNotFound, "query not found")
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+
+	// Counts a query by hour, day or week in the "tz" time zone, where dates given as "from" and "to" are read.
+	analytics.GET("/queries/:query/timeseries", func(c *gin.Context) {
+		loc, err := parseTimezone(c.Query("tz"))
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		from, to, err := parseTimeWindowIn(c, defaultTimeSeriesWindow, loc)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		granularity := models.TimeSeriesGranularity(c.DefaultQuery("granularity", string(models.TimeSeriesGranularityDay)))
+
+		series, err := timeSeriesSrv.QueryTimeSeries(c.Request.Context(), c.Param("query"), granularity, from, to, loc)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidGranularity) || errors.Is(err, service.ErrTooManyPoints) {
+				abortWithProblem(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, series)
+	})
+
+	// Estimates the most searched queries over the last "window", a duration such as 90s or 15m, across replicas.
+	analytics.GET("/realtime/top", func(c *gin.Context) {
+		window := defaultRealtimeWindow
+		if raw := c.Query("window"); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil {
+				abortWithProblem(c, http.StatusBadRequest, "window must be a duration, such as 5m")
+				return
+			}
+			window = parsed
+		}
+		limit, err := parseLimit(c, 10)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		top, err := realtimeTopSrv.TopQueries(c.Request.Context(), window, limit)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidRealtimeWindow) {
+				abortWithProblem(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, top)
+	})
+}
+
+// parseTimezone loads an IANA time zone name, such as America/New_York, defaulting to UTC.
+func parseTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	// Local would depend on the server the request reaches.
+	if err != nil || name == "Local" {
+		return nil, errors.New("tz must be an IANA time zone name, such as America/New_York")
+	}
+	return loc, nil
+}
+
+// parseTimeWindow reads the "from" and "to" query parameters as RFC 3339 timestamps or YYYY-MM-DD dates.
+// "to" defaults to now and "from" to defaultWindow before "to".
+func parseTimeWindow(c *gin.Context, defaultWindow time.Duration) (time.Time, time.Time, error) {
+	return parseTimeWindowIn(c, defaultWindow, time.UTC)
+}
+
+// parseTimeWindowIn is parseTimeWindow with dates read as midnight in loc.
+func parseTimeWindowIn(c *gin.Context, defaultWindow time.Duration, loc *time.Location) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := parseTimeIn(raw, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultWindow)
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := parseTimeIn(raw, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+func parseTime(raw string) (time.Time, error) {
+	return parseTimeIn(raw, time.UTC)
+}
+
+func parseTimeIn(raw string, loc *time.Location) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return parsed.UTC(), nil
+	}
+	return time.ParseInLocation(time.DateOnly, raw, loc)
+}
+
+func parseLimit(c *gin.Context, defaultLimit int) (int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit <= 0 || limit > 1000 {
+		return 0, errors.New("limit must be an integer between 1 and 1000")
+	}
+	return limit, nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"search-logger/models"
+	"search-logger/service"
+	"search-logger/tenant"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// APIKeyHeader carries the API key of a request. Clients that cannot set headers, like browsers opening the
+	// dashboard, can send the key as the password of HTTP Basic credentials instead.
+	APIKeyHeader = "X-API-Key"
+
+	// authRealm is sent in WWW-Authenticate, so browsers prompt for credentials.
+	authRealm = `Basic realm="search-logger", charset="UTF-8"`
+
+	// apiKeyIDKey is the gin context key holding the ID of the key a request was authenticated with.
+	apiKeyIDKey = "api_key_id"
+)
+
+type AuthConfig struct {
+	// IngestKeysOptional lets requests without credentials through on the routes an ingest key can call. Keys are
+	// still checked when sent. Every other route always requires credentials.
+	IngestKeysOptional bool
+	// AdminUsername and AdminPassword are HTTP Basic credentials with the admin role, e.g. to bootstrap the first keys
+	// of a deployment. They are disabled when AdminPassword is empty, leaving admin keys as the only way in.
+	AdminUsername string
+	AdminPassword string
+}
+
+// AuthMiddleware requires an API key with one of roles, or the admin credentials, unless cfg.IngestKeysOptional
+// applies to roles. Routes are closed when no admin credentials are configured and no key exists. A key pinned to a tenant acts for
+// it as described by models.APIKey.ResolveTenant, so the middleware must run after TenantMiddleware. Requests with a
+// key are counted in its usage.
+func AuthMiddleware(keys service.APIKeyService, cfg AuthConfig, roles ...models.APIKeyRole) gin.HandlerFunc {
+	required := !cfg.IngestKeysOptional || !slices.Contains(roles, models.APIKeyRoleIngest)
+	return func(c *gin.Context) {
+		rawKey := c.GetHeader(APIKeyHeader)
+		if user, pass, ok := c.Request.BasicAuth(); ok && rawKey == "" {
+			if cfg.AdminPassword != "" && equalSecrets(user, cfg.AdminUsername) && equalSecrets(pass, cfg.AdminPassword) {
+				c.Next()
+				return
+			}
+			rawKey = pass
+		}
+		if rawKey == "" {
+			if required {
+				abortUnauthorized(c, "credentials required")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key, err := keys.Authenticate(ctx, rawKey)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				abortUnauthorized(c, err.Error())
+				return
+			}
+			abortWithInternalError(c, err)
+			return
+		}
+		c.Set(apiKeyIDKey, key.ID)
+
+		if !key.HasAnyRole(roles...) {
+			keys.RecordUsage(key, true)
+			abortWithProblem(c, http.StatusForbidden, fmt.Sprintf("api key needs one of the roles %v", roles))
+			return
+		}
+		tenantID, ok := key.ResolveTenant(tenant.FromContext(ctx))
+		if !ok {
+			keys.RecordUsage(key, true)
+			abortWithProblem(c, http.StatusForbidden, "api key is not valid for this tenant")
+			return
+		}
+		keys.RecordUsage(key, false)
+		c.Request = c.Request.WithContext(tenant.WithTenant(ctx, tenantID))
+		c.Next()
+	}
+}
+
+func abortUnauthorized(c *gin.Context, detail string) {
+	c.Header("WWW-Authenticate", authRealm)
+	abortWithProblem(c, http.StatusUnauthorized, detail)
+}
+
+// equalSecrets compares secrets in constant time, hashing them first so their lengths do not leak either.
+func equalSecrets(given, expected string) bool {
+	givenHash, expectedHash := sha256.Sum256([]byte(given)), sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(givenHash[:], expectedHash[:]) == 1
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/service"
+	"search-logger/storage_util"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestAPIKeyService(t *testing.T) service.APIKeyService {
+	db := storage_util.InitDB()
+	assert.NoError(t, storage_util.Migrate(db))
+	return service.NewAPIKeyService(database.NewAPIKeyDatabaseRepository(db), service.APIKeyConfig{CacheTTL: time.Minute}, slog.Default())
+}
+
+// setupAuthRouter serves 200 on GET /protected to the requests the middleware lets through.
+func setupAuthRouter(keys service.APIKeyService, cfg AuthConfig, roles ...models.APIKeyRole) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TenantMiddleware("X-Tenant-ID", "", ""))
+	r.GET("/protected", AuthMiddleware(keys, cfg, roles...), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+type authRequest struct {
+	apiKey             string
+	username, password string
+}
+
+func (a authRequest) perform(r http.Handler) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	if a.apiKey != "" {
+		req.Header.Set(APIKeyHeader, a.apiKey)
+	}
+	if a.password != "" {
+		req.SetBasicAuth(a.username, a.password)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddleware_Admin(t *testing.T) {
+	keys := setupTestAPIKeyService(t)
+	adminKey, _, err := keys.Create(context.Background(), "ops", []models.APIKeyRole{models.APIKeyRoleAdmin}, "", nil)
+	assert.NoError(t, err)
+	withPassword := AuthConfig{AdminUsername: "admin", AdminPassword: "s3cret"}
+
+	tests := []struct {
+		name    string
+		cfg     AuthConfig
+		request authRequest
+		status  int
+	}{
+		{"Missing credentials are refused", withPassword, authRequest{}, http.StatusUnauthorized},
+		{"A wrong admin password is refused", withPassword, authRequest{username: "admin", password: "guess"}, http.StatusUnauthorized},
+		{"A wrong admin user name is refused", withPassword, authRequest{username: "root", password: "s3cret"}, http.StatusUnauthorized},
+		{"The admin credentials are accepted", withPassword, authRequest{username: "admin", password: "s3cret"}, http.StatusOK},
+		{"Without an admin password missing credentials are refused", AuthConfig{}, authRequest{}, http.StatusUnauthorized},
+		{"Without an admin password an empty one is refused", AuthConfig{AdminUsername: "admin"}, authRequest{username: "admin", password: " "}, http.StatusUnauthorized},
+		{"Without an admin password admin keys are accepted", AuthConfig{}, authRequest{apiKey: adminKey}, http.StatusOK},
+		{"Admin keys are accepted as the Basic password", AuthConfig{}, authRequest{username: "anyone", password: adminKey}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			r := setupAuthRouter(keys, tt.cfg, models.APIKeyRoleAdmin)
+
+			// ACT
+			w := tt.request.perform(r)
+
+			// ASSERT
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, authRealm, w.Header().Get("WWW-Authenticate"))
+				assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_Roles(t *testing.T) {
+	ctx := context.Background()
+	keys := setupTestAPIKeyService(t)
+	createKey := func(tenantID string, roles ...models.APIKeyRole) (string, *models.APIKey) {
+		rawKey, key, err := keys.Create(ctx, "test", roles, tenantID, nil)
+		assert.NoError(t, err)
+		return rawKey, key
+	}
+	ingestKey, _ := createKey("", models.APIKeyRoleIngest)
+	analyticsKey, _ := createKey("", models.APIKeyRoleReadAnalytics)
+	adminKey, _ := createKey("", models.APIKeyRoleAdmin)
+	acmeKey, _ := createKey("acme", models.APIKeyRoleIngest)
+	revokedKey, revoked := createKey("", models.APIKeyRoleReadAnalytics)
+	_, err := keys.Revoke(ctx, revoked.ID)
+	assert.NoError(t, err)
+
+	// The route groups, with the roles main gives their middleware.
+	ingest := []models.APIKeyRole{models.APIKeyRoleIngest}
+	spellcheck := []models.APIKeyRole{models.APIKeyRoleIngest, models.APIKeyRoleReadAnalytics}
+	analytics := []models.APIKeyRole{models.APIKeyRoleReadAnalytics}
+	admin := []models.APIKeyRole{models.APIKeyRoleAdmin}
+	optional := AuthConfig{IngestKeysOptional: true}
+
+	tests := []struct {
+		name    string
+		cfg     AuthConfig
+		roles   []models.APIKeyRole
+		request authRequest
+		status  int
+	}{
+		{"Ingest routes require credentials by default", AuthConfig{}, ingest, authRequest{}, http.StatusUnauthorized},
+		{"Spellcheck requires credentials by default", AuthConfig{}, spellcheck, authRequest{}, http.StatusUnauthorized},
+		{"Analytics routes require credentials by default", AuthConfig{}, analytics, authRequest{}, http.StatusUnauthorized},
+		{"Ingest routes can be opened", optional, ingest, authRequest{}, http.StatusOK},
+		{"Spellcheck can be opened", optional, spellcheck, authRequest{}, http.StatusOK},
+		{"Analytics routes stay closed when ingest routes are opened", optional, analytics, authRequest{}, http.StatusUnauthorized},
+		{"Admin routes stay closed when ingest routes are opened", optional, admin, authRequest{}, http.StatusUnauthorized},
+		{"Keys sent to opened routes are still checked", optional, ingest, authRequest{apiKey: "slk_unknown"}, http.StatusUnauthorized},
+		{"Ingest keys can ingest", AuthConfig{}, ingest, authRequest{apiKey: ingestKey}, http.StatusOK},
+		{"Ingest keys can ask for spellings", AuthConfig{}, spellcheck, authRequest{apiKey: ingestKey}, http.StatusOK},
+		{"Ingest keys cannot read analytics", AuthConfig{}, analytics, authRequest{apiKey: ingestKey}, http.StatusForbidden},
+		{"Ingest keys cannot administer", AuthConfig{}, admin, authRequest{apiKey: ingestKey}, http.StatusForbidden},
+		{"Analytics keys cannot ingest", AuthConfig{}, ingest, authRequest{apiKey: analyticsKey}, http.StatusForbidden},
+		{"Analytics keys can ask for spellings", AuthConfig{}, spellcheck, authRequest{apiKey: analyticsKey}, http.StatusOK},
+		{"Analytics keys can read analytics", AuthConfig{}, analytics, authRequest{apiKey: analyticsKey}, http.StatusOK},
+		{"Analytics keys cannot administer", AuthConfig{}, admin, authRequest{apiKey: analyticsKey}, http.StatusForbidden},
+		{"Admin keys can ingest", AuthConfig{}, ingest, authRequest{apiKey: adminKey}, http.StatusOK},
+		{"Admin keys can read analytics", AuthConfig{}, analytics, authRequest{apiKey: adminKey}, http.StatusOK},
+		{"Keys can be sent as the Basic password", AuthConfig{}, analytics, authRequest{username: "anyone", password: analyticsKey}, http.StatusOK},
+		{"Roles are checked for keys sent as the Basic password", AuthConfig{}, analytics, authRequest{username: "anyone", password: ingestKey}, http.StatusForbidden},
+		{"Unknown keys are refused", AuthConfig{}, analytics, authRequest{apiKey: "slk_unknown"}, http.StatusUnauthorized},
+		{"Revoked keys are refused", AuthConfig{}, analytics, authRequest{apiKey: revokedKey}, http.StatusUnauthorized},
+		{"Revoked keys are refused on opened routes", optional, spellcheck, authRequest{apiKey: revokedKey}, http.StatusUnauthorized},
+		{"The admin credentials can read analytics", AuthConfig{AdminUsername: "admin", AdminPassword: "s3cret"}, analytics, authRequest{username: "admin", password: "s3cret"}, http.StatusOK},
+		{"Keys pinned to a tenant act for it", AuthConfig{}, ingest, authRequest{apiKey: acmeKey}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			r := setupAuthRouter(keys, tt.cfg, tt.roles...)
+
+			// ACT
+			w := tt.request.perform(r)
+
+			// ASSERT
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+
+	t.Run("Keys pinned to a tenant are refused for other tenants", func(t *testing.T) {
+		// ARRANGE
+		r := setupAuthRouter(keys, AuthConfig{}, ingest...)
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set(APIKeyHeader, acmeKey)
+		req.Header.Set("X-Tenant-ID", "globex")
+		w := httptest.NewRecorder()
+
+		// ACT
+		r.ServeHTTP(w, req)
+
+		// ASSERT
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"search-logger/models"
+	"search-logger/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateBlocklistRuleRequest struct {
+	Type    models.BlocklistRuleType `json:"type"`
+	Pattern string                   `json:"pattern"`
+}
+
+type BlocklistMetricsResponse struct {
+	From    time.Time                   `json:"from"`
+	To      time.Time                   `json:"to"`
+	Blocked int                         `json:"blocked"`
+	Rules   []models.BlocklistRuleStats `json:"rules"`
+}
+
+func RegisterBlocklistRoutes(r *gin.Engine, srv service.BlocklistService, middleware ...gin.HandlerFunc) {
+	blocklist := r.Group("/admin/blocklist", middleware...)
+
+	blocklist.GET("/rules", func(c *gin.Context) {
+		rules, err := srv.ListRules(c.Request.Context())
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	})
+
+	blocklist.POST("/rules", func(c *gin.Context) {
+		var req CreateBlocklistRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule, err := srv.AddRule(c.Request.Context(), req.Type, req.Pattern)
+		if errors.Is(err, service.ErrInvalidBlocklistRule) {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, rule)
+	})
+
+	blocklist.DELETE("/rules/:id", func(c *gin.Context) {
+		deleted, err := srv.DeleteRule(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		if !deleted {
+			abortWithProblem(c, http.StatusNotFound, "blocklist rule not found")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	blocklist.POST("/purge", func(c *gin.Context) {
+		result, err := srv.Purge(c.Request.Context())
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	blocklist.GET("/metrics", func(c *gin.Context) {
+		from, to, err := parseTimeWindow(c, defaultAnalyticsWindow)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		stats, err := srv.Stats(c.Request.Context(), from, to)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+
+		resp := BlocklistMetricsResponse{From: from, To: to, Rules: stats}
+		for _, rule := range stats {
+			resp.Blocked += rule.Blocked
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"search-logger/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClickRequest struct {
+	QueryText string `json:"query_text"`
+	ResultID  string `json:"result_id"`
+	// Position is the 1-based rank of the clicked result.
+	Position int `json:"position"`
+}
+
+// RegisterClickRoutes registers the click tracking routes. The given middleware only applies to these routes.
+func RegisterClickRoutes(r *gin.Engine, clickSrv service.ClickService, middleware ...gin.HandlerFunc) {
+	clicks := r.Group("", middleware...)
+
+	clicks.POST("/search/click", func(c *gin.Context) {
+		var req ClickRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		attributedQueryText, err := clickSrv.RecordClick(c.Request.Context(), resolveClientIdentifier(c), req.QueryText, req.ResultID, req.Position)
+		if errors.Is(err, service.ErrNoQueryToAttribute) {
+			abortWithProblem(c, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidClick) {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"query": attributedQueryText})
+	})
+}
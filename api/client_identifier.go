@@ -0,0 +1,20 @@
+package api
+
+import "github.com/gin-gonic/gin"
+
+// NewRouter returns an engine that only reads the client IP from the X-Forwarded-For and X-Real-IP headers of requests
+// sent by trustedProxies, IP addresses or CIDR ranges, and uses the address of the connection otherwise. Clients could
+// otherwise pick a new identifier on every request, and escape the rate limit and the bot filter.
+func NewRouter(trustedProxies []string) (*gin.Engine, error) {
+	r := gin.New()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// resolveClientIdentifier returns the key used to group a client's keystrokes and requests.
+// TODO: create clientIdentifier from userID + IP address. userID would likely come from a decoded JWT token. If userID is not present, we can rely on IP address.
+func resolveClientIdentifier(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
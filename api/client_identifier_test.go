@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"search-logger/service"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingRateLimiter lets every request through and records the client identifiers it was asked about.
+type recordingRateLimiter struct {
+	service.RateLimitService
+	clientIdentifiers []string
+}
+
+func (l *recordingRateLimiter) Allow(_ context.Context, clientIdentifier string) (bool, time.Duration) {
+	l.clientIdentifiers = append(l.clientIdentifiers, clientIdentifier)
+	return true, 0
+}
+
+func TestNewRouter_ClientIdentifier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+		expected       string
+	}{
+		{"Spoofed headers are ignored by default", nil, "203.0.113.7:41000", []string{"198.51.100.1", "198.51.100.2"}, "ip:203.0.113.7"},
+		{"Headers from untrusted proxies are ignored", []string{"10.0.0.0/8"}, "203.0.113.7:41000", []string{"198.51.100.1", "198.51.100.2"}, "ip:203.0.113.7"},
+		{"Headers from trusted proxies are used", []string{"10.0.0.0/8"}, "10.0.0.5:41000", []string{"198.51.100.1", "198.51.100.1"}, "ip:198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			r, err := NewRouter(tt.trustedProxies)
+			assert.NoError(t, err)
+			limiter := &recordingRateLimiter{}
+			r.GET("/limited", RateLimitMiddleware(limiter), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			// ACT
+			for _, forwardedFor := range tt.forwardedFor {
+				req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				r.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			// ASSERT
+			assert.Equal(t, []string{tt.expected, tt.expected}, limiter.clientIdentifiers)
+		})
+	}
+
+	t.Run("Invalid proxies are rejected", func(t *testing.T) {
+		_, err := NewRouter([]string{"not-an-address"})
+		assert.Error(t, err)
+	})
+}
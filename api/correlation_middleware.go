@@ -0,0 +1,54 @@
+package api
+
+import (
+	"log/slog"
+	"search-logger/correlation"
+	"search-logger/tenant"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CorrelationMiddleware puts on the request context the X-Request-ID and W3C traceparent the client sent, or new ones
+// when they are missing or invalid, and echoes the request ID in the response.
+func CorrelationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := correlation.FromIncoming(c.Request.Context(), c.GetHeader(correlation.RequestIDHeader), c.GetHeader(correlation.TraceParentHeader))
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(correlation.RequestIDHeader, correlation.RequestIDFromContext(ctx))
+		c.Next()
+	}
+}
+
+// AccessLogMiddleware logs one structured record per request once it has been handled. It must run after
+// CorrelationMiddleware for the record to carry the request ID.
+func AccessLogMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+			slog.String("tenant", tenant.FromContext(c.Request.Context())),
+		}
+		if keyID := c.GetString(apiKeyIDKey); keyID != "" {
+			attrs = append(attrs, slog.String("api_key_id", keyID))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "HTTP request", attrs...)
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// RegisterDashboardRoutes serves the analytics dashboard at /dashboard. The page only calls the service's own JSON
+// APIs, sending the tenant picked in it in tenantHeader. The given middleware only applies to the page.
+func RegisterDashboardRoutes(r *gin.Engine, tenantHeader string, middleware ...gin.HandlerFunc) error {
+	var page bytes.Buffer
+	if err := dashboardTemplate.Execute(&page, struct{ TenantHeader string }{tenantHeader}); err != nil {
+		return err
+	}
+
+	r.GET("/dashboard", append(middleware, func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-Frame-Options", "DENY")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+	})...)
+	return nil
+}
@@ -1,36 +1,61 @@
 package api
 
 import (
-	"log/slog"
+	"errors"
 	"net/http"
-	"search-logger/repository/cache"
-	"search-logger/repository/database"
 	"search-logger/service"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type SearchRequest struct {
 	QueryText string `json:"query_text"`
+	// ResultCount is optional. Callers that already know how many results the search returned can report it inline.
+	ResultCount *int `json:"result_count"`
+	// ClientSequence and ClientTimestamp are optional, and order the client's keystrokes as it typed them.
+	ClientSequence  *int64     `json:"client_sequence"`
+	ClientTimestamp *time.Time `json:"client_timestamp"`
 }
 
-func RegisterRoutes(r *gin.Engine, dbRepo database.SearchLogRepository, cacheRepo cache.LatestClientQueryCacheRepository) {
-	logger := slog.Default()
-	srv := service.NewSearchLogService(dbRepo, cacheRepo, logger)
+type SearchResultCountRequest struct {
+	QueryText   string `json:"query_text"`
+	ResultCount *int   `json:"result_count"`
+}
+
+// RegisterRoutes registers the search routes described in openapi.json. Keystrokes sent to /search are handed to
+// ingestion. The given middleware only applies to these routes.
+func RegisterRoutes(r *gin.Engine, srv service.SearchLogService, ingestion service.IngestionService, middleware ...gin.HandlerFunc) {
+	search := r.Group("", middleware...)
 
 	// I did not write tests for this endpoint.  I just have it here to show where I would call LogSearch()
-	r.POST("/search", func(c *gin.Context) {
+	search.POST("/search", func(c *gin.Context) {
 		var searchLog SearchRequest
 		if err := c.ShouldBindJSON(&searchLog); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// TODO: create clientIdentifier from userID + IP address. userID would likely come from a decoded JWT token. If userID is not present, we can rely on IP address.
-		clientIdentifier := ""
-		go func() {
-			_ = srv.LogSearch(c.Request.Context(), clientIdentifier, searchLog.QueryText)
-		}()
+		clientIdentifier := resolveClientIdentifier(c)
+		ctx := service.WithClientInfo(c.Request.Context(), service.ClientInfo{
+			UserAgent: c.Request.UserAgent(),
+			IP:        c.ClientIP(),
+		})
+		ctx = service.WithKeystrokeOrder(ctx, service.KeystrokeOrder{
+			Sequence:        searchLog.ClientSequence,
+			ClientTimestamp: searchLog.ClientTimestamp,
+		})
+		err := ingestion.Ingest(ctx, clientIdentifier, searchLog.QueryText, searchLog.ResultCount)
+		if errors.Is(err, service.ErrIngestionQueueFull) || errors.Is(err, service.ErrIngestionStopped) {
+			c.Header("Retry-After", "1")
+			_ = c.Error(err)
+			abortWithProblem(c, http.StatusServiceUnavailable, "search ingestion is busy, retry later")
+			return
+		}
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
 
 		searchResult := map[string]interface{}{
 			"foo": "bar",
@@ -38,4 +63,28 @@ func RegisterRoutes(r *gin.Engine, dbRepo database.SearchLogRepository, cacheRep
 		c.JSON(http.StatusOK, searchResult)
 	})
 
+	// Reports the result count of a search already sent to /search, for callers that only know it afterwards.
+	search.POST("/search/results", func(c *gin.Context) {
+		var req SearchResultCountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.ResultCount == nil {
+			abortWithProblem(c, http.StatusBadRequest, "result_count is required", InvalidParam{Name: "body.result_count", Reason: "is required"})
+			return
+		}
+
+		err := srv.ReportResultCount(c.Request.Context(), resolveClientIdentifier(c), req.QueryText, *req.ResultCount)
+		if errors.Is(err, service.ErrInvalidSearch) {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	})
+
 }
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"search-logger/service"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingSearchLogService struct {
+	service.SearchLogService
+	err error
+}
+
+func (s failingSearchLogService) ReportResultCount(context.Context, string, string, int) error {
+	return s.err
+}
+
+func TestRegisterRoutes_ReportResultCountErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedDetail string
+	}{
+		{"Invalid searches are bad requests", fmt.Errorf("%w: query text cannot be empty", service.ErrInvalidSearch), http.StatusBadRequest, "invalid search: query text cannot be empty"},
+		{"Other errors are internal errors that are not exposed", errors.New("redis: connection refused"), http.StatusInternalServerError, "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			r := gin.New()
+			RegisterRoutes(r, failingSearchLogService{err: tt.err}, nil)
+			req := httptest.NewRequest(http.MethodPost, "/search/results", strings.NewReader(`{"query_text": "laptop", "result_count": 0}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// ACT
+			r.ServeHTTP(w, req)
+
+			// ASSERT
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.expectedDetail, problem.Detail)
+		})
+	}
+}
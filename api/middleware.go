@@ -0,0 +1,24 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"search-logger/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware rejects requests with 429 once the resolved client has used up its token bucket.
+func RateLimitMiddleware(limiter service.RateLimitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter := limiter.Allow(c.Request.Context(), resolveClientIdentifier(c))
+		if !allowed {
+			// Retry-After only supports whole seconds, so round up to avoid clients retrying too early.
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			abortWithProblem(c, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
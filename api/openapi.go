@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// LoadOpenAPI parses and validates the embedded OpenAPI document, naming the tenant header as configured.
+func LoadOpenAPI(tenantHeader string) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		return nil, err
+	}
+	if param, ok := doc.Components.Parameters["TenantHeader"]; ok && param.Value != nil {
+		param.Value.Name = tenantHeader
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+func RegisterOpenAPIRoutes(r *gin.Engine, doc *openapi3.T) {
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+}
+
+// OpenAPIValidationMiddleware rejects requests that do not match their operation in doc with a problem+json body.
+// Bodies larger than maxBodyBytes or that are not valid UTF-8 are rejected before being decoded. Routes without an
+// operation in doc are not validated.
+func OpenAPIValidationMiddleware(doc *openapi3.T, maxBodyBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := openAPIPath(c.FullPath())
+		pathItem := doc.Paths.Find(path)
+		if pathItem == nil || pathItem.GetOperation(c.Request.Method) == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					abortWithProblem(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxBodyBytes))
+					return
+				}
+				abortWithProblem(c, http.StatusBadRequest, "request body could not be read")
+				return
+			}
+			if !utf8.Valid(body) {
+				abortWithProblem(c, http.StatusBadRequest, "request body is not valid UTF-8")
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			defer func() { c.Request.Body = io.NopCloser(bytes.NewReader(body)) }()
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request: c.Request,
+			Route: &routers.Route{
+				Spec:      doc,
+				Path:      path,
+				PathItem:  pathItem,
+				Method:    c.Request.Method,
+				Operation: pathItem.GetOperation(c.Request.Method),
+			},
+			Options: &openapi3filter.Options{MultiError: true, AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			if isUnsupportedContentType(err) {
+				abortWithProblem(c, http.StatusUnsupportedMediaType, "request body must be application/json")
+				return
+			}
+			abortWithProblem(c, http.StatusBadRequest, "request does not match the API specification", invalidParams(err)...)
+			return
+		}
+		c.Next()
+	}
+}
+
+func isUnsupportedContentType(err error) bool {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) || requestErr.RequestBody == nil {
+		return false
+	}
+	return strings.HasPrefix(requestErr.Reason, "header Content-Type has unexpected value") ||
+		strings.HasPrefix(requestErr.Reason, "unsupported content type")
+}
+
+// openAPIPath turns a gin route such as /webhooks/:id into its OpenAPI form, /webhooks/{id}.
+func openAPIPath(fullPath string) string {
+	segments := strings.Split(fullPath, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// invalidParams flattens validation errors into one entry per offending field.
+func invalidParams(err error) []InvalidParam {
+	// RequestError unwraps to its cause, so it is matched before looking for the errors it wraps.
+	if requestErr, ok := err.(*openapi3filter.RequestError); ok {
+		name := "body"
+		if requestErr.Parameter != nil {
+			name = requestErr.Parameter.In + "." + requestErr.Parameter.Name
+		}
+		var multiErr openapi3.MultiError
+		switch {
+		case requestErr.Err == nil:
+			return []InvalidParam{{Name: name, Reason: requestErr.Reason}}
+		case errors.As(requestErr.Err, &multiErr):
+			params := make([]InvalidParam, 0, len(multiErr))
+			for _, e := range multiErr {
+				params = append(params, schemaInvalidParam(name, e))
+			}
+			return params
+		default:
+			return []InvalidParam{schemaInvalidParam(name, requestErr.Err)}
+		}
+	}
+
+	var multiErr openapi3.MultiError
+	if errors.As(err, &multiErr) {
+		var params []InvalidParam
+		for _, e := range multiErr {
+			params = append(params, invalidParams(e)...)
+		}
+		return params
+	}
+	return []InvalidParam{{Name: "request", Reason: err.Error()}}
+}
+
+func schemaInvalidParam(name string, err error) InvalidParam {
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return InvalidParam{Name: name, Reason: err.Error()}
+	}
+	if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+		name += "." + strings.Join(pointer, ".")
+	}
+	reason := schemaErr.Reason
+	if schemaErr.SchemaField == "pattern" && schemaErr.Schema.Description != "" {
+		// The pattern itself is meaningless to callers, so explain it with the schema description instead.
+		description := strings.TrimSuffix(schemaErr.Schema.Description, ".")
+		reason = "must be " + strings.ToLower(description[:1]) + description[1:]
+	}
+	return InvalidParam{Name: name, Reason: reason}
+}
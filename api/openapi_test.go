@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	// ARRANGE
+	doc, err := LoadOpenAPI("X-Tenant-ID")
+	assert.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterOpenAPIRoutes(r, doc)
+	RegisterRoutes(r, nil, nil)
+	RegisterClickRoutes(r, nil)
+	RegisterWebhookRoutes(r, nil, nil)
+	RegisterQuarantineRoutes(r, nil)
+	RegisterAnalyticsRoutes(r, nil, nil, nil, nil, nil)
+	RegisterSpellcheckRoutes(r, nil)
+	RegisterSearchLogRoutes(r, nil)
+	RegisterSynonymRoutes(r, nil)
+	RegisterBlocklistRoutes(r, nil)
+	assert.NoError(t, RegisterDashboardRoutes(r, "X-Tenant-ID"))
+
+	// ACT
+	routes := r.Routes()
+
+	// ASSERT
+	for _, route := range routes {
+		pathItem := doc.Paths.Find(openAPIPath(route.Path))
+		if assert.NotNil(t, pathItem, route.Path) {
+			assert.NotNil(t, pathItem.GetOperation(route.Method), route.Method+" "+route.Path)
+		}
+	}
+}
+
+func TestOpenAPIValidationMiddleware(t *testing.T) {
+	doc, err := LoadOpenAPI("X-Tenant-ID")
+	assert.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(OpenAPIValidationMiddleware(doc, 4096))
+	accept := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/search", accept)
+	r.POST("/search/click", accept)
+
+	tests := []struct {
+		name         string
+		path         string
+		contentType  string
+		body         string
+		status       int
+		invalidParam string
+	}{
+		{"Valid searches are let through", "/search", "application/json", `{"query_text": "laptop", "result_count": 3}`, http.StatusOK, ""},
+		{"Missing queries are rejected", "/search", "application/json", `{"result_count": 3}`, http.StatusBadRequest, "body.query_text"},
+		{"Empty queries are rejected", "/search", "application/json", `{"query_text": ""}`, http.StatusBadRequest, "body.query_text"},
+		{"Blank queries are rejected", "/search", "application/json", `{"query_text": "   "}`, http.StatusBadRequest, "body.query_text"},
+		{"Queries with control characters are rejected", "/search", "application/json", `{"query_text": "lap\u0000top"}`, http.StatusBadRequest, "body.query_text"},
+		{"Queries up to 512 characters are let through", "/search", "application/json", `{"query_text": "` + strings.Repeat("é", 512) + `"}`, http.StatusOK, ""},
+		{"Oversized queries are rejected", "/search", "application/json", `{"query_text": "` + strings.Repeat("a", 513) + `"}`, http.StatusBadRequest, "body.query_text"},
+		{"Negative result counts are rejected", "/search", "application/json", `{"query_text": "laptop", "result_count": -1}`, http.StatusBadRequest, "body.result_count"},
+		{"Bodies that are not UTF-8 are rejected", "/search", "application/json", "{\"query_text\": \"lap\xfftop\"}", http.StatusBadRequest, ""},
+		{"Bodies over the size limit are rejected", "/search", "application/json", `{"query_text": "` + strings.Repeat("a", 4096) + `"}`, http.StatusRequestEntityTooLarge, ""},
+		{"Bodies that are not JSON are rejected", "/search", "text/plain", `laptop`, http.StatusUnsupportedMediaType, ""},
+		{"Valid clicks are let through", "/search/click", "application/json", `{"query_text": "laptop", "result_id": "sku-1", "position": 1}`, http.StatusOK, ""},
+		{"Clicks without a query are let through", "/search/click", "application/json", `{"result_id": "sku-1", "position": 2}`, http.StatusOK, ""},
+		{"Clicks without a result are rejected", "/search/click", "application/json", `{"query_text": "laptop", "position": 1}`, http.StatusBadRequest, "body.result_id"},
+		{"Clicks at position 0 are rejected", "/search/click", "application/json", `{"result_id": "sku-1", "position": 0}`, http.StatusBadRequest, "body.position"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			// ACT
+			r.ServeHTTP(w, req)
+
+			// ASSERT
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusOK {
+				return
+			}
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.path, problem.Instance)
+			if tt.invalidParam != "" {
+				var names []string
+				for _, param := range problem.InvalidParams {
+					names = append(names, param.Name)
+				}
+				assert.Contains(t, names, tt.invalidParam)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Type is always "about:blank", so Title is the status text.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// InvalidParam names a request field that failed validation, e.g. "body.query_text", and why.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func abortWithProblem(c *gin.Context, status int, detail string, invalidParams ...InvalidParam) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, Problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        detail,
+		Instance:      c.Request.URL.Path,
+		InvalidParams: invalidParams,
+	})
+}
+
+// abortWithInternalError answers with a generic problem, so that internal errors are not exposed to clients. The error
+// is attached to the request instead, for the access log to record it along with the request ID.
+func abortWithInternalError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	abortWithProblem(c, http.StatusInternalServerError, "internal server error")
+}
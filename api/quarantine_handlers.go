@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"search-logger/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReleaseClientRequest struct {
+	ClientIdentifier string `json:"client_identifier"`
+}
+
+func RegisterQuarantineRoutes(r *gin.Engine, quarantineSrv service.QuarantineService, middleware ...gin.HandlerFunc) {
+	quarantine := r.Group("/admin/quarantine", middleware...)
+
+	quarantine.GET("", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit <= 0 {
+			abortWithProblem(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		searches, err := quarantineSrv.ListPending(c.Request.Context(), c.Query("client_identifier"), limit)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, searches)
+	})
+
+	quarantine.POST("/:id/release", func(c *gin.Context) {
+		released, err := quarantineSrv.Release(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		if !released {
+			abortWithProblem(c, http.StatusNotFound, "quarantined search not found or already released")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	quarantine.POST("/release", func(c *gin.Context) {
+		var req ReleaseClientRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.ClientIdentifier == "" {
+			abortWithProblem(c, http.StatusBadRequest, "client_identifier is required")
+			return
+		}
+		released, err := quarantineSrv.ReleaseClient(c.Request.Context(), req.ClientIdentifier)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"released": released})
+	})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterSearchLogRoutes(r *gin.Engine, dbRepo database.SearchLogRepository, middleware ...gin.HandlerFunc) {
+	searchLogs := r.Group("", middleware...)
+
+	// Browses search logs page by page. next_cursor and prev_cursor are passed back as cursor, with the same filters and sort.
+	searchLogs.GET("/search-logs", func(c *gin.Context) {
+		filter := models.SearchLogFilter{
+			Contains: c.Query("contains"),
+			Sort:     models.SearchLogSort(c.DefaultQuery("sort", string(models.SearchLogSortCount))),
+			Cursor:   c.Query("cursor"),
+		}
+		if !filter.Sort.IsValid() {
+			abortWithProblem(c, http.StatusBadRequest, "sort must be one of count, updated_at or query")
+			return
+		}
+		if raw := c.Query("min_count"); raw != "" {
+			minCount, err := strconv.Atoi(raw)
+			if err != nil || minCount < 0 {
+				abortWithProblem(c, http.StatusBadRequest, "min_count must be a non-negative integer")
+				return
+			}
+			filter.MinCount = minCount
+		}
+		if raw := c.Query("updated_after"); raw != "" {
+			updatedAfter, err := parseTime(raw)
+			if err != nil {
+				abortWithProblem(c, http.StatusBadRequest, "updated_after must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+				return
+			}
+			filter.UpdatedAfter = updatedAfter
+		}
+		limit, err := parseLimit(c, 50)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter.Limit = limit
+
+		page, err := dbRepo.ListSearchLogs(c.Request.Context(), filter)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	})
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchLogRoutes_InvalidParameters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterSearchLogRoutes(r, nil)
+
+	for _, query := range []string{"sort=random", "min_count=-1", "updated_after=yesterday", "limit=0"} {
+		t.Run(query, func(t *testing.T) {
+			// ARRANGE
+			req := httptest.NewRequest(http.MethodGet, "/search-logs?"+query, nil)
+			w := httptest.NewRecorder()
+
+			// ACT
+			r.ServeHTTP(w, req)
+
+			// ASSERT
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.NotEmpty(t, problem.Detail)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"search-logger/models"
+	"search-logger/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SpellcheckResponse struct {
+	Query       string                      `json:"query"`
+	Suggestions []models.SpellingSuggestion `json:"suggestions"`
+}
+
+func RegisterSpellcheckRoutes(r *gin.Engine, spellcheckSrv service.SpellcheckService, middleware ...gin.HandlerFunc) {
+	spellcheck := r.Group("", middleware...)
+
+	spellcheck.GET("/spellcheck", func(c *gin.Context) {
+		queryText := strings.ToLower(strings.TrimSpace(c.Query("q")))
+		if queryText == "" {
+			abortWithProblem(c, http.StatusBadRequest, "q is required")
+			return
+		}
+		limit, err := parseLimit(c, 5)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		suggestions, err := spellcheckSrv.Suggest(c.Request.Context(), queryText, limit)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, SpellcheckResponse{Query: queryText, Suggestions: suggestions})
+	})
+}
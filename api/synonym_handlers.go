@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpsertSynonymRequest struct {
+	Canonical string `json:"canonical"`
+}
+
+type MergeSynonymsRequest struct {
+	Canonical string `json:"canonical"`
+}
+
+type ImportSynonymsResponse struct {
+	Imported int                         `json:"imported"`
+	Merged   []models.SynonymMergeResult `json:"merged,omitempty"`
+}
+
+func RegisterSynonymRoutes(r *gin.Engine, repo database.SynonymRepository, middleware ...gin.HandlerFunc) {
+	synonyms := r.Group("/admin/synonyms", middleware...)
+
+	synonyms.GET("", func(c *gin.Context) {
+		list, err := repo.List(c.Request.Context(), c.Query("canonical"))
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	})
+
+	synonyms.GET("/:variant", func(c *gin.Context) {
+		synonym, err := repo.Get(c.Request.Context(), c.Param("variant"))
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		if synonym == nil {
+			abortWithProblem(c, http.StatusNotFound, "synonym not found")
+			return
+		}
+		c.JSON(http.StatusOK, synonym)
+	})
+
+	synonyms.PUT("/:variant", func(c *gin.Context) {
+		var req UpsertSynonymRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Canonical == "" {
+			abortWithProblem(c, http.StatusBadRequest, "canonical is required")
+			return
+		}
+		synonym, err := repo.Upsert(c.Request.Context(), c.Param("variant"), req.Canonical)
+		if err != nil {
+			abortWithSynonymError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, synonym)
+	})
+
+	synonyms.DELETE("/:variant", func(c *gin.Context) {
+		deleted, err := repo.Delete(c.Request.Context(), c.Param("variant"))
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		if !deleted {
+			abortWithProblem(c, http.StatusNotFound, "synonym not found")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// The import takes a synonyms file either as the "file" field of a multipart form or as the raw request body.
+	// With merge=true, the existing counts of every imported variant are merged into its canonical query.
+	synonyms.POST("/import", func(c *gin.Context) {
+		merge, err := strconv.ParseBool(c.DefaultQuery("merge", "false"))
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, "merge must be a boolean")
+			return
+		}
+
+		var body io.Reader = c.Request.Body
+		if c.ContentType() == gin.MIMEMultipartPOSTForm {
+			fileHeader, err := c.FormFile("file")
+			if err != nil {
+				abortWithProblem(c, http.StatusBadRequest, "file is required")
+				return
+			}
+			file, err := fileHeader.Open()
+			if err != nil {
+				abortWithProblem(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+		parsed, err := service.ParseSynonyms(body)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		imported, err := repo.Import(c.Request.Context(), parsed)
+		if err != nil {
+			abortWithSynonymError(c, err)
+			return
+		}
+		resp := ImportSynonymsResponse{Imported: imported}
+		if merge {
+			merged := make(map[string]bool)
+			for _, synonym := range parsed {
+				if merged[synonym.Canonical] {
+					continue
+				}
+				merged[synonym.Canonical] = true
+				result, err := repo.Merge(c.Request.Context(), synonym.Canonical)
+				if err != nil {
+					abortWithInternalError(c, err)
+					return
+				}
+				resp.Merged = append(resp.Merged, *result)
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	synonyms.POST("/merge", func(c *gin.Context) {
+		var req MergeSynonymsRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Canonical == "" {
+			abortWithProblem(c, http.StatusBadRequest, "canonical is required")
+			return
+		}
+		result, err := repo.Merge(c.Request.Context(), req.Canonical)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+}
+
+func abortWithSynonymError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrInvalidSynonym):
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, database.ErrSynonymChain):
+		abortWithProblem(c, http.StatusConflict, err.Error())
+	default:
+		abortWithInternalError(c, err)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"search-logger/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantMiddleware resolves the tenant of every request with tenant.Resolve and puts it on the request context.
+func TenantMiddleware(header, jwtSecret, jwtClaim string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, err := tenant.Resolve(c.GetHeader(header), c.GetHeader("Authorization"), jwtSecret, jwtClaim)
+		if err != nil {
+			abortWithProblem(c, tenantErrorStatus(err), err.Error())
+			return
+		}
+
+		c.Request = c.Request.WithContext(tenant.WithTenant(c.Request.Context(), tenantID))
+		c.Next()
+	}
+}
+
+func tenantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, tenant.ErrInvalidToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, tenant.ErrTenantMismatch):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"search-logger/tenant"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TenantMiddleware("X-Tenant-ID", "", ""))
+	r.GET("/tenant", func(c *gin.Context) {
+		c.String(http.StatusOK, tenant.FromContext(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		body   string
+	}{
+		{"Requests without a tenant get the default one", "", http.StatusOK, tenant.DefaultTenantID},
+		{"The tenant header selects the tenant", "acme", http.StatusOK, "acme"},
+		{"Invalid tenants are rejected with a problem", "Not A Tenant", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			// ACT
+			r.ServeHTTP(w, req)
+
+			// ASSERT
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.body, w.Body.String())
+			} else {
+				assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"search-logger/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TracingMiddleware traces every request with a server span, which is the span CorrelationMiddleware put on the
+// request context, so it must run after it.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched route"
+		}
+		ctx, span := tracing.Start(c.Request.Context(), "HTTP "+c.Request.Method+" "+route,
+			tracing.AsRequestSpan(),
+			tracing.WithKind(tracing.SpanKindServer),
+			tracing.WithAttributes(
+				tracing.String("http.request.method", c.Request.Method),
+				tracing.String("http.route", c.FullPath()),
+				tracing.String("url.path", c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			if last := c.Errors.Last(); last != nil {
+				span.RecordError(last)
+			} else {
+				span.RecordError(errors.New(http.StatusText(status)))
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateWebhookEndpointRequest struct {
+	URL string `json:"url"`
+	// Secret is optional; one is generated when omitted. It is only ever returned in the create response.
+	Secret string `json:"secret"`
+}
+
+type CreateWebhookEndpointResponse struct {
+	models.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+type CreateWebhookRuleRequest struct {
+	Type        models.WebhookRuleType `json:"type"`
+	Threshold   int                    `json:"threshold"`
+	SpikeFactor float64                `json:"spike_factor"`
+}
+
+// RegisterWebhookRoutes manages the endpoints and rules of repo, and has webhookSrv reload them after every change.
+func RegisterWebhookRoutes(r *gin.Engine, repo database.WebhookRepository, webhookSrv service.WebhookService, middleware ...gin.HandlerFunc) {
+	webhooks := r.Group("/webhooks", middleware...)
+
+	webhooks.POST("", func(c *gin.Context) {
+		var req CreateWebhookEndpointRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			abortWithProblem(c, http.StatusBadRequest, "url must be an absolute http or https URL")
+			return
+		}
+
+		secret := req.Secret
+		if secret == "" {
+			generated, err := generateWebhookSecret()
+			if err != nil {
+				abortWithInternalError(c, err)
+				return
+			}
+			secret = generated
+		}
+
+		endpoint := models.NewWebhookEndpoint(req.URL, secret)
+		if err := repo.CreateEndpoint(c.Request.Context(), endpoint); err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, CreateWebhookEndpointResponse{WebhookEndpoint: *endpoint, Secret: secret})
+	})
+
+	webhooks.GET("", func(c *gin.Context) {
+		endpoints, err := repo.ListEndpoints(c.Request.Context())
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, endpoints)
+	})
+
+	webhooks.DELETE("/:id", func(c *gin.Context) {
+		if err := repo.DeleteEndpoint(c.Request.Context(), c.Param("id")); err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		webhookSrv.InvalidateRules(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	webhooks.POST("/:id/rules", func(c *gin.Context) {
+		endpoint, ok := findWebhookEndpoint(c, repo)
+		if !ok {
+			return
+		}
+
+		var req CreateWebhookRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		switch {
+		case !req.Type.IsValid():
+			abortWithProblem(c, http.StatusBadRequest, "type must be one of first_seen, count_threshold, rate_spike")
+			return
+		case req.Type == models.WebhookRuleCountThreshold && req.Threshold <= 0:
+			abortWithProblem(c, http.StatusBadRequest, "threshold must be positive for count_threshold rules")
+			return
+		case req.Type == models.WebhookRuleRateSpike && req.SpikeFactor <= 1:
+			abortWithProblem(c, http.StatusBadRequest, "spike_factor must be greater than 1 for rate_spike rules")
+			return
+		}
+
+		rule := models.NewWebhookRule(endpoint.ID, req.Type, req.Threshold, req.SpikeFactor)
+		if err := repo.CreateRule(c.Request.Context(), rule); err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		webhookSrv.InvalidateRules(c.Request.Context())
+		c.JSON(http.StatusCreated, rule)
+	})
+
+	webhooks.GET("/:id/rules", func(c *gin.Context) {
+		rules, err := repo.ListRules(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	})
+
+	webhooks.DELETE("/:id/rules/:ruleId", func(c *gin.Context) {
+		if err := repo.DeleteRule(c.Request.Context(), c.Param("ruleId")); err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		webhookSrv.InvalidateRules(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	webhooks.GET("/:id/deliveries", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 {
+			abortWithProblem(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		deliveries, err := repo.ListDeliveries(c.Request.Context(), c.Param("id"), limit)
+		if err != nil {
+			abortWithInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, deliveries)
+	})
+}
+
+func findWebhookEndpoint(c *gin.Context, repo database.WebhookRepository) (*models.WebhookEndpoint, bool) {
+	endpoint, err := repo.GetEndpoint(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		abortWithInternalError(c, err)
+		return nil, false
+	}
+	if endpoint == nil {
+		abortWithProblem(c, http.StatusNotFound, "webhook endpoint not found")
+		return nil, false
+	}
+	return endpoint, true
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
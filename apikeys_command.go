@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/service"
+	"search-logger/storage_util"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const apiKeysUsage = `Usage: search-logger apikeys <command> [arguments]
+
+Commands:
+  create -name NAME -roles ROLE[,ROLE] [-tenant TENANT] [-expires-in DURATION]
+  list
+  revoke ID
+  usage [-days N] ID
+
+Roles are ingest, read-analytics and admin.`
+
+// runAPIKeysCommand manages API keys in the configured database, e.g. "search-logger apikeys list", and returns the
+// exit code of the process.
+func runAPIKeysCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, apiKeysUsage)
+		return 2
+	}
+
+	if storage_util.IsInMemory() {
+		fmt.Fprintln(stderr, "The database is in memory, so keys would be lost: set SQLITE_PATH or DB_DIALECT=postgres")
+		return 1
+	}
+	db := storage_util.InitDB()
+	defer func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+	if config.IsDBAutoMigrateEnabled() {
+		if err := storage_util.Migrate(db); err != nil {
+			fmt.Fprintln(stderr, "Failed to migrate database:", err)
+			return 1
+		}
+	}
+	// Usage is not recorded from the command line, so it never needs flushing.
+	srv := service.NewAPIKeyService(database.NewAPIKeyDatabaseRepository(db), service.APIKeyConfig{}, slog.Default())
+
+	var err error
+	ctx := context.Background()
+	switch args[0] {
+	case "create":
+		err = createAPIKey(ctx, srv, args[1:], stdout)
+	case "list":
+		err = listAPIKeys(ctx, srv, stdout)
+	case "revoke":
+		err = revokeAPIKey(ctx, srv, args[1:], stdout)
+	case "usage":
+		err = printAPIKeyUsage(ctx, srv, args[1:], stdout)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", args[0], apiKeysUsage)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func createAPIKey(ctx context.Context, srv service.APIKeyService, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	name := flags.String("name", "", "what the key is for, e.g. storefront")
+	roles := flags.String("roles", "", "comma separated roles: ingest, read-analytics, admin")
+	tenantID := flags.String("tenant", "", "pins the key to a tenant")
+	expiresIn := flags.Duration("expires-in", 0, "how long the key is valid for, e.g. 720h; never expires when zero")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var keyRoles []models.APIKeyRole
+	for _, role := range strings.Split(*roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			keyRoles = append(keyRoles, models.APIKeyRole(role))
+		}
+	}
+	var expiresAt *time.Time
+	if *expiresIn > 0 {
+		at := time.Now().Add(*expiresIn).UTC()
+		expiresAt = &at
+	}
+
+	rawKey, key, err := srv.Create(ctx, *name, keyRoles, *tenantID, expiresAt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Created API key %s (%s) with roles %s.\n", key.ID, key.Name, key.Roles)
+	fmt.Fprintln(stdout, "Store it now, it cannot be shown again:")
+	fmt.Fprintln(stdout, rawKey)
+	return nil
+}
+
+func listAPIKeys(ctx context.Context, srv service.APIKeyService, stdout io.Writer) error {
+	keys, err := srv.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tROLES\tTENANT\tSTATUS\tLAST USED")
+	now := time.Now()
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, key.Roles, orDash(key.TenantID), apiKeyStatus(key, now), formatOptionalTime(key.LastUsedAt))
+	}
+	return w.Flush()
+}
+
+func apiKeyStatus(key models.APIKey, now time.Time) string {
+	switch {
+	case key.RevokedAt != nil:
+		return "revoked"
+	case !key.IsActive(now):
+		return "expired"
+	case key.ExpiresAt != nil:
+		return "expires " + key.ExpiresAt.UTC().Format(time.RFC3339)
+	default:
+		return "active"
+	}
+}
+
+func revokeAPIKey(ctx context.Context, srv service.APIKeyService, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: search-logger apikeys revoke ID")
+	}
+	revoked, err := srv.Revoke(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return fmt.Errorf("no active API key %s", args[0])
+	}
+	fmt.Fprintf(stdout, "Revoked API key %s. Replicas stop accepting it once their cache expires.\n", args[0])
+	return nil
+}
+
+func printAPIKeyUsage(ctx context.Context, srv service.APIKeyService, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("usage", flag.ContinueOnError)
+	days := flags.Int("days", 30, "how many UTC days to show, today included")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *days <= 0 {
+		return errors.New("usage: search-logger apikeys usage [-days N] ID")
+	}
+
+	key, err := srv.Get(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("no API key %s", flags.Arg(0))
+	}
+	to := time.Now().UTC()
+	usage, err := srv.Usage(ctx, key.ID, to.AddDate(0, 0, 1-*days), to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "API key %s (%s), last used %s\n", key.ID, key.Name, formatOptionalTime(key.LastUsedAt))
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DAY\tREQUESTS\tDENIED\t")
+	var requests, denied int64
+	for _, day := range usage {
+		fmt.Fprintf(w, "%s\t%d\t%d\t\n", day.Day.UTC().Format(time.DateOnly), day.Requests, day.Denied)
+		requests += day.Requests
+		denied += day.Denied
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t\n", requests, denied)
+	return w.Flush()
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
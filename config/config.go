@@ -1,39 +1,317 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
 	logSearchDebounceDelaySeconds int
 	defaultCacheTTLSeconds        int
+
+	webhookMaxAttempts         int
+	webhookInitialBackoffMs    int
+	webhookRequestTimeoutMs    int
+	webhookRateSpikeMinPerHour int
+	webhookRulesRefreshSeconds int
+
+	rateLimitEnabled         bool
+	rateLimitBurst           int
+	rateLimitRefillPerSecond float64
+
+	botFilterEnabled              bool
+	botFilterMode                 string
+	botUserAgentPatterns          []string
+	botMaxQueriesPerMinute        int
+	botMaxDistinctQueries         int
+	botDistinctQueryWindowSeconds int
+	botFlagTTLSeconds             int
+
+	tenantHeader                       string
+	tenantJWTSecret                    string
+	tenantJWTClaim                     string
+	tenantDebounceDelaySecondsOverride map[string]int
+	tenantCacheTTLSecondsOverride      map[string]int
+
+	sessionWindowSeconds int
+
+	spellcheckMaxEditDistance        int
+	spellcheckMinCount               int
+	spellcheckMaxTerms               int
+	spellcheckMaxCachedTerms         int
+	spellcheckRebuildIntervalSeconds int
+
+	grpcEnabled          bool
+	grpcPort             int
+	grpcDefaultTimeoutMs int
+
+	maxRequestBodyBytes int
+
+	blocklistProfanityEnabled       bool
+	blocklistRefreshIntervalSeconds int
+
+	ingestionMode      string
+	ingestionQueueSize int
+	ingestionWorkers   int
+
+	httpPort                int
+	trustedProxies          []string
+	httpReadTimeoutSeconds  int
+	httpWriteTimeoutSeconds int
+	httpIdleTimeoutSeconds  int
+	shutdownTimeoutSeconds  int
+
+	tracingExporter     string
+	tracingSampleRatio  float64
+	tracingOTLPEndpoint string
+	tracingOTLPHeaders  map[string]string
+	tracingServiceName  string
+
+	replicaID                         string
+	realtimeTopCapacity               int
+	realtimeTopBucketSeconds          int
+	realtimeTopRetentionMinutes       int
+	realtimeTopPublishIntervalSeconds int
+
+	uniqueClientsSnapshotIntervalSeconds int
+
+	adminUsername string
+	adminPassword string
+
+	dbAutoMigrate bool
+
+	ingestAPIKeysOptional           bool
+	apiKeyCacheTTLSeconds           int
+	apiKeyUsageFlushIntervalSeconds int
+)
+
+const (
+	// BotFilterModeLog only logs the clients it flags, and keeps counting their searches.
+	BotFilterModeLog = "log"
+	// BotFilterModeQuarantine records searches from flagged clients in quarantined_searches for review.
+	BotFilterModeQuarantine = "quarantine"
+	// BotFilterModeExclude drops searches from flagged clients.
+	BotFilterModeExclude = "exclude"
+
+	// IngestionModeDirect logs keystrokes as soon as the API accepts them.
+	IngestionModeDirect = "direct"
+	// IngestionModeChannel queues keystrokes in memory for a worker pool.
+	IngestionModeChannel = "channel"
+	// IngestionModeRedis queues keystrokes in a Redis list consumed by the worker pools of all replicas.
+	IngestionModeRedis = "redis"
+
+	// TracingExporterNone records no spans.
+	TracingExporterNone = "none"
+	// TracingExporterStdout writes spans to stdout as JSON, for development.
+	TracingExporterStdout = "stdout"
+	// TracingExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP.
+	TracingExporterOTLP = "otlp"
 )
 
+// defaultBotUserAgentPatterns only matches crawlers and headless browsers. HTTP libraries such as curl or
+// python-requests are left out since server-side integrations use them too.
+var defaultBotUserAgentPatterns = []string{
+	"bot", "crawler", "spider", "slurp", "headlesschrome", "phantomjs",
+}
+
 func init() {
-	delayStr := os.Getenv("LOG_SEARCH_DEBOUNCE_DELAY_SECONDS")
-	if delayStr == "" {
-		logSearchDebounceDelaySeconds = 3
-	} else {
-		val, err := strconv.Atoi(delayStr)
-		if err != nil {
-			log.Fatalf("Invalid LOG_SEARCH_DEBOUNCE_DELAY_SECONDS: %v", err)
+	logSearchDebounceDelaySeconds = getEnvInt("LOG_SEARCH_DEBOUNCE_DELAY_SECONDS", 3)
+	defaultCacheTTLSeconds = getEnvInt("DEFAULT_CACHE_TTL_SECONDS", 30)
+
+	webhookMaxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5)
+	webhookInitialBackoffMs = getEnvInt("WEBHOOK_INITIAL_BACKOFF_MS", 500)
+	webhookRequestTimeoutMs = getEnvInt("WEBHOOK_REQUEST_TIMEOUT_MS", 5000)
+	webhookRateSpikeMinPerHour = getEnvInt("WEBHOOK_RATE_SPIKE_MIN_PER_HOUR", 10)
+	webhookRulesRefreshSeconds = getEnvInt("WEBHOOK_RULES_REFRESH_INTERVAL_SECONDS", 30)
+
+	rateLimitEnabled = getEnvBool("RATE_LIMIT_ENABLED", true)
+	rateLimitBurst = getEnvInt("RATE_LIMIT_BURST", 20)
+	rateLimitRefillPerSecond = getEnvFloat("RATE_LIMIT_REFILL_PER_SECOND", 5)
+
+	botFilterEnabled = getEnvBool("BOT_FILTER_ENABLED", true)
+	botFilterMode = getEnvString("BOT_FILTER_MODE", BotFilterModeLog)
+	if botFilterMode != BotFilterModeLog && botFilterMode != BotFilterModeQuarantine && botFilterMode != BotFilterModeExclude {
+		log.Fatalf("Invalid BOT_FILTER_MODE: %q", botFilterMode)
+	}
+	botUserAgentPatterns = getEnvList("BOT_USER_AGENT_PATTERNS", defaultBotUserAgentPatterns)
+	botMaxQueriesPerMinute = getEnvInt("BOT_MAX_QUERIES_PER_MINUTE", 20)
+	botMaxDistinctQueries = getEnvInt("BOT_MAX_DISTINCT_QUERIES", 60)
+	botDistinctQueryWindowSeconds = getEnvInt("BOT_DISTINCT_QUERY_WINDOW_SECONDS", 600)
+	botFlagTTLSeconds = getEnvInt("BOT_FLAG_TTL_SECONDS", 3600)
+
+	tenantHeader = getEnvString("TENANT_HEADER", "X-Tenant-ID")
+	tenantJWTSecret = os.Getenv("TENANT_JWT_SECRET")
+	tenantJWTClaim = getEnvString("TENANT_JWT_CLAIM", "tenant_id")
+	tenantDebounceDelaySecondsOverride = getEnvIntMap("TENANT_DEBOUNCE_DELAY_SECONDS")
+	tenantCacheTTLSecondsOverride = getEnvIntMap("TENANT_CACHE_TTL_SECONDS")
+
+	sessionWindowSeconds = getEnvInt("SESSION_WINDOW_SECONDS", 1800)
+
+	spellcheckMaxEditDistance = getEnvInt("SPELLCHECK_MAX_EDIT_DISTANCE", 2)
+	spellcheckMinCount = getEnvInt("SPELLCHECK_MIN_COUNT", 2)
+	spellcheckMaxTerms = getEnvInt("SPELLCHECK_MAX_TERMS", 100000)
+	spellcheckMaxCachedTerms = getEnvInt("SPELLCHECK_MAX_CACHED_TERMS", 1000000)
+	if spellcheckMaxCachedTerms <= 0 {
+		log.Fatalf("Invalid SPELLCHECK_MAX_CACHED_TERMS: %d is not positive", spellcheckMaxCachedTerms)
+	}
+	spellcheckRebuildIntervalSeconds = getEnvInt("SPELLCHECK_REBUILD_INTERVAL_SECONDS", 300)
+
+	grpcEnabled = getEnvBool("GRPC_ENABLED", true)
+	grpcPort = getEnvInt("GRPC_PORT", 9090)
+	grpcDefaultTimeoutMs = getEnvInt("GRPC_DEFAULT_TIMEOUT_MS", 10000)
+
+	maxRequestBodyBytes = getEnvInt("MAX_REQUEST_BODY_BYTES", 16384)
+
+	blocklistProfanityEnabled = getEnvBool("BLOCKLIST_PROFANITY_ENABLED", true)
+	blocklistRefreshIntervalSeconds = getEnvInt("BLOCKLIST_REFRESH_INTERVAL_SECONDS", 30)
+
+	ingestionMode = getEnvString("INGESTION_MODE", IngestionModeDirect)
+	if ingestionMode != IngestionModeDirect && ingestionMode != IngestionModeChannel && ingestionMode != IngestionModeRedis {
+		log.Fatalf("Invalid INGESTION_MODE: %q", ingestionMode)
+	}
+	ingestionQueueSize = getEnvInt("INGESTION_QUEUE_SIZE", 10000)
+	ingestionWorkers = getEnvInt("INGESTION_WORKERS", 8)
+
+	httpPort = getEnvInt("HTTP_PORT", 8080)
+	trustedProxies = getEnvList("TRUSTED_PROXIES", nil)
+	httpReadTimeoutSeconds = getEnvInt("HTTP_READ_TIMEOUT_SECONDS", 10)
+	httpWriteTimeoutSeconds = getEnvInt("HTTP_WRITE_TIMEOUT_SECONDS", 30)
+	httpIdleTimeoutSeconds = getEnvInt("HTTP_IDLE_TIMEOUT_SECONDS", 120)
+	shutdownTimeoutSeconds = getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 20)
+
+	tracingExporter = getEnvString("TRACING_EXPORTER", TracingExporterNone)
+	if tracingExporter != TracingExporterNone && tracingExporter != TracingExporterStdout && tracingExporter != TracingExporterOTLP {
+		log.Fatalf("Invalid TRACING_EXPORTER: %q", tracingExporter)
+	}
+	tracingSampleRatio = getEnvFloat("TRACING_SAMPLE_RATIO", 1)
+	if tracingSampleRatio < 0 || tracingSampleRatio > 1 {
+		log.Fatalf("Invalid TRACING_SAMPLE_RATIO: %v is not between 0 and 1", tracingSampleRatio)
+	}
+	tracingOTLPEndpoint = getEnvString("TRACING_OTLP_ENDPOINT", "http://localhost:4318/v1/traces")
+	tracingOTLPHeaders = getEnvStringMap("TRACING_OTLP_HEADERS")
+	tracingServiceName = getEnvString("TRACING_SERVICE_NAME", "search-logger")
+
+	replicaID = getEnvString("REPLICA_ID", defaultReplicaID())
+	realtimeTopCapacity = getEnvInt("REALTIME_TOP_CAPACITY", 1000)
+	realtimeTopBucketSeconds = getEnvInt("REALTIME_TOP_BUCKET_SECONDS", 60)
+	realtimeTopRetentionMinutes = getEnvInt("REALTIME_TOP_RETENTION_MINUTES", 60)
+	if realtimeTopCapacity <= 0 || realtimeTopBucketSeconds <= 0 || realtimeTopRetentionMinutes*60 < realtimeTopBucketSeconds {
+		log.Fatalf("Invalid REALTIME_TOP_* settings: the capacity and bucket must be positive and the retention must hold a bucket")
+	}
+	realtimeTopPublishIntervalSeconds = getEnvInt("REALTIME_TOP_PUBLISH_INTERVAL_SECONDS", 5)
+
+	uniqueClientsSnapshotIntervalSeconds = getEnvInt("UNIQUE_CLIENTS_SNAPSHOT_INTERVAL_SECONDS", 60)
+
+	adminUsername = getEnvString("ADMIN_USERNAME", "admin")
+	adminPassword = os.Getenv("ADMIN_PASSWORD")
+
+	dbAutoMigrate = getEnvBool("DB_AUTO_MIGRATE", true)
+
+	ingestAPIKeysOptional = getEnvBool("INGEST_API_KEYS_OPTIONAL", false)
+	apiKeyCacheTTLSeconds = getEnvInt("API_KEY_CACHE_TTL_SECONDS", 30)
+	apiKeyUsageFlushIntervalSeconds = getEnvInt("API_KEY_USAGE_FLUSH_INTERVAL_SECONDS", 10)
+}
+
+// defaultReplicaID tells replicas apart by host name and process ID, which stays unique when several run on a host.
+func defaultReplicaID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "replica"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// getEnvStringMap parses a comma separated list of key=value pairs, e.g. "acme=eu,globex=us".
+func getEnvStringMap(name string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range getEnvList(name, nil) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			log.Fatalf("Invalid %s: %q is not a key=value pair", name, pair)
 		}
-		logSearchDebounceDelaySeconds = val
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
 	}
+	return values
+}
 
-	ttlStr := os.Getenv("DEFAULT_CACHE_TTL_SECONDS")
-	if ttlStr == "" {
-		defaultCacheTTLSeconds = 30
-	} else {
-		val, err := strconv.Atoi(ttlStr)
+// getEnvIntMap parses a comma separated list of key=value pairs with integer values, e.g. "acme=5,globex=2".
+func getEnvIntMap(name string) map[string]int {
+	values := make(map[string]int)
+	for key, valueStr := range getEnvStringMap(name) {
+		val, err := strconv.Atoi(valueStr)
 		if err != nil {
-			log.Fatalf("Invalid DEFAULT_CACHE_TTL_SECONDS: %v", err)
+			log.Fatalf("Invalid %s: %v", name, err)
+		}
+		values[key] = val
+	}
+	return values
+}
+
+func getEnvString(name string, defaultValue string) string {
+	str := os.Getenv(name)
+	if str == "" {
+		return defaultValue
+	}
+	return str
+}
+
+// getEnvList parses a comma separated list, ignoring blank entries.
+func getEnvList(name string, defaultValue []string) []string {
+	str := os.Getenv(name)
+	if str == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(str, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
 		}
-		defaultCacheTTLSeconds = val
 	}
+	return values
+}
+
+func getEnvInt(name string, defaultValue int) int {
+	str := os.Getenv(name)
+	if str == "" {
+		return defaultValue
+	}
+
+	val, err := strconv.Atoi(str)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return val
+}
+
+func getEnvFloat(name string, defaultValue float64) float64 {
+	str := os.Getenv(name)
+	if str == "" {
+		return defaultValue
+	}
+
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return val
+}
+
+func getEnvBool(name string, defaultValue bool) bool {
+	str := os.Getenv(name)
+	if str == "" {
+		return defaultValue
+	}
+
+	val, err := strconv.ParseBool(str)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return val
 }
 
 func GetLogSearchDebounceDelaySeconds() time.Duration {
@@ -43,3 +321,309 @@ func GetLogSearchDebounceDelaySeconds() time.Duration {
 func GetDefaultCacheTTLSeconds() time.Duration {
 	return time.Duration(defaultCacheTTLSeconds) * time.Second
 }
+
+// GetLogSearchDebounceDelayForTenant returns the tenant's TENANT_DEBOUNCE_DELAY_SECONDS override, or the global delay.
+func GetLogSearchDebounceDelayForTenant(tenantID string) time.Duration {
+	if seconds, ok := tenantDebounceDelaySecondsOverride[tenantID]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return GetLogSearchDebounceDelaySeconds()
+}
+
+// GetCacheTTLForTenant returns the tenant's TENANT_CACHE_TTL_SECONDS override, or the global TTL.
+func GetCacheTTLForTenant(tenantID string) time.Duration {
+	if seconds, ok := tenantCacheTTLSecondsOverride[tenantID]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return GetDefaultCacheTTLSeconds()
+}
+
+// GetWebhookMaxAttempts is the number of delivery attempts made for a webhook event before giving up.
+func GetWebhookMaxAttempts() int {
+	return webhookMaxAttempts
+}
+
+// GetWebhookInitialBackoff is the delay before the first retry. Each subsequent retry doubles it.
+func GetWebhookInitialBackoff() time.Duration {
+	return time.Duration(webhookInitialBackoffMs) * time.Millisecond
+}
+
+func GetWebhookRequestTimeout() time.Duration {
+	return time.Duration(webhookRequestTimeoutMs) * time.Millisecond
+}
+
+// GetWebhookRateSpikeMinPerHour is the minimum number of searches in the current hour before a rate spike rule can fire,
+// so that going from 1 to 3 searches is not reported as a spike.
+func GetWebhookRateSpikeMinPerHour() int {
+	return webhookRateSpikeMinPerHour
+}
+
+// GetWebhookRulesRefreshInterval is how long a replica uses a tenant's webhook rules before reloading them, so rules
+// changed through another replica apply within that delay.
+func GetWebhookRulesRefreshInterval() time.Duration {
+	return time.Duration(webhookRulesRefreshSeconds) * time.Second
+}
+
+func IsRateLimitEnabled() bool {
+	return rateLimitEnabled
+}
+
+// GetRateLimitBurst is the number of /search requests a client can make back to back before being limited.
+func GetRateLimitBurst() int {
+	return rateLimitBurst
+}
+
+// GetRateLimitRefillPerSecond is the sustained number of /search requests per second allowed per client.
+func GetRateLimitRefillPerSecond() float64 {
+	return rateLimitRefillPerSecond
+}
+
+func IsBotFilterEnabled() bool {
+	return botFilterEnabled
+}
+
+// GetBotFilterMode is BotFilterModeLog, BotFilterModeQuarantine or BotFilterModeExclude.
+func GetBotFilterMode() string {
+	return botFilterMode
+}
+
+// GetBotUserAgentPatterns are case-insensitive substrings that mark a user agent as automated.
+func GetBotUserAgentPatterns() []string {
+	return botUserAgentPatterns
+}
+
+// GetBotMaxQueriesPerMinute is the number of finalized queries per minute above which a client is flagged.
+func GetBotMaxQueriesPerMinute() int {
+	return botMaxQueriesPerMinute
+}
+
+// GetBotMaxDistinctQueries is the number of distinct finalized queries within GetBotDistinctQueryWindow above which a client is flagged.
+func GetBotMaxDistinctQueries() int {
+	return botMaxDistinctQueries
+}
+
+func GetBotDistinctQueryWindow() time.Duration {
+	return time.Duration(botDistinctQueryWindowSeconds) * time.Second
+}
+
+// GetBotFlagTTL is how long a client stays flagged after it was classified as automated.
+func GetBotFlagTTL() time.Duration {
+	return time.Duration(botFlagTTLSeconds) * time.Second
+}
+
+// GetTenantHeader is the request header a tenant can be selected with when no JWT identifies it.
+func GetTenantHeader() string {
+	return tenantHeader
+}
+
+// GetTenantJWTSecret is the HS256 secret bearer tokens are verified with. Tenants are not read from JWTs when empty.
+func GetTenantJWTSecret() string {
+	return tenantJWTSecret
+}
+
+// GetTenantJWTClaim is the JWT claim holding the tenant ID.
+func GetTenantJWTClaim() string {
+	return tenantJWTClaim
+}
+
+// GetSessionWindow is how long after a client's last finalized query its next actions are still considered part of
+// the same search session, e.g. for click attribution.
+func GetSessionWindow() time.Duration {
+	return time.Duration(sessionWindowSeconds) * time.Second
+}
+
+// GetSpellcheckMaxEditDistance is the largest edit distance at which a logged query is suggested as a correction.
+func GetSpellcheckMaxEditDistance() int {
+	return spellcheckMaxEditDistance
+}
+
+// GetSpellcheckMinCount is the number of searches a query needs to be part of the spelling dictionary.
+func GetSpellcheckMinCount() int {
+	return spellcheckMinCount
+}
+
+// GetSpellcheckMaxTerms caps the spelling dictionary of a tenant to its most searched queries.
+func GetSpellcheckMaxTerms() int {
+	return spellcheckMaxTerms
+}
+
+// GetSpellcheckMaxCachedTerms is how many terms the spelling dictionaries kept in memory hold across tenants before the
+// least recently used ones are dropped.
+func GetSpellcheckMaxCachedTerms() int {
+	return spellcheckMaxCachedTerms
+}
+
+// GetSpellcheckRebuildInterval is how old a tenant's spelling dictionary can get before it is rebuilt from search_logs.
+func GetSpellcheckRebuildInterval() time.Duration {
+	return time.Duration(spellcheckRebuildIntervalSeconds) * time.Second
+}
+
+func IsGRPCEnabled() bool {
+	return grpcEnabled
+}
+
+// GetGRPCPort is the port the gRPC server listens on, separately from the HTTP server.
+func GetGRPCPort() int {
+	return grpcPort
+}
+
+// GetGRPCDefaultTimeout is the deadline applied to gRPC calls whose client did not set one.
+func GetGRPCDefaultTimeout() time.Duration {
+	return time.Duration(grpcDefaultTimeoutMs) * time.Millisecond
+}
+
+// GetMaxRequestBodyBytes is the largest request body accepted by the routes validated against the OpenAPI document.
+func GetMaxRequestBodyBytes() int64 {
+	return int64(maxRequestBodyBytes)
+}
+
+// IsBlocklistProfanityEnabled tells whether queries containing a word of the bundled profanity list are blocked.
+func IsBlocklistProfanityEnabled() bool {
+	return blocklistProfanityEnabled
+}
+
+// GetBlocklistRefreshInterval is how long a replica uses a tenant's blocklist rules before reloading them, so rules
+// changed through another replica apply within that delay.
+func GetBlocklistRefreshInterval() time.Duration {
+	return time.Duration(blocklistRefreshIntervalSeconds) * time.Second
+}
+
+// GetIngestionMode is IngestionModeDirect, IngestionModeChannel or IngestionModeRedis.
+func GetIngestionMode() string {
+	return ingestionMode
+}
+
+// GetIngestionQueueSize is how many keystrokes can wait in the ingestion queue before new ones are refused.
+func GetIngestionQueueSize() int {
+	return ingestionQueueSize
+}
+
+// GetIngestionWorkers is how many keystrokes a replica logs from the ingestion queue at once.
+func GetIngestionWorkers() int {
+	return ingestionWorkers
+}
+
+// GetHTTPPort is the port the HTTP server listens on.
+func GetHTTPPort() int {
+	return httpPort
+}
+
+// GetTrustedProxies are the IP addresses and CIDR ranges of the proxies whose X-Forwarded-For and X-Real-IP headers are
+// trusted to tell the client IP. None are by default, so clients are told apart by the address they connect from.
+func GetTrustedProxies() []string {
+	return trustedProxies
+}
+
+// GetHTTPReadTimeout bounds reading a whole request, body included.
+func GetHTTPReadTimeout() time.Duration {
+	return time.Duration(httpReadTimeoutSeconds) * time.Second
+}
+
+// GetHTTPWriteTimeout bounds handling a request and writing its response, from the end of reading its headers.
+func GetHTTPWriteTimeout() time.Duration {
+	return time.Duration(httpWriteTimeoutSeconds) * time.Second
+}
+
+// GetHTTPIdleTimeout is how long a keep-alive connection waits for its next request.
+func GetHTTPIdleTimeout() time.Duration {
+	return time.Duration(httpIdleTimeoutSeconds) * time.Second
+}
+
+// GetShutdownTimeout is how long the whole shutdown sequence may take, from stopping the servers to closing the
+// database and Redis connections.
+func GetShutdownTimeout() time.Duration {
+	return time.Duration(shutdownTimeoutSeconds) * time.Second
+}
+
+// GetTracingExporter is TracingExporterNone, TracingExporterStdout or TracingExporterOTLP.
+func GetTracingExporter() string {
+	return tracingExporter
+}
+
+// GetTracingSampleRatio is the share of new traces that are recorded. Traces started by a caller that sent a
+// traceparent keep the caller's decision.
+func GetTracingSampleRatio() float64 {
+	return tracingSampleRatio
+}
+
+// GetTracingOTLPEndpoint is the OTLP/HTTP traces endpoint of the collector spans are sent to.
+func GetTracingOTLPEndpoint() string {
+	return tracingOTLPEndpoint
+}
+
+// GetTracingOTLPHeaders are added to every request sent to the collector, e.g. for authentication.
+func GetTracingOTLPHeaders() map[string]string {
+	return tracingOTLPHeaders
+}
+
+// GetTracingServiceName is the service.name spans are exported with.
+func GetTracingServiceName() string {
+	return tracingServiceName
+}
+
+// GetReplicaID identifies this replica in the state it shares with the others through Redis.
+func GetReplicaID() string {
+	return replicaID
+}
+
+// GetRealtimeTopCapacity is how many queries each sketch of recently finalized queries tracks. Estimates are exact
+// while fewer distinct queries are finalized in a bucket.
+func GetRealtimeTopCapacity() int {
+	return realtimeTopCapacity
+}
+
+// GetRealtimeTopBucket is the span of time covered by each sketch, and so the precision of real-time windows.
+func GetRealtimeTopBucket() time.Duration {
+	return time.Duration(realtimeTopBucketSeconds) * time.Second
+}
+
+// GetRealtimeTopRetention is the longest real-time window, after which sketches are dropped.
+func GetRealtimeTopRetention() time.Duration {
+	return time.Duration(realtimeTopRetentionMinutes) * time.Minute
+}
+
+// GetRealtimeTopPublishInterval is how often a replica shares its sketches with the others. Zero disables sharing.
+func GetRealtimeTopPublishInterval() time.Duration {
+	return time.Duration(realtimeTopPublishIntervalSeconds) * time.Second
+}
+
+// GetUniqueClientsSnapshotInterval is how often a replica saves the distinct clients estimates of the queries it
+// counted to the database. Zero saves them only on shutdown.
+func GetUniqueClientsSnapshotInterval() time.Duration {
+	return time.Duration(uniqueClientsSnapshotIntervalSeconds) * time.Second
+}
+
+// GetAdminUsername is the user name of the HTTP Basic credentials with the admin role, accepted along with API keys.
+func GetAdminUsername() string {
+	return adminUsername
+}
+
+// GetAdminPassword is the password of the admin credentials. Empty disables them, so only API keys can use the API.
+func GetAdminPassword() string {
+	return adminPassword
+}
+
+// IsDBAutoMigrateEnabled tells whether the database schema is migrated on startup, creating missing tables, columns and
+// indexes.
+func IsDBAutoMigrateEnabled() bool {
+	return dbAutoMigrate
+}
+
+// AreIngestAPIKeysOptional tells whether the routes and gRPC methods an ingest key can call let requests without
+// credentials through, for search boxes that cannot keep a key secret. Keys are still checked when sent, and every
+// other route always requires an API key or the admin credentials.
+func AreIngestAPIKeysOptional() bool {
+	return ingestAPIKeysOptional
+}
+
+// GetAPIKeyCacheTTL is how long a replica trusts an API key it looked up, so a key revoked through another replica or
+// the CLI keeps working there for up to that long.
+func GetAPIKeyCacheTTL() time.Duration {
+	return time.Duration(apiKeyCacheTTLSeconds) * time.Second
+}
+
+// GetAPIKeyUsageFlushInterval is how often a replica saves the usage of API keys to the database. Zero saves it only on
+// shutdown.
+func GetAPIKeyUsageFlushInterval() time.Duration {
+	return time.Duration(apiKeyUsageFlushIntervalSeconds) * time.Second
+}
@@ -0,0 +1,73 @@
+package correlation
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header a request ID is accepted from and echoed in, over HTTP and as gRPC metadata.
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]{1,128}$`)
+
+type requestIDContextKey struct{}
+
+type traceParentContextKey struct{}
+
+// NewRequestID returns a random request ID, for requests that did not bring a valid one.
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// IsValidRequestID reports whether a request ID sent by a client is short and plain enough to be logged as is.
+func IsValidRequestID(requestID string) bool {
+	return requestIDPattern.MatchString(requestID)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID on ctx, or an empty string when there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+func WithTraceParent(ctx context.Context, traceParent TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentContextKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the trace context on ctx, and false when there is none.
+func TraceParentFromContext(ctx context.Context) (TraceParent, bool) {
+	traceParent, ok := ctx.Value(traceParentContextKey{}).(TraceParent)
+	return traceParent, ok
+}
+
+// Copy returns to with the request ID and trace context of from, for work that outlives the request.
+func Copy(to, from context.Context) context.Context {
+	if requestID := RequestIDFromContext(from); requestID != "" {
+		to = WithRequestID(to, requestID)
+	}
+	if traceParent, ok := TraceParentFromContext(from); ok {
+		to = WithTraceParent(to, traceParent)
+	}
+	return to
+}
+
+// FromIncoming puts on ctx the request ID and trace context a caller sent, or new ones when they are missing or
+// invalid. The request gets its own span in the caller's trace.
+func FromIncoming(ctx context.Context, requestID, traceParentHeader string) context.Context {
+	if !IsValidRequestID(requestID) {
+		requestID = NewRequestID()
+	}
+	traceParent, err := ParseTraceParent(traceParentHeader)
+	if err != nil {
+		traceParent = NewTraceParent()
+	} else {
+		traceParent = traceParent.Child()
+	}
+	return WithTraceParent(WithRequestID(ctx, requestID), traceParent)
+}
@@ -0,0 +1,93 @@
+package correlation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	t.Run("Valid header", func(t *testing.T) {
+		traceParent, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		assert.NoError(t, err)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceParent.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", traceParent.SpanID)
+		assert.True(t, traceParent.Sampled)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceParent.String())
+	})
+
+	t.Run("Invalid headers", func(t *testing.T) {
+		for _, header := range []string{
+			"",
+			"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"00-4bf92f3577b34da6-00f067aa0ba902b7-01",
+		} {
+			_, err := ParseTraceParent(header)
+			assert.ErrorIs(t, err, ErrInvalidTraceParent, header)
+		}
+	})
+}
+
+func TestFromIncoming(t *testing.T) {
+	t.Run("Request ID and trace of the caller are kept, with a new span", func(t *testing.T) {
+		ctx := FromIncoming(context.Background(), "req-1", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+		assert.Equal(t, "req-1", RequestIDFromContext(ctx))
+		traceParent, ok := TraceParentFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceParent.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", traceParent.ParentSpanID)
+		assert.NotEqual(t, "00f067aa0ba902b7", traceParent.SpanID)
+		assert.False(t, traceParent.Sampled)
+	})
+
+	t.Run("Missing or invalid values are replaced", func(t *testing.T) {
+		ctx := FromIncoming(context.Background(), "not a valid id\n", "garbage")
+
+		assert.True(t, IsValidRequestID(RequestIDFromContext(ctx)))
+		traceParent, ok := TraceParentFromContext(ctx)
+		assert.True(t, ok)
+		_, err := ParseTraceParent(traceParent.String())
+		assert.NoError(t, err)
+		assert.Empty(t, traceParent.ParentSpanID)
+	})
+}
+
+func TestLogHandler(t *testing.T) {
+	t.Run("Records logged with a context carry its request ID and trace", func(t *testing.T) {
+		// ARRANGE
+		var out bytes.Buffer
+		logger := slog.New(NewLogHandler(slog.NewJSONHandler(&out, nil))).With("component", "test")
+		ctx := FromIncoming(context.Background(), "req-2", "")
+		traceParent, _ := TraceParentFromContext(ctx)
+
+		// ACT
+		logger.InfoContext(Copy(context.Background(), ctx), "hello")
+
+		// ASSERT
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+		assert.Equal(t, "req-2", record["request_id"])
+		assert.Equal(t, traceParent.TraceID, record["trace_id"])
+		assert.Equal(t, traceParent.SpanID, record["span_id"])
+		assert.Equal(t, "test", record["component"])
+	})
+
+	t.Run("Records logged without a context are unchanged", func(t *testing.T) {
+		var out bytes.Buffer
+		slog.New(NewLogHandler(slog.NewJSONHandler(&out, nil))).Info("hello")
+
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+		assert.NotContains(t, record, "request_id")
+		assert.NotContains(t, record, "trace_id")
+	})
+}
@@ -0,0 +1,35 @@
+package correlation
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adds the request ID and trace context on the context of each record, so the logs of a request, and of
+// the work it leaves in the background, can be told apart. Records need to be logged with a context, e.g.
+// logger.ErrorContext(ctx, ...).
+type LogHandler struct {
+	slog.Handler
+}
+
+func NewLogHandler(handler slog.Handler) *LogHandler {
+	return &LogHandler{Handler: handler}
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if traceParent, ok := TraceParentFromContext(ctx); ok {
+		record.AddAttrs(slog.String("trace_id", traceParent.TraceID), slog.String("span_id", traceParent.SpanID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithGroup(name)}
+}
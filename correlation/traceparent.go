@@ -0,0 +1,77 @@
+package correlation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TraceParentHeader is the W3C Trace Context header, over HTTP and as gRPC metadata.
+const TraceParentHeader = "traceparent"
+
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceParent is a W3C trace context: the trace a request belongs to, the span handling it, and the span of the
+// caller that sent it, if any.
+type TraceParent struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Sampled      bool
+}
+
+// NewTraceParent starts a new sampled trace.
+func NewTraceParent() TraceParent {
+	return TraceParent{TraceID: randomHex(16), SpanID: randomHex(8), Sampled: true}
+}
+
+// ParseTraceParent parses a version 00 traceparent header, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+// The span of the header becomes SpanID; call Child to get the span of the request it came with.
+func ParseTraceParent(header string) (TraceParent, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(spanID, 16) || !isHex(flags, 2) {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+
+	flagBits, _ := hex.DecodeString(flags)
+	return TraceParent{TraceID: traceID, SpanID: spanID, Sampled: flagBits[0]&1 == 1}, nil
+}
+
+// Child returns a new span of the same trace, whose parent is tp's span.
+func (tp TraceParent) Child() TraceParent {
+	return TraceParent{TraceID: tp.TraceID, SpanID: randomHex(8), ParentSpanID: tp.SpanID, Sampled: tp.Sampled}
+}
+
+// String formats tp as a traceparent header, naming tp's span as the parent of whatever receives it.
+func (tp TraceParent) String() string {
+	flags := "00"
+	if tp.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", tp.TraceID, tp.SpanID, flags)
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func randomHex(bytes int) string {
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
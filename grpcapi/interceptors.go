@@ -0,0 +1,181 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"search-logger/correlation"
+	"search-logger/models"
+	searchloggerv1 "search-logger/proto/searchlogger/v1"
+	"search-logger/service"
+	"search-logger/tenant"
+	"search-logger/tracing"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tenantContext resolves the tenant of a call from its metadata, the same way TenantMiddleware does for HTTP requests.
+func tenantContext(ctx context.Context, cfg Config) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tenantID, err := tenant.Resolve(firstMetadataValue(md, cfg.TenantHeader), firstMetadataValue(md, "authorization"), cfg.JWTSecret, cfg.JWTClaim)
+	if err != nil {
+		return nil, status.Error(tenantErrorCode(err), err.Error())
+	}
+	return tenant.WithTenant(ctx, tenantID), nil
+}
+
+// apiKeyMetadata carries the API key of a call, like the X-API-Key header over HTTP.
+const apiKeyMetadata = "x-api-key"
+
+// methodRoles are the roles a key needs to call each method, one of them being enough. Methods not listed, like
+// server reflection, need a key with any role.
+var methodRoles = map[string][]models.APIKeyRole{
+	searchloggerv1.SearchLogger_LogSearch_FullMethodName:      {models.APIKeyRoleIngest},
+	searchloggerv1.SearchLogger_LogSearchBatch_FullMethodName: {models.APIKeyRoleIngest},
+	searchloggerv1.SearchLogger_GetCount_FullMethodName:       {models.APIKeyRoleReadAnalytics},
+	searchloggerv1.SearchLogger_TopQueries_FullMethodName:     {models.APIKeyRoleReadAnalytics},
+}
+
+// authContext checks the API key of a call the same way AuthMiddleware does for HTTP requests, and puts on ctx the
+// tenant the key acts for. It must run after tenantContext.
+func authContext(ctx context.Context, cfg Config, fullMethod string) (context.Context, error) {
+	roles, ok := methodRoles[fullMethod]
+	if !ok {
+		roles = []models.APIKeyRole{models.APIKeyRoleIngest, models.APIKeyRoleReadAnalytics}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	rawKey := firstMetadataValue(md, apiKeyMetadata)
+	if rawKey == "" {
+		if ok && cfg.IngestKeysOptional && slices.Contains(roles, models.APIKeyRoleIngest) {
+			return ctx, nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "api key required")
+	}
+
+	key, err := cfg.APIKeys.Authenticate(ctx, rawKey)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "error checking api key")
+	}
+	if !key.HasAnyRole(roles...) {
+		cfg.APIKeys.RecordUsage(key, true)
+		return nil, status.Errorf(codes.PermissionDenied, "api key needs one of the roles %v", roles)
+	}
+	tenantID, ok := key.ResolveTenant(tenant.FromContext(ctx))
+	if !ok {
+		cfg.APIKeys.RecordUsage(key, true)
+		return nil, status.Error(codes.PermissionDenied, "api key is not valid for this tenant")
+	}
+	cfg.APIKeys.RecordUsage(key, false)
+	return tenant.WithTenant(ctx, tenantID), nil
+}
+
+// correlationContext puts on ctx the request ID and trace context of a call, from its metadata or new ones, the same
+// way CorrelationMiddleware does for HTTP requests. The request ID is sent back in the response header metadata.
+func correlationContext(ctx context.Context, setHeader func(metadata.MD) error) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = correlation.FromIncoming(ctx, firstMetadataValue(md, correlation.RequestIDHeader), firstMetadataValue(md, correlation.TraceParentHeader))
+	_ = setHeader(metadata.Pairs(correlation.RequestIDHeader, correlation.RequestIDFromContext(ctx)))
+	return ctx
+}
+
+// startCallSpan traces a call with a server span, which is the span correlationContext put on ctx. The returned
+// function ends it with the call's error.
+func startCallSpan(ctx context.Context, fullMethod string) (context.Context, func(error)) {
+	ctx, span := tracing.Start(ctx, "gRPC "+fullMethod,
+		tracing.AsRequestSpan(),
+		tracing.WithKind(tracing.SpanKindServer),
+		tracing.WithAttributes(tracing.String("rpc.system", "grpc"), tracing.String("rpc.method", fullMethod)),
+	)
+	return ctx, func(err error) {
+		span.SetAttributes(tracing.String("rpc.grpc.status_code", status.Code(err).String()))
+		span.RecordError(err)
+		span.End()
+	}
+}
+
+func firstMetadataValue(md metadata.MD, key string) string {
+	values := md.Get(strings.ToLower(key))
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func tenantErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, tenant.ErrInvalidToken):
+		return codes.Unauthenticated
+	case errors.Is(err, tenant.ErrTenantMismatch):
+		return codes.PermissionDenied
+	default:
+		return codes.InvalidArgument
+	}
+}
+
+// withDefaultDeadline keeps the deadline the client sent, which gRPC already puts on ctx, and applies defaultTimeout
+// to calls without one.
+func withDefaultDeadline(ctx context.Context, defaultTimeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || defaultTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, defaultTimeout)
+}
+
+func unaryInterceptor(cfg Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ any, err error) {
+		ctx, cancel := withDefaultDeadline(ctx, cfg.DefaultTimeout)
+		defer cancel()
+		ctx = correlationContext(ctx, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) })
+		ctx, endSpan := startCallSpan(ctx, info.FullMethod)
+		defer func() { endSpan(err) }()
+
+		ctx, err = tenantContext(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		ctx, err = authContext(ctx, cfg, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamInterceptor does not apply the default deadline, since streams like LogSearchBatch can stay open for longer.
+// Handlers apply it to each message instead.
+func streamInterceptor(cfg Config) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := correlationContext(stream.Context(), stream.SetHeader)
+		ctx, endSpan := startCallSpan(ctx, info.FullMethod)
+		defer func() { endSpan(err) }()
+
+		ctx, err = tenantContext(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		ctx, err = authContext(ctx, cfg, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextServerStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// contextServerStream overrides the context of a stream, so handlers see the tenant and request ID set by
+// interceptors.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,206 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	searchloggerv1 "search-logger/proto/searchlogger/v1"
+	"search-logger/service"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultTopQueriesLimit = 10
+	maxTopQueriesLimit     = 1000
+	// maxQueryTextLength and maxResultCount match the QueryText and ResultCount schemas of the OpenAPI document.
+	maxQueryTextLength = 512
+	maxResultCount     = 1000000000
+)
+
+type Config struct {
+	// DefaultTimeout is the deadline of unary calls whose client did not set one, and of each search of a
+	// LogSearchBatch stream, which can stay open for longer.
+	DefaultTimeout time.Duration
+	TenantHeader   string
+	JWTSecret      string
+	JWTClaim       string
+	// APIKeys authenticates the keys sent in the x-api-key metadata, with the roles of methodRoles.
+	APIKeys service.APIKeyService
+	// IngestKeysOptional lets calls without a key through on the methods an ingest key can call. Keys are still
+	// checked when sent. Every other method always requires a key.
+	IngestKeysOptional bool
+	// RateLimiter limits the searches of each client, by the client_id of the request, since a peer is often a backend
+	// relaying the searches of many clients. Searches are not limited when nil.
+	RateLimiter service.RateLimitService
+}
+
+type searchLoggerServer struct {
+	searchloggerv1.UnimplementedSearchLoggerServer
+	srv       service.SearchLogService
+	ingestion service.IngestionService
+	cfg       Config
+	logger    *slog.Logger
+}
+
+// NewServer returns a gRPC server exposing srv as the SearchLogger service, with server reflection enabled for
+// callers with an API key. Searches are handed to ingestion, like over HTTP.
+func NewServer(srv service.SearchLogService, ingestion service.IngestionService, cfg Config, logger *slog.Logger) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryInterceptor(cfg)),
+		grpc.StreamInterceptor(streamInterceptor(cfg)),
+	)
+	searchloggerv1.RegisterSearchLoggerServer(server, &searchLoggerServer{srv: srv, ingestion: ingestion, cfg: cfg, logger: logger})
+	reflection.Register(server)
+	return server
+}
+
+func (s *searchLoggerServer) LogSearch(ctx context.Context, req *searchloggerv1.LogSearchRequest) (*searchloggerv1.LogSearchResponse, error) {
+	if err := s.logSearch(ctx, req); err != nil {
+		return nil, err
+	}
+	return &searchloggerv1.LogSearchResponse{}, nil
+}
+
+func (s *searchLoggerServer) LogSearchBatch(stream searchloggerv1.SearchLogger_LogSearchBatchServer) error {
+	var accepted, rejected int32
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&searchloggerv1.LogSearchBatchResponse{Accepted: accepted, Rejected: rejected})
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.logBatchedSearch(stream.Context(), req); err != nil {
+			s.logger.WarnContext(stream.Context(), "Rejected search in batch", "error", err, "clientID", req.GetClientId())
+			rejected++
+			continue
+		}
+		accepted++
+	}
+}
+
+// logBatchedSearch logs a search of a LogSearchBatch stream within the default deadline, which the stream itself does
+// not get.
+func (s *searchLoggerServer) logBatchedSearch(ctx context.Context, req *searchloggerv1.LogSearchRequest) error {
+	ctx, cancel := withDefaultDeadline(ctx, s.cfg.DefaultTimeout)
+	defer cancel()
+	return s.logSearch(ctx, req)
+}
+
+// logSearch mirrors POST /search: the search is ingested and debounced in the background, and an inline result count
+// is attached to it.
+func (s *searchLoggerServer) logSearch(ctx context.Context, req *searchloggerv1.LogSearchRequest) error {
+	clientID := strings.TrimSpace(req.GetClientId())
+	if clientID == "" {
+		return status.Error(codes.InvalidArgument, "client_id is required")
+	}
+	if err := validateQueryText(req.GetQueryText()); err != nil {
+		return err
+	}
+	if req.ResultCount != nil && (req.GetResultCount() < 0 || req.GetResultCount() > maxResultCount) {
+		return status.Errorf(codes.InvalidArgument, "result_count must be between 0 and %d", maxResultCount)
+	}
+
+	clientIdentifier := "client:" + clientID
+	if s.cfg.RateLimiter != nil {
+		if allowed, retryAfter := s.cfg.RateLimiter.Allow(ctx, clientIdentifier); !allowed {
+			return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", retryAfter.Round(time.Millisecond))
+		}
+	}
+	ctx = service.WithClientInfo(ctx, service.ClientInfo{UserAgent: req.GetUserAgent(), IP: req.GetIp()})
+	order := service.KeystrokeOrder{Sequence: req.ClientSequence}
+	if req.ClientTimestamp != nil {
+		clientTimestamp := req.GetClientTimestamp().AsTime()
+		order.ClientTimestamp = &clientTimestamp
+	}
+	ctx = service.WithKeystrokeOrder(ctx, order)
+	var resultCount *int
+	if req.ResultCount != nil {
+		count := int(req.GetResultCount())
+		resultCount = &count
+	}
+	err := s.ingestion.Ingest(ctx, clientIdentifier, req.GetQueryText(), resultCount)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrIngestionQueueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, service.ErrIngestionStopped):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return s.contextError(ctx, err)
+	}
+}
+
+// validateQueryText applies the rules of the QueryText schema of the OpenAPI document: up to maxQueryTextLength
+// characters of printable text, with at least one that is not a space. gRPC already rejects invalid UTF-8.
+func validateQueryText(queryText string) error {
+	if strings.TrimSpace(queryText) == "" {
+		return status.Error(codes.InvalidArgument, "query_text is required")
+	}
+	if utf8.RuneCountInString(queryText) > maxQueryTextLength {
+		return status.Errorf(codes.InvalidArgument, "query_text must be at most %d characters", maxQueryTextLength)
+	}
+	if strings.ContainsFunc(queryText, func(r rune) bool { return r < 0x20 || r == 0x7F }) {
+		return status.Error(codes.InvalidArgument, "query_text cannot contain control characters")
+	}
+	return nil
+}
+
+func (s *searchLoggerServer) GetCount(ctx context.Context, req *searchloggerv1.GetCountRequest) (*searchloggerv1.GetCountResponse, error) {
+	queryText := strings.ToLower(strings.TrimSpace(req.GetQueryText()))
+	if queryText == "" {
+		return nil, status.Error(codes.InvalidArgument, "query_text is required")
+	}
+
+	count, err := s.srv.GetSearchLogCountByQueryText(ctx, queryText)
+	if err != nil {
+		return nil, s.contextError(ctx, err)
+	}
+	return &searchloggerv1.GetCountResponse{QueryText: queryText, Count: int64(count)}, nil
+}
+
+func (s *searchLoggerServer) TopQueries(ctx context.Context, req *searchloggerv1.TopQueriesRequest) (*searchloggerv1.TopQueriesResponse, error) {
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultTopQueriesLimit
+	}
+	if limit < 0 || limit > maxTopQueriesLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", maxTopQueriesLimit)
+	}
+
+	searchLogs, err := s.srv.TopQueries(ctx, limit)
+	if err != nil {
+		return nil, s.contextError(ctx, err)
+	}
+
+	queries := make([]*searchloggerv1.QueryCount, 0, len(searchLogs))
+	for _, searchLog := range searchLogs {
+		queries = append(queries, &searchloggerv1.QueryCount{QueryText: searchLog.QueryText, Count: int64(searchLog.Count)})
+	}
+	return &searchloggerv1.TopQueriesResponse{Queries: queries}, nil
+}
+
+// contextError reports a failure caused by the call's deadline or cancellation with the matching status code. Other
+// failures are logged and reported as a generic internal error, so that they are not exposed to clients.
+func (s *searchLoggerServer) contextError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(ctx.Err(), context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		s.logger.ErrorContext(ctx, "Error handling gRPC call", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
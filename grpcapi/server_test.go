@@ -0,0 +1,297 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"search-logger/models"
+	searchloggerv1 "search-logger/proto/searchlogger/v1"
+	"search-logger/repository/database"
+	"search-logger/service"
+	"search-logger/storage_util"
+	"search-logger/tenant"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// stubSearchLogService counts every query once, or waits for the end of the call when slow.
+type stubSearchLogService struct {
+	service.SearchLogService
+	slow bool
+}
+
+func (s stubSearchLogService) GetSearchLogCountByQueryText(ctx context.Context, queryText string) (int, error) {
+	if s.slow {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	return 1, nil
+}
+
+// stubIngestionService records the queries it ingests, or fails with err.
+type stubIngestionService struct {
+	service.IngestionService
+	err     error
+	mu      *sync.Mutex
+	queries *[]string
+}
+
+func newStubIngestionService(err error) stubIngestionService {
+	return stubIngestionService{err: err, mu: &sync.Mutex{}, queries: &[]string{}}
+}
+
+func (s stubIngestionService) Ingest(ctx context.Context, clientIdentifier, queryText string, resultCount *int) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.queries = append(*s.queries, tenant.FromContext(ctx)+"/"+queryText)
+	return nil
+}
+
+func (s stubIngestionService) ingested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), *s.queries...)
+}
+
+// stubRateLimiter allows the first allowed searches of each client.
+type stubRateLimiter struct {
+	allowed int
+	mu      *sync.Mutex
+	used    map[string]int
+}
+
+func (l stubRateLimiter) Allow(ctx context.Context, clientIdentifier string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used[clientIdentifier]++
+	return l.used[clientIdentifier] <= l.allowed, time.Second
+}
+
+func setupTestAPIKeys(t *testing.T) service.APIKeyService {
+	db := storage_util.InitDB()
+	assert.NoError(t, storage_util.Migrate(db))
+	return service.NewAPIKeyService(database.NewAPIKeyDatabaseRepository(db), service.APIKeyConfig{CacheTTL: time.Minute}, slog.Default())
+}
+
+// setupTestServer serves NewServer over an in-memory connection, and returns a client connected to it.
+func setupTestServer(t *testing.T, srv service.SearchLogService, ingestion service.IngestionService, cfg Config) *grpc.ClientConn {
+	cfg.TenantHeader = "x-tenant-id"
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(srv, ingestion, cfg, slog.Default())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withAPIKey(ctx context.Context, rawKey string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, rawKey)
+}
+
+func createTestKey(t *testing.T, keys service.APIKeyService, roles ...models.APIKeyRole) string {
+	rawKey, _, err := keys.Create(context.Background(), "test", roles, "", nil)
+	assert.NoError(t, err)
+	return rawKey
+}
+
+func TestServer_Auth(t *testing.T) {
+	keys := setupTestAPIKeys(t)
+	ingestKey := createTestKey(t, keys, models.APIKeyRoleIngest)
+	analyticsKey := createTestKey(t, keys, models.APIKeyRoleReadAnalytics)
+	logSearch := func(ctx context.Context, conn *grpc.ClientConn) error {
+		_, err := searchloggerv1.NewSearchLoggerClient(conn).LogSearch(ctx, &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "laptop"})
+		return err
+	}
+	getCount := func(ctx context.Context, conn *grpc.ClientConn) error {
+		_, err := searchloggerv1.NewSearchLoggerClient(conn).GetCount(ctx, &searchloggerv1.GetCountRequest{QueryText: "laptop"})
+		return err
+	}
+	listServices := func(ctx context.Context, conn *grpc.ClientConn) error {
+		stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&reflectionv1.ServerReflectionRequest{MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{}}); err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		optional bool
+		call     func(context.Context, *grpc.ClientConn) error
+		rawKey   string
+		code     codes.Code
+	}{
+		{"Searches require a key by default", false, logSearch, "", codes.Unauthenticated},
+		{"Searches can be opened", true, logSearch, "", codes.OK},
+		{"Searches need the ingest role", false, logSearch, analyticsKey, codes.PermissionDenied},
+		{"Ingest keys can log searches", false, logSearch, ingestKey, codes.OK},
+		{"Counts require a key even when searches are opened", true, getCount, "", codes.Unauthenticated},
+		{"Counts need the read-analytics role", false, getCount, ingestKey, codes.PermissionDenied},
+		{"Analytics keys can read counts", false, getCount, analyticsKey, codes.OK},
+		{"Unknown keys are refused", true, logSearch, "slk_unknown", codes.Unauthenticated},
+		{"Reflection requires a key", false, listServices, "", codes.Unauthenticated},
+		{"Reflection requires a key even when searches are opened", true, listServices, "", codes.Unauthenticated},
+		{"Reflection accepts keys of any role", false, listServices, ingestKey, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			conn := setupTestServer(t, stubSearchLogService{}, newStubIngestionService(nil), Config{APIKeys: keys, IngestKeysOptional: tt.optional})
+			ctx := context.Background()
+			if tt.rawKey != "" {
+				ctx = withAPIKey(ctx, tt.rawKey)
+			}
+
+			// ACT
+			err := tt.call(ctx, conn)
+
+			// ASSERT
+			assert.Equal(t, tt.code, status.Code(err), err)
+		})
+	}
+}
+
+func TestServer_LogSearch(t *testing.T) {
+	keys := setupTestAPIKeys(t)
+	ctx := withAPIKey(context.Background(), createTestKey(t, keys, models.APIKeyRoleIngest))
+	resultCount := func(count int32) *int32 { return &count }
+
+	tests := []struct {
+		name      string
+		req       *searchloggerv1.LogSearchRequest
+		ingestErr error
+		code      codes.Code
+	}{
+		{"Valid searches are ingested", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "laptop", ResultCount: resultCount(3)}, nil, codes.OK},
+		{"Client IDs are required", &searchloggerv1.LogSearchRequest{ClientId: " ", QueryText: "laptop"}, nil, codes.InvalidArgument},
+		{"Blank queries are rejected", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: " "}, nil, codes.InvalidArgument},
+		{"Queries up to the maximum length are ingested", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: strings.Repeat("é", maxQueryTextLength)}, nil, codes.OK},
+		{"Oversized queries are rejected", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: strings.Repeat("a", maxQueryTextLength+1)}, nil, codes.InvalidArgument},
+		{"Queries with control characters are rejected", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "lap\x00top"}, nil, codes.InvalidArgument},
+		{"Negative result counts are rejected", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "laptop", ResultCount: resultCount(-1)}, nil, codes.InvalidArgument},
+		{"Oversized result counts are rejected", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "laptop", ResultCount: resultCount(maxResultCount + 1)}, nil, codes.InvalidArgument},
+		{"A full queue exhausts resources", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "laptop"}, service.ErrIngestionQueueFull, codes.ResourceExhausted},
+		{"A stopping ingestion is unavailable", &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "laptop"}, service.ErrIngestionStopped, codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ARRANGE
+			ingestion := newStubIngestionService(tt.ingestErr)
+			conn := setupTestServer(t, stubSearchLogService{}, ingestion, Config{APIKeys: keys})
+
+			// ACT
+			_, err := searchloggerv1.NewSearchLoggerClient(conn).LogSearch(ctx, tt.req)
+
+			// ASSERT
+			assert.Equal(t, tt.code, status.Code(err), err)
+			if tt.code == codes.OK {
+				assert.Equal(t, []string{tenant.DefaultTenantID + "/" + tt.req.GetQueryText()}, ingestion.ingested())
+			} else {
+				assert.Empty(t, ingestion.ingested())
+			}
+		})
+	}
+
+	t.Run("Searches are rate limited per client", func(t *testing.T) {
+		// ARRANGE
+		ingestion := newStubIngestionService(nil)
+		limiter := stubRateLimiter{allowed: 2, mu: &sync.Mutex{}, used: make(map[string]int)}
+		conn := setupTestServer(t, stubSearchLogService{}, ingestion, Config{APIKeys: keys, RateLimiter: limiter})
+		client := searchloggerv1.NewSearchLoggerClient(conn)
+
+		// ACT
+		var codesSeen []codes.Code
+		for i := 0; i < 3; i++ {
+			_, err := client.LogSearch(ctx, &searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: "laptop"})
+			codesSeen = append(codesSeen, status.Code(err))
+		}
+
+		// ASSERT
+		assert.Equal(t, []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted}, codesSeen)
+		assert.Len(t, ingestion.ingested(), 2)
+	})
+
+	t.Run("Clients relayed by one peer have their own limit", func(t *testing.T) {
+		// ARRANGE
+		ingestion := newStubIngestionService(nil)
+		limiter := stubRateLimiter{allowed: 2, mu: &sync.Mutex{}, used: make(map[string]int)}
+		conn := setupTestServer(t, stubSearchLogService{}, ingestion, Config{APIKeys: keys, RateLimiter: limiter})
+		client := searchloggerv1.NewSearchLoggerClient(conn)
+
+		// ACT
+		var codesSeen []codes.Code
+		for _, clientID := range []string{"c1", "c2", "c3", "c1", "c2", "c3"} {
+			_, err := client.LogSearch(ctx, &searchloggerv1.LogSearchRequest{ClientId: clientID, QueryText: "laptop"})
+			codesSeen = append(codesSeen, status.Code(err))
+		}
+
+		// ASSERT
+		for _, code := range codesSeen {
+			assert.Equal(t, codes.OK, code)
+		}
+		assert.Len(t, ingestion.ingested(), 6)
+	})
+}
+
+func TestServer_Deadlines(t *testing.T) {
+	keys := setupTestAPIKeys(t)
+
+	t.Run("Unary calls without a deadline get the default one", func(t *testing.T) {
+		// ARRANGE
+		conn := setupTestServer(t, stubSearchLogService{slow: true}, newStubIngestionService(nil), Config{APIKeys: keys, DefaultTimeout: 50 * time.Millisecond})
+		ctx := withAPIKey(context.Background(), createTestKey(t, keys, models.APIKeyRoleReadAnalytics))
+
+		// ACT
+		_, err := searchloggerv1.NewSearchLoggerClient(conn).GetCount(ctx, &searchloggerv1.GetCountRequest{QueryText: "laptop"})
+
+		// ASSERT
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err), err)
+	})
+
+	t.Run("Batch streams outlive the default deadline", func(t *testing.T) {
+		// ARRANGE
+		ingestion := newStubIngestionService(nil)
+		conn := setupTestServer(t, stubSearchLogService{}, ingestion, Config{APIKeys: keys, DefaultTimeout: 50 * time.Millisecond})
+		ctx := withAPIKey(context.Background(), createTestKey(t, keys, models.APIKeyRoleIngest))
+		stream, err := searchloggerv1.NewSearchLoggerClient(conn).LogSearchBatch(ctx)
+		assert.NoError(t, err)
+
+		// ACT
+		for _, queryText := range []string{"laptop", "", "phone"} {
+			assert.NoError(t, stream.Send(&searchloggerv1.LogSearchRequest{ClientId: "c1", QueryText: queryText}))
+			time.Sleep(100 * time.Millisecond)
+		}
+		resp, err := stream.CloseAndRecv()
+
+		// ASSERT
+		if assert.NoError(t, err) {
+			assert.Equal(t, int32(2), resp.GetAccepted())
+			assert.Equal(t, int32(1), resp.GetRejected())
+		}
+		assert.Equal(t, []string{"default/laptop", "default/phone"}, ingestion.ingested())
+	})
+}
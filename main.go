@@ -1,31 +1,300 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"search-logger/api"
+	"search-logger/config"
+	"search-logger/correlation"
+	"search-logger/grpcapi"
+	"search-logger/models"
 	"search-logger/repository/cache"
 	"search-logger/repository/database"
+	"search-logger/service"
 	"search-logger/storage_util"
+	"search-logger/tracing"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
 )
 
 func main() {
-	logHandler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	logHandler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})
-	slog.SetDefault(slog.New(logHandler))
+	slog.SetDefault(slog.New(correlation.NewLogHandler(logHandler)))
+	if len(os.Args) > 1 && os.Args[1] == "apikeys" {
+		os.Exit(runAPIKeysCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
 	slog.Info("Starting Search Logger Service")
+	tracer := newTracer(slog.Default())
+	tracing.SetDefault(tracer)
 
 	// Initialize database and cache repositories
 	postgresDB := storage_util.InitDB()
+	if storage_util.IsInMemory() {
+		slog.Warn("SQLITE_PATH is not set, so the database is kept in memory and lost on shutdown")
+	}
+	if config.IsDBAutoMigrateEnabled() {
+		if err := storage_util.Migrate(postgresDB); err != nil {
+			slog.Error("Failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+	}
 	redisCache := storage_util.InitRedis()
 	dbRepo := database.NewSearchLogDatabaseRepository(postgresDB)
 	cacheRepo := cache.NewLatestClientQueryCacheRepository(redisCache)
+	webhookRepo := database.NewWebhookDatabaseRepository(postgresDB)
+	queryRateRepo := cache.NewQueryRateCacheRepository(redisCache)
+	rateLimitRepo := cache.NewRateLimitCacheRepository(redisCache)
+	quarantineRepo := database.NewQuarantineDatabaseRepository(postgresDB)
+	clientActivityRepo := cache.NewClientActivityCacheRepository(redisCache)
+	clickRepo := database.NewClickDatabaseRepository(postgresDB)
+	clientSessionRepo := cache.NewClientSessionCacheRepository(redisCache)
+	transitionRepo := database.NewTransitionDatabaseRepository(postgresDB)
+	synonymRepo := database.NewSynonymDatabaseRepository(postgresDB)
+	blocklistRepo := database.NewBlocklistDatabaseRepository(postgresDB)
+	searchEventQueueRepo := cache.NewSearchEventQueueCacheRepository(redisCache)
+	realtimeSketchRepo := cache.NewRealtimeSketchCacheRepository(redisCache)
+	uniqueClientRepo := cache.NewUniqueClientCacheRepository(redisCache)
+	apiKeyRepo := database.NewAPIKeyDatabaseRepository(postgresDB)
+
+	// Initialize services
+	logger := slog.Default()
+	webhookSrv := service.NewWebhookService(webhookRepo, queryRateRepo, logger)
+	synonymSrv := service.NewSynonymService(synonymRepo, logger)
+	clickSrv := service.NewClickService(clickRepo, cacheRepo, clientSessionRepo, synonymSrv, config.GetSessionWindow(), logger)
+	transitionSrv := service.NewTransitionService(transitionRepo, clientSessionRepo, config.GetSessionWindow(), logger)
+	uniqueClientSrv := service.NewUniqueClientService(dbRepo, uniqueClientRepo, config.GetUniqueClientsSnapshotInterval(), logger)
+	blocklistSrv := service.NewBlocklistService(blocklistRepo, service.BlocklistConfigFromEnv(), logger, uniqueClientSrv)
+	realtimeTopSrv := service.NewRealtimeTopService(realtimeSketchRepo, blocklistSrv, service.RealtimeTopConfigFromEnv(), logger)
+	searchLogOpts := []service.Option{
+		// Synonyms first, so that the blocklist sees the canonical query and blocking it blocks its variants too.
+		service.WithPersistFilters(synonymSrv, blocklistSrv),
+		service.WithPersistedListeners(webhookSrv, clickSrv, transitionSrv, realtimeTopSrv, uniqueClientSrv),
+	}
+	if config.IsBotFilterEnabled() {
+		botFilterSrv := service.NewBotFilterService(clientActivityRepo, quarantineRepo, service.BotFilterConfigFromEnv(), logger)
+		searchLogOpts = append(searchLogOpts, service.WithPersistFilters(botFilterSrv))
+	}
+	searchLogSrv := service.NewSearchLogService(dbRepo, cacheRepo, logger, searchLogOpts...)
+	quarantineSrv := service.NewQuarantineService(quarantineRepo, clientActivityRepo, searchLogSrv)
+	ingestionSrv := service.NewIngestionService(searchLogSrv, searchEventQueueRepo, service.IngestionConfigFromEnv(), logger)
+	timeSeriesSrv := service.NewTimeSeriesService(dbRepo)
+	spellcheckSrv := service.NewSpellcheckService(dbRepo, service.SpellcheckConfigFromEnv(), logger)
+	apiKeySrv := service.NewAPIKeyService(apiKeyRepo, service.APIKeyConfigFromEnv(), logger)
+
+	openAPIDoc, err := api.LoadOpenAPI(config.GetTenantHeader())
+	if err != nil {
+		slog.Error("Failed to load OpenAPI document", "error", err)
+		os.Exit(1)
+	}
+
+	authCfg := api.AuthConfig{
+		IngestKeysOptional: config.AreIngestAPIKeysOptional(),
+		AdminUsername:      config.GetAdminUsername(),
+		AdminPassword:      config.GetAdminPassword(),
+	}
+	if authCfg.IngestKeysOptional {
+		slog.Warn("INGEST_API_KEYS_OPTIONAL is set, so anyone can log searches and clicks and ask for spellings without an API key")
+	}
+	if !authCfg.IngestKeysOptional {
+		warnWithoutIngestKeys(context.Background(), apiKeySrv)
+	}
+	if authCfg.AdminPassword == "" {
+		slog.Warn("ADMIN_PASSWORD is not set, so only API keys can use the API and the dashboard")
+	}
+	ingestAuth := api.AuthMiddleware(apiKeySrv, authCfg, models.APIKeyRoleIngest)
+	analyticsAuth := api.AuthMiddleware(apiKeySrv, authCfg, models.APIKeyRoleReadAnalytics)
+	adminAuth := api.AuthMiddleware(apiKeySrv, authCfg, models.APIKeyRoleAdmin)
+
+	searchMiddleware := []gin.HandlerFunc{ingestAuth}
+	var rateLimitSrv service.RateLimitService
+	if config.IsRateLimitEnabled() {
+		rateLimitSrv = service.NewRateLimitService(rateLimitRepo, config.GetRateLimitBurst(), config.GetRateLimitRefillPerSecond(), logger)
+		searchMiddleware = append(searchMiddleware, api.RateLimitMiddleware(rateLimitSrv))
+	}
+	searchMiddleware = append(searchMiddleware, api.OpenAPIValidationMiddleware(openAPIDoc, config.GetMaxRequestBodyBytes()))
+
+	var grpcServer *grpc.Server
+	if config.IsGRPCEnabled() {
+		grpcServer = grpcapi.NewServer(searchLogSrv, ingestionSrv, grpcapi.Config{
+			DefaultTimeout:     config.GetGRPCDefaultTimeout(),
+			TenantHeader:       config.GetTenantHeader(),
+			JWTSecret:          config.GetTenantJWTSecret(),
+			JWTClaim:           config.GetTenantJWTClaim(),
+			APIKeys:            apiKeySrv,
+			IngestKeysOptional: authCfg.IngestKeysOptional,
+			RateLimiter:        rateLimitSrv,
+		}, logger)
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", config.GetGRPCPort()))
+		if err != nil {
+			slog.Error("Failed to listen for gRPC", "error", err, "port", config.GetGRPCPort())
+			os.Exit(1)
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				slog.Error("gRPC server stopped", "error", err)
+			}
+		}()
+	}
 
 	// Register API routes
-	r := gin.Default()
-	api.RegisterRoutes(r, dbRepo, cacheRepo)
-	r.Run(":8080")
+	r, err := api.NewRouter(config.GetTrustedProxies())
+	if err != nil {
+		slog.Error("Invalid TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
+	}
+	r.Use(api.CorrelationMiddleware(), api.TracingMiddleware(), api.AccessLogMiddleware(logger), gin.Recovery())
+	r.Use(api.TenantMiddleware(config.GetTenantHeader(), config.GetTenantJWTSecret(), config.GetTenantJWTClaim()))
+	api.RegisterOpenAPIRoutes(r, openAPIDoc)
+	api.RegisterRoutes(r, searchLogSrv, ingestionSrv, searchMiddleware...)
+	api.RegisterClickRoutes(r, clickSrv, searchMiddleware...)
+	api.RegisterWebhookRoutes(r, webhookRepo, webhookSrv, adminAuth)
+	api.RegisterQuarantineRoutes(r, quarantineSrv, adminAuth)
+	api.RegisterAnalyticsRoutes(r, dbRepo, clickRepo, transitionRepo, timeSeriesSrv, realtimeTopSrv, analyticsAuth)
+	// Search boxes suggest spellings while the user types, so ingest keys can call it too.
+	api.RegisterSpellcheckRoutes(r, spellcheckSrv, api.AuthMiddleware(apiKeySrv, authCfg, models.APIKeyRoleIngest, models.APIKeyRoleReadAnalytics))
+	api.RegisterSearchLogRoutes(r, dbRepo, analyticsAuth)
+	api.RegisterSynonymRoutes(r, synonymRepo, adminAuth)
+	api.RegisterBlocklistRoutes(r, blocklistSrv, adminAuth)
+	if err := api.RegisterDashboardRoutes(r, config.GetTenantHeader(), adminAuth); err != nil {
+		slog.Error("Failed to render dashboard", "error", err)
+		os.Exit(1)
+	}
+
+	httpServer := &http.Server{
+		Addr:         fmt.Sprintf(":%d", config.GetHTTPPort()),
+		Handler:      r,
+		ReadTimeout:  config.GetHTTPReadTimeout(),
+		WriteTimeout: config.GetHTTPWriteTimeout(),
+		IdleTimeout:  config.GetHTTPIdleTimeout(),
+	}
+	serverErrors := make(chan error, 1)
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErrors <- err
+		}
+	}()
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	exitCode := 0
+	select {
+	case <-signalCtx.Done():
+		slog.Info("Shutting down")
+	case err := <-serverErrors:
+		slog.Error("HTTP server stopped", "error", err, "port", config.GetHTTPPort())
+		exitCode = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetShutdownTimeout())
+	defer cancel()
+	shutdown(ctx, httpServer, grpcServer, ingestionSrv, searchLogSrv, spellcheckSrv, realtimeTopSrv, uniqueClientSrv, apiKeySrv, webhookSrv, tracer, postgresDB, redisCache)
+	os.Exit(exitCode)
+}
+
+// warnWithoutIngestKeys warns when no active key can log searches, since every POST /search then answers 401 unless
+// INGEST_API_KEYS_OPTIONAL is set.
+func warnWithoutIngestKeys(ctx context.Context, apiKeySrv service.APIKeyService) {
+	keys, err := apiKeySrv.List(ctx)
+	if err != nil {
+		slog.Warn("Failed to list API keys", "error", err)
+		return
+	}
+	now := time.Now()
+	for _, key := range keys {
+		if key.IsActive(now) && key.HasAnyRole(models.APIKeyRoleIngest) {
+			return
+		}
+	}
+	slog.Warn("No active API key can log searches, so POST /search and gRPC LogSearch answer 401 until one is created with `search-logger apikeys create -roles ingest`, or INGEST_API_KEYS_OPTIONAL is set")
+}
+
+// shutdown stops accepting traffic, logs the keystrokes already accepted, finalizes those still being debounced,
+// stops rebuilding spelling dictionaries, shares the last real-time sketches, snapshots the last distinct clients
+// estimates, saves the last API key usage, finishes the webhook deliveries in flight, exports the remaining spans, and
+// closes the database and Redis connections, giving up on any step still running when ctx is done.
+func shutdown(ctx context.Context, httpServer *http.Server, grpcServer *grpc.Server, ingestionSrv service.IngestionService, searchLogSrv service.SearchLogService, spellcheckSrv service.SpellcheckService, realtimeTopSrv service.RealtimeTopService, uniqueClientSrv service.UniqueClientService, apiKeySrv service.APIKeyService, webhookSrv service.WebhookService, tracer tracing.Tracer, db *gorm.DB, redisCache *redis.Client) {
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Error("HTTP server did not shut down in time", "error", err)
+		httpServer.Close()
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			slog.Error("gRPC server did not shut down in time", "error", ctx.Err())
+			grpcServer.Stop()
+		}
+	}
+
+	if err := ingestionSrv.Drain(ctx); err != nil {
+		slog.Error("Search ingestion did not drain in time", "error", err)
+	}
+	if err := searchLogSrv.Flush(ctx); err != nil {
+		slog.Error("Pending searches were not flushed in time", "error", err)
+	}
+	if err := spellcheckSrv.Stop(ctx); err != nil {
+		slog.Error("Spelling dictionary rebuilds did not stop in time", "error", err)
+	}
+	if err := realtimeTopSrv.Stop(ctx); err != nil {
+		slog.Error("Failed to publish real-time sketches", "error", err)
+	}
+	if err := uniqueClientSrv.Stop(ctx); err != nil {
+		slog.Error("Failed to snapshot distinct clients", "error", err)
+	}
+	if err := apiKeySrv.Stop(ctx); err != nil {
+		slog.Error("Failed to save API key usage", "error", err)
+	}
+	if err := webhookSrv.Drain(ctx); err != nil {
+		slog.Error("Webhook deliveries did not finish in time and were interrupted", "error", err)
+	}
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error("Spans were not exported in time", "error", err)
+	}
+
+	if sqlDB, err := db.DB(); err != nil {
+		slog.Error("Failed to get database connection", "error", err)
+	} else if err := sqlDB.Close(); err != nil {
+		slog.Error("Failed to close database connection", "error", err)
+	}
+	if err := redisCache.Close(); err != nil {
+		slog.Error("Failed to close Redis connection", "error", err)
+	}
+	slog.Info("Shut down")
+}
+
+// newTracer returns a tracer exporting spans to the configured exporter, or recording none.
+func newTracer(logger *slog.Logger) tracing.Tracer {
+	var exporter tracing.Exporter
+	switch config.GetTracingExporter() {
+	case config.TracingExporterStdout:
+		exporter = tracing.NewStdoutExporter(os.Stdout)
+	case config.TracingExporterOTLP:
+		exporter = tracing.NewOTLPHTTPExporter(config.GetTracingOTLPEndpoint(), config.GetTracingServiceName(), config.GetTracingOTLPHeaders(), &http.Client{Timeout: 10 * time.Second})
+	default:
+		return tracing.Default()
+	}
+	return tracing.NewTracer(exporter, tracing.Config{
+		SampleRatio:  config.GetTracingSampleRatio(),
+		QueueSize:    2048,
+		BatchSize:    512,
+		BatchTimeout: 5 * time.Second,
+	}, logger)
 }
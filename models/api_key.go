@@ -0,0 +1,123 @@
+package models
+
+import (
+	"search-logger/tenant"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyRole string
+
+const (
+	// APIKeyRoleIngest allows logging searches and clicks, and spelling suggestions for search boxes.
+	APIKeyRoleIngest APIKeyRole = "ingest"
+	// APIKeyRoleReadAnalytics allows reading search logs and analytics.
+	APIKeyRoleReadAnalytics APIKeyRole = "read-analytics"
+	// APIKeyRoleAdmin allows everything, including the admin routes, webhooks and the dashboard.
+	APIKeyRoleAdmin APIKeyRole = "admin"
+)
+
+func (r APIKeyRole) IsValid() bool {
+	switch r {
+	case APIKeyRoleIngest, APIKeyRoleReadAnalytics, APIKeyRoleAdmin:
+		return true
+	}
+	return false
+}
+
+// APIKey authenticates callers of the HTTP and gRPC APIs. Only a SHA-256 hash of the key is stored, along with its
+// first characters so it can be recognized in listings.
+type APIKey struct {
+	ID     string `json:"id" gorm:"type:uuid;primaryKey"`
+	Name   string `json:"name" gorm:"not null"`
+	Prefix string `json:"prefix" gorm:"not null"`
+	Hash   string `json:"-" gorm:"uniqueIndex;not null"`
+	// Roles is a comma separated list of APIKeyRole.
+	Roles string `json:"roles" gorm:"not null"`
+	// TenantID pins the key to a tenant. Keys without one can act for any tenant.
+	TenantID   string     `json:"tenant_id,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*APIKey) TableName() string {
+	return "api_keys"
+}
+
+func NewAPIKey(name, prefix, hash string, roles []APIKeyRole, tenantID string, expiresAt *time.Time) *APIKey {
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, string(role))
+	}
+	return &APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Roles:     strings.Join(roleNames, ","),
+		TenantID:  tenantID,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// RoleList splits Roles.
+func (k *APIKey) RoleList() []APIKeyRole {
+	var roles []APIKeyRole
+	for _, role := range strings.Split(k.Roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, APIKeyRole(role))
+		}
+	}
+	return roles
+}
+
+// HasAnyRole reports whether the key has one of roles. Admin keys have every role.
+func (k *APIKey) HasAnyRole(roles ...APIKeyRole) bool {
+	keyRoles := k.RoleList()
+	if slices.Contains(keyRoles, APIKeyRoleAdmin) {
+		return true
+	}
+	for _, role := range roles {
+		if slices.Contains(keyRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveTenant returns the tenant a request made with the key for requestedTenant acts for. A key pinned to a tenant
+// acts for it when the request names no tenant, i.e. requestedTenant is tenant.DefaultTenantID, and cannot act for
+// other tenants.
+func (k *APIKey) ResolveTenant(requestedTenant string) (string, bool) {
+	if k.TenantID == "" || k.TenantID == requestedTenant {
+		return requestedTenant, true
+	}
+	if requestedTenant == tenant.DefaultTenantID {
+		return k.TenantID, true
+	}
+	return "", false
+}
+
+// IsActive reports whether the key can still be used at the given time.
+func (k *APIKey) IsActive(at time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || at.Before(*k.ExpiresAt))
+}
+
+// APIKeyDailyUsage counts the requests made with a key on a UTC day.
+type APIKeyDailyUsage struct {
+	KeyID string    `json:"key_id" gorm:"type:uuid;primaryKey"`
+	Day   time.Time `json:"day" gorm:"primaryKey"`
+	// Requests counts the requests the key was accepted for.
+	Requests int64 `json:"requests" gorm:"not null;default:0"`
+	// Denied counts the requests refused because the key lacked the role of the route.
+	Denied int64 `json:"denied" gorm:"not null;default:0"`
+}
+
+func (*APIKeyDailyUsage) TableName() string {
+	return "api_key_daily_usage"
+}
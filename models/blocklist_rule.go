@@ -0,0 +1,90 @@
+package models
+
+import (
+	"search-logger/tenant"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BlocklistRuleType string
+
+const (
+	// BlocklistRuleExact blocks a query equal to Pattern.
+	BlocklistRuleExact BlocklistRuleType = "exact"
+	// BlocklistRulePrefix blocks queries starting with Pattern, e.g. "http://".
+	BlocklistRulePrefix BlocklistRuleType = "prefix"
+	// BlocklistRuleRegex blocks queries matching the regular expression Pattern anywhere.
+	BlocklistRuleRegex BlocklistRuleType = "regex"
+	// BlocklistRuleProfanity is the type of the bundled profanity list, which is not stored as a rule.
+	BlocklistRuleProfanity BlocklistRuleType = "profanity"
+)
+
+// ProfanityRuleID identifies the bundled profanity list in blocked query counts.
+const ProfanityRuleID = "profanity"
+
+func (t BlocklistRuleType) IsValid() bool {
+	switch t {
+	case BlocklistRuleExact, BlocklistRulePrefix, BlocklistRuleRegex:
+		return true
+	}
+	return false
+}
+
+// BlocklistRule keeps matching queries out of search_logs. Rules are matched against the lowercased, trimmed query.
+type BlocklistRule struct {
+	ID        string            `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID  string            `json:"tenant_id" gorm:"index;not null"`
+	Type      BlocklistRuleType `json:"type" gorm:"not null"`
+	Pattern   string            `json:"pattern" gorm:"not null"`
+	CreatedAt time.Time         `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*BlocklistRule) TableName() string {
+	return "blocklist_rules"
+}
+
+func NewBlocklistRule(ruleType BlocklistRuleType, pattern string) *BlocklistRule {
+	return &BlocklistRule{
+		ID:       uuid.New().String(),
+		TenantID: tenant.DefaultTenantID,
+		Type:     ruleType,
+		Pattern:  pattern,
+	}
+}
+
+func (r *BlocklistRule) BeforeSave(_ *gorm.DB) (err error) {
+	r.Pattern = strings.TrimSpace(r.Pattern)
+	if r.Type != BlocklistRuleRegex {
+		r.Pattern = strings.ToLower(r.Pattern)
+	}
+	return nil
+}
+
+// BlockedQueryCount is the number of queries a blocklist rule kept out of search_logs on one UTC day.
+type BlockedQueryCount struct {
+	TenantID string    `json:"tenant_id" gorm:"primaryKey"`
+	RuleID   string    `json:"rule_id" gorm:"primaryKey"`
+	Day      time.Time `json:"day" gorm:"primaryKey"`
+	Count    int       `json:"count"`
+}
+
+func (*BlockedQueryCount) TableName() string {
+	return "blocked_query_counts"
+}
+
+// BlocklistRuleStats is how many queries a rule blocked within a time window. Pattern is empty for the profanity list.
+type BlocklistRuleStats struct {
+	RuleID  string            `json:"rule_id"`
+	Type    BlocklistRuleType `json:"type"`
+	Pattern string            `json:"pattern,omitempty"`
+	Blocked int               `json:"blocked"`
+}
+
+// BlocklistPurgeResult lists the logged queries deleted because they match the blocklist.
+type BlocklistPurgeResult struct {
+	Purged  int      `json:"purged"`
+	Queries []string `json:"queries"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"search-logger/tenant"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QuarantinedSearch is a finalized query from a client classified as automated. It is kept out of search_logs
+// until an admin releases it.
+type QuarantinedSearch struct {
+	ID               string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID         string     `json:"tenant_id" gorm:"index;not null;default:'default'"`
+	ClientIdentifier string     `json:"client_identifier" gorm:"index"`
+	QueryText        string     `json:"query"`
+	Reason           string     `json:"reason"`
+	UserAgent        string     `json:"user_agent"`
+	ReleasedAt       *time.Time `json:"released_at,omitempty" gorm:"index"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*QuarantinedSearch) TableName() string {
+	return "quarantined_searches"
+}
+
+func NewQuarantinedSearch(clientIdentifier, queryText, reason, userAgent string) *QuarantinedSearch {
+	return &QuarantinedSearch{
+		ID:               uuid.New().String(),
+		TenantID:         tenant.DefaultTenantID,
+		ClientIdentifier: clientIdentifier,
+		QueryText:        queryText,
+		Reason:           reason,
+		UserAgent:        userAgent,
+	}
+}
+
+func (s *QuarantinedSearch) BeforeSave(_ *gorm.DB) (err error) {
+	s.QueryText = strings.ToLower(strings.TrimSpace(s.QueryText))
+	return nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// QueryResultClick aggregates the clicks on one result of a query.
+type QueryResultClick struct {
+	TenantID  string `json:"tenant_id" gorm:"primaryKey"`
+	QueryText string `json:"query" gorm:"primaryKey"`
+	ResultID  string `json:"result_id" gorm:"primaryKey"`
+	Clicks    int    `json:"clicks"`
+	// PositionSum is the sum of the 1-based positions the result was clicked at, so the mean position is PositionSum / Clicks.
+	PositionSum int       `json:"position_sum"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (*QueryResultClick) TableName() string {
+	return "query_result_clicks"
+}
+
+type ResultClickStats struct {
+	ResultID          string  `json:"result_id"`
+	Clicks            int     `json:"clicks"`
+	MeanClickPosition float64 `json:"mean_click_position"`
+}
+
+type QueryClickStats struct {
+	QueryText          string `json:"query"`
+	SearchCount        int    `json:"search_count"`
+	ClickedSearchCount int    `json:"clicked_search_count"`
+	UniqueClients      int64  `json:"unique_clients"`
+	// CTR is the share of searches with at least one click.
+	CTR               float64            `json:"ctr"`
+	Clicks            int                `json:"clicks"`
+	MeanClickPosition float64            `json:"mean_click_position"`
+	Results           []ResultClickStats `json:"results,omitempty"`
+}
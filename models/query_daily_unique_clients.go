@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// QueryDailyUniqueClients is a snapshot of the estimated number of distinct clients that searched a query on one UTC
+// day.
+type QueryDailyUniqueClients struct {
+	TenantID      string    `json:"tenant_id" gorm:"primaryKey"`
+	QueryText     string    `json:"query" gorm:"primaryKey"`
+	Day           time.Time `json:"day" gorm:"primaryKey"`
+	UniqueClients int64     `json:"unique_clients"`
+}
+
+func (*QueryDailyUniqueClients) TableName() string {
+	return "query_daily_unique_clients"
+}
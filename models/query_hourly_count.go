@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// QueryHourlyCount is the number of times a query was counted in search_logs during one UTC hour. Time series by
+// day or week, in any time zone, are rolled up from it.
+type QueryHourlyCount struct {
+	TenantID  string    `json:"tenant_id" gorm:"primaryKey"`
+	QueryText string    `json:"query" gorm:"primaryKey"`
+	Hour      time.Time `json:"hour" gorm:"primaryKey"`
+	Count     int       `json:"count"`
+}
+
+func (*QueryHourlyCount) TableName() string {
+	return "query_hourly_counts"
+}
+
+type TimeSeriesGranularity string
+
+const (
+	TimeSeriesGranularityHour TimeSeriesGranularity = "hour"
+	TimeSeriesGranularityDay  TimeSeriesGranularity = "day"
+	// TimeSeriesGranularityWeek buckets start on Mondays.
+	TimeSeriesGranularityWeek TimeSeriesGranularity = "week"
+)
+
+func (g TimeSeriesGranularity) IsValid() bool {
+	return g == TimeSeriesGranularityHour || g == TimeSeriesGranularityDay || g == TimeSeriesGranularityWeek
+}
+
+// TimeSeriesPoint is the count of a query in the bucket starting at Start. UniqueClients is only set on the buckets of
+// daily series that are UTC days, which is what distinct clients are counted by.
+type TimeSeriesPoint struct {
+	Start         time.Time `json:"start"`
+	Count         int       `json:"count"`
+	UniqueClients *int64    `json:"unique_clients,omitempty"`
+}
+
+// QueryTimeSeries counts a query in every bucket from the one containing From through the one containing To,
+// including empty buckets.
+type QueryTimeSeries struct {
+	QueryText   string                `json:"query"`
+	Granularity TimeSeriesGranularity `json:"granularity"`
+	Timezone    string                `json:"timezone"`
+	From        time.Time             `json:"from"`
+	To          time.Time             `json:"to"`
+	Total       int                   `json:"total"`
+	// UniqueClients is the query's all-time estimated number of distinct clients.
+	UniqueClients int64             `json:"unique_clients"`
+	Points        []TimeSeriesPoint `json:"points"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"search-logger/tenant"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuerySynonym maps a variant of a query, e.g. "tvs", to the canonical query it is counted as, e.g. "tv".
+type QuerySynonym struct {
+	TenantID  string    `json:"tenant_id" gorm:"primaryKey"`
+	Variant   string    `json:"variant" gorm:"primaryKey"`
+	Canonical string    `json:"canonical" gorm:"index;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (*QuerySynonym) TableName() string {
+	return "query_synonyms"
+}
+
+func NewQuerySynonym(variant, canonical string) *QuerySynonym {
+	return &QuerySynonym{
+		TenantID:  tenant.DefaultTenantID,
+		Variant:   variant,
+		Canonical: canonical,
+	}
+}
+
+func (s *QuerySynonym) BeforeSave(_ *gorm.DB) (err error) {
+	s.Variant = strings.ToLower(strings.TrimSpace(s.Variant))
+	s.Canonical = strings.ToLower(strings.TrimSpace(s.Canonical))
+	return nil
+}
+
+// SynonymMergeResult reports the variant rows of search_logs folded into a canonical query by a merge.
+type SynonymMergeResult struct {
+	Canonical      string   `json:"canonical"`
+	MergedVariants []string `json:"merged_variants"`
+	MergedCount    int      `json:"merged_count"`
+	Count          int      `json:"count"`
+}
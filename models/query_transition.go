@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// QueryTransition counts how often a client's finalized query was followed by another one within the same session.
+type QueryTransition struct {
+	TenantID  string    `json:"tenant_id" gorm:"primaryKey"`
+	FromQuery string    `json:"from_query" gorm:"primaryKey"`
+	ToQuery   string    `json:"to_query" gorm:"primaryKey"`
+	Count     int       `json:"count"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (*QueryTransition) TableName() string {
+	return "query_transitions"
+}
+
+type NextQuery struct {
+	QueryText string `json:"query"`
+	Count     int    `json:"count"`
+	// Share is the fraction of the reformulations of the source query that went to this query.
+	Share float64 `json:"share"`
+}
+
+// ReformulationStats describes what clients searched for next after a query. AbandonmentRate is the share of the
+// query's searches that were not followed by another query within the session.
+type ReformulationStats struct {
+	QueryText       string      `json:"query"`
+	SearchCount     int         `json:"search_count"`
+	Reformulations  int         `json:"reformulations"`
+	AbandonmentRate float64     `json:"abandonment_rate"`
+	NextQueries     []NextQuery `json:"next_queries"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// SketchCounter is a query tracked by a Space-Saving sketch. Count overestimates how often the query was finalized
+// by at most Error.
+type SketchCounter struct {
+	QueryText string `json:"q"`
+	Count     int64  `json:"c"`
+	Error     int64  `json:"e"`
+}
+
+// SketchSnapshot is the state of a Space-Saving sketch, as replicas share it through Redis. Total is the number of
+// queries the sketch was fed.
+type SketchSnapshot struct {
+	Capacity int             `json:"capacity"`
+	Total    int64           `json:"total"`
+	Counters []SketchCounter `json:"counters"`
+}
+
+// HeavyHitter is an estimate of how often a query was finalized: at least MinCount and at most Count times.
+type HeavyHitter struct {
+	QueryText string `json:"query"`
+	Count     int64  `json:"count"`
+	MinCount  int64  `json:"min_count"`
+	Error     int64  `json:"error"`
+}
+
+// RealtimeTopQueries ranks the queries finalized from From to To across replicas. Queries not listed were finalized
+// at most ErrorBound times. Partial is set when the sketches of the other replicas could not be read.
+type RealtimeTopQueries struct {
+	Window     string        `json:"window"`
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Total      int64         `json:"total"`
+	ErrorBound int64         `json:"error_bound"`
+	Replicas   int           `json:"replicas"`
+	Partial    bool          `json:"partial,omitempty"`
+	Queries    []HeavyHitter `json:"queries"`
+}
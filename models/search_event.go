@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// SearchEvent is a keystroke accepted by the API and queued until a worker logs it.
+type SearchEvent struct {
+	TenantID         string `json:"tenant_id"`
+	ClientIdentifier string `json:"client_identifier"`
+	QueryText        string `json:"query_text"`
+	ResultCount      *int   `json:"result_count,omitempty"`
+	// Client describes the end user, when the API could tell.
+	Client          *SearchEventClient `json:"client,omitempty"`
+	ClientSequence  *int64             `json:"client_sequence,omitempty"`
+	ClientTimestamp *time.Time         `json:"client_timestamp,omitempty"`
+	// ReceivedAt is when the API accepted the keystroke, which orders it when the client did not.
+	ReceivedAt time.Time `json:"received_at"`
+	// RequestID and TraceParent correlate the logs of the worker logging the keystroke with the request that sent it.
+	RequestID   string `json:"request_id,omitempty"`
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+type SearchEventClient struct {
+	UserAgent string `json:"user_agent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"search-logger/tenant"
 	"strings"
 	"time"
 
@@ -9,11 +10,18 @@ import (
 )
 
 type SearchLog struct {
-	ID        string    `json:"id" gorm:"type:uuid;primaryKey"`
-	QueryText string    `json:"query" gorm:"unique"`
-	Count     int       `json:"count"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                 string `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID           string `json:"tenant_id" gorm:"uniqueIndex:idx_search_logs_tenant_query;not null;default:'default'"`
+	QueryText          string `json:"query" gorm:"uniqueIndex:idx_search_logs_tenant_query"`
+	Count              int    `json:"count"`
+	ZeroResultCount    int    `json:"zero_result_count" gorm:"not null;default:0"`
+	ClickedSearchCount int    `json:"clicked_search_count" gorm:"not null;default:0"`
+	// UniqueClients is the estimated number of distinct clients that searched the query, as last snapshotted.
+	UniqueClients int64     `json:"unique_clients" gorm:"not null;default:0"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	// PreviousCount is Count before the increment that returned the search log. It is not stored.
+	PreviousCount int `json:"-" gorm:"-"`
 }
 
 func (*SearchLog) TableName() string {
@@ -23,6 +31,7 @@ func (*SearchLog) TableName() string {
 func NewSearchLog(queryText string, count int) *SearchLog {
 	return &SearchLog{
 		ID:        uuid.New().String(),
+		TenantID:  tenant.DefaultTenantID,
 		QueryText: queryText,
 		Count:     count,
 	}
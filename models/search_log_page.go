@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type SearchLogSort string
+
+const (
+	// SearchLogSortCount lists the most searched queries first.
+	SearchLogSortCount SearchLogSort = "count"
+	// SearchLogSortUpdatedAt lists the most recently searched queries first.
+	SearchLogSortUpdatedAt SearchLogSort = "updated_at"
+	// SearchLogSortQuery lists queries alphabetically.
+	SearchLogSortQuery SearchLogSort = "query"
+)
+
+func (s SearchLogSort) IsValid() bool {
+	switch s {
+	case SearchLogSortCount, SearchLogSortUpdatedAt, SearchLogSortQuery:
+		return true
+	}
+	return false
+}
+
+// SearchLogFilter selects a page of search logs. Zero values do not filter. Cursor is a NextCursor or PrevCursor of
+// a previous page listed with the same filter and sort.
+type SearchLogFilter struct {
+	Contains     string
+	MinCount     int
+	UpdatedAfter time.Time
+	Sort         SearchLogSort
+	Cursor       string
+	Limit        int
+}
+
+// SearchLogPage is a page of search logs. TotalEstimate counts every search log matching the filter, but stops
+// counting at a cap, in which case TotalIsExact is false.
+type SearchLogPage struct {
+	SearchLogs    []SearchLog `json:"search_logs"`
+	TotalEstimate int64       `json:"total_estimate"`
+	TotalIsExact  bool        `json:"total_is_exact"`
+	NextCursor    string      `json:"next_cursor,omitempty"`
+	PrevCursor    string      `json:"prev_cursor,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// SpellingSuggestion is a logged query close to a misspelled one. Distance is the number of insertions, deletions,
+// substitutions or transpositions between them and Count is how often the suggestion was searched.
+type SpellingSuggestion struct {
+	QueryText string `json:"query"`
+	Distance  int    `json:"distance"`
+	Count     int    `json:"count"`
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"search-logger/tenant"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookRuleType string
+
+const (
+	// WebhookRuleFirstSeen fires the first time a query is persisted.
+	WebhookRuleFirstSeen WebhookRuleType = "first_seen"
+	// WebhookRuleCountThreshold fires when a query's total count reaches Threshold.
+	WebhookRuleCountThreshold WebhookRuleType = "count_threshold"
+	// WebhookRuleRateSpike fires when a query's searches in the current hour reach SpikeFactor times the previous hour.
+	WebhookRuleRateSpike WebhookRuleType = "rate_spike"
+)
+
+func (t WebhookRuleType) IsValid() bool {
+	switch t {
+	case WebhookRuleFirstSeen, WebhookRuleCountThreshold, WebhookRuleRateSpike:
+		return true
+	}
+	return false
+}
+
+type WebhookEndpoint struct {
+	ID        string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID  string    `json:"tenant_id" gorm:"index;not null;default:'default'"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"-" gorm:"not null"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (*WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+func NewWebhookEndpoint(url, secret string) *WebhookEndpoint {
+	return &WebhookEndpoint{
+		ID:       uuid.New().String(),
+		TenantID: tenant.DefaultTenantID,
+		URL:      url,
+		Secret:   secret,
+		Active:   true,
+	}
+}
+
+type WebhookRule struct {
+	ID         string          `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID   string          `json:"tenant_id" gorm:"index;not null;default:'default'"`
+	EndpointID string          `json:"endpoint_id" gorm:"type:uuid;index;not null"`
+	Type       WebhookRuleType `json:"type" gorm:"not null"`
+	// Threshold is the total count a query must reach for count_threshold rules.
+	Threshold int `json:"threshold,omitempty"`
+	// SpikeFactor is the multiple of the previous hour's searches that the current hour must reach for rate_spike rules.
+	SpikeFactor float64   `json:"spike_factor,omitempty"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*WebhookRule) TableName() string {
+	return "webhook_rules"
+}
+
+func NewWebhookRule(endpointID string, ruleType WebhookRuleType, threshold int, spikeFactor float64) *WebhookRule {
+	return &WebhookRule{
+		ID:          uuid.New().String(),
+		TenantID:    tenant.DefaultTenantID,
+		EndpointID:  endpointID,
+		Type:        ruleType,
+		Threshold:   threshold,
+		SpikeFactor: spikeFactor,
+	}
+}
+
+// WebhookDelivery records a single delivery attempt of a webhook event.
+type WebhookDelivery struct {
+	ID         string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID   string    `json:"tenant_id" gorm:"index;not null;default:'default'"`
+	EndpointID string    `json:"endpoint_id" gorm:"type:uuid;index;not null"`
+	RuleID     string    `json:"rule_id" gorm:"type:uuid"`
+	EventID    string    `json:"event_id" gorm:"type:uuid;index"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Payload    string    `json:"payload"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+func NewWebhookDelivery(endpointID, ruleID, eventID string, attempt int, payload string) *WebhookDelivery {
+	return &WebhookDelivery{
+		ID:         uuid.New().String(),
+		TenantID:   tenant.DefaultTenantID,
+		EndpointID: endpointID,
+		RuleID:     ruleID,
+		EventID:    eventID,
+		Attempt:    attempt,
+		Payload:    payload,
+	}
+}
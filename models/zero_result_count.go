@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// ZeroResultCount is the number of zero-result searches for a query on one UTC day, used to rank
+// zero-result queries over a time window.
+type ZeroResultCount struct {
+	TenantID  string    `json:"tenant_id" gorm:"primaryKey"`
+	QueryText string    `json:"query" gorm:"primaryKey"`
+	Day       time.Time `json:"day" gorm:"primaryKey"`
+	Count     int       `json:"count"`
+}
+
+func (*ZeroResultCount) TableName() string {
+	return "zero_result_counts"
+}
+
+// ZeroResultQuery is a query ranked by how often it returned no results within a time window.
+type ZeroResultQuery struct {
+	QueryText       string `json:"query"`
+	ZeroResultCount int    `json:"zero_result_count"`
+	// SearchCount is the query's all-time count in search_logs.
+	SearchCount int `json:"search_count"`
+	// UniqueClients is the query's all-time estimated number of distinct clients.
+	UniqueClients int64 `json:"unique_clients"`
+}
@@ -0,0 +1,555 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: searchlogger/v1/search_logger.proto
+
+package searchloggerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LogSearchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// client_id identifies the end user searching, e.g. a user or session ID. It groups the client's keystrokes.
+	ClientId    string `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	QueryText   string `protobuf:"bytes,2,opt,name=query_text,json=queryText,proto3" json:"query_text,omitempty"`
+	ResultCount *int32 `protobuf:"varint,3,opt,name=result_count,json=resultCount,proto3,oneof" json:"result_count,omitempty"`
+	// user_agent and ip describe the end user, for bot filtering.
+	UserAgent string `protobuf:"bytes,4,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	Ip        string `protobuf:"bytes,5,opt,name=ip,proto3" json:"ip,omitempty"`
+	// client_sequence and client_timestamp order the client's keystrokes as it typed them. client_sequence must
+	// increase with every keystroke of the client.
+	ClientSequence  *int64                 `protobuf:"varint,6,opt,name=client_sequence,json=clientSequence,proto3,oneof" json:"client_sequence,omitempty"`
+	ClientTimestamp *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=client_timestamp,json=clientTimestamp,proto3" json:"client_timestamp,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *LogSearchRequest) Reset() {
+	*x = LogSearchRequest{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogSearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogSearchRequest) ProtoMessage() {}
+
+func (x *LogSearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogSearchRequest.ProtoReflect.Descriptor instead.
+func (*LogSearchRequest) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LogSearchRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *LogSearchRequest) GetQueryText() string {
+	if x != nil {
+		return x.QueryText
+	}
+	return ""
+}
+
+func (x *LogSearchRequest) GetResultCount() int32 {
+	if x != nil && x.ResultCount != nil {
+		return *x.ResultCount
+	}
+	return 0
+}
+
+func (x *LogSearchRequest) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *LogSearchRequest) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *LogSearchRequest) GetClientSequence() int64 {
+	if x != nil && x.ClientSequence != nil {
+		return *x.ClientSequence
+	}
+	return 0
+}
+
+func (x *LogSearchRequest) GetClientTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ClientTimestamp
+	}
+	return nil
+}
+
+type LogSearchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogSearchResponse) Reset() {
+	*x = LogSearchResponse{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogSearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogSearchResponse) ProtoMessage() {}
+
+func (x *LogSearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogSearchResponse.ProtoReflect.Descriptor instead.
+func (*LogSearchResponse) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{1}
+}
+
+type LogSearchBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      int32                  `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Rejected      int32                  `protobuf:"varint,2,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogSearchBatchResponse) Reset() {
+	*x = LogSearchBatchResponse{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogSearchBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogSearchBatchResponse) ProtoMessage() {}
+
+func (x *LogSearchBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogSearchBatchResponse.ProtoReflect.Descriptor instead.
+func (*LogSearchBatchResponse) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LogSearchBatchResponse) GetAccepted() int32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *LogSearchBatchResponse) GetRejected() int32 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+type GetCountRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	QueryText     string                 `protobuf:"bytes,1,opt,name=query_text,json=queryText,proto3" json:"query_text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCountRequest) Reset() {
+	*x = GetCountRequest{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCountRequest) ProtoMessage() {}
+
+func (x *GetCountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCountRequest.ProtoReflect.Descriptor instead.
+func (*GetCountRequest) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetCountRequest) GetQueryText() string {
+	if x != nil {
+		return x.QueryText
+	}
+	return ""
+}
+
+type GetCountResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	QueryText     string                 `protobuf:"bytes,1,opt,name=query_text,json=queryText,proto3" json:"query_text,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCountResponse) Reset() {
+	*x = GetCountResponse{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCountResponse) ProtoMessage() {}
+
+func (x *GetCountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCountResponse.ProtoReflect.Descriptor instead.
+func (*GetCountResponse) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetCountResponse) GetQueryText() string {
+	if x != nil {
+		return x.QueryText
+	}
+	return ""
+}
+
+func (x *GetCountResponse) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type TopQueriesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// limit defaults to 10 and cannot exceed 1000.
+	Limit         int32 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TopQueriesRequest) Reset() {
+	*x = TopQueriesRequest{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TopQueriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopQueriesRequest) ProtoMessage() {}
+
+func (x *TopQueriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopQueriesRequest.ProtoReflect.Descriptor instead.
+func (*TopQueriesRequest) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TopQueriesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type TopQueriesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Queries       []*QueryCount          `protobuf:"bytes,1,rep,name=queries,proto3" json:"queries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TopQueriesResponse) Reset() {
+	*x = TopQueriesResponse{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TopQueriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopQueriesResponse) ProtoMessage() {}
+
+func (x *TopQueriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopQueriesResponse.ProtoReflect.Descriptor instead.
+func (*TopQueriesResponse) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TopQueriesResponse) GetQueries() []*QueryCount {
+	if x != nil {
+		return x.Queries
+	}
+	return nil
+}
+
+type QueryCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	QueryText     string                 `protobuf:"bytes,1,opt,name=query_text,json=queryText,proto3" json:"query_text,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryCount) Reset() {
+	*x = QueryCount{}
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryCount) ProtoMessage() {}
+
+func (x *QueryCount) ProtoReflect() protoreflect.Message {
+	mi := &file_searchlogger_v1_search_logger_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryCount.ProtoReflect.Descriptor instead.
+func (*QueryCount) Descriptor() ([]byte, []int) {
+	return file_searchlogger_v1_search_logger_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *QueryCount) GetQueryText() string {
+	if x != nil {
+		return x.QueryText
+	}
+	return ""
+}
+
+func (x *QueryCount) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+var File_searchlogger_v1_search_logger_proto protoreflect.FileDescriptor
+
+const file_searchlogger_v1_search_logger_proto_rawDesc = "" +
+	"\n" +
+	"#searchlogger/v1/search_logger.proto\x12\x0fsearchlogger.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xbf\x02\n" +
+	"\x10LogSearchRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1d\n" +
+	"\n" +
+	"query_text\x18\x02 \x01(\tR\tqueryText\x12&\n" +
+	"\fresult_count\x18\x03 \x01(\x05H\x00R\vresultCount\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\x04 \x01(\tR\tuserAgent\x12\x0e\n" +
+	"\x02ip\x18\x05 \x01(\tR\x02ip\x12,\n" +
+	"\x0fclient_sequence\x18\x06 \x01(\x03H\x01R\x0eclientSequence\x88\x01\x01\x12E\n" +
+	"\x10client_timestamp\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x0fclientTimestampB\x0f\n" +
+	"\r_result_countB\x12\n" +
+	"\x10_client_sequence\"\x13\n" +
+	"\x11LogSearchResponse\"P\n" +
+	"\x16LogSearchBatchResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x05R\baccepted\x12\x1a\n" +
+	"\brejected\x18\x02 \x01(\x05R\brejected\"0\n" +
+	"\x0fGetCountRequest\x12\x1d\n" +
+	"\n" +
+	"query_text\x18\x01 \x01(\tR\tqueryText\"G\n" +
+	"\x10GetCountResponse\x12\x1d\n" +
+	"\n" +
+	"query_text\x18\x01 \x01(\tR\tqueryText\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\")\n" +
+	"\x11TopQueriesRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\"K\n" +
+	"\x12TopQueriesResponse\x125\n" +
+	"\aqueries\x18\x01 \x03(\v2\x1b.searchlogger.v1.QueryCountR\aqueries\"A\n" +
+	"\n" +
+	"QueryCount\x12\x1d\n" +
+	"\n" +
+	"query_text\x18\x01 \x01(\tR\tqueryText\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count2\xea\x02\n" +
+	"\fSearchLogger\x12R\n" +
+	"\tLogSearch\x12!.searchlogger.v1.LogSearchRequest\x1a\".searchlogger.v1.LogSearchResponse\x12^\n" +
+	"\x0eLogSearchBatch\x12!.searchlogger.v1.LogSearchRequest\x1a'.searchlogger.v1.LogSearchBatchResponse(\x01\x12O\n" +
+	"\bGetCount\x12 .searchlogger.v1.GetCountRequest\x1a!.searchlogger.v1.GetCountResponse\x12U\n" +
+	"\n" +
+	"TopQueries\x12\".searchlogger.v1.TopQueriesRequest\x1a#.searchlogger.v1.TopQueriesResponseB4Z2search-logger/proto/searchlogger/v1;searchloggerv1b\x06proto3"
+
+var (
+	file_searchlogger_v1_search_logger_proto_rawDescOnce sync.Once
+	file_searchlogger_v1_search_logger_proto_rawDescData []byte
+)
+
+func file_searchlogger_v1_search_logger_proto_rawDescGZIP() []byte {
+	file_searchlogger_v1_search_logger_proto_rawDescOnce.Do(func() {
+		file_searchlogger_v1_search_logger_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_searchlogger_v1_search_logger_proto_rawDesc), len(file_searchlogger_v1_search_logger_proto_rawDesc)))
+	})
+	return file_searchlogger_v1_search_logger_proto_rawDescData
+}
+
+var file_searchlogger_v1_search_logger_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_searchlogger_v1_search_logger_proto_goTypes = []any{
+	(*LogSearchRequest)(nil),       // 0: searchlogger.v1.LogSearchRequest
+	(*LogSearchResponse)(nil),      // 1: searchlogger.v1.LogSearchResponse
+	(*LogSearchBatchResponse)(nil), // 2: searchlogger.v1.LogSearchBatchResponse
+	(*GetCountRequest)(nil),        // 3: searchlogger.v1.GetCountRequest
+	(*GetCountResponse)(nil),       // 4: searchlogger.v1.GetCountResponse
+	(*TopQueriesRequest)(nil),      // 5: searchlogger.v1.TopQueriesRequest
+	(*TopQueriesResponse)(nil),     // 6: searchlogger.v1.TopQueriesResponse
+	(*QueryCount)(nil),             // 7: searchlogger.v1.QueryCount
+	(*timestamppb.Timestamp)(nil),  // 8: google.protobuf.Timestamp
+}
+var file_searchlogger_v1_search_logger_proto_depIdxs = []int32{
+	8, // 0: searchlogger.v1.LogSearchRequest.client_timestamp:type_name -> google.protobuf.Timestamp
+	7, // 1: searchlogger.v1.TopQueriesResponse.queries:type_name -> searchlogger.v1.QueryCount
+	0, // 2: searchlogger.v1.SearchLogger.LogSearch:input_type -> searchlogger.v1.LogSearchRequest
+	0, // 3: searchlogger.v1.SearchLogger.LogSearchBatch:input_type -> searchlogger.v1.LogSearchRequest
+	3, // 4: searchlogger.v1.SearchLogger.GetCount:input_type -> searchlogger.v1.GetCountRequest
+	5, // 5: searchlogger.v1.SearchLogger.TopQueries:input_type -> searchlogger.v1.TopQueriesRequest
+	1, // 6: searchlogger.v1.SearchLogger.LogSearch:output_type -> searchlogger.v1.LogSearchResponse
+	2, // 7: searchlogger.v1.SearchLogger.LogSearchBatch:output_type -> searchlogger.v1.LogSearchBatchResponse
+	4, // 8: searchlogger.v1.SearchLogger.GetCount:output_type -> searchlogger.v1.GetCountResponse
+	6, // 9: searchlogger.v1.SearchLogger.TopQueries:output_type -> searchlogger.v1.TopQueriesResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_searchlogger_v1_search_logger_proto_init() }
+func file_searchlogger_v1_search_logger_proto_init() {
+	if File_searchlogger_v1_search_logger_proto != nil {
+		return
+	}
+	file_searchlogger_v1_search_logger_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_searchlogger_v1_search_logger_proto_rawDesc), len(file_searchlogger_v1_search_logger_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_searchlogger_v1_search_logger_proto_goTypes,
+		DependencyIndexes: file_searchlogger_v1_search_logger_proto_depIdxs,
+		MessageInfos:      file_searchlogger_v1_search_logger_proto_msgTypes,
+	}.Build()
+	File_searchlogger_v1_search_logger_proto = out.File
+	file_searchlogger_v1_search_logger_proto_goTypes = nil
+	file_searchlogger_v1_search_logger_proto_depIdxs = nil
+}
@@ -0,0 +1,273 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: searchlogger/v1/search_logger.proto
+
+package searchloggerv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	SearchLogger_LogSearch_FullMethodName      = "/searchlogger.v1.SearchLogger/LogSearch"
+	SearchLogger_LogSearchBatch_FullMethodName = "/searchlogger.v1.SearchLogger/LogSearchBatch"
+	SearchLogger_GetCount_FullMethodName       = "/searchlogger.v1.SearchLogger/GetCount"
+	SearchLogger_TopQueries_FullMethodName     = "/searchlogger.v1.SearchLogger/TopQueries"
+)
+
+// SearchLoggerClient is the client API for SearchLogger service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SearchLogger is the gRPC counterpart of the HTTP search API. The tenant is selected with the same header as over
+// HTTP, sent as metadata, or with a bearer token in the "authorization" metadata.
+type SearchLoggerClient interface {
+	// LogSearch records a keystroke of a client's search. Like POST /search, it returns before the query is debounced.
+	LogSearch(ctx context.Context, in *LogSearchRequest, opts ...grpc.CallOption) (*LogSearchResponse, error)
+	// LogSearchBatch records a stream of searches, possibly from many clients, and reports how many were accepted
+	// once the client closes the stream.
+	LogSearchBatch(ctx context.Context, opts ...grpc.CallOption) (SearchLogger_LogSearchBatchClient, error)
+	GetCount(ctx context.Context, in *GetCountRequest, opts ...grpc.CallOption) (*GetCountResponse, error)
+	// TopQueries ranks queries by their all-time count.
+	TopQueries(ctx context.Context, in *TopQueriesRequest, opts ...grpc.CallOption) (*TopQueriesResponse, error)
+}
+
+type searchLoggerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSearchLoggerClient(cc grpc.ClientConnInterface) SearchLoggerClient {
+	return &searchLoggerClient{cc}
+}
+
+func (c *searchLoggerClient) LogSearch(ctx context.Context, in *LogSearchRequest, opts ...grpc.CallOption) (*LogSearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LogSearchResponse)
+	err := c.cc.Invoke(ctx, SearchLogger_LogSearch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *searchLoggerClient) LogSearchBatch(ctx context.Context, opts ...grpc.CallOption) (SearchLogger_LogSearchBatchClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SearchLogger_ServiceDesc.Streams[0], SearchLogger_LogSearchBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &searchLoggerLogSearchBatchClient{ClientStream: stream}
+	return x, nil
+}
+
+type SearchLogger_LogSearchBatchClient interface {
+	Send(*LogSearchRequest) error
+	CloseAndRecv() (*LogSearchBatchResponse, error)
+	grpc.ClientStream
+}
+
+type searchLoggerLogSearchBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *searchLoggerLogSearchBatchClient) Send(m *LogSearchRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *searchLoggerLogSearchBatchClient) CloseAndRecv() (*LogSearchBatchResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(LogSearchBatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *searchLoggerClient) GetCount(ctx context.Context, in *GetCountRequest, opts ...grpc.CallOption) (*GetCountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCountResponse)
+	err := c.cc.Invoke(ctx, SearchLogger_GetCount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *searchLoggerClient) TopQueries(ctx context.Context, in *TopQueriesRequest, opts ...grpc.CallOption) (*TopQueriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TopQueriesResponse)
+	err := c.cc.Invoke(ctx, SearchLogger_TopQueries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchLoggerServer is the server API for SearchLogger service.
+// All implementations must embed UnimplementedSearchLoggerServer
+// for forward compatibility
+//
+// SearchLogger is the gRPC counterpart of the HTTP search API. The tenant is selected with the same header as over
+// HTTP, sent as metadata, or with a bearer token in the "authorization" metadata.
+type SearchLoggerServer interface {
+	// LogSearch records a keystroke of a client's search. Like POST /search, it returns before the query is debounced.
+	LogSearch(context.Context, *LogSearchRequest) (*LogSearchResponse, error)
+	// LogSearchBatch records a stream of searches, possibly from many clients, and reports how many were accepted
+	// once the client closes the stream.
+	LogSearchBatch(SearchLogger_LogSearchBatchServer) error
+	GetCount(context.Context, *GetCountRequest) (*GetCountResponse, error)
+	// TopQueries ranks queries by their all-time count.
+	TopQueries(context.Context, *TopQueriesRequest) (*TopQueriesResponse, error)
+	mustEmbedUnimplementedSearchLoggerServer()
+}
+
+// UnimplementedSearchLoggerServer must be embedded to have forward compatible implementations.
+type UnimplementedSearchLoggerServer struct {
+}
+
+func (UnimplementedSearchLoggerServer) LogSearch(context.Context, *LogSearchRequest) (*LogSearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LogSearch not implemented")
+}
+func (UnimplementedSearchLoggerServer) LogSearchBatch(SearchLogger_LogSearchBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method LogSearchBatch not implemented")
+}
+func (UnimplementedSearchLoggerServer) GetCount(context.Context, *GetCountRequest) (*GetCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCount not implemented")
+}
+func (UnimplementedSearchLoggerServer) TopQueries(context.Context, *TopQueriesRequest) (*TopQueriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TopQueries not implemented")
+}
+func (UnimplementedSearchLoggerServer) mustEmbedUnimplementedSearchLoggerServer() {}
+
+// UnsafeSearchLoggerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SearchLoggerServer will
+// result in compilation errors.
+type UnsafeSearchLoggerServer interface {
+	mustEmbedUnimplementedSearchLoggerServer()
+}
+
+func RegisterSearchLoggerServer(s grpc.ServiceRegistrar, srv SearchLoggerServer) {
+	s.RegisterService(&SearchLogger_ServiceDesc, srv)
+}
+
+func _SearchLogger_LogSearch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogSearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchLoggerServer).LogSearch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchLogger_LogSearch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchLoggerServer).LogSearch(ctx, req.(*LogSearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SearchLogger_LogSearchBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SearchLoggerServer).LogSearchBatch(&searchLoggerLogSearchBatchServer{ServerStream: stream})
+}
+
+type SearchLogger_LogSearchBatchServer interface {
+	SendAndClose(*LogSearchBatchResponse) error
+	Recv() (*LogSearchRequest, error)
+	grpc.ServerStream
+}
+
+type searchLoggerLogSearchBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *searchLoggerLogSearchBatchServer) SendAndClose(m *LogSearchBatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *searchLoggerLogSearchBatchServer) Recv() (*LogSearchRequest, error) {
+	m := new(LogSearchRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _SearchLogger_GetCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchLoggerServer).GetCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchLogger_GetCount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchLoggerServer).GetCount(ctx, req.(*GetCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SearchLogger_TopQueries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopQueriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchLoggerServer).TopQueries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchLogger_TopQueries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchLoggerServer).TopQueries(ctx, req.(*TopQueriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SearchLogger_ServiceDesc is the grpc.ServiceDesc for SearchLogger service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SearchLogger_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "searchlogger.v1.SearchLogger",
+	HandlerType: (*SearchLoggerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "LogSearch",
+			Handler:    _SearchLogger_LogSearch_Handler,
+		},
+		{
+			MethodName: "GetCount",
+			Handler:    _SearchLogger_GetCount_Handler,
+		},
+		{
+			MethodName: "TopQueries",
+			Handler:    _SearchLogger_TopQueries_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "LogSearchBatch",
+			Handler:       _SearchLogger_LogSearchBatch_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "searchlogger/v1/search_logger.proto",
+}
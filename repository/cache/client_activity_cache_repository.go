@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"search-logger/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	clientVelocityKeyPrefix = "client_velocity"
+	clientDistinctKeyPrefix = "client_distinct"
+	clientFlagKeyPrefix     = "client_flag"
+)
+
+// ClientActivityCacheRepository tracks per-client search activity used to classify automated traffic.
+// Keys are scoped to the tenant on the context.
+type ClientActivityCacheRepository interface {
+	// IncrementVelocity counts a finalized query for the client and returns how many it finalized in the current window.
+	IncrementVelocity(ctx context.Context, clientIdentifier string, window time.Duration) (int64, error)
+	// AddDistinctQuery records a finalized query and returns how many distinct queries the client finalized within window.
+	AddDistinctQuery(ctx context.Context, clientIdentifier, queryText string, window time.Duration) (int64, error)
+	// Flag marks the client as automated for ttl, remembering why.
+	Flag(ctx context.Context, clientIdentifier, reason string, ttl time.Duration) error
+	// GetFlag returns the reason a client was flagged, or an empty string when it is not flagged.
+	GetFlag(ctx context.Context, clientIdentifier string) (string, error)
+	// Unflag clears the client's flag and recorded activity, so it is classified afresh.
+	Unflag(ctx context.Context, clientIdentifier string) error
+}
+
+type clientActivityCacheRepository struct {
+	cache *redis.Client
+}
+
+func NewClientActivityCacheRepository(cache *redis.Client) ClientActivityCacheRepository {
+	return &clientActivityCacheRepository{cache: cache}
+}
+
+func (c clientActivityCacheRepository) IncrementVelocity(ctx context.Context, clientIdentifier string, window time.Duration) (int64, error) {
+	key := tenant.Key(ctx, clientVelocityKeyPrefix+":"+clientIdentifier)
+	pipe := c.cache.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	// NX keeps the window fixed from the first query instead of sliding it forward on every query.
+	pipe.ExpireNX(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (c clientActivityCacheRepository) AddDistinctQuery(ctx context.Context, clientIdentifier, queryText string, window time.Duration) (int64, error) {
+	if queryText == "" {
+		return 0, errors.New("query text cannot be empty")
+	}
+
+	key := tenant.Key(ctx, clientDistinctKeyPrefix+":"+clientIdentifier)
+	pipe := c.cache.TxPipeline()
+	pipe.SAdd(ctx, key, queryText)
+	pipe.ExpireNX(ctx, key, window)
+	card := pipe.SCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return card.Val(), nil
+}
+
+func (c clientActivityCacheRepository) Flag(ctx context.Context, clientIdentifier, reason string, ttl time.Duration) error {
+	return c.cache.Set(ctx, tenant.Key(ctx, clientFlagKeyPrefix+":"+clientIdentifier), reason, ttl).Err()
+}
+
+func (c clientActivityCacheRepository) GetFlag(ctx context.Context, clientIdentifier string) (string, error) {
+	reason, err := c.cache.Get(ctx, tenant.Key(ctx, clientFlagKeyPrefix+":"+clientIdentifier)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", err
+	}
+	return reason, nil
+}
+
+func (c clientActivityCacheRepository) Unflag(ctx context.Context, clientIdentifier string) error {
+	return c.cache.Del(ctx,
+		tenant.Key(ctx, clientFlagKeyPrefix+":"+clientIdentifier),
+		tenant.Key(ctx, clientVelocityKeyPrefix+":"+clientIdentifier),
+		tenant.Key(ctx, clientDistinctKeyPrefix+":"+clientIdentifier),
+	).Err()
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientActivityCacheRepository(t *testing.T) {
+	repo := NewClientActivityCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+
+	t.Run("Velocity counts finalized queries per client", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			count, err := repo.IncrementVelocity(ctx, "velocity-client", time.Minute)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(i), count)
+		}
+
+		count, err := repo.IncrementVelocity(ctx, "other-client", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Distinct queries ignore repeats", func(t *testing.T) {
+		for _, queryText := range []string{"a", "b", "a", "c", "b"} {
+			_, err := repo.AddDistinctQuery(ctx, "distinct-client", queryText, time.Minute)
+			assert.NoError(t, err)
+		}
+
+		count, err := repo.AddDistinctQuery(ctx, "distinct-client", "a", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("Flag and read back the reason", func(t *testing.T) {
+		reason, err := repo.GetFlag(ctx, "flagged-client")
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+
+		assert.NoError(t, repo.Flag(ctx, "flagged-client", "velocity", time.Minute))
+
+		reason, err = repo.GetFlag(ctx, "flagged-client")
+		assert.NoError(t, err)
+		assert.Equal(t, "velocity", reason)
+	})
+
+	t.Run("Unflag clears the flag and activity", func(t *testing.T) {
+		assert.NoError(t, repo.Flag(ctx, "released-client", "velocity", time.Minute))
+		_, err := repo.IncrementVelocity(ctx, "released-client", time.Minute)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.Unflag(ctx, "released-client"))
+
+		reason, err := repo.GetFlag(ctx, "released-client")
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+		count, err := repo.IncrementVelocity(ctx, "released-client", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -5,14 +5,50 @@ import (
 	"encoding/json"
 	"errors"
 	"search-logger/config"
+	"search-logger/tenant"
+	"search-logger/tracing"
 	"strings"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// setAttempts bounds how often SetIfNewer and SetResultCount retry when the key keeps changing under them.
+const setAttempts = 3
+
+// finalizeScript atomically deletes the client query at KEYS[1] if it is still the keystroke identified by ARGV
+// (query text, creation time in milliseconds) and that keystroke was not finalized before. The finalized value is kept
+// at KEYS[2] for ARGV[3] milliseconds, both to finalize each keystroke once and so that keystrokes arriving after it
+// out of order are not taken for new searches. Returns the deleted value, or nil.
+var finalizeScript = redis.NewScript(`
+local function is_keystroke(value)
+	local decoded = cjson.decode(value)
+	return decoded["query_text"] == ARGV[1] and decoded["created_at_unix_ms"] == tonumber(ARGV[2])
+end
+
+local finalized = redis.call("GET", KEYS[2])
+if finalized and is_keystroke(finalized) then
+	return false
+end
+
+local value = redis.call("GET", KEYS[1])
+if not value or not is_keystroke(value) then
+	return false
+end
+
+redis.call("DEL", KEYS[1])
+redis.call("SET", KEYS[2], value, "PX", ARGV[3])
+return value
+`)
+
 type ClientQueryValue struct {
 	QueryText                 string `json:"query_text"`
 	CreatedAtUnixMilliseconds int64  `json:"created_at_unix_ms"`
+	// ResultCount is the number of results the search returned, when the caller reported it.
+	ResultCount *int `json:"result_count,omitempty"`
+	// Sequence and ClientTimestampUnixMilliseconds order the client's keystrokes as the client sent them, when it
+	// supplied them. Server time is only used when they are absent.
+	Sequence                        *int64 `json:"sequence,omitempty"`
+	ClientTimestampUnixMilliseconds *int64 `json:"client_ts_unix_ms,omitempty"`
 }
 
 func NewClientQueryValue(queryText string, createdAtUnixMilli int64) *ClientQueryValue {
@@ -22,10 +58,45 @@ func NewClientQueryValue(queryText string, createdAtUnixMilli int64) *ClientQuer
 	}
 }
 
+// IsNewerThan tells whether v is a later keystroke than other. Client sequence numbers are compared when both have one,
+// then client timestamps, and otherwise the server times, where a tie goes to v as the later write.
+func (v *ClientQueryValue) IsNewerThan(other *ClientQueryValue) bool {
+	if other == nil {
+		return true
+	}
+	if v.Sequence != nil && other.Sequence != nil {
+		// An equal sequence number is the same keystroke sent twice, e.g. a retried request.
+		return *v.Sequence > *other.Sequence
+	}
+	return v.sentAfter(other)
+}
+
+// sentAfter compares the client timestamps of v and other when both have one, and otherwise the server times, where a
+// tie goes to v as the later write. Unlike sequence numbers, timestamps keep increasing when the client starts a new
+// sequence, e.g. after a page reload.
+func (v *ClientQueryValue) sentAfter(other *ClientQueryValue) bool {
+	if v.ClientTimestampUnixMilliseconds != nil && other.ClientTimestampUnixMilliseconds != nil &&
+		*v.ClientTimestampUnixMilliseconds != *other.ClientTimestampUnixMilliseconds {
+		return *v.ClientTimestampUnixMilliseconds > *other.ClientTimestampUnixMilliseconds
+	}
+	return v.CreatedAtUnixMilliseconds >= other.CreatedAtUnixMilliseconds
+}
+
+// LatestClientQueryCacheRepository keys are scoped to the tenant on the context.
 type LatestClientQueryCacheRepository interface {
 	Get(ctx context.Context, key string) (*ClientQueryValue, error)
 	Set(ctx context.Context, key string, value *ClientQueryValue) error
+	// SetIfNewer writes value only if it is newer than the client query at key, see ClientQueryValue.IsNewerThan,
+	// and reports whether it did.
+	SetIfNewer(ctx context.Context, key string, value *ClientQueryValue) (bool, error)
 	Delete(ctx context.Context, key string) error
+	// Finalize removes and returns the client query at key if it is still value's keystroke, so that exactly one
+	// debounce of a keystroke, on any replica, can count it. It returns nil when a newer keystroke replaced it or
+	// the keystroke was already finalized.
+	Finalize(ctx context.Context, key string, value *ClientQueryValue) (*ClientQueryValue, error)
+	// SetResultCount attaches a result count to the client query at key if it is still queryText, and reports
+	// whether it did.
+	SetResultCount(ctx context.Context, key, queryText string, resultCount int) (bool, error)
 }
 
 type latestClientQueryCacheRepository struct {
@@ -36,8 +107,11 @@ func NewLatestClientQueryCacheRepository(cache *redis.Client) LatestClientQueryC
 	return &latestClientQueryCacheRepository{cache: cache}
 }
 
-func (c latestClientQueryCacheRepository) Get(ctx context.Context, key string) (*ClientQueryValue, error) {
-	value, err := c.cache.Get(ctx, key).Result()
+func (c latestClientQueryCacheRepository) Get(ctx context.Context, key string) (_ *ClientQueryValue, err error) {
+	ctx, endSpan := startSpan(ctx, "Get")
+	defer func() { endSpan(err) }()
+
+	value, err := c.cache.Get(ctx, tenant.Key(ctx, key)).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, nil
@@ -53,13 +127,16 @@ func (c latestClientQueryCacheRepository) Get(ctx context.Context, key string) (
 	return &clientQueryValue, nil
 }
 
-func (c latestClientQueryCacheRepository) Set(ctx context.Context, key string, value *ClientQueryValue) error {
+func (c latestClientQueryCacheRepository) Set(ctx context.Context, key string, value *ClientQueryValue) (err error) {
+	ctx, endSpan := startSpan(ctx, "Set")
+	defer func() { endSpan(err) }()
+
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	err = c.cache.Set(ctx, key, data, config.GetDefaultCacheTTLSeconds()).Err()
+	err = c.cache.Set(ctx, tenant.Key(ctx, key), data, config.GetCacheTTLForTenant(tenant.FromContext(ctx))).Err()
 	if err != nil {
 		return err
 	}
@@ -67,15 +144,171 @@ func (c latestClientQueryCacheRepository) Set(ctx context.Context, key string, v
 	return nil
 }
 
-func (c latestClientQueryCacheRepository) Delete(ctx context.Context, key string) error {
+// startSpan starts the span of an operation on a client's cached query. The returned function ends it with the
+// operation's error.
+func startSpan(ctx context.Context, operation string) (context.Context, func(error)) {
+	ctx, span := tracing.Start(ctx, "cache."+operation, tracing.WithKind(tracing.SpanKindClient), tracing.WithAttributes(
+		tracing.String("db.system", "redis"),
+		tracing.String("db.operation", operation),
+		tracing.String("tenant", tenant.FromContext(ctx)),
+	))
+	return ctx, func(err error) {
+		span.RecordError(err)
+		span.End()
+	}
+}
+
+// finalizedKey holds the client's last finalized query.
+func finalizedKey(ctx context.Context, key string) string {
+	return tenant.Key(ctx, key) + ":finalized"
+}
+
+// SetIfNewer only writes if the key was not changed since it was compared, so two replicas writing keystrokes of the
+// same client at once keep the newer one. When no query is pending, value is compared with the last finalized query
+// instead, by time only since the client may have started a new sequence since. When either key changes in between,
+// it is compared again.
+func (c latestClientQueryCacheRepository) SetIfNewer(ctx context.Context, key string, value *ClientQueryValue) (_ bool, err error) {
+	ctx, endSpan := startSpan(ctx, "SetIfNewer")
+	defer func() { endSpan(err) }()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	redisKey, lastFinalizedKey := tenant.Key(ctx, key), finalizedKey(ctx, key)
+	ttl := config.GetCacheTTLForTenant(tenant.FromContext(ctx))
+	for attempt := 0; attempt < setAttempts; attempt++ {
+		written := false
+		err := c.cache.Watch(ctx, func(tx *redis.Tx) error {
+			current, err := tx.Get(ctx, redisKey).Result()
+			pending := err == nil
+			if errors.Is(err, redis.Nil) {
+				current, err = tx.Get(ctx, lastFinalizedKey).Result()
+			}
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if err == nil {
+				var currentValue ClientQueryValue
+				if err := json.Unmarshal([]byte(current), &currentValue); err != nil {
+					return err
+				}
+				if pending && !value.IsNewerThan(&currentValue) || !pending && !value.sentAfter(&currentValue) {
+					return nil
+				}
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, redisKey, data, ttl)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			written = true
+			return nil
+		}, redisKey, lastFinalizedKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return written, err
+		}
+	}
+	return false, redis.TxFailedErr
+}
+
+func (c latestClientQueryCacheRepository) Delete(ctx context.Context, key string) (err error) {
+	ctx, endSpan := startSpan(ctx, "Delete")
+	defer func() { endSpan(err) }()
+
 	if key == "" {
 		return errors.New("key cannot be empty")
 	}
 
-	err := c.cache.Del(ctx, key).Err()
+	err = c.cache.Del(ctx, tenant.Key(ctx, key)).Err()
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (c latestClientQueryCacheRepository) Finalize(ctx context.Context, key string, value *ClientQueryValue) (_ *ClientQueryValue, err error) {
+	ctx, endSpan := startSpan(ctx, "Finalize")
+	defer func() { endSpan(err) }()
+
+	if key == "" {
+		return nil, errors.New("key cannot be empty")
+	}
+
+	keys := []string{tenant.Key(ctx, key), finalizedKey(ctx, key)}
+	ttl := config.GetCacheTTLForTenant(tenant.FromContext(ctx)).Milliseconds()
+	result, err := finalizeScript.Run(ctx, c.cache, keys, value.QueryText, value.CreatedAtUnixMilliseconds, ttl).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var finalized ClientQueryValue
+	if err = json.Unmarshal([]byte(result), &finalized); err != nil {
+		return nil, err
+	}
+	return &finalized, nil
+}
+
+// SetResultCount only writes if the key was not changed since it was read, so it cannot overwrite a newer keystroke
+// or bring back a finalized one. When the key changes in between, it is read again.
+func (c latestClientQueryCacheRepository) SetResultCount(ctx context.Context, key, queryText string, resultCount int) (_ bool, err error) {
+	ctx, endSpan := startSpan(ctx, "SetResultCount")
+	defer func() { endSpan(err) }()
+
+	redisKey := tenant.Key(ctx, key)
+	for attempt := 0; attempt < setAttempts; attempt++ {
+		updated, err := c.setResultCount(ctx, redisKey, queryText, resultCount)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return updated, err
+		}
+	}
+	return false, redis.TxFailedErr
+}
+
+func (c latestClientQueryCacheRepository) setResultCount(ctx context.Context, redisKey, queryText string, resultCount int) (bool, error) {
+	updated := false
+	err := c.cache.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, redisKey).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil
+			}
+			return err
+		}
+
+		var clientQueryValue ClientQueryValue
+		if err = json.Unmarshal([]byte(value), &clientQueryValue); err != nil {
+			return err
+		}
+		if clientQueryValue.QueryText != queryText {
+			return nil
+		}
+		clientQueryValue.ResultCount = &resultCount
+		data, err := json.Marshal(clientQueryValue)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, redisKey, data, redis.SetArgs{KeepTTL: true})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		updated = true
+		return nil
+	}, redisKey)
+	if err != nil {
+		return false, err
+	}
+	return updated, nil
+}
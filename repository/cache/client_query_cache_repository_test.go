@@ -2,7 +2,10 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"search-logger/storage_util"
+	"search-logger/tenant"
+	"sync"
 	"testing"
 	"time"
 
@@ -50,3 +53,274 @@ func TestClientQueryCacheRepository_SetAndGet(t *testing.T) {
 		assert.Nil(t, result)
 	})
 }
+
+func TestClientQueryCacheRepository_TenantScoping(t *testing.T) {
+	cache := setupTestRedis(t)
+	repo := NewLatestClientQueryCacheRepository(cache)
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	t.Run("Same client key does not collide across tenants", func(t *testing.T) {
+		assert.NoError(t, repo.Set(acme, "client", NewClientQueryValue("acme query", 1)))
+		assert.NoError(t, repo.Set(globex, "client", NewClientQueryValue("globex query", 2)))
+
+		result, err := repo.Get(acme, "client")
+		assert.NoError(t, err)
+		assert.Equal(t, "acme query", result.QueryText)
+
+		assert.NoError(t, repo.Delete(globex, "client"))
+		result, err = repo.Get(acme, "client")
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+		result, err = repo.Get(globex, "client")
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestClientQueryCacheRepository_Finalize(t *testing.T) {
+	cache := setupTestRedis(t)
+	repo := NewLatestClientQueryCacheRepository(cache)
+	ctx := context.Background()
+
+	t.Run("Finalize the cached keystroke once", func(t *testing.T) {
+		value := NewClientQueryValue("laptop", 1000)
+		resultCount := 0
+		value.ResultCount = &resultCount
+		assert.NoError(t, repo.Set(ctx, "finalize-client-1", value))
+
+		finalized, err := repo.Finalize(ctx, "finalize-client-1", NewClientQueryValue("laptop", 1000))
+		assert.NoError(t, err)
+		assert.Equal(t, value, finalized)
+
+		cached, err := repo.Get(ctx, "finalize-client-1")
+		assert.NoError(t, err)
+		assert.Nil(t, cached)
+
+		// Writing the same keystroke again does not make it count twice.
+		assert.NoError(t, repo.Set(ctx, "finalize-client-1", value))
+		finalized, err = repo.Finalize(ctx, "finalize-client-1", value)
+		assert.NoError(t, err)
+		assert.Nil(t, finalized)
+	})
+
+	t.Run("Superseded keystrokes are not finalized and do not delete the newer one", func(t *testing.T) {
+		assert.NoError(t, repo.Set(ctx, "finalize-client-2", NewClientQueryValue("headphones", 2000)))
+
+		finalized, err := repo.Finalize(ctx, "finalize-client-2", NewClientQueryValue("hea", 1000))
+		assert.NoError(t, err)
+		assert.Nil(t, finalized)
+		finalized, err = repo.Finalize(ctx, "finalize-client-2", NewClientQueryValue("headphones", 1000))
+		assert.NoError(t, err)
+		assert.Nil(t, finalized, "same text typed earlier is a different keystroke")
+
+		cached, err := repo.Get(ctx, "finalize-client-2")
+		assert.NoError(t, err)
+		assert.Equal(t, "headphones", cached.QueryText)
+	})
+
+	t.Run("Nothing to finalize", func(t *testing.T) {
+		finalized, err := repo.Finalize(ctx, "finalize-client-3", NewClientQueryValue("laptop", 1000))
+		assert.NoError(t, err)
+		assert.Nil(t, finalized)
+	})
+
+	t.Run("Concurrent debounces finalize each client's latest keystroke exactly once", func(t *testing.T) {
+		const clients, keystrokes, replicas = 20, 5, 4
+		latest := make(map[string]*ClientQueryValue, clients)
+		for i := 0; i < clients; i++ {
+			key := fmt.Sprintf("concurrent-client-%d", i)
+			for k := 1; k <= keystrokes; k++ {
+				value := NewClientQueryValue(fmt.Sprintf("query-%d", k), int64(k))
+				assert.NoError(t, repo.Set(ctx, key, value))
+				latest[key] = value
+			}
+		}
+
+		// Every replica runs the debounce of every keystroke of every client at the same time.
+		var mu sync.Mutex
+		finalizedQueries := make(map[string][]string)
+		var wg sync.WaitGroup
+		for key := range latest {
+			for k := 1; k <= keystrokes; k++ {
+				for r := 0; r < replicas; r++ {
+					wg.Add(1)
+					go func(key string, value *ClientQueryValue) {
+						defer wg.Done()
+						finalized, err := repo.Finalize(ctx, key, value)
+						assert.NoError(t, err)
+						if finalized != nil {
+							mu.Lock()
+							finalizedQueries[key] = append(finalizedQueries[key], finalized.QueryText)
+							mu.Unlock()
+						}
+					}(key, NewClientQueryValue(fmt.Sprintf("query-%d", k), int64(k)))
+				}
+			}
+		}
+		wg.Wait()
+
+		assert.Len(t, finalizedQueries, clients)
+		for key, value := range latest {
+			assert.Equal(t, []string{value.QueryText}, finalizedQueries[key], key)
+		}
+	})
+}
+
+func TestClientQueryCacheRepository_SetResultCount(t *testing.T) {
+	cache := setupTestRedis(t)
+	repo := NewLatestClientQueryCacheRepository(cache)
+	ctx := context.Background()
+	assert.NoError(t, repo.Set(ctx, "result-client", NewClientQueryValue("laptop", 1000)))
+
+	t.Run("Attach result count to the pending query", func(t *testing.T) {
+		attached, err := repo.SetResultCount(ctx, "result-client", "laptop", 0)
+		assert.NoError(t, err)
+		assert.True(t, attached)
+
+		cached, err := repo.Get(ctx, "result-client")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, *cached.ResultCount)
+		assert.Equal(t, int64(1000), cached.CreatedAtUnixMilliseconds)
+
+		ttl, err := cache.PTTL(ctx, tenant.Key(ctx, "result-client")).Result()
+		assert.NoError(t, err)
+		assert.Greater(t, ttl, time.Duration(0), "the TTL is kept")
+	})
+
+	t.Run("Ignore other queries", func(t *testing.T) {
+		attached, err := repo.SetResultCount(ctx, "result-client", "laptops", 3)
+		assert.NoError(t, err)
+		assert.False(t, attached)
+	})
+
+	t.Run("Do not bring back a finalized query", func(t *testing.T) {
+		_, err := repo.Finalize(ctx, "result-client", NewClientQueryValue("laptop", 1000))
+		assert.NoError(t, err)
+
+		attached, err := repo.SetResultCount(ctx, "result-client", "laptop", 3)
+		assert.NoError(t, err)
+		assert.False(t, attached)
+
+		cached, err := repo.Get(ctx, "result-client")
+		assert.NoError(t, err)
+		assert.Nil(t, cached)
+	})
+}
+
+func TestClientQueryValue_IsNewerThan(t *testing.T) {
+	withOrder := func(createdAt int64, sequence, clientTimestamp *int64) *ClientQueryValue {
+		value := NewClientQueryValue("query", createdAt)
+		value.Sequence = sequence
+		value.ClientTimestampUnixMilliseconds = clientTimestamp
+		return value
+	}
+	n := func(i int64) *int64 { return &i }
+
+	tests := []struct {
+		name  string
+		value *ClientQueryValue
+		other *ClientQueryValue
+		want  bool
+	}{
+		{"Anything is newer than nothing", withOrder(1, nil, nil), nil, true},
+		{"Higher sequence wins over server time", withOrder(1, n(2), nil), withOrder(9, n(1), nil), true},
+		{"Lower sequence loses over server time", withOrder(9, n(1), nil), withOrder(1, n(2), nil), false},
+		{"Equal sequence is the same keystroke", withOrder(9, n(1), nil), withOrder(1, n(1), nil), false},
+		{"Sequence wins over client timestamp", withOrder(1, n(2), n(1)), withOrder(1, n(1), n(2)), true},
+		{"Later client timestamp wins without sequences", withOrder(1, nil, n(2)), withOrder(9, n(1), n(1)), true},
+		{"Earlier client timestamp loses", withOrder(9, nil, n(1)), withOrder(1, nil, n(2)), false},
+		{"Server time decides when the client did not say", withOrder(2, n(1), nil), withOrder(1, nil, n(5)), true},
+		{"Server time ties go to the later write", withOrder(1, nil, nil), withOrder(1, nil, nil), true},
+		{"Earlier server time loses", withOrder(1, nil, nil), withOrder(2, nil, nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.value.IsNewerThan(tt.other))
+		})
+	}
+}
+
+func TestClientQueryCacheRepository_SetIfNewer(t *testing.T) {
+	cache := setupTestRedis(t)
+	repo := NewLatestClientQueryCacheRepository(cache)
+	ctx := context.Background()
+	withSequence := func(queryText string, createdAt, sequence int64) *ClientQueryValue {
+		value := NewClientQueryValue(queryText, createdAt)
+		value.Sequence = &sequence
+		return value
+	}
+
+	t.Run("Keep the newer keystroke when an older one arrives late", func(t *testing.T) {
+		written, err := repo.SetIfNewer(ctx, "ordered-client-1", withSequence("headphones", 1000, 2))
+		assert.NoError(t, err)
+		assert.True(t, written)
+
+		written, err = repo.SetIfNewer(ctx, "ordered-client-1", withSequence("hea", 2000, 1))
+		assert.NoError(t, err)
+		assert.False(t, written)
+
+		cached, err := repo.Get(ctx, "ordered-client-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "headphones", cached.QueryText)
+	})
+
+	t.Run("Late keystrokes are not taken for a new search after finalization", func(t *testing.T) {
+		finalized, err := repo.Finalize(ctx, "ordered-client-1", withSequence("headphones", 1000, 2))
+		assert.NoError(t, err)
+		assert.NotNil(t, finalized)
+
+		written, err := repo.SetIfNewer(ctx, "ordered-client-1", withSequence("head", 500, 1))
+		assert.NoError(t, err)
+		assert.False(t, written)
+
+		written, err = repo.SetIfNewer(ctx, "ordered-client-1", withSequence("headphones case", 3000, 3))
+		assert.NoError(t, err)
+		assert.True(t, written)
+	})
+
+	t.Run("A reset sequence starts a new search after finalization", func(t *testing.T) {
+		// ARRANGE
+		clientTimestamp := func(value *ClientQueryValue, unixMilli int64) *ClientQueryValue {
+			value.ClientTimestampUnixMilliseconds = &unixMilli
+			return value
+		}
+		reloaded := clientTimestamp(withSequence("monitor", 2000, 7), 20000)
+		written, err := repo.SetIfNewer(ctx, "ordered-client-3", reloaded)
+		assert.NoError(t, err)
+		assert.True(t, written)
+		finalized, err := repo.Finalize(ctx, "ordered-client-3", reloaded)
+		assert.NoError(t, err)
+		assert.NotNil(t, finalized)
+
+		// ACT
+		late, err := repo.SetIfNewer(ctx, "ordered-client-3", clientTimestamp(withSequence("moni", 3000, 5), 19000))
+		assert.NoError(t, err)
+		reset, err := repo.SetIfNewer(ctx, "ordered-client-3", clientTimestamp(withSequence("k", 3000, 1), 25000))
+		assert.NoError(t, err)
+
+		// ASSERT
+		assert.False(t, late, "keystrokes typed before the finalized one are still ignored")
+		assert.True(t, reset)
+		cached, err := repo.Get(ctx, "ordered-client-3")
+		assert.NoError(t, err)
+		assert.Equal(t, "k", cached.QueryText)
+	})
+
+	t.Run("Concurrent writes keep the highest sequence", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for sequence := int64(1); sequence <= 10; sequence++ {
+			wg.Add(1)
+			go func(sequence int64) {
+				defer wg.Done()
+				_, err := repo.SetIfNewer(ctx, "ordered-client-2", withSequence(fmt.Sprintf("query-%d", sequence), 1000, sequence))
+				assert.NoError(t, err)
+			}(sequence)
+		}
+		wg.Wait()
+
+		cached, err := repo.Get(ctx, "ordered-client-2")
+		assert.NoError(t, err)
+		assert.Equal(t, "query-10", cached.QueryText)
+	})
+}
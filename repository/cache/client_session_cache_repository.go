@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"search-logger/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	lastFinalizedQueryKeyPrefix = "last_finalized_query"
+	clickedQueryKeyPrefix       = "clicked_query"
+	pendingClickKeyPrefix       = "pending_click"
+	transitionQueryKeyPrefix    = "transition_query"
+)
+
+type FinalizedQueryValue struct {
+	QueryText                   string `json:"query_text"`
+	FinalizedAtUnixMilliseconds int64  `json:"finalized_at_unix_ms"`
+}
+
+// ClientSessionCacheRepository keeps short-lived per-client state about finalized queries.
+// Keys are scoped to the tenant on the context.
+type ClientSessionCacheRepository interface {
+	// GetLastFinalizedQuery returns the client's most recently finalized query, or nil once the session has expired.
+	GetLastFinalizedQuery(ctx context.Context, clientIdentifier string) (*FinalizedQueryValue, error)
+	SetLastFinalizedQuery(ctx context.Context, clientIdentifier string, value *FinalizedQueryValue, ttl time.Duration) error
+	// MarkClickedQuery returns true the first time a client clicks a result of queryText within ttl.
+	MarkClickedQuery(ctx context.Context, clientIdentifier, queryText string, ttl time.Duration) (bool, error)
+	// MarkPendingClick remembers that a client clicked a result of a query that has not been finalized yet.
+	MarkPendingClick(ctx context.Context, clientIdentifier, queryText string, ttl time.Duration) error
+	// TakePendingClick removes a pending click mark and reports whether there was one.
+	TakePendingClick(ctx context.Context, clientIdentifier, queryText string) (bool, error)
+	// SwapTransitionQuery atomically stores the client's latest finalized query for transition tracking and returns the
+	// previous one, or nil when there was none within ttl. It is kept apart from GetLastFinalizedQuery so the two
+	// consumers do not depend on the order they run in.
+	SwapTransitionQuery(ctx context.Context, clientIdentifier string, value *FinalizedQueryValue, ttl time.Duration) (*FinalizedQueryValue, error)
+}
+
+type clientSessionCacheRepository struct {
+	cache *redis.Client
+}
+
+func NewClientSessionCacheRepository(cache *redis.Client) ClientSessionCacheRepository {
+	return &clientSessionCacheRepository{cache: cache}
+}
+
+func (c clientSessionCacheRepository) GetLastFinalizedQuery(ctx context.Context, clientIdentifier string) (*FinalizedQueryValue, error) {
+	value, err := c.cache.Get(ctx, tenant.Key(ctx, lastFinalizedQueryKeyPrefix+":"+clientIdentifier)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var finalizedQueryValue FinalizedQueryValue
+	if err = json.Unmarshal([]byte(value), &finalizedQueryValue); err != nil {
+		return nil, err
+	}
+	return &finalizedQueryValue, nil
+}
+
+func (c clientSessionCacheRepository) SetLastFinalizedQuery(ctx context.Context, clientIdentifier string, value *FinalizedQueryValue, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.cache.Set(ctx, tenant.Key(ctx, lastFinalizedQueryKeyPrefix+":"+clientIdentifier), data, ttl).Err()
+}
+
+func (c clientSessionCacheRepository) MarkClickedQuery(ctx context.Context, clientIdentifier, queryText string, ttl time.Duration) (bool, error) {
+	if queryText == "" {
+		return false, errors.New("query text cannot be empty")
+	}
+	return c.cache.SetNX(ctx, tenant.Key(ctx, clickedQueryKeyPrefix+":"+clientIdentifier+":"+queryText), 1, ttl).Result()
+}
+
+func (c clientSessionCacheRepository) MarkPendingClick(ctx context.Context, clientIdentifier, queryText string, ttl time.Duration) error {
+	return c.cache.Set(ctx, tenant.Key(ctx, pendingClickKeyPrefix+":"+clientIdentifier+":"+queryText), 1, ttl).Err()
+}
+
+func (c clientSessionCacheRepository) TakePendingClick(ctx context.Context, clientIdentifier, queryText string) (bool, error) {
+	deleted, err := c.cache.Del(ctx, tenant.Key(ctx, pendingClickKeyPrefix+":"+clientIdentifier+":"+queryText)).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+func (c clientSessionCacheRepository) SwapTransitionQuery(ctx context.Context, clientIdentifier string, value *FinalizedQueryValue, ttl time.Duration) (*FinalizedQueryValue, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := c.cache.SetArgs(ctx, tenant.Key(ctx, transitionQueryKeyPrefix+":"+clientIdentifier), data, redis.SetArgs{
+		TTL: ttl,
+		Get: true,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var previousValue FinalizedQueryValue
+	if err = json.Unmarshal([]byte(previous), &previousValue); err != nil {
+		return nil, err
+	}
+	return &previousValue, nil
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSessionCacheRepository(t *testing.T) {
+	repo := NewClientSessionCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+
+	t.Run("Set and get last finalized query", func(t *testing.T) {
+		result, err := repo.GetLastFinalizedQuery(ctx, "session-client")
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+
+		value := &FinalizedQueryValue{QueryText: "laptop", FinalizedAtUnixMilliseconds: 1000}
+		assert.NoError(t, repo.SetLastFinalizedQuery(ctx, "session-client", value, time.Minute))
+
+		result, err = repo.GetLastFinalizedQuery(ctx, "session-client")
+		assert.NoError(t, err)
+		assert.Equal(t, value, result)
+	})
+
+	t.Run("Clicked query is marked once", func(t *testing.T) {
+		first, err := repo.MarkClickedQuery(ctx, "session-client", "laptop", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, first)
+		first, err = repo.MarkClickedQuery(ctx, "session-client", "laptop", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, first)
+	})
+
+	t.Run("Pending click can be taken once", func(t *testing.T) {
+		assert.NoError(t, repo.MarkPendingClick(ctx, "session-client", "laptop", time.Minute))
+
+		taken, err := repo.TakePendingClick(ctx, "session-client", "laptop")
+		assert.NoError(t, err)
+		assert.True(t, taken)
+		taken, err = repo.TakePendingClick(ctx, "session-client", "laptop")
+		assert.NoError(t, err)
+		assert.False(t, taken)
+	})
+}
+
+func TestClientSessionCacheRepository_SwapTransitionQuery(t *testing.T) {
+	repo := NewClientSessionCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+
+	previous, err := repo.SwapTransitionQuery(ctx, "transition-client", &FinalizedQueryValue{QueryText: "laptop"}, time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, previous)
+
+	previous, err = repo.SwapTransitionQuery(ctx, "transition-client", &FinalizedQueryValue{QueryText: "gaming laptop"}, time.Minute)
+	assert.NoError(t, err)
+	assert.NotNil(t, previous)
+	assert.Equal(t, "laptop", previous.QueryText)
+}
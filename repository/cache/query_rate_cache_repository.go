@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"search-logger/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	queryRateKeyPrefix     = "query_rate"
+	queryRateMarkKeyPrefix = "query_rate_mark"
+)
+
+// QueryRateCacheRepository keeps hourly search counters per query, shared by all replicas. Keys are scoped to the tenant on the context.
+type QueryRateCacheRepository interface {
+	// IncrementHourly increments the counter for queryText in the hour containing at and returns the
+	// counts of that hour and of the hour before it.
+	IncrementHourly(ctx context.Context, queryText string, at time.Time) (current int64, previous int64, err error)
+	// MarkOnce returns true only for the first caller marking key, until ttl expires.
+	MarkOnce(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+type queryRateCacheRepository struct {
+	cache *redis.Client
+}
+
+func NewQueryRateCacheRepository(cache *redis.Client) QueryRateCacheRepository {
+	return &queryRateCacheRepository{cache: cache}
+}
+
+func queryRateKey(ctx context.Context, queryText string, hour time.Time) string {
+	return tenant.Key(ctx, fmt.Sprintf("%s:%s:%d", queryRateKeyPrefix, queryText, hour.Unix()))
+}
+
+func (c queryRateCacheRepository) IncrementHourly(ctx context.Context, queryText string, at time.Time) (int64, int64, error) {
+	if queryText == "" {
+		return 0, 0, errors.New("query text cannot be empty")
+	}
+
+	hour := at.UTC().Truncate(time.Hour)
+	currentKey := queryRateKey(ctx, queryText, hour)
+	previousKey := queryRateKey(ctx, queryText, hour.Add(-time.Hour))
+
+	pipe := c.cache.TxPipeline()
+	incr := pipe.Incr(ctx, currentKey)
+	// Keep the counter long enough to serve as the "previous hour" for the next hour.
+	pipe.Expire(ctx, currentKey, 2*time.Hour+time.Minute)
+	prev := pipe.Get(ctx, previousKey)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+
+	previous, err := prev.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+	return incr.Val(), previous, nil
+}
+
+func (c queryRateCacheRepository) MarkOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if key == "" {
+		return false, errors.New("key cannot be empty")
+	}
+	return c.cache.SetNX(ctx, tenant.Key(ctx, queryRateMarkKeyPrefix+":"+key), 1, ttl).Result()
+}
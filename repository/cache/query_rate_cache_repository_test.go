@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRateCacheRepository_IncrementHourly(t *testing.T) {
+	repo := NewQueryRateCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	t.Run("Counts searches per hour and reports the previous hour", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, _, err := repo.IncrementHourly(ctx, "laptop", now.Add(-time.Hour))
+			assert.NoError(t, err)
+		}
+
+		current, previous, err := repo.IncrementHourly(ctx, "laptop", now)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), current)
+		assert.Equal(t, int64(3), previous)
+
+		current, previous, err = repo.IncrementHourly(ctx, "laptop", now.Add(10*time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), current)
+		assert.Equal(t, int64(3), previous)
+	})
+
+	t.Run("Previous hour is zero for a new query", func(t *testing.T) {
+		current, previous, err := repo.IncrementHourly(ctx, "brand new", now)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), current)
+		assert.Equal(t, int64(0), previous)
+	})
+}
+
+func TestQueryRateCacheRepository_MarkOnce(t *testing.T) {
+	repo := NewQueryRateCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+
+	first, err := repo.MarkOnce(ctx, "rule:query:1", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, first)
+
+	first, err = repo.MarkOnce(ctx, "rule:query:1", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, first)
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"search-logger/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const rateLimitKeyPrefix = "rate_limit"
+
+// tokenBucketScript atomically refills and takes one token from the bucket stored at KEYS[1].
+// ARGV: burst, refill rate in tokens per second, current time in milliseconds.
+// Returns {allowed (0/1), milliseconds until a token is available}.
+var tokenBucketScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil then
+	tokens = burst
+	ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(burst, tokens + elapsed * rate / 1000)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, retry_after}
+`)
+
+// RateLimitCacheRepository is a token bucket rate limiter shared by all replicas. Keys are scoped to the tenant on the context.
+type RateLimitCacheRepository interface {
+	// Take removes a token from key's bucket. When the bucket is empty it returns false and how long until a token is available.
+	Take(ctx context.Context, key string, burst int, refillPerSecond float64) (bool, time.Duration, error)
+}
+
+type rateLimitCacheRepository struct {
+	cache *redis.Client
+}
+
+func NewRateLimitCacheRepository(cache *redis.Client) RateLimitCacheRepository {
+	return &rateLimitCacheRepository{cache: cache}
+}
+
+func (c rateLimitCacheRepository) Take(ctx context.Context, key string, burst int, refillPerSecond float64) (bool, time.Duration, error) {
+	if burst <= 0 || refillPerSecond <= 0 {
+		return false, 0, errors.New("burst and refill rate must be positive")
+	}
+
+	res, err := tokenBucketScript.Run(ctx, c.cache, []string{tenant.Key(ctx, rateLimitKeyPrefix+":"+key)},
+		burst, refillPerSecond, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, errors.New("unexpected token bucket script result")
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
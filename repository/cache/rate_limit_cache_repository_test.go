@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitCacheRepository_Take(t *testing.T) {
+	repo := NewRateLimitCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+
+	t.Run("Allows up to burst then limits", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			allowed, _, err := repo.Take(ctx, "client-a", 3, 1)
+			assert.NoError(t, err)
+			assert.True(t, allowed)
+		}
+
+		allowed, retryAfter, err := repo.Take(ctx, "client-a", 3, 1)
+		assert.NoError(t, err)
+		assert.False(t, allowed)
+		assert.Greater(t, retryAfter, time.Duration(0))
+		assert.LessOrEqual(t, retryAfter, time.Second)
+	})
+
+	t.Run("Buckets are independent per key", func(t *testing.T) {
+		allowed, _, err := repo.Take(ctx, "client-b", 3, 1)
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+	})
+
+	t.Run("Tokens refill over time", func(t *testing.T) {
+		allowed, _, err := repo.Take(ctx, "client-c", 1, 20)
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+		allowed, _, err = repo.Take(ctx, "client-c", 1, 20)
+		assert.NoError(t, err)
+		assert.False(t, allowed)
+
+		time.Sleep(100 * time.Millisecond)
+		allowed, _, err = repo.Take(ctx, "client-c", 1, 20)
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+	})
+
+	t.Run("Reject invalid bucket configuration", func(t *testing.T) {
+		_, _, err := repo.Take(ctx, "client-d", 0, 1)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"search-logger/models"
+	"search-logger/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const realtimeSketchKeyPrefix = "realtime_sketch"
+
+// RealtimeSketchCacheRepository shares the sketches of recently finalized queries that every replica keeps per time
+// bucket, so any replica can merge them. Each bucket is a hash of sketches by replica ID. Keys are scoped to the
+// tenant on the context.
+type RealtimeSketchCacheRepository interface {
+	// Publish stores the sketch replicaID keeps for the bucket starting at bucketStart, replacing the one it
+	// published before. The bucket expires ttl after its last publish.
+	Publish(ctx context.Context, bucketStart time.Time, replicaID string, sketch *models.SketchSnapshot, ttl time.Duration) error
+	// List returns the sketches published for each of bucketStarts, by bucket start in Unix seconds, then by replica
+	// ID. Buckets without any sketch are left out.
+	List(ctx context.Context, bucketStarts []time.Time) (map[int64]map[string]*models.SketchSnapshot, error)
+}
+
+type realtimeSketchCacheRepository struct {
+	cache *redis.Client
+}
+
+func NewRealtimeSketchCacheRepository(cache *redis.Client) RealtimeSketchCacheRepository {
+	return &realtimeSketchCacheRepository{cache: cache}
+}
+
+func realtimeSketchKey(ctx context.Context, bucketStart time.Time) string {
+	return tenant.Key(ctx, fmt.Sprintf("%s:%d", realtimeSketchKeyPrefix, bucketStart.Unix()))
+}
+
+func (c realtimeSketchCacheRepository) Publish(ctx context.Context, bucketStart time.Time, replicaID string, sketch *models.SketchSnapshot, ttl time.Duration) error {
+	data, err := json.Marshal(sketch)
+	if err != nil {
+		return err
+	}
+
+	key := realtimeSketchKey(ctx, bucketStart)
+	pipe := c.cache.TxPipeline()
+	pipe.HSet(ctx, key, replicaID, data)
+	pipe.Expire(ctx, key, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (c realtimeSketchCacheRepository) List(ctx context.Context, bucketStarts []time.Time) (map[int64]map[string]*models.SketchSnapshot, error) {
+	pipe := c.cache.Pipeline()
+	results := make([]*redis.MapStringStringCmd, len(bucketStarts))
+	for i, bucketStart := range bucketStarts {
+		results[i] = pipe.HGetAll(ctx, realtimeSketchKey(ctx, bucketStart))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	sketches := make(map[int64]map[string]*models.SketchSnapshot)
+	for i, result := range results {
+		for replicaID, data := range result.Val() {
+			var sketch models.SketchSnapshot
+			if err := json.Unmarshal([]byte(data), &sketch); err != nil {
+				return nil, fmt.Errorf("decoding sketch of replica %s: %w", replicaID, err)
+			}
+			bucket := bucketStarts[i].Unix()
+			if sketches[bucket] == nil {
+				sketches[bucket] = make(map[string]*models.SketchSnapshot)
+			}
+			sketches[bucket][replicaID] = &sketch
+		}
+	}
+	return sketches, nil
+}
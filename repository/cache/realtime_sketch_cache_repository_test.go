@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"search-logger/models"
+	"search-logger/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealtimeSketchCacheRepository_PublishAndList(t *testing.T) {
+	repo := NewRealtimeSketchCacheRepository(setupTestRedis(t))
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	bucket := time.Date(2026, 10, 19, 10, 5, 0, 0, time.UTC)
+
+	t.Run("Lists the latest sketch of every replica by bucket", func(t *testing.T) {
+		// ARRANGE
+		first := &models.SketchSnapshot{Capacity: 10, Total: 1, Counters: []models.SketchCounter{{QueryText: "laptop", Count: 1}}}
+		latest := &models.SketchSnapshot{Capacity: 10, Total: 3, Counters: []models.SketchCounter{{QueryText: "laptop", Count: 3}}}
+		other := &models.SketchSnapshot{Capacity: 10, Total: 2, Counters: []models.SketchCounter{{QueryText: "phone", Count: 2}}}
+
+		// ACT
+		assert.NoError(t, repo.Publish(ctx, bucket, "replica-a", first, time.Hour))
+		assert.NoError(t, repo.Publish(ctx, bucket, "replica-a", latest, time.Hour))
+		assert.NoError(t, repo.Publish(ctx, bucket, "replica-b", other, time.Hour))
+		sketches, err := repo.List(ctx, []time.Time{bucket, bucket.Add(time.Minute)})
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, map[int64]map[string]*models.SketchSnapshot{
+			bucket.Unix(): {"replica-a": latest, "replica-b": other},
+		}, sketches)
+	})
+
+	t.Run("Tenants do not see each other's sketches", func(t *testing.T) {
+		sketches, err := repo.List(tenant.WithTenant(context.Background(), "globex"), []time.Time{bucket})
+		assert.NoError(t, err)
+		assert.Empty(t, sketches)
+	})
+}
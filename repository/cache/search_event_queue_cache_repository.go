@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"search-logger/models"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// searchEventQueueKey is shared by all tenants, since every event carries its tenant.
+const searchEventQueueKey = "search_events"
+
+// pushIfNotFullScript pushes ARGV[1] onto the list at KEYS[1] unless it already holds ARGV[2] elements.
+// Returns 1 if it pushed, 0 otherwise.
+var pushIfNotFullScript = redis.NewScript(`
+if redis.call("LLEN", KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("LPUSH", KEYS[1], ARGV[1])
+return 1
+`)
+
+// requeueScript moves the events of the processing list at KEYS[1] back to the queue at KEYS[3], where they are popped
+// first, unless the consumer's heartbeat at KEYS[2] shows it is still running. Returns how many events it moved.
+var requeueScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+local moved = 0
+while redis.call("LMOVE", KEYS[1], KEYS[3], "LEFT", "RIGHT") do
+	moved = moved + 1
+end
+return moved
+`)
+
+// QueuedSearchEvent is an event popped from the queue. It stays in the processing list of the consumer that popped it
+// until the consumer acknowledges it.
+type QueuedSearchEvent struct {
+	Event *models.SearchEvent
+	// payload is the event as it is stored in the processing list.
+	payload string
+}
+
+// SearchEventQueueCacheRepository is a FIFO queue of search events shared by all replicas. Consumers pop events into a
+// processing list of their own and acknowledge them once they are done, so that the events of a consumer that stopped
+// without acknowledging them can be requeued once its heartbeat expires.
+type SearchEventQueueCacheRepository interface {
+	// Push appends event unless the queue already holds maxLen events, and reports whether it did.
+	Push(ctx context.Context, event *models.SearchEvent, maxLen int) (bool, error)
+	// Pop moves the oldest event to the processing list of consumer, waiting up to timeout for one, or not at all when
+	// timeout is zero. It returns nil when none arrived in time.
+	Pop(ctx context.Context, consumer string, timeout time.Duration) (*QueuedSearchEvent, error)
+	// Ack removes an event from the processing list of consumer once it is done with it.
+	Ack(ctx context.Context, consumer string, event *QueuedSearchEvent) error
+	// Heartbeat tells the other consumers that consumer is running for the next ttl.
+	Heartbeat(ctx context.Context, consumer string, ttl time.Duration) error
+	// RequeueAbandoned moves the events left in the processing lists of consumers without a heartbeat back to the
+	// queue, and returns how many it moved.
+	RequeueAbandoned(ctx context.Context) (int, error)
+	Len(ctx context.Context) (int64, error)
+}
+
+type searchEventQueueCacheRepository struct {
+	cache *redis.Client
+}
+
+func NewSearchEventQueueCacheRepository(cache *redis.Client) SearchEventQueueCacheRepository {
+	return &searchEventQueueCacheRepository{cache: cache}
+}
+
+func processingKey(consumer string) string {
+	return searchEventQueueKey + ":processing:" + consumer
+}
+
+func heartbeatKey(consumer string) string {
+	return searchEventQueueKey + ":consumer:" + consumer
+}
+
+func (c searchEventQueueCacheRepository) Push(ctx context.Context, event *models.SearchEvent, maxLen int) (bool, error) {
+	if event == nil {
+		return false, errors.New("search event cannot be nil")
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+
+	pushed, err := pushIfNotFullScript.Run(ctx, c.cache, []string{searchEventQueueKey}, data, maxLen).Int()
+	if err != nil {
+		return false, err
+	}
+	return pushed == 1, nil
+}
+
+func (c searchEventQueueCacheRepository) Pop(ctx context.Context, consumer string, timeout time.Duration) (*QueuedSearchEvent, error) {
+	var payload string
+	var err error
+	if timeout > 0 {
+		payload, err = c.cache.BLMove(ctx, searchEventQueueKey, processingKey(consumer), "RIGHT", "LEFT", timeout).Result()
+	} else {
+		payload, err = c.cache.LMove(ctx, searchEventQueueKey, processingKey(consumer), "RIGHT", "LEFT").Result()
+	}
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var event models.SearchEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		// An event that cannot be decoded would be requeued forever.
+		c.cache.LRem(ctx, processingKey(consumer), 1, payload)
+		return nil, err
+	}
+	return &QueuedSearchEvent{Event: &event, payload: payload}, nil
+}
+
+func (c searchEventQueueCacheRepository) Ack(ctx context.Context, consumer string, event *QueuedSearchEvent) error {
+	if event == nil {
+		return errors.New("search event cannot be nil")
+	}
+	return c.cache.LRem(ctx, processingKey(consumer), 1, event.payload).Err()
+}
+
+func (c searchEventQueueCacheRepository) Heartbeat(ctx context.Context, consumer string, ttl time.Duration) error {
+	return c.cache.Set(ctx, heartbeatKey(consumer), time.Now().UnixMilli(), ttl).Err()
+}
+
+func (c searchEventQueueCacheRepository) RequeueAbandoned(ctx context.Context) (int, error) {
+	requeued := 0
+	iter := c.cache.Scan(ctx, 0, processingKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		consumer := strings.TrimPrefix(key, processingKey(""))
+		moved, err := requeueScript.Run(ctx, c.cache, []string{key, heartbeatKey(consumer), searchEventQueueKey}).Int()
+		if err != nil {
+			return requeued, err
+		}
+		requeued += moved
+	}
+	return requeued, iter.Err()
+}
+
+func (c searchEventQueueCacheRepository) Len(ctx context.Context) (int64, error) {
+	return c.cache.LLen(ctx, searchEventQueueKey).Result()
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"search-logger/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchEventQueueCacheRepository(t *testing.T) {
+	repo := NewSearchEventQueueCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+	resultCount := 0
+
+	t.Run("Pop returns events in the order they were pushed", func(t *testing.T) {
+		first := &models.SearchEvent{TenantID: "acme", ClientIdentifier: "ip:1", QueryText: "laptop", ResultCount: &resultCount, ReceivedAt: time.UnixMilli(1000).UTC()}
+		second := &models.SearchEvent{TenantID: "acme", ClientIdentifier: "ip:1", QueryText: "laptops", ReceivedAt: time.UnixMilli(2000).UTC()}
+		for _, event := range []*models.SearchEvent{first, second} {
+			pushed, err := repo.Push(ctx, event, 10)
+			assert.NoError(t, err)
+			assert.True(t, pushed)
+		}
+
+		popped, err := repo.Pop(ctx, "replica-1", time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, first, popped.Event)
+		assert.NoError(t, repo.Ack(ctx, "replica-1", popped))
+		popped, err = repo.Pop(ctx, "replica-1", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, second, popped.Event)
+		assert.NoError(t, repo.Ack(ctx, "replica-1", popped))
+	})
+
+	t.Run("Pop returns nil when the queue stays empty", func(t *testing.T) {
+		popped, err := repo.Pop(ctx, "replica-1", time.Second)
+		assert.NoError(t, err)
+		assert.Nil(t, popped)
+		popped, err = repo.Pop(ctx, "replica-1", 0)
+		assert.NoError(t, err)
+		assert.Nil(t, popped)
+	})
+
+	t.Run("Unacknowledged events are requeued once their consumer stops", func(t *testing.T) {
+		// ARRANGE
+		for _, queryText := range []string{"monitor", "keyboard", "mouse"} {
+			pushed, err := repo.Push(ctx, &models.SearchEvent{QueryText: queryText}, 10)
+			assert.NoError(t, err)
+			assert.True(t, pushed)
+		}
+		assert.NoError(t, repo.Heartbeat(ctx, "running", time.Minute))
+		running, err := repo.Pop(ctx, "running", 0)
+		assert.NoError(t, err)
+		stopped, err := repo.Pop(ctx, "stopped", 0)
+		assert.NoError(t, err)
+
+		// ACT
+		requeued, err := repo.RequeueAbandoned(ctx)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requeued)
+		popped, err := repo.Pop(ctx, "running", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, stopped.Event, popped.Event, "requeued events are popped first")
+		assert.Equal(t, "monitor", running.Event.QueryText)
+		for _, event := range []*QueuedSearchEvent{running, popped} {
+			assert.NoError(t, repo.Ack(ctx, "running", event))
+		}
+		popped, err = repo.Pop(ctx, "running", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "mouse", popped.Event.QueryText)
+		assert.NoError(t, repo.Ack(ctx, "running", popped))
+		requeued, err = repo.RequeueAbandoned(ctx)
+		assert.NoError(t, err)
+		assert.Zero(t, requeued)
+	})
+
+	t.Run("Push refuses events once the queue is full", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			pushed, err := repo.Push(ctx, &models.SearchEvent{QueryText: "laptop"}, 2)
+			assert.NoError(t, err)
+			assert.True(t, pushed)
+		}
+		pushed, err := repo.Push(ctx, &models.SearchEvent{QueryText: "laptop"}, 2)
+		assert.NoError(t, err)
+		assert.False(t, pushed)
+
+		length, err := repo.Len(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), length)
+	})
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"search-logger/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const uniqueClientsKeyPrefix = "unique_clients"
+
+// dailyUniqueClientsTTL keeps a day's HyperLogLog until well after the day is over, so its last snapshot is taken.
+const dailyUniqueClientsTTL = 48 * time.Hour
+
+// UniqueClientCacheRepository estimates how many distinct clients searched each query, all-time and per UTC day, with
+// HyperLogLogs shared by all replicas. Keys are scoped to the tenant on the context.
+type UniqueClientCacheRepository interface {
+	// Add records that clientIdentifier searched queryText at the given time, and returns the estimated number of
+	// distinct clients that searched it, all-time and on that UTC day.
+	Add(ctx context.Context, queryText, clientIdentifier string, at time.Time) (total int64, daily int64, err error)
+	// Delete forgets the clients that searched queryTexts, all-time and on the days whose estimates have not expired
+	// by now.
+	Delete(ctx context.Context, queryTexts []string, now time.Time) error
+}
+
+type uniqueClientCacheRepository struct {
+	cache *redis.Client
+}
+
+func NewUniqueClientCacheRepository(cache *redis.Client) UniqueClientCacheRepository {
+	return &uniqueClientCacheRepository{cache: cache}
+}
+
+func uniqueClientsKey(ctx context.Context, queryText string) string {
+	return tenant.Key(ctx, fmt.Sprintf("%s:%s", uniqueClientsKeyPrefix, queryText))
+}
+
+func dailyUniqueClientsKey(ctx context.Context, queryText string, day time.Time) string {
+	return tenant.Key(ctx, fmt.Sprintf("%s:%s:%d", uniqueClientsKeyPrefix, queryText, day.Unix()))
+}
+
+func (c uniqueClientCacheRepository) Add(ctx context.Context, queryText, clientIdentifier string, at time.Time) (int64, int64, error) {
+	if queryText == "" {
+		return 0, 0, errors.New("query text cannot be empty")
+	}
+	if clientIdentifier == "" {
+		return 0, 0, errors.New("client identifier cannot be empty")
+	}
+
+	totalKey := uniqueClientsKey(ctx, queryText)
+	dailyKey := dailyUniqueClientsKey(ctx, queryText, at.UTC().Truncate(24*time.Hour))
+	pipe := c.cache.TxPipeline()
+	pipe.PFAdd(ctx, totalKey, clientIdentifier)
+	pipe.PFAdd(ctx, dailyKey, clientIdentifier)
+	pipe.Expire(ctx, dailyKey, dailyUniqueClientsTTL)
+	total := pipe.PFCount(ctx, totalKey)
+	daily := pipe.PFCount(ctx, dailyKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return total.Val(), daily.Val(), nil
+}
+
+func (c uniqueClientCacheRepository) Delete(ctx context.Context, queryTexts []string, now time.Time) error {
+	// A day's estimate is kept until dailyUniqueClientsTTL after the day's last search, which can be at its very end.
+	oldestDay := now.UTC().Add(-dailyUniqueClientsTTL).Truncate(24*time.Hour).AddDate(0, 0, -1)
+	var keys []string
+	for _, queryText := range queryTexts {
+		keys = append(keys, uniqueClientsKey(ctx, queryText))
+		for day := now.UTC().Truncate(24 * time.Hour); !day.Before(oldestDay); day = day.AddDate(0, 0, -1) {
+			keys = append(keys, dailyUniqueClientsKey(ctx, queryText, day))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.cache.Del(ctx, keys...).Err()
+}
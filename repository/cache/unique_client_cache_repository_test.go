@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"search-logger/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueClientCacheRepository_Add(t *testing.T) {
+	repo := NewUniqueClientCacheRepository(setupTestRedis(t))
+	ctx := context.Background()
+	day := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Counts distinct clients all-time and per UTC day", func(t *testing.T) {
+		// ACT
+		for i := 0; i < 3; i++ {
+			_, _, err := repo.Add(ctx, "refund", "client:a", day.Add(-24*time.Hour))
+			assert.NoError(t, err)
+		}
+		_, _, err := repo.Add(ctx, "refund", "client:a", day)
+		assert.NoError(t, err)
+		total, daily, err := repo.Add(ctx, "refund", "client:b", day.Add(14*time.Hour))
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, int64(2), daily)
+	})
+
+	t.Run("Estimates many clients within a few percent", func(t *testing.T) {
+		var total int64
+		for i := 0; i < 5000; i++ {
+			var err error
+			total, _, err = repo.Add(ctx, "laptop", fmt.Sprintf("ip:10.0.%d.%d", i/256, i%256), day)
+			assert.NoError(t, err)
+		}
+
+		assert.InDelta(t, 5000, total, 5000*0.03)
+	})
+
+	t.Run("Tenants are counted apart", func(t *testing.T) {
+		total, daily, err := repo.Add(tenant.WithTenant(ctx, "acme"), "refund", "client:c", day)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, int64(1), daily)
+	})
+
+	t.Run("Deleted queries are counted from scratch", func(t *testing.T) {
+		// ACT
+		err := repo.Delete(ctx, []string{"refund"}, day.Add(14*time.Hour))
+		assert.NoError(t, err)
+
+		// ASSERT
+		total, daily, err := repo.Add(ctx, "refund", "client:c", day)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, int64(1), daily)
+		_, daily, err = repo.Add(ctx, "refund", "client:c", day.Add(-24*time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), daily, "the previous day is forgotten too")
+	})
+}
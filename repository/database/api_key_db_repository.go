@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// APIKeyRepository methods are not scoped to the tenant on the context: keys are looked up before the tenant of a
+// request is known, and a key can act for every tenant.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	// GetByHash returns the key with the given hash, revoked and expired ones included, or nil when there is none.
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	Get(ctx context.Context, id string) (*models.APIKey, error)
+	List(ctx context.Context) ([]models.APIKey, error)
+	// Revoke marks the key as revoked at the given time, and reports whether an active key was revoked.
+	Revoke(ctx context.Context, id string, at time.Time) (bool, error)
+	// RecordUsage adds requests and denied requests to the usage of the key on the UTC day containing lastUsedAt, and
+	// moves its last use forward to lastUsedAt.
+	RecordUsage(ctx context.Context, keyID string, requests, denied int64, lastUsedAt time.Time) error
+	// ListUsage returns the daily usage of the key on the UTC days from "from" through "to".
+	ListUsage(ctx context.Context, keyID string, from, to time.Time) ([]models.APIKeyDailyUsage, error)
+}
+
+type apiKeyDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyDatabaseRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyDatabaseRepository{db: db}
+}
+
+func (i apiKeyDatabaseRepository) Create(ctx context.Context, key *models.APIKey) error {
+	if key == nil || key.Hash == "" {
+		return errors.New("api key hash cannot be empty")
+	}
+	return i.db.WithContext(ctx).Create(key).Error
+}
+
+func (i apiKeyDatabaseRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return i.first(ctx, "hash = ?", hash)
+}
+
+func (i apiKeyDatabaseRepository) Get(ctx context.Context, id string) (*models.APIKey, error) {
+	return i.first(ctx, "id = ?", id)
+}
+
+func (i apiKeyDatabaseRepository) first(ctx context.Context, query string, args ...interface{}) (*models.APIKey, error) {
+	var key models.APIKey
+	err := i.db.WithContext(ctx).Where(query, args...).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (i apiKeyDatabaseRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := i.db.WithContext(ctx).Order("created_at, id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (i apiKeyDatabaseRepository) Revoke(ctx context.Context, id string, at time.Time) (bool, error) {
+	res := i.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at.UTC())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (i apiKeyDatabaseRepository) RecordUsage(ctx context.Context, keyID string, requests, denied int64, lastUsedAt time.Time) error {
+	usage := &models.APIKeyDailyUsage{
+		KeyID:    keyID,
+		Day:      lastUsedAt.UTC().Truncate(24 * time.Hour),
+		Requests: requests,
+		Denied:   denied,
+	}
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "key_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"requests": gorm.Expr("api_key_daily_usage.requests + ?", requests),
+				"denied":   gorm.Expr("api_key_daily_usage.denied + ?", denied),
+			}),
+		}).Create(usage).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).
+			Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", keyID, lastUsedAt.UTC()).
+			Update("last_used_at", lastUsedAt.UTC()).Error
+	})
+}
+
+func (i apiKeyDatabaseRepository) ListUsage(ctx context.Context, keyID string, from, to time.Time) ([]models.APIKeyDailyUsage, error) {
+	var usage []models.APIKeyDailyUsage
+	err := i.db.WithContext(ctx).
+		Where("key_id = ? AND day >= ? AND day <= ?", keyID, from.UTC().Truncate(24*time.Hour), to.UTC().Truncate(24*time.Hour)).
+		Order("day").
+		Find(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"search-logger/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyDatabaseRepository_Keys(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewAPIKeyDatabaseRepository(db)
+	ctx := context.Background()
+	key := models.NewAPIKey("storefront", "slk_abcd", "hash-1", []models.APIKeyRole{models.APIKeyRoleIngest}, "acme", nil)
+
+	t.Run("Create and get by hash", func(t *testing.T) {
+		assert.NoError(t, repo.Create(ctx, key))
+
+		found, err := repo.GetByHash(ctx, "hash-1")
+		assert.NoError(t, err)
+		if assert.NotNil(t, found) {
+			assert.Equal(t, key.ID, found.ID)
+			assert.Equal(t, []models.APIKeyRole{models.APIKeyRoleIngest}, found.RoleList())
+			assert.Equal(t, "acme", found.TenantID)
+		}
+
+		missing, err := repo.GetByHash(ctx, "hash-2")
+		assert.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("Reject keys without hash", func(t *testing.T) {
+		assert.Error(t, repo.Create(ctx, models.NewAPIKey("broken", "slk_", "", nil, "", nil)))
+	})
+
+	t.Run("Revoke once", func(t *testing.T) {
+		at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		revoked, err := repo.Revoke(ctx, key.ID, at)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = repo.Revoke(ctx, key.ID, at.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+
+		found, err := repo.Get(ctx, key.ID)
+		assert.NoError(t, err)
+		if assert.NotNil(t, found) && assert.NotNil(t, found.RevokedAt) {
+			assert.True(t, at.Equal(*found.RevokedAt))
+			assert.False(t, found.IsActive(at))
+		}
+
+		keys, err := repo.List(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+	})
+}
+
+func TestAPIKeyDatabaseRepository_Usage(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewAPIKeyDatabaseRepository(db)
+	ctx := context.Background()
+	key := models.NewAPIKey("analyst", "slk_efgh", "hash-1", []models.APIKeyRole{models.APIKeyRoleReadAnalytics}, "", nil)
+	assert.NoError(t, repo.Create(ctx, key))
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// ACT
+	assert.NoError(t, repo.RecordUsage(ctx, key.ID, 3, 1, day.Add(time.Hour)))
+	assert.NoError(t, repo.RecordUsage(ctx, key.ID, 2, 0, day))
+	assert.NoError(t, repo.RecordUsage(ctx, key.ID, 5, 0, day.AddDate(0, 0, 1)))
+	assert.NoError(t, repo.RecordUsage(ctx, key.ID, 7, 0, day.AddDate(0, 0, 5)))
+
+	// ASSERT
+	usage, err := repo.ListUsage(ctx, key.ID, day, day.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	if assert.Len(t, usage, 2) {
+		assert.Equal(t, int64(5), usage[0].Requests)
+		assert.Equal(t, int64(1), usage[0].Denied)
+		assert.Equal(t, int64(5), usage[1].Requests)
+	}
+
+	found, err := repo.Get(ctx, key.ID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) && assert.NotNil(t, found.LastUsedAt) {
+		assert.True(t, day.AddDate(0, 0, 5).Equal(*found.LastUsedAt))
+	}
+}
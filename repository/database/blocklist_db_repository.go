@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"search-logger/tenant"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// purgeBatchSize is how many search logs a purge reads and deletes at a time.
+const purgeBatchSize = 1000
+
+// BlocklistRepository methods are scoped to the tenant on the context.
+type BlocklistRepository interface {
+	Create(ctx context.Context, rule *models.BlocklistRule) error
+	List(ctx context.Context) ([]models.BlocklistRule, error)
+	Delete(ctx context.Context, id string) (bool, error)
+	// IncrementBlocked counts a query blocked by the rule at the given time.
+	IncrementBlocked(ctx context.Context, ruleID string, at time.Time) error
+	// ListBlockedCounts sums the blocked queries of each rule on the UTC days from "from" through "to", by rule ID.
+	ListBlockedCounts(ctx context.Context, from, to time.Time) (map[string]int, error)
+	// PurgeSearchLogs deletes the search logs whose query matches, along with their daily zero-result counts, hourly
+	// rollups, daily distinct clients, clicks and transitions, and returns the deleted queries.
+	PurgeSearchLogs(ctx context.Context, match func(queryText string) bool) ([]string, error)
+}
+
+type blocklistDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewBlocklistDatabaseRepository(db *gorm.DB) BlocklistRepository {
+	return &blocklistDatabaseRepository{db: db}
+}
+
+func (i blocklistDatabaseRepository) Create(ctx context.Context, rule *models.BlocklistRule) error {
+	if rule == nil {
+		return errors.New("blocklist rule cannot be nil")
+	}
+	if !rule.Type.IsValid() {
+		return errors.New("invalid blocklist rule type")
+	}
+	rule.TenantID = tenant.FromContext(ctx)
+	return i.db.WithContext(ctx).Create(rule).Error
+}
+
+func (i blocklistDatabaseRepository) List(ctx context.Context) ([]models.BlocklistRule, error) {
+	var rules []models.BlocklistRule
+	err := i.db.WithContext(ctx).Where("tenant_id = ?", tenant.FromContext(ctx)).Order("created_at, id").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (i blocklistDatabaseRepository) Delete(ctx context.Context, id string) (bool, error) {
+	res := i.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenant.FromContext(ctx), id).Delete(&models.BlocklistRule{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (i blocklistDatabaseRepository) IncrementBlocked(ctx context.Context, ruleID string, at time.Time) error {
+	daily := &models.BlockedQueryCount{
+		TenantID: tenant.FromContext(ctx),
+		RuleID:   ruleID,
+		Day:      at.UTC().Truncate(24 * time.Hour),
+		Count:    1,
+	}
+	return i.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "rule_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("blocked_query_counts.count + ?", 1)}),
+	}).Create(daily).Error
+}
+
+func (i blocklistDatabaseRepository) ListBlockedCounts(ctx context.Context, from, to time.Time) (map[string]int, error) {
+	var rows []struct {
+		RuleID  string
+		Blocked int
+	}
+	err := i.db.WithContext(ctx).Model(&models.BlockedQueryCount{}).
+		Select("rule_id, SUM(count) AS blocked").
+		Where("tenant_id = ? AND day >= ? AND day <= ?", tenant.FromContext(ctx), from.UTC().Truncate(24*time.Hour), to.UTC().Truncate(24*time.Hour)).
+		Group("rule_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.RuleID] = row.Blocked
+	}
+	return counts, nil
+}
+
+// PurgeSearchLogs walks search_logs in id order, deleting each batch's matches in its own transaction so a large purge
+// does not hold one long transaction.
+func (i blocklistDatabaseRepository) PurgeSearchLogs(ctx context.Context, match func(queryText string) bool) ([]string, error) {
+	tenantID := tenant.FromContext(ctx)
+	purged := []string{}
+	var lastID string
+	for {
+		var batch []models.SearchLog
+		err := i.db.WithContext(ctx).Select("id, query_text").
+			Where("tenant_id = ? AND id > ?", tenantID, lastID).
+			Order("id").Limit(purgeBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return purged, nil
+		}
+		lastID = batch[len(batch)-1].ID
+
+		var matched []string
+		for _, searchLog := range batch {
+			if match(searchLog.QueryText) {
+				matched = append(matched, searchLog.QueryText)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		err = i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&models.SearchLog{}, &models.ZeroResultCount{}, &models.QueryHourlyCount{}, &models.QueryDailyUniqueClients{}, &models.QueryResultClick{}} {
+				if err := tx.Where("tenant_id = ? AND query_text IN ?", tenantID, matched).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Where("tenant_id = ? AND (from_query IN ? OR to_query IN ?)", tenantID, matched, matched).
+				Delete(&models.QueryTransition{}).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		purged = append(purged, matched...)
+	}
+}
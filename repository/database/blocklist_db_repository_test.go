@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"search-logger/models"
+	"search-logger/tenant"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocklistDatabaseRepository_Rules(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewBlocklistDatabaseRepository(db)
+	ctx := context.Background()
+
+	t.Run("Create normalizes exact and prefix patterns", func(t *testing.T) {
+		rule := models.NewBlocklistRule(models.BlocklistRulePrefix, " HTTP:// ")
+		assert.NoError(t, repo.Create(ctx, rule))
+		assert.Equal(t, "http://", rule.Pattern)
+
+		regex := models.NewBlocklistRule(models.BlocklistRuleRegex, `^\d+$`)
+		assert.NoError(t, repo.Create(ctx, regex))
+		assert.Equal(t, `^\d+$`, regex.Pattern)
+	})
+
+	t.Run("Reject unknown rule type", func(t *testing.T) {
+		assert.Error(t, repo.Create(ctx, models.NewBlocklistRule("contains", "test")))
+	})
+
+	t.Run("List and delete rules of the tenant", func(t *testing.T) {
+		rules, err := repo.List(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, rules, 2)
+
+		other, err := repo.List(tenant.WithTenant(ctx, "acme"))
+		assert.NoError(t, err)
+		assert.Empty(t, other)
+
+		deleted, err := repo.Delete(tenant.WithTenant(ctx, "acme"), rules[0].ID)
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+
+		deleted, err = repo.Delete(ctx, rules[0].ID)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+	})
+}
+
+func TestBlocklistDatabaseRepository_BlockedCounts(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewBlocklistDatabaseRepository(db)
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, repo.IncrementBlocked(ctx, "rule-1", day))
+	assert.NoError(t, repo.IncrementBlocked(ctx, "rule-1", day.Add(time.Hour)))
+	assert.NoError(t, repo.IncrementBlocked(ctx, "rule-1", day.AddDate(0, 0, 1)))
+	assert.NoError(t, repo.IncrementBlocked(ctx, models.ProfanityRuleID, day))
+	assert.NoError(t, repo.IncrementBlocked(ctx, "rule-1", day.AddDate(0, 0, 5)))
+
+	counts, err := repo.ListBlockedCounts(ctx, day, day.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"rule-1": 3, models.ProfanityRuleID: 1}, counts)
+}
+
+func TestBlocklistDatabaseRepository_PurgeSearchLogs(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewBlocklistDatabaseRepository(db)
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, queryText := range []string{"laptop", "http://spam.example", "http://more.spam", "monitor"} {
+		assert.NoError(t, db.Create(models.NewSearchLog(queryText, 1)).Error)
+	}
+	otherTenant := models.NewSearchLog("http://spam.example", 1)
+	otherTenant.TenantID = "acme"
+	assert.NoError(t, db.Create(otherTenant).Error)
+	assert.NoError(t, db.Create(&models.ZeroResultCount{TenantID: tenant.DefaultTenantID, QueryText: "http://spam.example", Day: day, Count: 1}).Error)
+	assert.NoError(t, db.Create(&models.QueryResultClick{TenantID: tenant.DefaultTenantID, QueryText: "http://spam.example", ResultID: "r1", Clicks: 1}).Error)
+	assert.NoError(t, db.Create(&models.QueryTransition{TenantID: tenant.DefaultTenantID, FromQuery: "laptop", ToQuery: "http://more.spam", Count: 1}).Error)
+
+	purged, err := repo.PurgeSearchLogs(ctx, func(queryText string) bool {
+		return strings.HasPrefix(queryText, "http://")
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"http://spam.example", "http://more.spam"}, purged)
+
+	var remaining []string
+	assert.NoError(t, db.Model(&models.SearchLog{}).Order("query_text").Pluck("query_text", &remaining).Error)
+	assert.Equal(t, []string{"http://spam.example", "laptop", "monitor"}, remaining, "other tenants are untouched")
+
+	for _, model := range []interface{}{&models.ZeroResultCount{}, &models.QueryResultClick{}, &models.QueryTransition{}} {
+		var count int64
+		assert.NoError(t, db.Model(model).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	}
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"search-logger/tenant"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClickRepository methods are scoped to the tenant on the context.
+type ClickRepository interface {
+	// RecordClick adds a click on a result of a query at a 1-based position.
+	RecordClick(ctx context.Context, queryText, resultID string, position int) error
+	// IncrementClickedSearches counts one more search of the query as clicked, for CTR. It returns false when the
+	// query has not been logged yet.
+	IncrementClickedSearches(ctx context.Context, queryText string) (bool, error)
+	// GetQueryClickStats returns a query's click statistics with its most clicked results, or nil when it has no searches or clicks.
+	GetQueryClickStats(ctx context.Context, queryText string, resultLimit int) (*models.QueryClickStats, error)
+	// TopClickedQueries returns click statistics of the queries with the most clicks, without per-result breakdown.
+	TopClickedQueries(ctx context.Context, limit int) ([]models.QueryClickStats, error)
+}
+
+type clickDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewClickDatabaseRepository(db *gorm.DB) ClickRepository {
+	return &clickDatabaseRepository{db: db}
+}
+
+func (i clickDatabaseRepository) RecordClick(ctx context.Context, queryText, resultID string, position int) error {
+	if queryText == "" || resultID == "" {
+		return errors.New("query text and result id cannot be empty")
+	}
+	if position < 1 {
+		return errors.New("position must be at least 1")
+	}
+
+	click := &models.QueryResultClick{
+		TenantID:    tenant.FromContext(ctx),
+		QueryText:   strings.ToLower(strings.TrimSpace(queryText)),
+		ResultID:    resultID,
+		Clicks:      1,
+		PositionSum: position,
+	}
+	return i.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}, {Name: "query_text"}, {Name: "result_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"clicks":       gorm.Expr("query_result_clicks.clicks + ?", 1),
+			"position_sum": gorm.Expr("query_result_clicks.position_sum + ?", position),
+			"updated_at":   gorm.Expr("CURRENT_TIMESTAMP"),
+		}),
+	}).Create(click).Error
+}
+
+func (i clickDatabaseRepository) IncrementClickedSearches(ctx context.Context, queryText string) (bool, error) {
+	res := i.db.WithContext(ctx).Model(&models.SearchLog{}).
+		Where("tenant_id = ? AND query_text = ?", tenant.FromContext(ctx), strings.ToLower(strings.TrimSpace(queryText))).
+		UpdateColumn("clicked_search_count", gorm.Expr("clicked_search_count + ?", 1))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+type queryClickTotals struct {
+	QueryText   string
+	Clicks      int
+	PositionSum int
+}
+
+func (i clickDatabaseRepository) GetQueryClickStats(ctx context.Context, queryText string, resultLimit int) (*models.QueryClickStats, error) {
+	if queryText == "" {
+		return nil, errors.New("query text cannot be empty")
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	queryText = strings.ToLower(strings.TrimSpace(queryText))
+
+	var searchLog models.SearchLog
+	err := i.db.WithContext(ctx).Where("tenant_id = ? AND query_text = ?", tenantID, queryText).First(&searchLog).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var clicks []models.QueryResultClick
+	err = i.db.WithContext(ctx).Where("tenant_id = ? AND query_text = ?", tenantID, queryText).
+		Order("clicks DESC, result_id").
+		Find(&clicks).Error
+	if err != nil {
+		return nil, err
+	}
+	if searchLog.ID == "" && len(clicks) == 0 {
+		return nil, nil
+	}
+
+	totals := queryClickTotals{QueryText: queryText}
+	for _, click := range clicks {
+		totals.Clicks += click.Clicks
+		totals.PositionSum += click.PositionSum
+	}
+	stats := newQueryClickStats(totals, searchLog)
+
+	for idx, click := range clicks {
+		if idx >= resultLimit {
+			break
+		}
+		stats.Results = append(stats.Results, models.ResultClickStats{
+			ResultID:          click.ResultID,
+			Clicks:            click.Clicks,
+			MeanClickPosition: meanPosition(click.PositionSum, click.Clicks),
+		})
+	}
+	return stats, nil
+}
+
+func (i clickDatabaseRepository) TopClickedQueries(ctx context.Context, limit int) ([]models.QueryClickStats, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	var totals []queryClickTotals
+	err := i.db.WithContext(ctx).Model(&models.QueryResultClick{}).
+		Select("query_text, SUM(clicks) AS clicks, SUM(position_sum) AS position_sum").
+		Where("tenant_id = ?", tenantID).
+		Group("query_text").
+		Order("clicks DESC, query_text").
+		Limit(limit).
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(totals) == 0 {
+		return []models.QueryClickStats{}, nil
+	}
+
+	queryTexts := make([]string, 0, len(totals))
+	for _, total := range totals {
+		queryTexts = append(queryTexts, total.QueryText)
+	}
+	var searchLogs []models.SearchLog
+	if err := i.db.WithContext(ctx).Where("tenant_id = ? AND query_text IN ?", tenantID, queryTexts).Find(&searchLogs).Error; err != nil {
+		return nil, err
+	}
+	searchLogsByQuery := make(map[string]models.SearchLog, len(searchLogs))
+	for _, searchLog := range searchLogs {
+		searchLogsByQuery[searchLog.QueryText] = searchLog
+	}
+
+	stats := make([]models.QueryClickStats, 0, len(totals))
+	for _, total := range totals {
+		searchLog := searchLogsByQuery[total.QueryText]
+		stats = append(stats, *newQueryClickStats(total, searchLog))
+	}
+	return stats, nil
+}
+
+// newQueryClickStats combines the clicks of a query with its search log, which is zero when it was not counted.
+func newQueryClickStats(totals queryClickTotals, searchLog models.SearchLog) *models.QueryClickStats {
+	stats := &models.QueryClickStats{
+		QueryText:          totals.QueryText,
+		SearchCount:        searchLog.Count,
+		ClickedSearchCount: searchLog.ClickedSearchCount,
+		UniqueClients:      searchLog.UniqueClients,
+		Clicks:             totals.Clicks,
+		MeanClickPosition:  meanPosition(totals.PositionSum, totals.Clicks),
+	}
+	if searchLog.Count > 0 {
+		// Clicks on a search that was filtered out before being counted can push this above 1.
+		stats.CTR = min(float64(searchLog.ClickedSearchCount)/float64(searchLog.Count), 1)
+	}
+	return stats
+}
+
+func meanPosition(positionSum, clicks int) float64 {
+	if clicks == 0 {
+		return 0
+	}
+	return float64(positionSum) / float64(clicks)
+}
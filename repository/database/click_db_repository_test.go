@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClickDatabaseRepository_RecordClick(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewClickDatabaseRepository(db)
+	searchLogRepo := NewSearchLogDatabaseRepository(db)
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		_, err := searchLogRepo.IncrementSearchLog(ctx, "laptop")
+		assert.NoError(t, err)
+	}
+
+	t.Run("Aggregate clicks per query and result", func(t *testing.T) {
+		assert.NoError(t, repo.RecordClick(ctx, "Laptop", "sku-1", 1))
+		assert.NoError(t, repo.RecordClick(ctx, "laptop", "sku-1", 3))
+		assert.NoError(t, repo.RecordClick(ctx, "laptop", "sku-2", 2))
+
+		counted, err := repo.IncrementClickedSearches(ctx, "laptop")
+		assert.NoError(t, err)
+		assert.True(t, counted)
+
+		stats, err := repo.GetQueryClickStats(ctx, "laptop", 10)
+		assert.NoError(t, err)
+		assert.NotNil(t, stats)
+		assert.Equal(t, 4, stats.SearchCount)
+		assert.Equal(t, 1, stats.ClickedSearchCount)
+		assert.Equal(t, 0.25, stats.CTR)
+		assert.Equal(t, 3, stats.Clicks)
+		assert.Equal(t, 2.0, stats.MeanClickPosition)
+		assert.Len(t, stats.Results, 2)
+		assert.Equal(t, "sku-1", stats.Results[0].ResultID)
+		assert.Equal(t, 2, stats.Results[0].Clicks)
+		assert.Equal(t, 2.0, stats.Results[0].MeanClickPosition)
+	})
+
+	t.Run("Clicked searches of unlogged queries are not counted", func(t *testing.T) {
+		counted, err := repo.IncrementClickedSearches(ctx, "not logged")
+		assert.NoError(t, err)
+		assert.False(t, counted)
+	})
+
+	t.Run("Reject invalid clicks", func(t *testing.T) {
+		assert.Error(t, repo.RecordClick(ctx, "laptop", "", 1))
+		assert.Error(t, repo.RecordClick(ctx, "laptop", "sku-1", 0))
+	})
+
+	t.Run("Unknown query has no stats", func(t *testing.T) {
+		stats, err := repo.GetQueryClickStats(ctx, "unknown", 10)
+		assert.NoError(t, err)
+		assert.Nil(t, stats)
+	})
+}
+
+func TestClickDatabaseRepository_TopClickedQueries(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewClickDatabaseRepository(db)
+	ctx := context.Background()
+
+	assert.NoError(t, repo.RecordClick(ctx, "tv", "tv-1", 1))
+	assert.NoError(t, repo.RecordClick(ctx, "phone", "phone-1", 1))
+	assert.NoError(t, repo.RecordClick(ctx, "phone", "phone-2", 5))
+
+	stats, err := repo.TopClickedQueries(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "phone", stats[0].QueryText)
+	assert.Equal(t, 2, stats[0].Clicks)
+	assert.Equal(t, 3.0, stats[0].MeanClickPosition)
+	assert.Equal(t, "tv", stats[1].QueryText)
+	assert.Equal(t, 0.0, stats[1].CTR)
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"search-logger/tenant"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuarantineRepository methods are scoped to the tenant on the context.
+type QuarantineRepository interface {
+	Create(ctx context.Context, search *models.QuarantinedSearch) error
+	// ListPending returns unreleased searches, newest first, optionally restricted to one client.
+	ListPending(ctx context.Context, clientIdentifier string, limit int) ([]models.QuarantinedSearch, error)
+	// Release marks a quarantined search as released and returns it, leaving counting it to the caller. It returns
+	// nil when the search does not exist or was already released.
+	Release(ctx context.Context, id string) (*models.QuarantinedSearch, error)
+	// ReleaseClient releases every pending search of a client like Release, and returns them oldest first. On error,
+	// the searches released so far are returned along with it.
+	ReleaseClient(ctx context.Context, clientIdentifier string) ([]models.QuarantinedSearch, error)
+}
+
+type quarantineDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewQuarantineDatabaseRepository(db *gorm.DB) QuarantineRepository {
+	return &quarantineDatabaseRepository{db: db}
+}
+
+func (i quarantineDatabaseRepository) Create(ctx context.Context, search *models.QuarantinedSearch) error {
+	if search == nil || search.QueryText == "" {
+		return errors.New("quarantined search query cannot be empty")
+	}
+	search.TenantID = tenant.FromContext(ctx)
+	return i.db.WithContext(ctx).Create(search).Error
+}
+
+func (i quarantineDatabaseRepository) ListPending(ctx context.Context, clientIdentifier string, limit int) ([]models.QuarantinedSearch, error) {
+	query := i.db.WithContext(ctx).Where("tenant_id = ? AND released_at IS NULL", tenant.FromContext(ctx))
+	if clientIdentifier != "" {
+		query = query.Where("client_identifier = ?", clientIdentifier)
+	}
+
+	var searches []models.QuarantinedSearch
+	if err := query.Order("created_at DESC").Limit(limit).Find(&searches).Error; err != nil {
+		return nil, err
+	}
+	return searches, nil
+}
+
+func (i quarantineDatabaseRepository) Release(ctx context.Context, id string) (*models.QuarantinedSearch, error) {
+	var search models.QuarantinedSearch
+	err := i.db.WithContext(ctx).Where("tenant_id = ? AND id = ? AND released_at IS NULL", tenant.FromContext(ctx), id).First(&search).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	released, err := i.markReleased(ctx, &search)
+	if err != nil || !released {
+		return nil, err
+	}
+	return &search, nil
+}
+
+func (i quarantineDatabaseRepository) ReleaseClient(ctx context.Context, clientIdentifier string) ([]models.QuarantinedSearch, error) {
+	if clientIdentifier == "" {
+		return nil, errors.New("client identifier cannot be empty")
+	}
+
+	var searches []models.QuarantinedSearch
+	err := i.db.WithContext(ctx).Where("tenant_id = ? AND client_identifier = ? AND released_at IS NULL", tenant.FromContext(ctx), clientIdentifier).
+		Order("created_at, id").
+		Find(&searches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	released := make([]models.QuarantinedSearch, 0, len(searches))
+	for idx := range searches {
+		ok, err := i.markReleased(ctx, &searches[idx])
+		if err != nil {
+			return released, err
+		}
+		if ok {
+			released = append(released, searches[idx])
+		}
+	}
+	return released, nil
+}
+
+// markReleased guards on released_at so that concurrent releases of the same search only return it once.
+func (i quarantineDatabaseRepository) markReleased(ctx context.Context, search *models.QuarantinedSearch) (bool, error) {
+	now := time.Now()
+	res := i.db.WithContext(ctx).Model(&models.QuarantinedSearch{}).
+		Where("id = ? AND released_at IS NULL", search.ID).
+		Update("released_at", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	search.ReleasedAt = &now
+	return true, nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"search-logger/models"
+	"search-logger/tenant"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantineDatabaseRepository_ListPending(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewQuarantineDatabaseRepository(db)
+	ctx := context.Background()
+
+	assert.NoError(t, repo.Create(ctx, models.NewQuarantinedSearch("bot-1", "  Cheap Pills ", testQuarantineReason, "curl/8.0")))
+	assert.NoError(t, repo.Create(ctx, models.NewQuarantinedSearch("bot-1", "free money", testQuarantineReason, "curl/8.0")))
+	assert.NoError(t, repo.Create(ctx, models.NewQuarantinedSearch("bot-2", "laptop", testQuarantineReason, "")))
+
+	t.Run("List all pending searches", func(t *testing.T) {
+		searches, err := repo.ListPending(ctx, "", 10)
+		assert.NoError(t, err)
+		assert.Len(t, searches, 3)
+	})
+
+	t.Run("List pending searches of one client", func(t *testing.T) {
+		searches, err := repo.ListPending(ctx, "bot-1", 10)
+		assert.NoError(t, err)
+		assert.Len(t, searches, 2)
+		for _, search := range searches {
+			assert.Equal(t, "bot-1", search.ClientIdentifier)
+		}
+	})
+
+	t.Run("Reject empty query", func(t *testing.T) {
+		err := repo.Create(ctx, models.NewQuarantinedSearch("bot-1", "", testQuarantineReason, ""))
+		assert.Error(t, err)
+	})
+}
+
+func TestQuarantineDatabaseRepository_Release(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewQuarantineDatabaseRepository(db)
+	ctx := context.Background()
+
+	t.Run("Release returns the search once", func(t *testing.T) {
+		search := models.NewQuarantinedSearch("client", "Released Query", testQuarantineReason, "")
+		assert.NoError(t, repo.Create(ctx, search))
+
+		released, err := repo.Release(ctx, search.ID)
+		assert.NoError(t, err)
+		if assert.NotNil(t, released) {
+			assert.Equal(t, "released query", released.QueryText)
+			assert.NotNil(t, released.ReleasedAt)
+		}
+		released, err = repo.Release(ctx, search.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, released)
+
+		pending, err := repo.ListPending(ctx, "client", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("Release every pending search of a client", func(t *testing.T) {
+		for _, queryText := range []string{"monitor", "monitor arm", "monitor stand"} {
+			assert.NoError(t, repo.Create(ctx, models.NewQuarantinedSearch("false-positive", queryText, testQuarantineReason, "")))
+		}
+		assert.NoError(t, repo.Create(ctx, models.NewQuarantinedSearch("real-bot", "monitor", testQuarantineReason, "")))
+
+		released, err := repo.ReleaseClient(ctx, "false-positive")
+		assert.NoError(t, err)
+		assert.Len(t, released, 3)
+		for _, search := range released {
+			assert.Equal(t, "false-positive", search.ClientIdentifier)
+		}
+
+		pending, err := repo.ListPending(ctx, "", 10)
+		assert.NoError(t, err)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, "real-bot", pending[0].ClientIdentifier)
+	})
+}
+
+const testQuarantineReason = "user_agent"
+
+func TestQuarantineDatabaseRepository_TenantScoping(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewQuarantineDatabaseRepository(db)
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	search := models.NewQuarantinedSearch("bot", "acme only", testQuarantineReason, "")
+	assert.NoError(t, repo.Create(acme, search))
+
+	pending, err := repo.ListPending(globex, "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	released, err := repo.Release(globex, search.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, released)
+
+	released, err = repo.Release(acme, search.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, released)
+}
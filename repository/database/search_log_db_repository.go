@@ -2,18 +2,59 @@ package database
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"search-logger/models"
+	"search-logger/tenant"
+	"search-logger/tracing"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// SearchLogRepository methods are scoped to the tenant on the context.
 type SearchLogRepository interface {
+	// IncrementSearchLog counts a search for queryText, in its all-time count and in the current hour's rollup.
 	IncrementSearchLog(ctx context.Context, queryText string) (*models.SearchLog, error)
+	// IncrementSearchLogAt counts a search like IncrementSearchLog, in the rollup of the hour it was searched at.
+	IncrementSearchLogAt(ctx context.Context, queryText string, searchedAt time.Time) (*models.SearchLog, error)
 	GetByQueryText(ctx context.Context, queryText string) (*models.SearchLog, error)
+	// IncrementZeroResult records that a search for an already logged query returned no results at the given time.
+	// It returns ErrSearchLogNotFound when the query has not been logged.
+	IncrementZeroResult(ctx context.Context, queryText string, at time.Time) error
+	// TopZeroResultQueries ranks queries by their zero-result searches on the UTC days from "from" through "to".
+	TopZeroResultQueries(ctx context.Context, from, to time.Time, limit int) ([]models.ZeroResultQuery, error)
+	// ListQueryCounts returns up to limit queries searched at least minCount times, most searched first.
+	ListQueryCounts(ctx context.Context, minCount, limit int) ([]models.SearchLog, error)
+	// ListSearchLogs returns a page of search logs matching filter, using keyset pagination. It returns
+	// ErrInvalidCursor when the cursor is malformed or was issued for another sort.
+	ListSearchLogs(ctx context.Context, filter models.SearchLogFilter) (*models.SearchLogPage, error)
+	// ListHourlyCounts returns the hourly rollups of queryText for the UTC hours starting from "from" until "to",
+	// oldest first. Hours without searches have no rollup.
+	ListHourlyCounts(ctx context.Context, queryText string, from, to time.Time) ([]models.QueryHourlyCount, error)
+	// SaveUniqueClients snapshots the estimated distinct clients of queryText, all-time in its search log and on the
+	// UTC day containing day. Nothing is saved once the search log is gone, e.g. purged or merged into a synonym. A
+	// snapshot never lowers an estimate, so the largest one seen by any replica is kept.
+	SaveUniqueClients(ctx context.Context, queryText string, day time.Time, total, daily int64) error
+	// ListDailyUniqueClients returns the snapshots of queryText for the UTC days starting from "from" until "to",
+	// oldest first.
+	ListDailyUniqueClients(ctx context.Context, queryText string, from, to time.Time) ([]models.QueryDailyUniqueClients, error)
 }
 
+var (
+	ErrSearchLogNotFound = errors.New("search log not found")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+)
+
+// searchLogTotalCap bounds the rows counted for SearchLogPage.TotalEstimate, so broad filters stay cheap.
+const searchLogTotalCap = 10000
+
 type searchLogDatabaseRepository struct {
 	db *gorm.DB
 }
@@ -23,35 +64,33 @@ func NewSearchLogDatabaseRepository(db *gorm.DB) SearchLogRepository {
 }
 
 func (i searchLogDatabaseRepository) IncrementSearchLog(ctx context.Context, queryText string) (*models.SearchLog, error) {
+	return i.IncrementSearchLogAt(ctx, queryText, time.Now())
+}
+
+func (i searchLogDatabaseRepository) IncrementSearchLogAt(ctx context.Context, queryText string, searchedAt time.Time) (_ *models.SearchLog, err error) {
+	ctx, span := tracing.Start(ctx, "db.IncrementSearchLog", tracing.WithKind(tracing.SpanKindClient), tracing.WithAttributes(
+		tracing.String("db.system", i.db.Dialector.Name()),
+		tracing.String("tenant", tenant.FromContext(ctx)),
+	))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	if queryText == "" {
 		return nil, errors.New("search log cannot be nil")
 	}
 
 	queryText = strings.ToLower(strings.TrimSpace(queryText))
 	var result *models.SearchLog
-	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var existing models.SearchLog
-		err := tx.Where("query_text = ?", queryText).First(&existing).Error
-
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+	err = i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		tenantID := tenant.FromContext(ctx)
+		result, err = incrementSearchLog(tx, tenantID, queryText, 1)
+		if err != nil {
 			return err
 		}
-
-		if existing.ID != "" {
-			existing.Count += 1
-			if err = tx.Save(&existing).Error; err != nil {
-				return err
-			}
-			result = &existing
-		} else {
-			searchLog := models.NewSearchLog(queryText, 1)
-			if err = tx.Create(searchLog).Error; err != nil {
-				return err
-			}
-			result = searchLog
-		}
-
-		return nil
+		return incrementHourlyCount(tx, tenantID, queryText, searchedAt, 1)
 	})
 
 	if err != nil {
@@ -61,13 +100,38 @@ func (i searchLogDatabaseRepository) IncrementSearchLog(ctx context.Context, que
 	return result, nil
 }
 
+// incrementSearchLog adds delta to the count of a tenant's normalized query inside an existing transaction, creating the row if needed.
+// The upsert only touches count and updated_at, so concurrent updates of the other counters of the row are not overwritten.
+func incrementSearchLog(tx *gorm.DB, tenantID, queryText string, delta int) (*models.SearchLog, error) {
+	searchLog := models.NewSearchLog(queryText, delta)
+	searchLog.TenantID = tenantID
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}, {Name: "query_text"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":      gorm.Expr("search_logs.count + ?", delta),
+			"updated_at": time.Now(),
+		}),
+	}).Create(searchLog).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.SearchLog
+	if err = tx.Where("tenant_id = ? AND query_text = ?", tenantID, queryText).First(&result).Error; err != nil {
+		return nil, err
+	}
+	result.PreviousCount = result.Count - delta
+	return &result, nil
+}
+
 func (i searchLogDatabaseRepository) GetByQueryText(ctx context.Context, queryText string) (*models.SearchLog, error) {
 	if queryText == "" {
 		return nil, errors.New("query text cannot be empty")
 	}
 
 	var searchLog models.SearchLog
-	err := i.db.WithContext(ctx).Where("query_text = ?", strings.ToLower(strings.TrimSpace(queryText))).
+	err := i.db.WithContext(ctx).
+		Where("tenant_id = ? AND query_text = ?", tenant.FromContext(ctx), strings.ToLower(strings.TrimSpace(queryText))).
 		First(&searchLog).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -78,3 +142,307 @@ func (i searchLogDatabaseRepository) GetByQueryText(ctx context.Context, queryTe
 
 	return &searchLog, nil
 }
+
+// incrementHourlyCount adds delta to the rollup of the UTC hour containing at, inside an existing transaction.
+func incrementHourlyCount(tx *gorm.DB, tenantID, queryText string, at time.Time, delta int) error {
+	hourly := &models.QueryHourlyCount{
+		TenantID:  tenantID,
+		QueryText: queryText,
+		Hour:      at.UTC().Truncate(time.Hour),
+		Count:     delta,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "query_text"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("query_hourly_counts.count + ?", delta)}),
+	}).Create(hourly).Error
+}
+
+func (i searchLogDatabaseRepository) IncrementZeroResult(ctx context.Context, queryText string, at time.Time) error {
+	if queryText == "" {
+		return errors.New("query text cannot be empty")
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	queryText = strings.ToLower(strings.TrimSpace(queryText))
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.SearchLog{}).
+			Where("tenant_id = ? AND query_text = ?", tenantID, queryText).
+			UpdateColumn("zero_result_count", gorm.Expr("zero_result_count + ?", 1))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrSearchLogNotFound
+		}
+
+		daily := &models.ZeroResultCount{
+			TenantID:  tenantID,
+			QueryText: queryText,
+			Day:       at.UTC().Truncate(24 * time.Hour),
+			Count:     1,
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "query_text"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("zero_result_counts.count + ?", 1)}),
+		}).Create(daily).Error
+	})
+}
+
+func (i searchLogDatabaseRepository) TopZeroResultQueries(ctx context.Context, from, to time.Time, limit int) ([]models.ZeroResultQuery, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	var results []models.ZeroResultQuery
+	err := i.db.WithContext(ctx).
+		Table("zero_result_counts AS z").
+		Select("z.query_text AS query_text, SUM(z.count) AS zero_result_count, COALESCE(MAX(s.count), 0) AS search_count, COALESCE(MAX(s.unique_clients), 0) AS unique_clients").
+		Joins("LEFT JOIN search_logs AS s ON s.tenant_id = z.tenant_id AND s.query_text = z.query_text").
+		Where("z.tenant_id = ? AND z.day >= ? AND z.day <= ?", tenant.FromContext(ctx), from.UTC().Truncate(24*time.Hour), to.UTC()).
+		Group("z.query_text").
+		Order("zero_result_count DESC, z.query_text").
+		Limit(limit).
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (i searchLogDatabaseRepository) ListHourlyCounts(ctx context.Context, queryText string, from, to time.Time) ([]models.QueryHourlyCount, error) {
+	var hourly []models.QueryHourlyCount
+	err := i.db.WithContext(ctx).
+		Where("tenant_id = ? AND query_text = ? AND hour >= ? AND hour < ?",
+			tenant.FromContext(ctx), strings.ToLower(strings.TrimSpace(queryText)), from.UTC(), to.UTC()).
+		Order("hour").
+		Find(&hourly).Error
+	if err != nil {
+		return nil, err
+	}
+	return hourly, nil
+}
+
+func (i searchLogDatabaseRepository) SaveUniqueClients(ctx context.Context, queryText string, day time.Time, total, daily int64) error {
+	if queryText == "" {
+		return errors.New("query text cannot be empty")
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	queryText = strings.ToLower(strings.TrimSpace(queryText))
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A query purged meanwhile, e.g. by another replica, has no search log left to snapshot.
+		var searchLogs int64
+		err := tx.Model(&models.SearchLog{}).Where("tenant_id = ? AND query_text = ?", tenantID, queryText).Count(&searchLogs).Error
+		if err != nil || searchLogs == 0 {
+			return err
+		}
+		err = tx.Model(&models.SearchLog{}).
+			Where("tenant_id = ? AND query_text = ? AND unique_clients < ?", tenantID, queryText, total).
+			UpdateColumn("unique_clients", total).Error
+		if err != nil {
+			return err
+		}
+		return saveDailyUniqueClients(tx, tenantID, queryText, day, daily)
+	})
+}
+
+// saveDailyUniqueClients snapshots the distinct clients of queryText on the UTC day containing day, inside an existing
+// transaction, keeping the larger of the new and current estimates.
+func saveDailyUniqueClients(tx *gorm.DB, tenantID, queryText string, day time.Time, uniqueClients int64) error {
+	snapshot := &models.QueryDailyUniqueClients{
+		TenantID:      tenantID,
+		QueryText:     queryText,
+		Day:           day.UTC().Truncate(24 * time.Hour),
+		UniqueClients: uniqueClients,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}, {Name: "query_text"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"unique_clients": gorm.Expr(
+			"CASE WHEN query_daily_unique_clients.unique_clients < ? THEN ? ELSE query_daily_unique_clients.unique_clients END", uniqueClients, uniqueClients,
+		)}),
+	}).Create(snapshot).Error
+}
+
+func (i searchLogDatabaseRepository) ListDailyUniqueClients(ctx context.Context, queryText string, from, to time.Time) ([]models.QueryDailyUniqueClients, error) {
+	var daily []models.QueryDailyUniqueClients
+	err := i.db.WithContext(ctx).
+		Where("tenant_id = ? AND query_text = ? AND day >= ? AND day < ?",
+			tenant.FromContext(ctx), strings.ToLower(strings.TrimSpace(queryText)), from.UTC(), to.UTC()).
+		Order("day").
+		Find(&daily).Error
+	if err != nil {
+		return nil, err
+	}
+	return daily, nil
+}
+
+func (i searchLogDatabaseRepository) ListQueryCounts(ctx context.Context, minCount, limit int) ([]models.SearchLog, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	var searchLogs []models.SearchLog
+	err := i.db.WithContext(ctx).
+		Select("query_text", "count").
+		Where("tenant_id = ? AND count >= ?", tenant.FromContext(ctx), minCount).
+		Order("count DESC, query_text").
+		Limit(limit).
+		Find(&searchLogs).Error
+	if err != nil {
+		return nil, err
+	}
+	return searchLogs, nil
+}
+
+// searchLogCursor is the position of a search log in a sort order. Backward cursors list the page before it.
+type searchLogCursor struct {
+	Sort     models.SearchLogSort `json:"s"`
+	Value    string               `json:"v"`
+	ID       string               `json:"id"`
+	Backward bool                 `json:"b,omitempty"`
+}
+
+func encodeSearchLogCursor(sort models.SearchLogSort, searchLog models.SearchLog, backward bool) string {
+	cursor := searchLogCursor{Sort: sort, ID: searchLog.ID, Backward: backward}
+	switch sort {
+	case models.SearchLogSortCount:
+		cursor.Value = strconv.Itoa(searchLog.Count)
+	case models.SearchLogSortUpdatedAt:
+		cursor.Value = searchLog.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case models.SearchLogSortQuery:
+		cursor.Value = searchLog.QueryText
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSearchLogCursor(encoded string, sort models.SearchLogSort) (*searchLogCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor searchLogCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Sort != sort || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// cursorValue returns the cursor's sort key as the type of its column.
+func (cursor searchLogCursor) cursorValue() (interface{}, error) {
+	switch cursor.Sort {
+	case models.SearchLogSortCount:
+		count, err := strconv.Atoi(cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return count, nil
+	case models.SearchLogSortUpdatedAt:
+		updatedAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return updatedAt.UTC(), nil
+	default:
+		return cursor.Value, nil
+	}
+}
+
+func (i searchLogDatabaseRepository) ListSearchLogs(ctx context.Context, filter models.SearchLogFilter) (*models.SearchLogPage, error) {
+	if filter.Limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	if filter.Sort == "" {
+		filter.Sort = models.SearchLogSortCount
+	}
+	if !filter.Sort.IsValid() {
+		return nil, fmt.Errorf("invalid sort %q", filter.Sort)
+	}
+
+	query := i.db.WithContext(ctx).Model(&models.SearchLog{}).Where("tenant_id = ?", tenant.FromContext(ctx))
+	if contains := strings.ToLower(strings.TrimSpace(filter.Contains)); contains != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(contains)
+		query = query.Where(`query_text LIKE ? ESCAPE '\'`, "%"+escaped+"%")
+	}
+	if filter.MinCount > 0 {
+		query = query.Where("count >= ?", filter.MinCount)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		query = query.Where("updated_at > ?", filter.UpdatedAfter.UTC())
+	}
+
+	var total int64
+	if err := i.db.WithContext(ctx).Table("(?) AS matching", query.Session(&gorm.Session{}).Select("id").Limit(searchLogTotalCap+1)).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// Count and updated_at list the largest first, query lists alphabetically. The id breaks ties between equal keys.
+	column, descending := "count", true
+	switch filter.Sort {
+	case models.SearchLogSortUpdatedAt:
+		column = "updated_at"
+	case models.SearchLogSortQuery:
+		column, descending = "query_text", false
+	}
+
+	var cursor *searchLogCursor
+	if filter.Cursor != "" {
+		var err error
+		if cursor, err = decodeSearchLogCursor(filter.Cursor, filter.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	backward := cursor != nil && cursor.Backward
+	// Walking backward flips the order, and the rows are put back in display order below.
+	scanDescending := descending != backward
+	direction, comparison := "ASC", ">"
+	if scanDescending {
+		direction, comparison = "DESC", "<"
+	}
+	if cursor != nil {
+		value, err := cursor.cursorValue()
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(
+			fmt.Sprintf("%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?)", column, comparison),
+			value, value, cursor.ID,
+		)
+	}
+
+	var searchLogs []models.SearchLog
+	err := query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(filter.Limit + 1).
+		Find(&searchLogs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(searchLogs) > filter.Limit
+	if hasMore {
+		searchLogs = searchLogs[:filter.Limit]
+	}
+	if backward {
+		slices.Reverse(searchLogs)
+	}
+
+	page := &models.SearchLogPage{
+		SearchLogs:    searchLogs,
+		TotalEstimate: min(total, searchLogTotalCap),
+		TotalIsExact:  total <= searchLogTotalCap,
+	}
+	if len(searchLogs) == 0 {
+		return page, nil
+	}
+	first, last := searchLogs[0], searchLogs[len(searchLogs)-1]
+	// Rows exist past the page in the direction it was listed when there was one more row, and in the other direction
+	// whenever the page was reached with a cursor.
+	if hasMore || backward {
+		page.NextCursor = encodeSearchLogCursor(filter.Sort, last, false)
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		page.PrevCursor = encodeSearchLogCursor(filter.Sort, first, true)
+	}
+	return page, nil
+}
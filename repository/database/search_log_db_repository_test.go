@@ -4,7 +4,9 @@ import (
 	"context"
 	"search-logger/models"
 	"search-logger/storage_util"
+	"search-logger/tenant"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
@@ -14,8 +16,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db := storage_util.InitDB()
 	assert.NotNil(t, db)
 
-	err := db.AutoMigrate(&models.SearchLog{})
-	assert.NoError(t, err)
+	assert.NoError(t, storage_util.Migrate(db))
 
 	return db
 }
@@ -30,6 +31,7 @@ func TestSearchLogDatabaseRepository_Upsert(t *testing.T) {
 		assert.NotNil(t, result)
 		assert.Equal(t, "test-query", result.QueryText)
 		assert.Equal(t, 1, result.Count)
+		assert.Equal(t, 0, result.PreviousCount)
 	})
 
 	t.Run("Update existing record", func(t *testing.T) {
@@ -41,6 +43,23 @@ func TestSearchLogDatabaseRepository_Upsert(t *testing.T) {
 		assert.NotNil(t, result)
 		assert.Equal(t, tq, result.QueryText)
 		assert.Equal(t, 3, result.Count) // Count should be updated
+		assert.Equal(t, 2, result.PreviousCount)
+	})
+
+	t.Run("Other counters are left alone", func(t *testing.T) {
+		// ARRANGE
+		tq := "test-query"
+		assert.NoError(t, repo.IncrementZeroResult(context.Background(), tq, time.Now()))
+		assert.NoError(t, repo.SaveUniqueClients(context.Background(), tq, time.Now(), 7, 7))
+
+		// ACT
+		result, err := repo.IncrementSearchLog(context.Background(), tq)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Count)
+		assert.Equal(t, 1, result.ZeroResultCount)
+		assert.Equal(t, int64(7), result.UniqueClients)
 	})
 }
 
@@ -65,3 +84,342 @@ func TestSearchLogDatabaseRepository_GetByQueryText(t *testing.T) {
 		assert.Nil(t, result)
 	})
 }
+
+func TestSearchLogDatabaseRepository_TenantScoping(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSearchLogDatabaseRepository(db)
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	t.Run("Same query is counted separately per tenant", func(t *testing.T) {
+		_, err := repo.IncrementSearchLog(acme, "tv")
+		assert.NoError(t, err)
+		_, err = repo.IncrementSearchLog(acme, "tv")
+		assert.NoError(t, err)
+		result, err := repo.IncrementSearchLog(globex, "tv")
+		assert.NoError(t, err)
+		assert.Equal(t, "globex", result.TenantID)
+		assert.Equal(t, 1, result.Count)
+
+		result, err = repo.GetByQueryText(acme, "tv")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.Count)
+		result, err = repo.GetByQueryText(globex, "tv")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Count)
+	})
+
+	t.Run("Queries of other tenants are not visible", func(t *testing.T) {
+		result, err := repo.GetByQueryText(context.Background(), "tv")
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestSearchLogDatabaseRepository_ZeroResults(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSearchLogDatabaseRepository(db)
+	ctx := context.Background()
+	day1 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	day3 := day2.Add(24 * time.Hour)
+
+	for _, queryText := range []string{"rare widget", "unknown brand", "laptop"} {
+		_, err := repo.IncrementSearchLog(ctx, queryText)
+		assert.NoError(t, err)
+	}
+
+	t.Run("Increment zero-result counts", func(t *testing.T) {
+		assert.NoError(t, repo.IncrementZeroResult(ctx, "rare widget", day1))
+		assert.NoError(t, repo.IncrementZeroResult(ctx, "rare widget", day1.Add(time.Hour)))
+		assert.NoError(t, repo.IncrementZeroResult(ctx, "rare widget", day3))
+		assert.NoError(t, repo.IncrementZeroResult(ctx, "unknown brand", day2))
+
+		searchLog, err := repo.GetByQueryText(ctx, "rare widget")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, searchLog.ZeroResultCount)
+		assert.Equal(t, 1, searchLog.Count)
+	})
+
+	t.Run("Unknown queries are not counted", func(t *testing.T) {
+		err := repo.IncrementZeroResult(ctx, "never logged", day1)
+		assert.ErrorIs(t, err, ErrSearchLogNotFound)
+	})
+
+	t.Run("Rank zero-result queries within a window", func(t *testing.T) {
+		results, err := repo.TopZeroResultQueries(ctx, day1, day2, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "rare widget", results[0].QueryText)
+		assert.Equal(t, 2, results[0].ZeroResultCount)
+		assert.Equal(t, 1, results[0].SearchCount)
+		assert.Equal(t, "unknown brand", results[1].QueryText)
+		assert.Equal(t, 1, results[1].ZeroResultCount)
+
+		results, err = repo.TopZeroResultQueries(ctx, day3, day3, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, 1, results[0].ZeroResultCount)
+	})
+
+	t.Run("Other tenants' zero results are not included", func(t *testing.T) {
+		results, err := repo.TopZeroResultQueries(tenant.WithTenant(ctx, "acme"), day1, day3, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+}
+
+func TestSearchLogDatabaseRepository_HourlyCounts(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSearchLogDatabaseRepository(db)
+
+	t.Run("Searches are rolled up by UTC hour", func(t *testing.T) {
+		// ARRANGE
+		ctx := tenant.WithTenant(context.Background(), "hourly")
+		before := time.Now().UTC().Truncate(time.Hour)
+
+		// ACT
+		_, err := repo.IncrementSearchLog(ctx, "Hourly Query")
+		assert.NoError(t, err)
+		_, err = repo.IncrementSearchLog(ctx, "hourly query")
+		assert.NoError(t, err)
+		_, err = repo.IncrementSearchLog(ctx, "other query")
+		assert.NoError(t, err)
+
+		// ASSERT
+		hourly, err := repo.ListHourlyCounts(ctx, "hourly query", before.Add(-time.Hour), before.Add(2*time.Hour))
+		assert.NoError(t, err)
+		total := 0
+		for _, hour := range hourly {
+			assert.True(t, hour.Hour.Equal(hour.Hour.Truncate(time.Hour)))
+			total += hour.Count
+		}
+		assert.Equal(t, 2, total)
+	})
+
+	t.Run("Hours outside the range or of other tenants are not listed", func(t *testing.T) {
+		// ARRANGE
+		ctx := tenant.WithTenant(context.Background(), "hourly")
+		now := time.Now().UTC()
+
+		// ACT
+		hourly, err := repo.ListHourlyCounts(ctx, "hourly query", now.Add(2*time.Hour), now.Add(3*time.Hour))
+		assert.NoError(t, err)
+		otherTenant, err := repo.ListHourlyCounts(context.Background(), "hourly query", now.Add(-2*time.Hour), now.Add(2*time.Hour))
+		assert.NoError(t, err)
+
+		// ASSERT
+		assert.Empty(t, hourly)
+		assert.Empty(t, otherTenant)
+	})
+}
+
+func TestSearchLogDatabaseRepository_UniqueClients(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSearchLogDatabaseRepository(db)
+	ctx := tenant.WithTenant(context.Background(), "unique")
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	_, err := repo.IncrementSearchLog(ctx, "refund")
+	assert.NoError(t, err)
+
+	t.Run("Snapshots are saved all-time and per UTC day", func(t *testing.T) {
+		// ACT
+		assert.NoError(t, repo.SaveUniqueClients(ctx, "refund", day.Add(15*time.Hour), 40, 12))
+		assert.NoError(t, repo.SaveUniqueClients(ctx, "refund", day.Add(-9*time.Hour), 41, 30))
+
+		// ASSERT
+		searchLog, err := repo.GetByQueryText(ctx, "refund")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(41), searchLog.UniqueClients)
+		daily, err := repo.ListDailyUniqueClients(ctx, "refund", day.AddDate(0, 0, -7), day.AddDate(0, 0, 1))
+		assert.NoError(t, err)
+		if assert.Len(t, daily, 2) {
+			assert.True(t, daily[0].Day.Equal(day.AddDate(0, 0, -1)))
+			assert.Equal(t, int64(30), daily[0].UniqueClients)
+			assert.True(t, daily[1].Day.Equal(day))
+			assert.Equal(t, int64(12), daily[1].UniqueClients)
+		}
+	})
+
+	t.Run("An older, smaller estimate does not lower a snapshot", func(t *testing.T) {
+		assert.NoError(t, repo.SaveUniqueClients(ctx, "refund", day, 35, 10))
+
+		searchLog, err := repo.GetByQueryText(ctx, "refund")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(41), searchLog.UniqueClients)
+		daily, err := repo.ListDailyUniqueClients(ctx, "refund", day, day.AddDate(0, 0, 1))
+		assert.NoError(t, err)
+		if assert.Len(t, daily, 1) {
+			assert.Equal(t, int64(12), daily[0].UniqueClients)
+		}
+	})
+
+	t.Run("Zero-result queries carry their distinct clients", func(t *testing.T) {
+		assert.NoError(t, repo.IncrementZeroResult(ctx, "refund", day))
+
+		queries, err := repo.TopZeroResultQueries(ctx, day, day.Add(time.Hour), 10)
+		assert.NoError(t, err)
+		if assert.Len(t, queries, 1) {
+			assert.Equal(t, int64(41), queries[0].UniqueClients)
+		}
+	})
+}
+
+func TestSearchLogDatabaseRepository_ListQueryCounts(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSearchLogDatabaseRepository(db)
+	ctx := context.Background()
+
+	for queryText, count := range map[string]int{"laptop": 3, "monitor": 2, "keyboard": 1} {
+		for i := 0; i < count; i++ {
+			_, err := repo.IncrementSearchLog(ctx, queryText)
+			assert.NoError(t, err)
+		}
+	}
+	_, err := repo.IncrementSearchLog(tenant.WithTenant(ctx, "acme"), "laptop bag")
+	assert.NoError(t, err)
+
+	t.Run("List queries above the minimum count, most searched first", func(t *testing.T) {
+		results, err := repo.ListQueryCounts(ctx, 2, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "laptop", results[0].QueryText)
+		assert.Equal(t, 3, results[0].Count)
+		assert.Equal(t, "monitor", results[1].QueryText)
+	})
+
+	t.Run("Limit results", func(t *testing.T) {
+		results, err := repo.ListQueryCounts(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, "laptop", results[0].QueryText)
+	})
+
+	t.Run("Other tenants' queries are not included", func(t *testing.T) {
+		results, err := repo.ListQueryCounts(tenant.WithTenant(ctx, "acme"), 1, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, "laptop bag", results[0].QueryText)
+	})
+}
+
+func TestSearchLogDatabaseRepository_ListSearchLogs(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSearchLogDatabaseRepository(db)
+	ctx := context.Background()
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	counts := []struct {
+		queryText string
+		count     int
+	}{
+		{"laptop", 5}, {"laptop bag", 3}, {"gaming laptop", 3}, {"monitor", 3}, {"100% cotton", 1}, {"usb_c cable", 2},
+	}
+	for n, c := range counts {
+		searchLog := models.NewSearchLog(c.queryText, c.count)
+		assert.NoError(t, db.Create(searchLog).Error)
+		assert.NoError(t, db.Model(searchLog).UpdateColumn("updated_at", base.Add(time.Duration(n)*time.Hour)).Error)
+	}
+	_, err := repo.IncrementSearchLog(tenant.WithTenant(ctx, "acme"), "laptop")
+	assert.NoError(t, err)
+
+	queryTexts := func(page *models.SearchLogPage) []string {
+		var texts []string
+		for _, searchLog := range page.SearchLogs {
+			texts = append(texts, searchLog.QueryText)
+		}
+		return texts
+	}
+
+	// listAll pages forward through every search log, then back from the last page, checking both agree.
+	listAll := func(t *testing.T, filter models.SearchLogFilter) []string {
+		var forward [][]string
+		var pages []*models.SearchLogPage
+		for {
+			page, err := repo.ListSearchLogs(ctx, filter)
+			assert.NoError(t, err)
+			forward = append(forward, queryTexts(page))
+			pages = append(pages, page)
+			if page.NextCursor == "" {
+				break
+			}
+			filter.Cursor = page.NextCursor
+		}
+
+		page := pages[len(pages)-1]
+		for n := len(pages) - 2; n >= 0; n-- {
+			assert.NotEmpty(t, page.PrevCursor)
+			filter.Cursor = page.PrevCursor
+			page, err = repo.ListSearchLogs(ctx, filter)
+			assert.NoError(t, err)
+			assert.Equal(t, forward[n], queryTexts(page))
+		}
+		assert.Empty(t, page.PrevCursor)
+
+		var all []string
+		for _, texts := range forward {
+			all = append(all, texts...)
+		}
+		return all
+	}
+
+	t.Run("Sort by count, breaking ties consistently", func(t *testing.T) {
+		all := listAll(t, models.SearchLogFilter{Sort: models.SearchLogSortCount, Limit: 2})
+		assert.Len(t, all, 6)
+		assert.Equal(t, "laptop", all[0])
+		assert.ElementsMatch(t, []string{"laptop bag", "gaming laptop", "monitor"}, all[1:4])
+		assert.Equal(t, []string{"usb_c cable", "100% cotton"}, all[4:])
+	})
+
+	t.Run("Sort by updated_at", func(t *testing.T) {
+		all := listAll(t, models.SearchLogFilter{Sort: models.SearchLogSortUpdatedAt, Limit: 4})
+		assert.Equal(t, []string{"usb_c cable", "100% cotton", "monitor", "gaming laptop", "laptop bag", "laptop"}, all)
+	})
+
+	t.Run("Sort by query", func(t *testing.T) {
+		all := listAll(t, models.SearchLogFilter{Sort: models.SearchLogSortQuery, Limit: 5})
+		assert.Equal(t, []string{"100% cotton", "gaming laptop", "laptop", "laptop bag", "monitor", "usb_c cable"}, all)
+	})
+
+	t.Run("Filter and estimate the total", func(t *testing.T) {
+		page, err := repo.ListSearchLogs(ctx, models.SearchLogFilter{Contains: "Laptop", MinCount: 3, Sort: models.SearchLogSortQuery, Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"gaming laptop", "laptop"}, queryTexts(page))
+		assert.Equal(t, int64(3), page.TotalEstimate)
+		assert.True(t, page.TotalIsExact)
+		assert.NotEmpty(t, page.NextCursor)
+		assert.Empty(t, page.PrevCursor)
+
+		page, err = repo.ListSearchLogs(ctx, models.SearchLogFilter{UpdatedAfter: base.Add(3 * time.Hour), Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"usb_c cable", "100% cotton"}, queryTexts(page))
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("Wildcards in contains are matched literally", func(t *testing.T) {
+		page, err := repo.ListSearchLogs(ctx, models.SearchLogFilter{Contains: "%", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"100% cotton"}, queryTexts(page))
+
+		page, err = repo.ListSearchLogs(ctx, models.SearchLogFilter{Contains: "b_c", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"usb_c cable"}, queryTexts(page))
+	})
+
+	t.Run("Reject cursors that are malformed or issued for another sort", func(t *testing.T) {
+		_, err := repo.ListSearchLogs(ctx, models.SearchLogFilter{Cursor: "not-a-cursor", Limit: 2})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		page, err := repo.ListSearchLogs(ctx, models.SearchLogFilter{Sort: models.SearchLogSortQuery, Limit: 2})
+		assert.NoError(t, err)
+		_, err = repo.ListSearchLogs(ctx, models.SearchLogFilter{Sort: models.SearchLogSortCount, Cursor: page.NextCursor, Limit: 2})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("Other tenants' search logs are not listed", func(t *testing.T) {
+		page, err := repo.ListSearchLogs(tenant.WithTenant(ctx, "acme"), models.SearchLogFilter{Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"laptop"}, queryTexts(page))
+		assert.Equal(t, int64(1), page.TotalEstimate)
+	})
+}
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"search-logger/tenant"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidSynonym = errors.New("variant and canonical query must be different and not empty")
+	// ErrSynonymChain is returned when a mapping would make a canonical query the variant of another, so every
+	// variant always resolves to its canonical query in one lookup.
+	ErrSynonymChain = errors.New("synonym would map to a query that is itself a variant, or remap a canonical query")
+)
+
+// SynonymRepository methods are scoped to the tenant on the context.
+type SynonymRepository interface {
+	// Upsert maps variant to canonical, replacing any previous mapping of variant.
+	Upsert(ctx context.Context, variant, canonical string) (*models.QuerySynonym, error)
+	Get(ctx context.Context, variant string) (*models.QuerySynonym, error)
+	// List returns every mapping, or only the variants of canonical when it is not empty.
+	List(ctx context.Context, canonical string) ([]models.QuerySynonym, error)
+	Delete(ctx context.Context, variant string) (bool, error)
+	// Import upserts every mapping in one transaction, so an invalid mapping leaves none of them applied.
+	Import(ctx context.Context, synonyms []models.QuerySynonym) (int, error)
+	// Merge folds the search_logs rows of canonical's variants, including their daily zero-result counts, hourly
+	// rollups and distinct clients estimates, into canonical's row and deletes them, in one transaction. The clicks and
+	// reformulations of the variants are folded into canonical's as well.
+	Merge(ctx context.Context, canonical string) (*models.SynonymMergeResult, error)
+}
+
+type synonymDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewSynonymDatabaseRepository(db *gorm.DB) SynonymRepository {
+	return &synonymDatabaseRepository{db: db}
+}
+
+func (i synonymDatabaseRepository) Upsert(ctx context.Context, variant, canonical string) (*models.QuerySynonym, error) {
+	var synonym *models.QuerySynonym
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		synonym, err = upsertSynonym(tx, tenant.FromContext(ctx), variant, canonical)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return synonym, nil
+}
+
+func upsertSynonym(tx *gorm.DB, tenantID, variant, canonical string) (*models.QuerySynonym, error) {
+	synonym := models.NewQuerySynonym(variant, canonical)
+	synonym.TenantID = tenantID
+	// Normalize before validating, as BeforeSave would.
+	synonym.Variant = strings.ToLower(strings.TrimSpace(synonym.Variant))
+	synonym.Canonical = strings.ToLower(strings.TrimSpace(synonym.Canonical))
+	if synonym.Variant == "" || synonym.Canonical == "" || synonym.Variant == synonym.Canonical {
+		return nil, ErrInvalidSynonym
+	}
+
+	var chained int64
+	err := tx.Model(&models.QuerySynonym{}).
+		Where("tenant_id = ? AND (variant = ? OR canonical = ?)", tenantID, synonym.Canonical, synonym.Variant).
+		Count(&chained).Error
+	if err != nil {
+		return nil, err
+	}
+	if chained > 0 {
+		return nil, ErrSynonymChain
+	}
+
+	err = tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "variant"}},
+		DoUpdates: clause.AssignmentColumns([]string{"canonical", "updated_at"}),
+	}).Create(synonym).Error
+	if err != nil {
+		return nil, err
+	}
+	return synonym, nil
+}
+
+func (i synonymDatabaseRepository) Get(ctx context.Context, variant string) (*models.QuerySynonym, error) {
+	var synonym models.QuerySynonym
+	err := i.db.WithContext(ctx).
+		Where("tenant_id = ? AND variant = ?", tenant.FromContext(ctx), strings.ToLower(strings.TrimSpace(variant))).
+		First(&synonym).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &synonym, nil
+}
+
+func (i synonymDatabaseRepository) List(ctx context.Context, canonical string) ([]models.QuerySynonym, error) {
+	query := i.db.WithContext(ctx).Where("tenant_id = ?", tenant.FromContext(ctx))
+	if canonical = strings.ToLower(strings.TrimSpace(canonical)); canonical != "" {
+		query = query.Where("canonical = ?", canonical)
+	}
+
+	var synonyms []models.QuerySynonym
+	if err := query.Order("canonical, variant").Find(&synonyms).Error; err != nil {
+		return nil, err
+	}
+	return synonyms, nil
+}
+
+func (i synonymDatabaseRepository) Delete(ctx context.Context, variant string) (bool, error) {
+	res := i.db.WithContext(ctx).
+		Where("tenant_id = ? AND variant = ?", tenant.FromContext(ctx), strings.ToLower(strings.TrimSpace(variant))).
+		Delete(&models.QuerySynonym{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (i synonymDatabaseRepository) Import(ctx context.Context, synonyms []models.QuerySynonym) (int, error) {
+	tenantID := tenant.FromContext(ctx)
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, synonym := range synonyms {
+			if _, err := upsertSynonym(tx, tenantID, synonym.Variant, synonym.Canonical); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(synonyms), nil
+}
+
+func (i synonymDatabaseRepository) Merge(ctx context.Context, canonical string) (*models.SynonymMergeResult, error) {
+	canonical = strings.ToLower(strings.TrimSpace(canonical))
+	if canonical == "" {
+		return nil, errors.New("canonical query cannot be empty")
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	result := &models.SynonymMergeResult{Canonical: canonical, MergedVariants: []string{}}
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var variants []string
+		err := tx.Model(&models.QuerySynonym{}).
+			Where("tenant_id = ? AND canonical = ?", tenantID, canonical).
+			Pluck("variant", &variants).Error
+		if err != nil {
+			return err
+		}
+
+		var variantLogs []models.SearchLog
+		if len(variants) > 0 {
+			if err := mergeClicks(tx, tenantID, canonical, variants); err != nil {
+				return err
+			}
+			if err := mergeTransitions(tx, tenantID, canonical, variants); err != nil {
+				return err
+			}
+			err = tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Order("query_text").Find(&variantLogs).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(variantLogs) == 0 {
+			var existing models.SearchLog
+			err := tx.Where("tenant_id = ? AND query_text = ?", tenantID, canonical).First(&existing).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			result.Count = existing.Count
+			return nil
+		}
+
+		var zeroResults, clickedSearches int
+		var uniqueClients int64
+		merged := make([]string, 0, len(variantLogs))
+		for _, variantLog := range variantLogs {
+			result.MergedCount += variantLog.Count
+			zeroResults += variantLog.ZeroResultCount
+			clickedSearches += variantLog.ClickedSearchCount
+			uniqueClients = max(uniqueClients, variantLog.UniqueClients)
+			merged = append(merged, variantLog.QueryText)
+		}
+
+		searchLog, err := incrementSearchLog(tx, tenantID, canonical, result.MergedCount)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.SearchLog{}).Where("id = ?", searchLog.ID).UpdateColumns(map[string]interface{}{
+			"zero_result_count":    gorm.Expr("zero_result_count + ?", zeroResults),
+			"clicked_search_count": gorm.Expr("clicked_search_count + ?", clickedSearches),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := mergeZeroResultCounts(tx, tenantID, canonical, merged); err != nil {
+			return err
+		}
+		if err := mergeHourlyCounts(tx, tenantID, canonical, merged); err != nil {
+			return err
+		}
+		if err := mergeUniqueClients(tx, tenantID, searchLog, uniqueClients, merged); err != nil {
+			return err
+		}
+		if err := tx.Where("tenant_id = ? AND query_text IN ?", tenantID, merged).Delete(&models.SearchLog{}).Error; err != nil {
+			return err
+		}
+
+		result.MergedVariants = merged
+		result.Count = searchLog.Count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// mergeZeroResultCounts moves the daily zero-result counts of variants onto canonical, adding up counts of the same day.
+func mergeZeroResultCounts(tx *gorm.DB, tenantID, canonical string, variants []string) error {
+	var daily []models.ZeroResultCount
+	if err := tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Find(&daily).Error; err != nil {
+		return err
+	}
+
+	for _, variantDay := range daily {
+		canonicalDay := &models.ZeroResultCount{TenantID: tenantID, QueryText: canonical, Day: variantDay.Day, Count: variantDay.Count}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "query_text"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("zero_result_counts.count + ?", variantDay.Count)}),
+		}).Create(canonicalDay).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Delete(&models.ZeroResultCount{}).Error
+}
+
+// mergeHourlyCounts moves the hourly rollups of variants onto canonical, adding up counts of the same hour.
+func mergeHourlyCounts(tx *gorm.DB, tenantID, canonical string, variants []string) error {
+	var hourly []models.QueryHourlyCount
+	if err := tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Find(&hourly).Error; err != nil {
+		return err
+	}
+
+	for _, variantHour := range hourly {
+		if err := incrementHourlyCount(tx, tenantID, canonical, variantHour.Hour, variantHour.Count); err != nil {
+			return err
+		}
+	}
+	return tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Delete(&models.QueryHourlyCount{}).Error
+}
+
+// mergeClicks moves the clicks on the results of variants onto canonical, adding up the clicks on the same result.
+func mergeClicks(tx *gorm.DB, tenantID, canonical string, variants []string) error {
+	var clicks []models.QueryResultClick
+	if err := tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Find(&clicks).Error; err != nil {
+		return err
+	}
+
+	for _, variantClick := range clicks {
+		canonicalClick := &models.QueryResultClick{
+			TenantID:    tenantID,
+			QueryText:   canonical,
+			ResultID:    variantClick.ResultID,
+			Clicks:      variantClick.Clicks,
+			PositionSum: variantClick.PositionSum,
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "tenant_id"}, {Name: "query_text"}, {Name: "result_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"clicks":       gorm.Expr("query_result_clicks.clicks + ?", variantClick.Clicks),
+				"position_sum": gorm.Expr("query_result_clicks.position_sum + ?", variantClick.PositionSum),
+				"updated_at":   gorm.Expr("CURRENT_TIMESTAMP"),
+			}),
+		}).Create(canonicalClick).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Delete(&models.QueryResultClick{}).Error
+}
+
+// mergeTransitions moves the reformulations from and to variants onto canonical, adding up those between the same
+// queries. Reformulations between canonical and its variants are dropped, like those from a query to itself.
+func mergeTransitions(tx *gorm.DB, tenantID, canonical string, variants []string) error {
+	var transitions []models.QueryTransition
+	err := tx.Where("tenant_id = ? AND (from_query IN ? OR to_query IN ?)", tenantID, variants, variants).Find(&transitions).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Where("tenant_id = ? AND (from_query IN ? OR to_query IN ?)", tenantID, variants, variants).Delete(&models.QueryTransition{}).Error
+	if err != nil {
+		return err
+	}
+
+	isVariant := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		isVariant[variant] = true
+	}
+	canonicalize := func(queryText string) string {
+		if isVariant[queryText] {
+			return canonical
+		}
+		return queryText
+	}
+	for _, variantTransition := range transitions {
+		fromQuery, toQuery := canonicalize(variantTransition.FromQuery), canonicalize(variantTransition.ToQuery)
+		if fromQuery == toQuery {
+			continue
+		}
+		canonicalTransition := &models.QueryTransition{TenantID: tenantID, FromQuery: fromQuery, ToQuery: toQuery, Count: variantTransition.Count}
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "tenant_id"}, {Name: "from_query"}, {Name: "to_query"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count":      gorm.Expr("query_transitions.count + ?", variantTransition.Count),
+				"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+			}),
+		}).Create(canonicalTransition).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeUniqueClients keeps on canonicalLog, and on each of its days, the largest distinct clients estimate among it and
+// the variants. Clients may have searched several of them, so estimates cannot be added up, and the result is a lower
+// bound.
+func mergeUniqueClients(tx *gorm.DB, tenantID string, canonicalLog *models.SearchLog, variantsUniqueClients int64, variants []string) error {
+	err := tx.Model(&models.SearchLog{}).
+		Where("id = ? AND unique_clients < ?", canonicalLog.ID, variantsUniqueClients).
+		UpdateColumn("unique_clients", variantsUniqueClients).Error
+	if err != nil {
+		return err
+	}
+
+	var daily []models.QueryDailyUniqueClients
+	if err := tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Find(&daily).Error; err != nil {
+		return err
+	}
+	for _, variantDay := range daily {
+		if err := saveDailyUniqueClients(tx, tenantID, canonicalLog.QueryText, variantDay.Day, variantDay.UniqueClients); err != nil {
+			return err
+		}
+	}
+	return tx.Where("tenant_id = ? AND query_text IN ?", tenantID, variants).Delete(&models.QueryDailyUniqueClients{}).Error
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"context"
+	"search-logger/models"
+	"search-logger/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynonymDatabaseRepository_Upsert(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSynonymDatabaseRepository(db)
+	ctx := context.Background()
+
+	t.Run("Insert normalized mapping", func(t *testing.T) {
+		synonym, err := repo.Upsert(ctx, " TVs ", "TV")
+		assert.NoError(t, err)
+		assert.Equal(t, "tvs", synonym.Variant)
+		assert.Equal(t, "tv", synonym.Canonical)
+
+		stored, err := repo.Get(ctx, "tvs")
+		assert.NoError(t, err)
+		assert.Equal(t, "tv", stored.Canonical)
+	})
+
+	t.Run("Replace mapping of existing variant", func(t *testing.T) {
+		_, err := repo.Upsert(ctx, "tvs", "television")
+		assert.NoError(t, err)
+
+		stored, err := repo.Get(ctx, "tvs")
+		assert.NoError(t, err)
+		assert.Equal(t, "television", stored.Canonical)
+	})
+
+	t.Run("Reject variant equal to canonical", func(t *testing.T) {
+		_, err := repo.Upsert(ctx, "tv", " TV")
+		assert.ErrorIs(t, err, ErrInvalidSynonym)
+	})
+
+	t.Run("Reject chains", func(t *testing.T) {
+		// "tvs" is a variant, so it cannot be a canonical query.
+		_, err := repo.Upsert(ctx, "tvz", "tvs")
+		assert.ErrorIs(t, err, ErrSynonymChain)
+
+		// "television" is a canonical query, so it cannot become a variant.
+		_, err = repo.Upsert(ctx, "television", "tv")
+		assert.ErrorIs(t, err, ErrSynonymChain)
+	})
+
+	t.Run("Mappings are scoped to the tenant", func(t *testing.T) {
+		stored, err := repo.Get(tenant.WithTenant(ctx, "acme"), "tvs")
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+}
+
+func TestSynonymDatabaseRepository_ListAndDelete(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSynonymDatabaseRepository(db)
+	ctx := context.Background()
+
+	_, err := repo.Upsert(ctx, "tvs", "tv")
+	assert.NoError(t, err)
+	_, err = repo.Upsert(ctx, "televisions", "tv")
+	assert.NoError(t, err)
+	_, err = repo.Upsert(ctx, "laptops", "laptop")
+	assert.NoError(t, err)
+
+	t.Run("List all mappings", func(t *testing.T) {
+		synonyms, err := repo.List(ctx, "")
+		assert.NoError(t, err)
+		assert.Len(t, synonyms, 3)
+		assert.Equal(t, "laptops", synonyms[0].Variant)
+	})
+
+	t.Run("List variants of one canonical query", func(t *testing.T) {
+		synonyms, err := repo.List(ctx, "TV")
+		assert.NoError(t, err)
+		assert.Len(t, synonyms, 2)
+	})
+
+	t.Run("Delete mapping", func(t *testing.T) {
+		deleted, err := repo.Delete(ctx, "tvs")
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+
+		deleted, err = repo.Delete(ctx, "tvs")
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+	})
+}
+
+func TestSynonymDatabaseRepository_Import(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSynonymDatabaseRepository(db)
+	ctx := context.Background()
+
+	t.Run("Import all mappings", func(t *testing.T) {
+		imported, err := repo.Import(ctx, []models.QuerySynonym{
+			{Variant: "tvs", Canonical: "tv"},
+			{Variant: "televisions", Canonical: "tv"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, imported)
+	})
+
+	t.Run("Invalid mapping rolls back the import", func(t *testing.T) {
+		imported, err := repo.Import(ctx, []models.QuerySynonym{
+			{Variant: "laptops", Canonical: "laptop"},
+			{Variant: "tv", Canonical: "television"},
+		})
+		assert.ErrorIs(t, err, ErrSynonymChain)
+		assert.Equal(t, 0, imported)
+
+		stored, err := repo.Get(ctx, "laptops")
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+}
+
+func TestSynonymDatabaseRepository_Merge(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewSynonymDatabaseRepository(db)
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := repo.Upsert(ctx, "tvs", "tv")
+	assert.NoError(t, err)
+	_, err = repo.Upsert(ctx, "televisions", "tv")
+	assert.NoError(t, err)
+
+	tv := models.NewSearchLog("tv", 5)
+	tv.ZeroResultCount = 1
+	tv.UniqueClients = 4
+	tvs := models.NewSearchLog("tvs", 3)
+	tvs.ZeroResultCount = 2
+	tvs.ClickedSearchCount = 1
+	tvs.UniqueClients = 6
+	assert.NoError(t, db.Create(tv).Error)
+	assert.NoError(t, db.Create(tvs).Error)
+	assert.NoError(t, db.Create(models.NewSearchLog("laptops", 4)).Error)
+	assert.NoError(t, db.Create(&models.ZeroResultCount{TenantID: tenant.DefaultTenantID, QueryText: "tv", Day: day, Count: 1}).Error)
+	assert.NoError(t, db.Create(&models.ZeroResultCount{TenantID: tenant.DefaultTenantID, QueryText: "tvs", Day: day, Count: 1}).Error)
+	assert.NoError(t, db.Create(&models.ZeroResultCount{TenantID: tenant.DefaultTenantID, QueryText: "tvs", Day: day.AddDate(0, 0, 1), Count: 1}).Error)
+	assert.NoError(t, db.Create(&models.QueryDailyUniqueClients{TenantID: tenant.DefaultTenantID, QueryText: "tv", Day: day, UniqueClients: 2}).Error)
+	assert.NoError(t, db.Create(&models.QueryDailyUniqueClients{TenantID: tenant.DefaultTenantID, QueryText: "tvs", Day: day, UniqueClients: 3}).Error)
+	clicks := NewClickDatabaseRepository(db)
+	assert.NoError(t, clicks.RecordClick(ctx, "tv", "sku-1", 1))
+	assert.NoError(t, clicks.RecordClick(ctx, "tvs", "sku-1", 3))
+	assert.NoError(t, clicks.RecordClick(ctx, "tvs", "sku-2", 2))
+	transitions := NewTransitionDatabaseRepository(db)
+	assert.NoError(t, transitions.RecordTransition(ctx, "tv", "oled tv"))
+	assert.NoError(t, transitions.RecordTransition(ctx, "tvs", "oled tv"))
+	assert.NoError(t, transitions.RecordTransition(ctx, "laptops", "tvs"))
+	assert.NoError(t, transitions.RecordTransition(ctx, "tvs", "tv"))
+
+	t.Run("Fold variant rows into the canonical row", func(t *testing.T) {
+		result, err := repo.Merge(ctx, "TV")
+		assert.NoError(t, err)
+		assert.Equal(t, "tv", result.Canonical)
+		assert.Equal(t, []string{"tvs"}, result.MergedVariants)
+		assert.Equal(t, 3, result.MergedCount)
+		assert.Equal(t, 8, result.Count)
+
+		var merged models.SearchLog
+		assert.NoError(t, db.Where("query_text = ?", "tv").First(&merged).Error)
+		assert.Equal(t, 8, merged.Count)
+		assert.Equal(t, 3, merged.ZeroResultCount)
+		assert.Equal(t, 1, merged.ClickedSearchCount)
+		// Clients may have searched both, so the larger estimate is kept rather than their sum.
+		assert.Equal(t, int64(6), merged.UniqueClients)
+
+		var variants int64
+		assert.NoError(t, db.Model(&models.SearchLog{}).Where("query_text = ?", "tvs").Count(&variants).Error)
+		assert.Equal(t, int64(0), variants)
+
+		var daily []models.ZeroResultCount
+		assert.NoError(t, db.Order("day").Find(&daily).Error)
+		assert.Len(t, daily, 2)
+		for _, d := range daily {
+			assert.Equal(t, "tv", d.QueryText)
+		}
+		assert.Equal(t, 2, daily[0].Count)
+		assert.Equal(t, 1, daily[1].Count)
+
+		var uniqueClients []models.QueryDailyUniqueClients
+		assert.NoError(t, db.Find(&uniqueClients).Error)
+		if assert.Len(t, uniqueClients, 1) {
+			assert.Equal(t, "tv", uniqueClients[0].QueryText)
+			assert.Equal(t, int64(3), uniqueClients[0].UniqueClients)
+		}
+
+		var resultClicks []models.QueryResultClick
+		assert.NoError(t, db.Order("result_id").Find(&resultClicks).Error)
+		if assert.Len(t, resultClicks, 2) {
+			assert.Equal(t, "tv", resultClicks[0].QueryText)
+			assert.Equal(t, 2, resultClicks[0].Clicks)
+			assert.Equal(t, 4, resultClicks[0].PositionSum)
+			assert.Equal(t, "tv", resultClicks[1].QueryText)
+			assert.Equal(t, 1, resultClicks[1].Clicks)
+		}
+
+		var queryTransitions []models.QueryTransition
+		assert.NoError(t, db.Order("from_query").Find(&queryTransitions).Error)
+		if assert.Len(t, queryTransitions, 2, "the reformulation from tvs to tv is dropped") {
+			assert.Equal(t, "laptops", queryTransitions[0].FromQuery)
+			assert.Equal(t, "tv", queryTransitions[0].ToQuery)
+			assert.Equal(t, 1, queryTransitions[0].Count)
+			assert.Equal(t, "tv", queryTransitions[1].FromQuery)
+			assert.Equal(t, "oled tv", queryTransitions[1].ToQuery)
+			assert.Equal(t, 2, queryTransitions[1].Count)
+		}
+	})
+
+	t.Run("Merging again is a no-op", func(t *testing.T) {
+		result, err := repo.Merge(ctx, "tv")
+		assert.NoError(t, err)
+		assert.Empty(t, result.MergedVariants)
+		assert.Equal(t, 8, result.Count)
+	})
+
+	t.Run("Create canonical row when only variants were logged", func(t *testing.T) {
+		_, err := repo.Upsert(ctx, "laptops", "laptop")
+		assert.NoError(t, err)
+
+		result, err := repo.Merge(ctx, "laptop")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Count)
+	})
+}
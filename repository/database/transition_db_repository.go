@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"search-logger/tenant"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TransitionRepository methods are scoped to the tenant on the context.
+type TransitionRepository interface {
+	RecordTransition(ctx context.Context, fromQuery, toQuery string) error
+	// GetReformulations returns the most common next queries after a query, or nil when the query has neither been
+	// logged nor reformulated.
+	GetReformulations(ctx context.Context, queryText string, limit int) (*models.ReformulationStats, error)
+}
+
+type transitionDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewTransitionDatabaseRepository(db *gorm.DB) TransitionRepository {
+	return &transitionDatabaseRepository{db: db}
+}
+
+func (i transitionDatabaseRepository) RecordTransition(ctx context.Context, fromQuery, toQuery string) error {
+	fromQuery = strings.ToLower(strings.TrimSpace(fromQuery))
+	toQuery = strings.ToLower(strings.TrimSpace(toQuery))
+	if fromQuery == "" || toQuery == "" {
+		return errors.New("transition queries cannot be empty")
+	}
+
+	transition := &models.QueryTransition{
+		TenantID:  tenant.FromContext(ctx),
+		FromQuery: fromQuery,
+		ToQuery:   toQuery,
+		Count:     1,
+	}
+	return i.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}, {Name: "from_query"}, {Name: "to_query"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":      gorm.Expr("query_transitions.count + ?", 1),
+			"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		}),
+	}).Create(transition).Error
+}
+
+func (i transitionDatabaseRepository) GetReformulations(ctx context.Context, queryText string, limit int) (*models.ReformulationStats, error) {
+	if queryText == "" {
+		return nil, errors.New("query text cannot be empty")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	queryText = strings.ToLower(strings.TrimSpace(queryText))
+
+	var searchLog models.SearchLog
+	err := i.db.WithContext(ctx).Where("tenant_id = ? AND query_text = ?", tenantID, queryText).First(&searchLog).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var reformulations int
+	err = i.db.WithContext(ctx).Model(&models.QueryTransition{}).
+		Select("COALESCE(SUM(count), 0)").
+		Where("tenant_id = ? AND from_query = ?", tenantID, queryText).
+		Scan(&reformulations).Error
+	if err != nil {
+		return nil, err
+	}
+	if searchLog.ID == "" && reformulations == 0 {
+		return nil, nil
+	}
+
+	var transitions []models.QueryTransition
+	err = i.db.WithContext(ctx).Where("tenant_id = ? AND from_query = ?", tenantID, queryText).
+		Order("count DESC, to_query").
+		Limit(limit).
+		Find(&transitions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.ReformulationStats{
+		QueryText:      queryText,
+		SearchCount:    searchLog.Count,
+		Reformulations: reformulations,
+		NextQueries:    make([]models.NextQuery, 0, len(transitions)),
+	}
+	if searchLog.Count > 0 {
+		// Reformulations of searches filtered out before being counted can exceed the count.
+		stats.AbandonmentRate = max(1-float64(reformulations)/float64(searchLog.Count), 0)
+	}
+	for _, transition := range transitions {
+		stats.NextQueries = append(stats.NextQueries, models.NextQuery{
+			QueryText: transition.ToQuery,
+			Count:     transition.Count,
+			Share:     float64(transition.Count) / float64(reformulations),
+		})
+	}
+	return stats, nil
+}
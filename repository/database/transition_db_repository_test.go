@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransitionDatabaseRepository_GetReformulations(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTransitionDatabaseRepository(db)
+	searchLogRepo := NewSearchLogDatabaseRepository(db)
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, err := searchLogRepo.IncrementSearchLog(ctx, "laptop")
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, repo.RecordTransition(ctx, "laptop", "gaming laptop"))
+	}
+	assert.NoError(t, repo.RecordTransition(ctx, "Laptop", " RTX Laptop "))
+
+	t.Run("Most common next queries and abandonment rate", func(t *testing.T) {
+		stats, err := repo.GetReformulations(ctx, "laptop", 10)
+		assert.NoError(t, err)
+		assert.NotNil(t, stats)
+		assert.Equal(t, 10, stats.SearchCount)
+		assert.Equal(t, 4, stats.Reformulations)
+		assert.InDelta(t, 0.6, stats.AbandonmentRate, 1e-9)
+		assert.Len(t, stats.NextQueries, 2)
+		assert.Equal(t, "gaming laptop", stats.NextQueries[0].QueryText)
+		assert.Equal(t, 3, stats.NextQueries[0].Count)
+		assert.Equal(t, 0.75, stats.NextQueries[0].Share)
+		assert.Equal(t, "rtx laptop", stats.NextQueries[1].QueryText)
+	})
+
+	t.Run("Limit next queries", func(t *testing.T) {
+		stats, err := repo.GetReformulations(ctx, "laptop", 1)
+		assert.NoError(t, err)
+		assert.Len(t, stats.NextQueries, 1)
+		assert.Equal(t, 4, stats.Reformulations)
+	})
+
+	t.Run("Unknown query", func(t *testing.T) {
+		stats, err := repo.GetReformulations(ctx, "unknown", 10)
+		assert.NoError(t, err)
+		assert.Nil(t, stats)
+	})
+
+	t.Run("Reject empty queries", func(t *testing.T) {
+		assert.Error(t, repo.RecordTransition(ctx, "laptop", " "))
+	})
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"search-logger/tenant"
+
+	"gorm.io/gorm"
+)
+
+// WebhookRepository methods are scoped to the tenant on the context.
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, id string) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id string) error
+	CreateRule(ctx context.Context, rule *models.WebhookRule) error
+	ListRules(ctx context.Context, endpointID string) ([]models.WebhookRule, error)
+	// ListActiveRules returns every rule whose endpoint is active, along with its endpoint keyed by ID.
+	ListActiveRules(ctx context.Context) ([]models.WebhookRule, map[string]models.WebhookEndpoint, error)
+	DeleteRule(ctx context.Context, id string) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, endpointID string, limit int) ([]models.WebhookDelivery, error)
+}
+
+type webhookDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookDatabaseRepository(db *gorm.DB) WebhookRepository {
+	return &webhookDatabaseRepository{db: db}
+}
+
+func (i webhookDatabaseRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	if endpoint == nil || endpoint.URL == "" {
+		return errors.New("webhook endpoint url cannot be empty")
+	}
+	endpoint.TenantID = tenant.FromContext(ctx)
+	return i.db.WithContext(ctx).Create(endpoint).Error
+}
+
+func (i webhookDatabaseRepository) GetEndpoint(ctx context.Context, id string) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := i.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenant.FromContext(ctx), id).First(&endpoint).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (i webhookDatabaseRepository) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	if err := i.db.WithContext(ctx).Where("tenant_id = ?", tenant.FromContext(ctx)).Order("created_at").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (i webhookDatabaseRepository) DeleteEndpoint(ctx context.Context, id string) error {
+	tenantID := tenant.FromContext(ctx)
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tenant_id = ? AND endpoint_id = ?", tenantID, id).Delete(&models.WebhookRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("tenant_id = ? AND id = ?", tenantID, id).Delete(&models.WebhookEndpoint{}).Error
+	})
+}
+
+func (i webhookDatabaseRepository) CreateRule(ctx context.Context, rule *models.WebhookRule) error {
+	if rule == nil || !rule.Type.IsValid() {
+		return errors.New("invalid webhook rule type")
+	}
+	rule.TenantID = tenant.FromContext(ctx)
+	return i.db.WithContext(ctx).Create(rule).Error
+}
+
+func (i webhookDatabaseRepository) ListRules(ctx context.Context, endpointID string) ([]models.WebhookRule, error) {
+	var rules []models.WebhookRule
+	err := i.db.WithContext(ctx).Where("tenant_id = ? AND endpoint_id = ?", tenant.FromContext(ctx), endpointID).
+		Order("created_at").
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (i webhookDatabaseRepository) ListActiveRules(ctx context.Context) ([]models.WebhookRule, map[string]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	tenantID := tenant.FromContext(ctx)
+	if err := i.db.WithContext(ctx).Where("tenant_id = ? AND active = ?", tenantID, true).Find(&endpoints).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, nil, nil
+	}
+
+	endpointsByID := make(map[string]models.WebhookEndpoint, len(endpoints))
+	endpointIDs := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpointsByID[endpoint.ID] = endpoint
+		endpointIDs = append(endpointIDs, endpoint.ID)
+	}
+
+	var rules []models.WebhookRule
+	if err := i.db.WithContext(ctx).Where("tenant_id = ? AND endpoint_id IN ?", tenantID, endpointIDs).Find(&rules).Error; err != nil {
+		return nil, nil, err
+	}
+	return rules, endpointsByID, nil
+}
+
+func (i webhookDatabaseRepository) DeleteRule(ctx context.Context, id string) error {
+	return i.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenant.FromContext(ctx), id).Delete(&models.WebhookRule{}).Error
+}
+
+func (i webhookDatabaseRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	delivery.TenantID = tenant.FromContext(ctx)
+	return i.db.WithContext(ctx).Create(delivery).Error
+}
+
+func (i webhookDatabaseRepository) ListDeliveries(ctx context.Context, endpointID string, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := i.db.WithContext(ctx).Where("tenant_id = ? AND endpoint_id = ?", tenant.FromContext(ctx), endpointID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"search-logger/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookDatabaseRepository_Endpoints(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewWebhookDatabaseRepository(db)
+	ctx := context.Background()
+
+	t.Run("Create and retrieve endpoint", func(t *testing.T) {
+		endpoint := models.NewWebhookEndpoint("https://example.com/hook", "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+
+		result, err := repo.GetEndpoint(ctx, endpoint.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+		assert.Equal(t, "https://example.com/hook", result.URL)
+		assert.Equal(t, "secret", result.Secret)
+		assert.True(t, result.Active)
+	})
+
+	t.Run("Reject endpoint without url", func(t *testing.T) {
+		err := repo.CreateEndpoint(ctx, models.NewWebhookEndpoint("", "secret"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Retrieve non-existing endpoint", func(t *testing.T) {
+		result, err := repo.GetEndpoint(ctx, "00000000-0000-0000-0000-000000000000")
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Delete endpoint removes its rules", func(t *testing.T) {
+		endpoint := models.NewWebhookEndpoint("https://example.com/other", "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleFirstSeen, 0, 0)))
+
+		assert.NoError(t, repo.DeleteEndpoint(ctx, endpoint.ID))
+
+		result, err := repo.GetEndpoint(ctx, endpoint.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+		rules, err := repo.ListRules(ctx, endpoint.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, rules)
+	})
+}
+
+func TestWebhookDatabaseRepository_ListActiveRules(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewWebhookDatabaseRepository(db)
+	ctx := context.Background()
+
+	active := models.NewWebhookEndpoint("https://example.com/active", "secret")
+	inactive := models.NewWebhookEndpoint("https://example.com/inactive", "secret")
+	assert.NoError(t, repo.CreateEndpoint(ctx, active))
+	assert.NoError(t, repo.CreateEndpoint(ctx, inactive))
+	assert.NoError(t, db.Model(inactive).Update("active", false).Error)
+
+	activeRule := models.NewWebhookRule(active.ID, models.WebhookRuleCountThreshold, 100, 0)
+	assert.NoError(t, repo.CreateRule(ctx, activeRule))
+	assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(inactive.ID, models.WebhookRuleFirstSeen, 0, 0)))
+
+	t.Run("Only rules of active endpoints are returned", func(t *testing.T) {
+		rules, endpoints, err := repo.ListActiveRules(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, rules, 1)
+		assert.Equal(t, activeRule.ID, rules[0].ID)
+		assert.Equal(t, 100, rules[0].Threshold)
+		assert.Contains(t, endpoints, active.ID)
+		assert.NotContains(t, endpoints, inactive.ID)
+	})
+
+	t.Run("Reject invalid rule type", func(t *testing.T) {
+		err := repo.CreateRule(ctx, models.NewWebhookRule(active.ID, "bogus", 0, 0))
+		assert.Error(t, err)
+	})
+}
+
+func TestWebhookDatabaseRepository_Deliveries(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewWebhookDatabaseRepository(db)
+	ctx := context.Background()
+
+	endpoint := models.NewWebhookEndpoint("https://example.com/hook", "secret")
+	assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+	for attempt := 1; attempt <= 3; attempt++ {
+		delivery := models.NewWebhookDelivery(endpoint.ID, "", "11111111-1111-1111-1111-111111111111", attempt, "{}")
+		assert.NoError(t, repo.CreateDelivery(ctx, delivery))
+	}
+
+	deliveries, err := repo.ListDeliveries(ctx, endpoint.ID, 2)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/tenant"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiKeyPrefix starts every key, so leaked keys are easy to recognize and scan for.
+	apiKeyPrefix = "slk_"
+	// apiKeySecretBytes is the entropy of a key, which is what makes a plain SHA-256 hash safe to store.
+	apiKeySecretBytes = 32
+	// apiKeyDisplayLength is how many leading characters of a key are stored in clear, to recognize it in listings.
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+	// unknownAPIKeyTTL is how long a replica remembers that a hash matches no key, so retrying a wrong key does not
+	// hit the database every time, while a key created through another replica or the CLI is usable soon after.
+	unknownAPIKeyTTL = 5 * time.Second
+	// maxUnknownAPIKeys bounds the unknown hashes remembered, so random keys cannot fill the memory up.
+	maxUnknownAPIKeys = 10000
+)
+
+var (
+	ErrInvalidAPIKey       = errors.New("invalid api key")
+	ErrInvalidAPIKeyConfig = errors.New("api key needs a name and at least one valid role")
+)
+
+// APIKeyConfig tunes how a replica authenticates API keys. Keys are cached for CacheTTL, so a key revoked through
+// another replica keeps working there for up to that long, and usage is saved every UsageFlushInterval.
+type APIKeyConfig struct {
+	CacheTTL           time.Duration
+	UsageFlushInterval time.Duration
+}
+
+// APIKeyConfigFromEnv builds an APIKeyConfig from the API_KEY_* environment variables.
+func APIKeyConfigFromEnv() APIKeyConfig {
+	return APIKeyConfig{
+		CacheTTL:           config.GetAPIKeyCacheTTL(),
+		UsageFlushInterval: config.GetAPIKeyUsageFlushInterval(),
+	}
+}
+
+type APIKeyService interface {
+	// Create generates a key and stores its hash. The key itself is only returned here. It returns
+	// ErrInvalidAPIKeyConfig for empty names, unknown roles and invalid tenants.
+	Create(ctx context.Context, name string, roles []models.APIKeyRole, tenantID string, expiresAt *time.Time) (string, *models.APIKey, error)
+	// Authenticate returns the active key matching rawKey, or ErrInvalidAPIKey.
+	Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error)
+	// RecordUsage counts a request made with the key, denied or not, to be saved on the next flush.
+	RecordUsage(key *models.APIKey, denied bool)
+	List(ctx context.Context) ([]models.APIKey, error)
+	Get(ctx context.Context, id string) (*models.APIKey, error)
+	// Revoke revokes the key immediately on this replica, and reports whether an active key was revoked.
+	Revoke(ctx context.Context, id string) (bool, error)
+	// Usage returns the daily usage of the key on the UTC days from "from" through "to", as of the last flush.
+	Usage(ctx context.Context, id string, from, to time.Time) ([]models.APIKeyDailyUsage, error)
+	// FlushUsage saves the usage counted since the last flush.
+	FlushUsage(ctx context.Context) error
+	// Stop stops flushing usage periodically, then flushes one last time.
+	Stop(ctx context.Context) error
+}
+
+type apiKeyService struct {
+	repo   database.APIKeyRepository
+	cfg    APIKeyConfig
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu *sync.Mutex
+	// keys caches the keys found by hash.
+	keys map[string]cachedAPIKey
+	// unknown holds when each hash that matched no key was looked up, for unknownAPIKeyTTL.
+	unknown map[string]time.Time
+	// pending holds the usage not flushed yet.
+	pending  map[apiKeyUsageKey]apiKeyUsage
+	stop     chan struct{}
+	stopOnce *sync.Once
+	stopped  chan struct{}
+}
+
+type cachedAPIKey struct {
+	key      *models.APIKey
+	loadedAt time.Time
+}
+
+type apiKeyUsageKey struct {
+	keyID string
+	day   time.Time
+}
+
+type apiKeyUsage struct {
+	requests   int64
+	denied     int64
+	lastUsedAt time.Time
+}
+
+// NewAPIKeyService flushes usage every cfg.UsageFlushInterval, unless it is zero.
+func NewAPIKeyService(repo database.APIKeyRepository, cfg APIKeyConfig, logger *slog.Logger) APIKeyService {
+	aks := &apiKeyService{
+		repo:     repo,
+		cfg:      cfg,
+		logger:   logger,
+		now:      time.Now,
+		mu:       &sync.Mutex{},
+		keys:     make(map[string]cachedAPIKey),
+		unknown:  make(map[string]time.Time),
+		pending:  make(map[apiKeyUsageKey]apiKeyUsage),
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+		stopped:  make(chan struct{}),
+	}
+	if cfg.UsageFlushInterval > 0 {
+		go aks.flushPeriodically()
+	} else {
+		close(aks.stopped)
+	}
+	return aks
+}
+
+func (aks *apiKeyService) Create(ctx context.Context, name string, roles []models.APIKeyRole, tenantID string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(roles) == 0 {
+		return "", nil, ErrInvalidAPIKeyConfig
+	}
+	for _, role := range roles {
+		if !role.IsValid() {
+			return "", nil, fmt.Errorf("%w: unknown role %q", ErrInvalidAPIKeyConfig, role)
+		}
+	}
+	if tenantID != "" && !tenant.IsValidID(tenantID) {
+		return "", nil, fmt.Errorf("%w: %w", ErrInvalidAPIKeyConfig, tenant.ErrInvalidID)
+	}
+
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("error generating api key: %w", err)
+	}
+	rawKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key := models.NewAPIKey(name, rawKey[:apiKeyDisplayLength], hashAPIKey(rawKey), roles, tenantID, expiresAt)
+	if err := aks.repo.Create(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("error saving api key: %w", err)
+	}
+	return rawKey, key, nil
+}
+
+func hashAPIKey(rawKey string) string {
+	hash := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(hash[:])
+}
+
+func (aks *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	rawKey = strings.TrimSpace(rawKey)
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	hash := hashAPIKey(rawKey)
+	now := aks.now()
+
+	aks.mu.Lock()
+	cached, ok := aks.keys[hash]
+	unknownAt, unknown := aks.unknown[hash]
+	aks.mu.Unlock()
+	if unknown && now.Sub(unknownAt) < unknownAPIKeyTTL {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ok || now.Sub(cached.loadedAt) >= aks.cfg.CacheTTL {
+		key, err := aks.repo.GetByHash(ctx, hash)
+		if err != nil {
+			return nil, fmt.Errorf("error getting api key: %w", err)
+		}
+		aks.mu.Lock()
+		if key == nil {
+			delete(aks.keys, hash)
+			aks.rememberUnknown(hash, now)
+		} else {
+			aks.keys[hash] = cachedAPIKey{key: key, loadedAt: now}
+			delete(aks.unknown, hash)
+		}
+		aks.mu.Unlock()
+		cached.key = key
+	}
+
+	if cached.key == nil || !cached.key.IsActive(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	return cached.key, nil
+}
+
+// rememberUnknown remembers that hash matched no key. When maxUnknownAPIKeys hashes are remembered, it forgets the
+// expired ones, or any one if none has expired. aks.mu must be held.
+func (aks *apiKeyService) rememberUnknown(hash string, now time.Time) {
+	if len(aks.unknown) >= maxUnknownAPIKeys {
+		for unknownHash, at := range aks.unknown {
+			if now.Sub(at) >= unknownAPIKeyTTL {
+				delete(aks.unknown, unknownHash)
+			}
+		}
+	}
+	if len(aks.unknown) >= maxUnknownAPIKeys {
+		for unknownHash := range aks.unknown {
+			delete(aks.unknown, unknownHash)
+			break
+		}
+	}
+	aks.unknown[hash] = now
+}
+
+func (aks *apiKeyService) RecordUsage(key *models.APIKey, denied bool) {
+	now := aks.now()
+	usageKey := apiKeyUsageKey{keyID: key.ID, day: now.UTC().Truncate(24 * time.Hour)}
+
+	aks.mu.Lock()
+	defer aks.mu.Unlock()
+	usage := aks.pending[usageKey]
+	if denied {
+		usage.denied++
+	} else {
+		usage.requests++
+	}
+	usage.lastUsedAt = now
+	aks.pending[usageKey] = usage
+}
+
+func (aks *apiKeyService) List(ctx context.Context) ([]models.APIKey, error) {
+	return aks.repo.List(ctx)
+}
+
+func (aks *apiKeyService) Get(ctx context.Context, id string) (*models.APIKey, error) {
+	return aks.repo.Get(ctx, id)
+}
+
+func (aks *apiKeyService) Revoke(ctx context.Context, id string) (bool, error) {
+	revoked, err := aks.repo.Revoke(ctx, id, aks.now())
+	if err != nil {
+		return false, err
+	}
+
+	aks.mu.Lock()
+	defer aks.mu.Unlock()
+	for hash, cached := range aks.keys {
+		if cached.key.ID == id {
+			delete(aks.keys, hash)
+		}
+	}
+	return revoked, nil
+}
+
+func (aks *apiKeyService) Usage(ctx context.Context, id string, from, to time.Time) ([]models.APIKeyDailyUsage, error) {
+	if from.After(to) {
+		return nil, errors.New("from must not be after to")
+	}
+	return aks.repo.ListUsage(ctx, id, from, to)
+}
+
+func (aks *apiKeyService) FlushUsage(ctx context.Context) error {
+	aks.mu.Lock()
+	pending := aks.pending
+	aks.pending = make(map[apiKeyUsageKey]apiKeyUsage)
+	aks.mu.Unlock()
+
+	var errs []error
+	for usageKey, usage := range pending {
+		if err := aks.repo.RecordUsage(ctx, usageKey.keyID, usage.requests, usage.denied, usage.lastUsedAt); err != nil {
+			errs = append(errs, fmt.Errorf("saving usage of api key %q: %w", usageKey.keyID, err))
+			aks.retry(usageKey, usage)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retry adds usage that could not be saved back to the pending usage, to be saved on the next flush.
+func (aks *apiKeyService) retry(usageKey apiKeyUsageKey, usage apiKeyUsage) {
+	aks.mu.Lock()
+	defer aks.mu.Unlock()
+	pending := aks.pending[usageKey]
+	pending.requests += usage.requests
+	pending.denied += usage.denied
+	if usage.lastUsedAt.After(pending.lastUsedAt) {
+		pending.lastUsedAt = usage.lastUsedAt
+	}
+	aks.pending[usageKey] = pending
+}
+
+func (aks *apiKeyService) flushPeriodically() {
+	defer close(aks.stopped)
+	ticker := time.NewTicker(aks.cfg.UsageFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := aks.FlushUsage(context.Background()); err != nil {
+				aks.logger.Error("Error saving api key usage", "error", err)
+			}
+		case <-aks.stop:
+			return
+		}
+	}
+}
+
+func (aks *apiKeyService) Stop(ctx context.Context) error {
+	aks.stopOnce.Do(func() { close(aks.stop) })
+	select {
+	case <-aks.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return aks.FlushUsage(ctx)
+}
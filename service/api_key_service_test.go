@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestAPIKeyRepository(t *testing.T) database.APIKeyRepository {
+	return database.NewAPIKeyDatabaseRepository(setupTestDB(t))
+}
+
+// countingAPIKeyRepository counts lookups by hash, and fails to record usage until healed.
+type countingAPIKeyRepository struct {
+	database.APIKeyRepository
+	lookups *int
+	healed  *bool
+}
+
+func (r countingAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	*r.lookups++
+	return r.APIKeyRepository.GetByHash(ctx, hash)
+}
+
+func (r countingAPIKeyRepository) RecordUsage(ctx context.Context, keyID string, requests, denied int64, lastUsedAt time.Time) error {
+	if !*r.healed {
+		return errors.New("database is down")
+	}
+	return r.APIKeyRepository.RecordUsage(ctx, keyID, requests, denied, lastUsedAt)
+}
+
+func TestAPIKeyService_Create(t *testing.T) {
+	srv := NewAPIKeyService(setupTestAPIKeyRepository(t), APIKeyConfig{CacheTTL: time.Minute}, slog.Default())
+	ctx := context.Background()
+
+	t.Run("Only the hash of the key is stored", func(t *testing.T) {
+		rawKey, key, err := srv.Create(ctx, "storefront", []models.APIKeyRole{models.APIKeyRoleIngest}, "acme", nil)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rawKey, apiKeyPrefix))
+		assert.True(t, strings.HasPrefix(rawKey, key.Prefix))
+		assert.Len(t, key.Prefix, apiKeyDisplayLength)
+		assert.NotContains(t, key.Hash, rawKey[len(apiKeyPrefix):])
+		assert.Equal(t, hashAPIKey(rawKey), key.Hash)
+	})
+
+	tests := []struct {
+		name     string
+		keyName  string
+		roles    []models.APIKeyRole
+		tenantID string
+	}{
+		{"Reject empty names", " ", []models.APIKeyRole{models.APIKeyRoleAdmin}, ""},
+		{"Reject keys without roles", "storefront", nil, ""},
+		{"Reject unknown roles", "storefront", []models.APIKeyRole{"write"}, ""},
+		{"Reject invalid tenants", "storefront", []models.APIKeyRole{models.APIKeyRoleIngest}, "Not A Tenant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := srv.Create(ctx, tt.keyName, tt.roles, tt.tenantID, nil)
+			assert.ErrorIs(t, err, ErrInvalidAPIKeyConfig)
+		})
+	}
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	newService := func(t *testing.T) (APIKeyService, *int) {
+		lookups, healed := 0, true
+		repo := countingAPIKeyRepository{APIKeyRepository: setupTestAPIKeyRepository(t), lookups: &lookups, healed: &healed}
+		return NewAPIKeyService(repo, APIKeyConfig{CacheTTL: time.Minute}, slog.Default()), &lookups
+	}
+
+	t.Run("Valid keys are cached", func(t *testing.T) {
+		// ARRANGE
+		srv, lookups := newService(t)
+		rawKey, created, err := srv.Create(ctx, "analyst", []models.APIKeyRole{models.APIKeyRoleReadAnalytics}, "", nil)
+		assert.NoError(t, err)
+
+		// ACT
+		for i := 0; i < 3; i++ {
+			key, err := srv.Authenticate(ctx, rawKey)
+			assert.NoError(t, err)
+			assert.Equal(t, created.ID, key.ID)
+		}
+
+		// ASSERT
+		assert.Equal(t, 1, *lookups)
+	})
+
+	t.Run("Unknown keys are rejected", func(t *testing.T) {
+		srv, _ := newService(t)
+		for _, rawKey := range []string{"", "not-a-key", apiKeyPrefix + "unknown"} {
+			_, err := srv.Authenticate(ctx, rawKey)
+			assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		}
+	})
+
+	t.Run("Unknown keys are remembered briefly", func(t *testing.T) {
+		// ARRANGE
+		srv, lookups := newService(t)
+		now := time.Now()
+		srv.(*apiKeyService).now = func() time.Time { return now }
+		rawKey := apiKeyPrefix + "unknown"
+
+		// ACT
+		for i := 0; i < 3; i++ {
+			_, err := srv.Authenticate(ctx, rawKey)
+			assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		}
+		now = now.Add(unknownAPIKeyTTL)
+		_, err := srv.Authenticate(ctx, rawKey)
+
+		// ASSERT
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		assert.Equal(t, 2, *lookups)
+	})
+
+	t.Run("Remembered unknown keys are bounded", func(t *testing.T) {
+		// ARRANGE
+		srv, _ := newService(t)
+		aks := srv.(*apiKeyService)
+
+		// ACT
+		for i := 0; i < maxUnknownAPIKeys+10; i++ {
+			_, err := srv.Authenticate(ctx, apiKeyPrefix+strconv.Itoa(i))
+			assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		}
+
+		// ASSERT
+		assert.Len(t, aks.unknown, maxUnknownAPIKeys)
+	})
+
+	t.Run("Revoked keys are rejected at once", func(t *testing.T) {
+		srv, _ := newService(t)
+		rawKey, key, err := srv.Create(ctx, "analyst", []models.APIKeyRole{models.APIKeyRoleReadAnalytics}, "", nil)
+		assert.NoError(t, err)
+		_, err = srv.Authenticate(ctx, rawKey)
+		assert.NoError(t, err)
+
+		revoked, err := srv.Revoke(ctx, key.ID)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		_, err = srv.Authenticate(ctx, rawKey)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+
+	t.Run("Expired keys are rejected", func(t *testing.T) {
+		srv, _ := newService(t)
+		expiresAt := time.Now().Add(-time.Minute)
+		rawKey, _, err := srv.Create(ctx, "temporary", []models.APIKeyRole{models.APIKeyRoleIngest}, "", &expiresAt)
+		assert.NoError(t, err)
+
+		_, err = srv.Authenticate(ctx, rawKey)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+}
+
+func TestAPIKeyService_Usage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Usage is saved on flush", func(t *testing.T) {
+		// ARRANGE
+		srv := NewAPIKeyService(setupTestAPIKeyRepository(t), APIKeyConfig{CacheTTL: time.Minute}, slog.Default())
+		_, key, err := srv.Create(ctx, "storefront", []models.APIKeyRole{models.APIKeyRoleIngest}, "", nil)
+		assert.NoError(t, err)
+		srv.RecordUsage(key, false)
+		srv.RecordUsage(key, false)
+		srv.RecordUsage(key, true)
+
+		// ACT
+		assert.NoError(t, srv.FlushUsage(ctx))
+
+		// ASSERT
+		today := time.Now()
+		usage, err := srv.Usage(ctx, key.ID, today, today)
+		assert.NoError(t, err)
+		if assert.Len(t, usage, 1) {
+			assert.Equal(t, int64(2), usage[0].Requests)
+			assert.Equal(t, int64(1), usage[0].Denied)
+		}
+		saved, err := srv.Get(ctx, key.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, saved.LastUsedAt)
+	})
+
+	t.Run("Failed flushes are retried", func(t *testing.T) {
+		// ARRANGE
+		lookups, healed := 0, false
+		repo := countingAPIKeyRepository{APIKeyRepository: setupTestAPIKeyRepository(t), lookups: &lookups, healed: &healed}
+		srv := NewAPIKeyService(repo, APIKeyConfig{CacheTTL: time.Minute}, slog.Default())
+		_, key, err := srv.Create(ctx, "storefront", []models.APIKeyRole{models.APIKeyRoleIngest}, "", nil)
+		assert.NoError(t, err)
+		srv.RecordUsage(key, false)
+
+		// ACT
+		assert.Error(t, srv.FlushUsage(ctx))
+		srv.RecordUsage(key, false)
+		healed = true
+		assert.NoError(t, srv.Stop(ctx))
+
+		// ASSERT
+		today := time.Now()
+		usage, err := srv.Usage(ctx, key.ID, today, today)
+		assert.NoError(t, err)
+		if assert.Len(t, usage, 1) {
+			assert.Equal(t, int64(2), usage[0].Requests)
+		}
+	})
+}
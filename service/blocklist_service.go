@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/tenant"
+	"strings"
+	"time"
+	"unicode"
+)
+
+//go:embed profanity.txt
+var profanityList []byte
+
+var ErrInvalidBlocklistRule = errors.New("invalid blocklist rule")
+
+type BlocklistConfig struct {
+	ProfanityEnabled bool
+	RefreshInterval  time.Duration
+}
+
+// BlocklistConfigFromEnv builds a BlocklistConfig from the BLOCKLIST_* environment variables.
+func BlocklistConfigFromEnv() BlocklistConfig {
+	return BlocklistConfig{
+		ProfanityEnabled: config.IsBlocklistProfanityEnabled(),
+		RefreshInterval:  config.GetBlocklistRefreshInterval(),
+	}
+}
+
+type BlocklistService interface {
+	PersistFilter
+	// Match returns the ID of the rule blocking queryText, models.ProfanityRuleID for the profanity list, or an
+	// empty string when the query is allowed.
+	Match(ctx context.Context, queryText string) (string, error)
+	// AddRule validates and stores a rule. It returns ErrInvalidBlocklistRule for unknown types, empty patterns and
+	// regular expressions that do not compile. Regular expressions match case-insensitively, like the other rules.
+	AddRule(ctx context.Context, ruleType models.BlocklistRuleType, pattern string) (*models.BlocklistRule, error)
+	ListRules(ctx context.Context) ([]models.BlocklistRule, error)
+	DeleteRule(ctx context.Context, id string) (bool, error)
+	// Purge deletes the already logged queries that the current blocklist would block, and notifies the
+	// QueriesPurgedListeners.
+	Purge(ctx context.Context) (*models.BlocklistPurgeResult, error)
+	// Stats returns how many queries each rule blocked on the UTC days from "from" through "to", including rules
+	// that blocked none.
+	Stats(ctx context.Context, from, to time.Time) ([]models.BlocklistRuleStats, error)
+}
+
+// QueriesPurgedListener is notified of the queries a blocklist purge deleted, to forget what it keeps about them
+// outside of the database.
+type QueriesPurgedListener interface {
+	OnQueriesPurged(ctx context.Context, queryTexts []string)
+}
+
+type blocklistService struct {
+	repo           database.BlocklistRepository
+	cfg            BlocklistConfig
+	profanity      map[string]bool
+	purgeListeners []QueriesPurgedListener
+	logger         *slog.Logger
+
+	matchers *tenantCache[*blocklistMatcher]
+}
+
+// blocklistMatcher holds a tenant's rules ready to be evaluated.
+type blocklistMatcher struct {
+	exact    map[string]string
+	prefixes []models.BlocklistRule
+	regexes  []compiledBlocklistRule
+}
+
+type compiledBlocklistRule struct {
+	id     string
+	regexp *regexp.Regexp
+}
+
+func NewBlocklistService(repo database.BlocklistRepository, cfg BlocklistConfig, logger *slog.Logger, purgeListeners ...QueriesPurgedListener) BlocklistService {
+	bs := &blocklistService{
+		repo:           repo,
+		cfg:            cfg,
+		purgeListeners: purgeListeners,
+		logger:         logger,
+	}
+	bs.matchers = newTenantCache(bs.load, cfg.RefreshInterval, maxCachedTenants)
+	if cfg.ProfanityEnabled {
+		bs.profanity = loadProfanityList()
+	}
+	return bs
+}
+
+func loadProfanityList() map[string]bool {
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(profanityList))
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word != "" && !strings.HasPrefix(word, "#") {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+func (bs blocklistService) BeforePersist(ctx context.Context, clientIdentifier, queryText string) (string, bool) {
+	ruleID, err := bs.Match(ctx, queryText)
+	if err != nil {
+		// Fail open like the other filters: the rules could not be loaded, which should not stop searches from being logged.
+		bs.logger.ErrorContext(ctx, "Error matching blocklist", "error", err, "clientIdentifier", clientIdentifier)
+		return queryText, true
+	}
+	if ruleID == "" {
+		return queryText, true
+	}
+
+	bs.logger.InfoContext(ctx, "Blocked query", "clientIdentifier", clientIdentifier, "ruleID", ruleID)
+	if err := bs.repo.IncrementBlocked(ctx, ruleID, time.Now()); err != nil {
+		bs.logger.ErrorContext(ctx, "Error counting blocked query", "error", err, "ruleID", ruleID)
+	}
+	return "", false
+}
+
+func (bs blocklistService) Match(ctx context.Context, queryText string) (string, error) {
+	matcher, err := bs.matcher(ctx)
+	if err != nil {
+		return "", err
+	}
+	return bs.match(matcher, strings.ToLower(strings.TrimSpace(queryText))), nil
+}
+
+func (bs blocklistService) match(matcher *blocklistMatcher, queryText string) string {
+	if id, ok := matcher.exact[queryText]; ok {
+		return id
+	}
+	for _, rule := range matcher.prefixes {
+		if strings.HasPrefix(queryText, rule.Pattern) {
+			return rule.ID
+		}
+	}
+	for _, rule := range matcher.regexes {
+		if rule.regexp.MatchString(queryText) {
+			return rule.id
+		}
+	}
+	if len(bs.profanity) > 0 {
+		words := strings.FieldsFunc(queryText, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if bs.profanity[word] {
+				return models.ProfanityRuleID
+			}
+		}
+	}
+	return ""
+}
+
+// matcher returns the tenant's rules, reloading them once they are older than the refresh interval. If reloading
+// fails, the previous rules keep being used.
+func (bs blocklistService) matcher(ctx context.Context) (*blocklistMatcher, error) {
+	matcher, stale, err := bs.matchers.Get(ctx)
+	if stale {
+		bs.logger.ErrorContext(ctx, "Error reloading blocklist rules", "error", err, "tenantID", tenant.FromContext(ctx))
+		return matcher, nil
+	}
+	return matcher, err
+}
+
+func (bs blocklistService) load(ctx context.Context) (*blocklistMatcher, error) {
+	rules, err := bs.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := &blocklistMatcher{exact: make(map[string]string)}
+	for _, rule := range rules {
+		switch rule.Type {
+		case models.BlocklistRuleExact:
+			matcher.exact[rule.Pattern] = rule.ID
+		case models.BlocklistRulePrefix:
+			matcher.prefixes = append(matcher.prefixes, rule)
+		case models.BlocklistRuleRegex:
+			re, err := compileBlocklistRegex(rule.Pattern)
+			if err != nil {
+				bs.logger.ErrorContext(ctx, "Skipping invalid blocklist regex", "error", err, "ruleID", rule.ID)
+				continue
+			}
+			matcher.regexes = append(matcher.regexes, compiledBlocklistRule{id: rule.ID, regexp: re})
+		}
+	}
+	return matcher, nil
+}
+
+// compileBlocklistRegex compiles a regex rule to match case-insensitively, since queries are lowercased before being
+// matched: a pattern such as "[A-Z]{10}" would otherwise never match.
+func compileBlocklistRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+func (bs blocklistService) AddRule(ctx context.Context, ruleType models.BlocklistRuleType, pattern string) (*models.BlocklistRule, error) {
+	if !ruleType.IsValid() {
+		return nil, fmt.Errorf("%w: type must be exact, prefix or regex", ErrInvalidBlocklistRule)
+	}
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("%w: pattern cannot be empty", ErrInvalidBlocklistRule)
+	}
+	if ruleType == models.BlocklistRuleRegex {
+		if _, err := compileBlocklistRegex(strings.TrimSpace(pattern)); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidBlocklistRule, err)
+		}
+	}
+
+	rule := models.NewBlocklistRule(ruleType, pattern)
+	if err := bs.repo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	bs.matchers.Invalidate(ctx)
+	return rule, nil
+}
+
+func (bs blocklistService) ListRules(ctx context.Context) ([]models.BlocklistRule, error) {
+	return bs.repo.List(ctx)
+}
+
+func (bs blocklistService) DeleteRule(ctx context.Context, id string) (bool, error) {
+	deleted, err := bs.repo.Delete(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	bs.matchers.Invalidate(ctx)
+	return deleted, nil
+}
+
+func (bs blocklistService) Purge(ctx context.Context) (*models.BlocklistPurgeResult, error) {
+	// The rules are loaded fresh, since the cached ones may predate a rule just added on another replica.
+	matcher, err := bs.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := bs.repo.PurgeSearchLogs(ctx, func(queryText string) bool {
+		return bs.match(matcher, queryText) != ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	bs.logger.InfoContext(ctx, "Purged blocked queries", "tenantID", tenant.FromContext(ctx), "purged", len(queries))
+	if len(queries) > 0 {
+		for _, listener := range bs.purgeListeners {
+			listener.OnQueriesPurged(ctx, queries)
+		}
+	}
+	return &models.BlocklistPurgeResult{Purged: len(queries), Queries: queries}, nil
+}
+
+func (bs blocklistService) Stats(ctx context.Context, from, to time.Time) ([]models.BlocklistRuleStats, error) {
+	counts, err := bs.repo.ListBlockedCounts(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := bs.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]models.BlocklistRuleStats, 0, len(rules)+1)
+	for _, rule := range rules {
+		stats = append(stats, models.BlocklistRuleStats{RuleID: rule.ID, Type: rule.Type, Pattern: rule.Pattern, Blocked: counts[rule.ID]})
+	}
+	if bs.cfg.ProfanityEnabled || counts[models.ProfanityRuleID] > 0 {
+		stats = append(stats, models.BlocklistRuleStats{
+			RuleID:  models.ProfanityRuleID,
+			Type:    models.BlocklistRuleProfanity,
+			Blocked: counts[models.ProfanityRuleID],
+		})
+	}
+	return stats, nil
+}
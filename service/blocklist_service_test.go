@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocklistService_Match(t *testing.T) {
+	repo := database.NewBlocklistDatabaseRepository(setupTestDB(t))
+	blocklist := NewBlocklistService(repo, BlocklistConfig{ProfanityEnabled: true, RefreshInterval: time.Minute}, slog.Default())
+	ctx := context.Background()
+
+	exact, err := blocklist.AddRule(ctx, models.BlocklistRuleExact, "Test")
+	assert.NoError(t, err)
+	prefix, err := blocklist.AddRule(ctx, models.BlocklistRulePrefix, "http://")
+	assert.NoError(t, err)
+	regex, err := blocklist.AddRule(ctx, models.BlocklistRuleRegex, `^[0-9a-f]{32}$`)
+	assert.NoError(t, err)
+	upperRegex, err := blocklist.AddRule(ctx, models.BlocklistRuleRegex, `^SKU-[0-9]+$`)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		queryText string
+		ruleID    string
+	}{
+		{"Exact rules match the whole normalized query", " TEST ", exact.ID},
+		{"Exact rules do not match substrings", "test laptop", ""},
+		{"Prefix rules", "http://spam.example", prefix.ID},
+		{"Regex rules", "0123456789abcdef0123456789abcdef", regex.ID},
+		{"Regex rules match case-insensitively", "SKU-42", upperRegex.ID},
+		{"Profanity list matches whole words", "what the fuck", models.ProfanityRuleID},
+		{"Profanity list ignores words containing a listed one", "scunthorpe", ""},
+		{"Other queries are allowed", "laptop", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleID, err := blocklist.Match(ctx, tt.queryText)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ruleID, ruleID)
+		})
+	}
+
+	t.Run("Rules are scoped to the tenant", func(t *testing.T) {
+		ruleID, err := blocklist.Match(tenant.WithTenant(ctx, "acme"), "test")
+		assert.NoError(t, err)
+		assert.Empty(t, ruleID)
+	})
+
+	t.Run("Deleted rules stop matching", func(t *testing.T) {
+		deleted, err := blocklist.DeleteRule(ctx, exact.ID)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+
+		ruleID, err := blocklist.Match(ctx, "test")
+		assert.NoError(t, err)
+		assert.Empty(t, ruleID)
+	})
+
+	t.Run("Reject invalid rules", func(t *testing.T) {
+		_, err := blocklist.AddRule(ctx, models.BlocklistRuleRegex, "(")
+		assert.ErrorIs(t, err, ErrInvalidBlocklistRule)
+		_, err = blocklist.AddRule(ctx, models.BlocklistRulePrefix, "  ")
+		assert.ErrorIs(t, err, ErrInvalidBlocklistRule)
+		_, err = blocklist.AddRule(ctx, models.BlocklistRuleProfanity, "word")
+		assert.ErrorIs(t, err, ErrInvalidBlocklistRule)
+	})
+}
+
+func TestBlocklistService_BeforePersist(t *testing.T) {
+	repo := database.NewBlocklistDatabaseRepository(setupTestDB(t))
+	blocklist := NewBlocklistService(repo, BlocklistConfig{ProfanityEnabled: true, RefreshInterval: time.Minute}, slog.Default())
+	ctx := context.Background()
+	rule, err := blocklist.AddRule(ctx, models.BlocklistRulePrefix, "www.")
+	assert.NoError(t, err)
+
+	t.Run("Blocked queries are rejected and counted", func(t *testing.T) {
+		_, keep := blocklist.BeforePersist(ctx, "client", "www.spam.example")
+		assert.False(t, keep)
+		_, keep = blocklist.BeforePersist(ctx, "client", "shit")
+		assert.False(t, keep)
+
+		stats, err := blocklist.Stats(ctx, time.Now(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, []models.BlocklistRuleStats{
+			{RuleID: rule.ID, Type: models.BlocklistRulePrefix, Pattern: "www.", Blocked: 1},
+			{RuleID: models.ProfanityRuleID, Type: models.BlocklistRuleProfanity, Blocked: 1},
+		}, stats)
+	})
+
+	t.Run("Allowed queries are kept unchanged", func(t *testing.T) {
+		queryText, keep := blocklist.BeforePersist(ctx, "client", "laptop")
+		assert.True(t, keep)
+		assert.Equal(t, "laptop", queryText)
+	})
+}
+
+func TestBlocklistService_AfterSynonyms(t *testing.T) {
+	// ARRANGE
+	db := setupTestDB(t)
+	ctx := context.Background()
+	blocklist := NewBlocklistService(database.NewBlocklistDatabaseRepository(db), BlocklistConfig{RefreshInterval: time.Minute}, slog.Default())
+	_, err := blocklist.AddRule(ctx, models.BlocklistRuleExact, "casino")
+	assert.NoError(t, err)
+	synonymRepo := database.NewSynonymDatabaseRepository(db)
+	_, err = synonymRepo.Upsert(ctx, "casinos", "casino")
+	assert.NoError(t, err)
+	searchLogs := database.NewSearchLogDatabaseRepository(db)
+	searchLogSrv := NewSearchLogService(searchLogs, setupTestRedis(t), slog.Default(),
+		WithPersistFilters(NewSynonymService(synonymRepo, slog.Default()), blocklist)).(*searchLogService)
+
+	// ACT
+	err = searchLogSrv.persist(ctx, "client", "casinos", nil, time.Now())
+
+	// ASSERT
+	assert.NoError(t, err)
+	for _, queryText := range []string{"casinos", "casino"} {
+		searchLog, err := searchLogs.GetByQueryText(ctx, queryText)
+		assert.NoError(t, err)
+		assert.Nil(t, searchLog, "a variant of a blocked query is blocked")
+	}
+}
+
+// recordingPurgeListener records the queries it is told were purged.
+type recordingPurgeListener struct {
+	purged []string
+}
+
+func (l *recordingPurgeListener) OnQueriesPurged(_ context.Context, queryTexts []string) {
+	l.purged = append(l.purged, queryTexts...)
+}
+
+func TestBlocklistService_Purge(t *testing.T) {
+	db := setupTestDB(t)
+	repo := database.NewBlocklistDatabaseRepository(db)
+	listener := &recordingPurgeListener{}
+	blocklist := NewBlocklistService(repo, BlocklistConfig{ProfanityEnabled: false, RefreshInterval: time.Minute}, slog.Default(), listener)
+	ctx := context.Background()
+
+	for _, queryText := range []string{"laptop", "test", "fuck"} {
+		assert.NoError(t, db.Create(models.NewSearchLog(queryText, 1)).Error)
+	}
+	_, err := blocklist.AddRule(ctx, models.BlocklistRuleExact, "test")
+	assert.NoError(t, err)
+
+	// ACT
+	result, err := blocklist.Purge(ctx)
+
+	// ASSERT
+	assert.NoError(t, err)
+	assert.Equal(t, &models.BlocklistPurgeResult{Purged: 1, Queries: []string{"test"}}, result, "the profanity list is disabled")
+
+	var remaining []string
+	assert.NoError(t, db.Model(&models.SearchLog{}).Order("query_text").Pluck("query_text", &remaining).Error)
+	assert.Equal(t, []string{"fuck", "laptop"}, remaining)
+	assert.Equal(t, []string{"test"}, listener.purged)
+}
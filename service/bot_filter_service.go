@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"strings"
+	"time"
+)
+
+const (
+	BotReasonUserAgent          = "user_agent"
+	BotReasonVelocity           = "velocity"
+	BotReasonDistinctQueryBurst = "distinct_query_burst"
+)
+
+type BotFilterConfig struct {
+	// Mode is config.BotFilterModeLog, config.BotFilterModeQuarantine or config.BotFilterModeExclude.
+	Mode                string
+	UserAgentPatterns   []string
+	MaxQueriesPerMinute int
+	MaxDistinctQueries  int
+	DistinctQueryWindow time.Duration
+	FlagTTL             time.Duration
+}
+
+// BotFilterConfigFromEnv builds a BotFilterConfig from the BOT_* environment variables.
+func BotFilterConfigFromEnv() BotFilterConfig {
+	return BotFilterConfig{
+		Mode:                config.GetBotFilterMode(),
+		UserAgentPatterns:   config.GetBotUserAgentPatterns(),
+		MaxQueriesPerMinute: config.GetBotMaxQueriesPerMinute(),
+		MaxDistinctQueries:  config.GetBotMaxDistinctQueries(),
+		DistinctQueryWindow: config.GetBotDistinctQueryWindow(),
+		FlagTTL:             config.GetBotFlagTTL(),
+	}
+}
+
+type BotFilterService interface {
+	PersistFilter
+	// Classify records a finalized query for the client and returns why the client is considered automated,
+	// or an empty string when it looks human.
+	Classify(ctx context.Context, clientIdentifier, queryText string) (string, error)
+}
+
+type botFilterService struct {
+	activity   cache.ClientActivityCacheRepository
+	quarantine database.QuarantineRepository
+	cfg        BotFilterConfig
+	logger     *slog.Logger
+}
+
+func NewBotFilterService(activity cache.ClientActivityCacheRepository, quarantine database.QuarantineRepository, cfg BotFilterConfig, logger *slog.Logger) BotFilterService {
+	patterns := make([]string, 0, len(cfg.UserAgentPatterns))
+	for _, pattern := range cfg.UserAgentPatterns {
+		patterns = append(patterns, strings.ToLower(pattern))
+	}
+	cfg.UserAgentPatterns = patterns
+
+	return &botFilterService{
+		activity:   activity,
+		quarantine: quarantine,
+		cfg:        cfg,
+		logger:     logger,
+	}
+}
+
+func (bfs botFilterService) BeforePersist(ctx context.Context, clientIdentifier, queryText string) (string, bool) {
+	// An admin already reviewed the searches released from quarantine.
+	if isReleasedSearch(ctx) {
+		return queryText, true
+	}
+
+	reason, err := bfs.Classify(ctx, clientIdentifier, queryText)
+	if err != nil {
+		// Fail open: losing a real search is worse than counting an occasional bot one.
+		bfs.logger.ErrorContext(ctx, "Error classifying client", "error", err, "clientIdentifier", clientIdentifier)
+		return queryText, true
+	}
+	if reason == "" || bfs.cfg.Mode == config.BotFilterModeLog {
+		return queryText, true
+	}
+
+	if bfs.cfg.Mode == config.BotFilterModeQuarantine {
+		clientInfo, _ := ClientInfoFromContext(ctx)
+		search := models.NewQuarantinedSearch(clientIdentifier, queryText, reason, clientInfo.UserAgent)
+		if err := bfs.quarantine.Create(ctx, search); err != nil {
+			bfs.logger.ErrorContext(ctx, "Error quarantining search", "error", err, "clientIdentifier", clientIdentifier, "queryText", queryText)
+		}
+	}
+	return queryText, false
+}
+
+func (bfs botFilterService) Classify(ctx context.Context, clientIdentifier, queryText string) (string, error) {
+	reason, err := bfs.activity.GetFlag(ctx, clientIdentifier)
+	if err != nil || reason != "" {
+		return reason, err
+	}
+
+	reason, err = bfs.classifyActivity(ctx, clientIdentifier, queryText)
+	if err != nil || reason == "" {
+		return reason, err
+	}
+
+	bfs.logger.InfoContext(ctx, "Flagging client as automated", "clientIdentifier", clientIdentifier, "reason", reason)
+	if err := bfs.activity.Flag(ctx, clientIdentifier, reason, bfs.cfg.FlagTTL); err != nil {
+		return "", err
+	}
+	return reason, nil
+}
+
+func (bfs botFilterService) classifyActivity(ctx context.Context, clientIdentifier, queryText string) (string, error) {
+	if clientInfo, ok := ClientInfoFromContext(ctx); ok && bfs.isBotUserAgent(clientInfo.UserAgent) {
+		return BotReasonUserAgent, nil
+	}
+
+	if bfs.cfg.MaxQueriesPerMinute > 0 {
+		perMinute, err := bfs.activity.IncrementVelocity(ctx, clientIdentifier, time.Minute)
+		if err != nil {
+			return "", err
+		}
+		if perMinute > int64(bfs.cfg.MaxQueriesPerMinute) {
+			return BotReasonVelocity, nil
+		}
+	}
+
+	if bfs.cfg.MaxDistinctQueries > 0 {
+		distinct, err := bfs.activity.AddDistinctQuery(ctx, clientIdentifier, queryText, bfs.cfg.DistinctQueryWindow)
+		if err != nil {
+			return "", err
+		}
+		if distinct > int64(bfs.cfg.MaxDistinctQueries) {
+			return BotReasonDistinctQueryBurst, nil
+		}
+	}
+
+	return "", nil
+}
+
+// isBotUserAgent reports whether the user agent matches a bot pattern. A missing user agent is not enough, since
+// server-side callers often send none.
+func (bfs botFilterService) isBotUserAgent(userAgent string) bool {
+	userAgent = strings.ToLower(strings.TrimSpace(userAgent))
+	if userAgent == "" {
+		return false
+	}
+	for _, pattern := range bfs.cfg.UserAgentPatterns {
+		if strings.Contains(userAgent, pattern) {
+			return true
+		}
+	}
+	return false
+}
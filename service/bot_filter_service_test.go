@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/storage_util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestQuarantineRepository(t *testing.T) database.QuarantineRepository {
+	return database.NewQuarantineDatabaseRepository(setupTestDB(t))
+}
+
+func testBotFilterConfig(mode string) BotFilterConfig {
+	return BotFilterConfig{
+		Mode:                mode,
+		UserAgentPatterns:   []string{"Bot", "curl"},
+		MaxQueriesPerMinute: 5,
+		MaxDistinctQueries:  3,
+		DistinctQueryWindow: time.Minute,
+		FlagTTL:             time.Hour,
+	}
+}
+
+func TestBotFilterService_Classify(t *testing.T) {
+	activity := cache.NewClientActivityCacheRepository(storage_util.InitRedis())
+	botFilter := NewBotFilterService(activity, setupTestQuarantineRepository(t), testBotFilterConfig(config.BotFilterModeExclude), slog.Default())
+	browser := ClientInfo{UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Safari/605.1.15"}
+
+	t.Run("Browser user agent with normal activity is human", func(t *testing.T) {
+		ctx := WithClientInfo(context.Background(), browser)
+		reason, err := botFilter.Classify(ctx, "human", "laptop")
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+	})
+
+	t.Run("Bot user agents are flagged case-insensitively", func(t *testing.T) {
+		ctx := WithClientInfo(context.Background(), ClientInfo{UserAgent: "Googlebot/2.1"})
+		reason, err := botFilter.Classify(ctx, "googlebot", "laptop")
+		assert.NoError(t, err)
+		assert.Equal(t, BotReasonUserAgent, reason)
+	})
+
+	t.Run("Missing user agent alone is not flagged", func(t *testing.T) {
+		ctx := WithClientInfo(context.Background(), ClientInfo{})
+		reason, err := botFilter.Classify(ctx, "no-agent", "laptop")
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+	})
+
+	t.Run("Too many finalized queries per minute are flagged", func(t *testing.T) {
+		ctx := WithClientInfo(context.Background(), browser)
+		var reason string
+		var err error
+		for i := 0; i < 6; i++ {
+			reason, err = botFilter.Classify(ctx, "fast-client", "same query")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, BotReasonVelocity, reason)
+	})
+
+	t.Run("Bursts of distinct queries are flagged", func(t *testing.T) {
+		ctx := WithClientInfo(context.Background(), browser)
+		var reason string
+		var err error
+		for _, queryText := range []string{"a", "b", "c", "d"} {
+			reason, err = botFilter.Classify(ctx, "enumerating-client", queryText)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, BotReasonDistinctQueryBurst, reason)
+	})
+
+	t.Run("Flagged clients stay flagged", func(t *testing.T) {
+		ctx := WithClientInfo(context.Background(), browser)
+		reason, err := botFilter.Classify(ctx, "enumerating-client", "a")
+		assert.NoError(t, err)
+		assert.Equal(t, BotReasonDistinctQueryBurst, reason)
+	})
+}
+
+func TestBotFilterService_BeforePersist(t *testing.T) {
+	activity := cache.NewClientActivityCacheRepository(storage_util.InitRedis())
+	botCtx := WithClientInfo(context.Background(), ClientInfo{UserAgent: "curl/8.4.0"})
+
+	t.Run("Exclude mode drops bot searches", func(t *testing.T) {
+		// ARRANGE
+		quarantine := setupTestQuarantineRepository(t)
+		botFilter := NewBotFilterService(activity, quarantine, testBotFilterConfig(config.BotFilterModeExclude), slog.Default())
+
+		// ACT
+		queryText, keep := botFilter.BeforePersist(botCtx, "excluded-bot", "scraped query")
+
+		// ASSERT
+		assert.False(t, keep)
+		assert.Equal(t, "scraped query", queryText)
+		pending, err := quarantine.ListPending(context.Background(), "", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("Quarantine mode records bot searches for review", func(t *testing.T) {
+		// ARRANGE
+		quarantine := setupTestQuarantineRepository(t)
+		botFilter := NewBotFilterService(activity, quarantine, testBotFilterConfig(config.BotFilterModeQuarantine), slog.Default())
+
+		// ACT
+		_, keep := botFilter.BeforePersist(botCtx, "quarantined-bot", "scraped query")
+
+		// ASSERT
+		assert.False(t, keep)
+		pending, err := quarantine.ListPending(context.Background(), "quarantined-bot", 10)
+		assert.NoError(t, err)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, "scraped query", pending[0].QueryText)
+		assert.Equal(t, BotReasonUserAgent, pending[0].Reason)
+		assert.Equal(t, "curl/8.4.0", pending[0].UserAgent)
+	})
+
+	t.Run("Log mode keeps bot searches", func(t *testing.T) {
+		// ARRANGE
+		quarantine := setupTestQuarantineRepository(t)
+		botFilter := NewBotFilterService(activity, quarantine, testBotFilterConfig(config.BotFilterModeLog), slog.Default())
+
+		// ACT
+		queryText, keep := botFilter.BeforePersist(botCtx, "logged-bot", "scraped query")
+
+		// ASSERT
+		assert.True(t, keep)
+		assert.Equal(t, "scraped query", queryText)
+		pending, err := quarantine.ListPending(context.Background(), "", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("Human searches are kept", func(t *testing.T) {
+		// ARRANGE
+		quarantine := setupTestQuarantineRepository(t)
+		botFilter := NewBotFilterService(activity, quarantine, testBotFilterConfig(config.BotFilterModeQuarantine), slog.Default())
+		ctx := WithClientInfo(context.Background(), ClientInfo{UserAgent: "Mozilla/5.0 Firefox/128.0"})
+
+		// ACT
+		queryText, keep := botFilter.BeforePersist(ctx, "firefox-user", "tent")
+
+		// ASSERT
+		assert.True(t, keep)
+		assert.Equal(t, "tent", queryText)
+	})
+}
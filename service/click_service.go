@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoQueryToAttribute = errors.New("no query to attribute the click to")
+	// ErrInvalidClick is returned for clicks without a result id or with a position below 1.
+	ErrInvalidClick = errors.New("invalid click")
+)
+
+type ClickService interface {
+	SearchLogPersistedListener
+	// RecordClick attributes a click on a result to the client's search and returns the query it was attributed to.
+	// It returns ErrInvalidClick for invalid fields, and ErrNoQueryToAttribute when the client has no search.
+	RecordClick(ctx context.Context, clientIdentifier, queryText, resultID string, position int) (string, error)
+}
+
+type clickService struct {
+	clicks        database.ClickRepository
+	latestQueries cache.LatestClientQueryCacheRepository
+	sessions      cache.ClientSessionCacheRepository
+	synonyms      SynonymService
+	sessionWindow time.Duration
+	logger        *slog.Logger
+}
+
+func NewClickService(clicks database.ClickRepository, latestQueries cache.LatestClientQueryCacheRepository, sessions cache.ClientSessionCacheRepository, synonyms SynonymService, sessionWindow time.Duration, logger *slog.Logger) ClickService {
+	return &clickService{
+		clicks:        clicks,
+		latestQueries: latestQueries,
+		sessions:      sessions,
+		synonyms:      synonyms,
+		sessionWindow: sessionWindow,
+		logger:        logger,
+	}
+}
+
+// OnSearchLogPersisted remembers the client's most recent finalized query, and counts a click made while that
+// query was still being debounced.
+func (cs clickService) OnSearchLogPersisted(ctx context.Context, clientIdentifier string, searchLog *models.SearchLog) {
+	value := &cache.FinalizedQueryValue{
+		QueryText:                   searchLog.QueryText,
+		FinalizedAtUnixMilliseconds: time.Now().UnixMilli(),
+	}
+	if err := cs.sessions.SetLastFinalizedQuery(ctx, clientIdentifier, value, cs.sessionWindow); err != nil {
+		cs.logger.ErrorContext(ctx, "Error setting last finalized query", "error", err, "clientIdentifier", clientIdentifier)
+	}
+
+	pending, err := cs.sessions.TakePendingClick(ctx, clientIdentifier, searchLog.QueryText)
+	if err != nil {
+		cs.logger.ErrorContext(ctx, "Error reading pending click", "error", err, "clientIdentifier", clientIdentifier)
+		return
+	}
+	if pending {
+		if _, err := cs.clicks.IncrementClickedSearches(ctx, searchLog.QueryText); err != nil {
+			cs.logger.ErrorContext(ctx, "Error counting clicked search", "error", err, "queryText", searchLog.QueryText)
+		}
+	}
+}
+
+func (cs clickService) RecordClick(ctx context.Context, clientIdentifier, queryText, resultID string, position int) (string, error) {
+	if resultID == "" {
+		return "", fmt.Errorf("%w: result id cannot be empty", ErrInvalidClick)
+	}
+	if position < 1 {
+		return "", fmt.Errorf("%w: position must be at least 1", ErrInvalidClick)
+	}
+
+	attributedQueryText, pending, err := cs.attribute(ctx, clientIdentifier, strings.TrimSpace(strings.ToLower(queryText)))
+	if err != nil {
+		return "", err
+	}
+
+	if err := cs.clicks.RecordClick(ctx, attributedQueryText, resultID, position); err != nil {
+		return "", fmt.Errorf("error recording click: %w", err)
+	}
+
+	first, err := cs.sessions.MarkClickedQuery(ctx, clientIdentifier, attributedQueryText, cs.sessionWindow)
+	if err != nil {
+		return "", fmt.Errorf("error marking clicked query: %w", err)
+	}
+	if !first {
+		return attributedQueryText, nil
+	}
+
+	if !pending {
+		if counted, err := cs.clicks.IncrementClickedSearches(ctx, attributedQueryText); err != nil || counted {
+			return attributedQueryText, err
+		}
+	}
+	// The search is not in search_logs yet, so count it as clicked once it is finalized.
+	if err := cs.sessions.MarkPendingClick(ctx, clientIdentifier, attributedQueryText, cs.sessionWindow); err != nil {
+		return "", fmt.Errorf("error marking pending click: %w", err)
+	}
+	return attributedQueryText, nil
+}
+
+// attribute picks the query a click belongs to. A click on the query the client is still debouncing means the client
+// stopped typing, so it belongs to that query even though it is not finalized yet. Otherwise the click belongs to the
+// client's most recent finalized query. Without either, the query sent with the click is used as is. Finalized
+// queries are already canonical; the others are canonicalized, so the click is counted under the query the search is.
+func (cs clickService) attribute(ctx context.Context, clientIdentifier, queryText string) (string, bool, error) {
+	latest, err := cs.latestQueries.Get(ctx, clientIdentifier)
+	if err != nil {
+		return "", false, fmt.Errorf("error getting latest client query: %w", err)
+	}
+	if latest != nil && queryText != "" && latest.QueryText == queryText {
+		return cs.canonicalize(ctx, clientIdentifier, latest.QueryText), true, nil
+	}
+
+	lastFinalized, err := cs.sessions.GetLastFinalizedQuery(ctx, clientIdentifier)
+	if err != nil {
+		return "", false, fmt.Errorf("error getting last finalized query: %w", err)
+	}
+	if lastFinalized != nil {
+		return lastFinalized.QueryText, false, nil
+	}
+
+	if queryText == "" {
+		return "", false, ErrNoQueryToAttribute
+	}
+	return cs.canonicalize(ctx, clientIdentifier, queryText), false, nil
+}
+
+func (cs clickService) canonicalize(ctx context.Context, clientIdentifier, queryText string) string {
+	canonical, err := cs.synonyms.Canonicalize(ctx, queryText)
+	if err != nil {
+		// Like the synonym filter, attributing the click to the variant is better than losing it.
+		cs.logger.ErrorContext(ctx, "Error looking up synonym", "error", err, "clientIdentifier", clientIdentifier, "query", queryText)
+		return queryText
+	}
+	return canonical
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/storage_util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClickService_RecordClick(t *testing.T) {
+	db := setupTestDB(t)
+	redisClient := storage_util.InitRedis()
+	searchLogRepo := database.NewSearchLogDatabaseRepository(db)
+	clickRepo := database.NewClickDatabaseRepository(db)
+	latestQueries := cache.NewLatestClientQueryCacheRepository(redisClient)
+	synonymRepo := database.NewSynonymDatabaseRepository(db)
+	clicks := NewClickService(clickRepo, latestQueries, cache.NewClientSessionCacheRepository(redisClient), NewSynonymService(synonymRepo, slog.Default()), time.Minute, slog.Default())
+	ctx := context.Background()
+	_, err := synonymRepo.Upsert(ctx, "tvs", "tv")
+	assert.NoError(t, err)
+
+	t.Run("Clicks are attributed to the most recent finalized query", func(t *testing.T) {
+		// ARRANGE
+		searchLog, err := searchLogRepo.IncrementSearchLog(ctx, "gaming laptop")
+		assert.NoError(t, err)
+		clicks.OnSearchLogPersisted(ctx, "click-client-1", searchLog)
+
+		// ACT
+		attributed, err := clicks.RecordClick(ctx, "click-client-1", "gaming lap", "sku-1", 2)
+		assert.NoError(t, err)
+		_, err = clicks.RecordClick(ctx, "click-client-1", "", "sku-2", 4)
+		assert.NoError(t, err)
+
+		// ASSERT
+		assert.Equal(t, "gaming laptop", attributed)
+		stats, err := clickRepo.GetQueryClickStats(ctx, "gaming laptop", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stats.Clicks)
+		assert.Equal(t, 3.0, stats.MeanClickPosition)
+		assert.Equal(t, 1, stats.ClickedSearchCount, "several clicks on one search count as one clicked search")
+		assert.Equal(t, 1.0, stats.CTR)
+	})
+
+	t.Run("Clicks on a query still being debounced are counted once it is finalized", func(t *testing.T) {
+		// ARRANGE
+		assert.NoError(t, latestQueries.Set(ctx, "click-client-2", cache.NewClientQueryValue("headphones", time.Now().UnixMilli())))
+
+		// ACT
+		attributed, err := clicks.RecordClick(ctx, "click-client-2", "Headphones", "sku-9", 1)
+		assert.NoError(t, err)
+		searchLog, err := searchLogRepo.IncrementSearchLog(ctx, "headphones")
+		assert.NoError(t, err)
+		clicks.OnSearchLogPersisted(ctx, "click-client-2", searchLog)
+
+		// ASSERT
+		assert.Equal(t, "headphones", attributed)
+		stats, err := clickRepo.GetQueryClickStats(ctx, "headphones", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stats.Clicks)
+		assert.Equal(t, 1, stats.ClickedSearchCount)
+	})
+
+	t.Run("Clicks on a variant being debounced are counted under its canonical query", func(t *testing.T) {
+		// ARRANGE
+		assert.NoError(t, latestQueries.Set(ctx, "click-client-4", cache.NewClientQueryValue("tvs", time.Now().UnixMilli())))
+
+		// ACT
+		attributed, err := clicks.RecordClick(ctx, "click-client-4", "tvs", "sku-5", 1)
+		assert.NoError(t, err)
+		// The synonym filter rewrites the variant before it is persisted.
+		searchLog, err := searchLogRepo.IncrementSearchLog(ctx, "tv")
+		assert.NoError(t, err)
+		clicks.OnSearchLogPersisted(ctx, "click-client-4", searchLog)
+
+		// ASSERT
+		assert.Equal(t, "tv", attributed)
+		stats, err := clickRepo.GetQueryClickStats(ctx, "tv", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stats.Clicks)
+		assert.Equal(t, 1, stats.ClickedSearchCount)
+	})
+
+	t.Run("Clicks without any query to attribute to are rejected", func(t *testing.T) {
+		_, err := clicks.RecordClick(ctx, "click-client-3", "", "sku-1", 1)
+		assert.ErrorIs(t, err, ErrNoQueryToAttribute)
+	})
+
+	t.Run("Invalid positions are rejected", func(t *testing.T) {
+		_, err := clicks.RecordClick(ctx, "click-client-1", "gaming laptop", "sku-1", 0)
+		assert.ErrorIs(t, err, ErrInvalidClick)
+	})
+}
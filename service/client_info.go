@@ -0,0 +1,21 @@
+package service
+
+import "context"
+
+// ClientInfo describes the client behind a request, as far as the HTTP layer could tell.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type clientInfoContextKey struct{}
+
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoContextKey{}, info)
+}
+
+// ClientInfoFromContext returns the client info attached to ctx, and false when there is none.
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoContextKey{}).(ClientInfo)
+	return info, ok
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+	"search-logger/correlation"
+	"search-logger/tenant"
+)
+
+// detachContext returns a background context carrying the request-scoped values of ctx, for work that outlives the
+// request, such as the debounced persistence in LogSearch.
+func detachContext(ctx context.Context) context.Context {
+	detached := tenant.WithTenant(context.Background(), tenant.FromContext(ctx))
+	detached = correlation.Copy(detached, ctx)
+	if clientInfo, ok := ClientInfoFromContext(ctx); ok {
+		detached = WithClientInfo(detached, clientInfo)
+	}
+	return detached
+}
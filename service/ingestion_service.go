@@ -0,0 +1,467 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/correlation"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/tenant"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrIngestionQueueFull = errors.New("ingestion queue is full")
+	ErrIngestionStopped   = errors.New("ingestion is shutting down")
+)
+
+const (
+	// ingestionPollTimeout is how long a Redis worker waits for an event before checking whether it should stop.
+	ingestionPollTimeout = time.Second
+	// ingestionHeartbeatInterval is how often a replica tells the others it is still consuming the Redis queue. Once
+	// it missed ingestionHeartbeatMisses in a row, the events it did not finish are requeued for the others.
+	ingestionHeartbeatInterval = 10 * time.Second
+	ingestionHeartbeatMisses   = 3
+)
+
+type IngestionConfig struct {
+	// Mode is config.IngestionModeDirect, config.IngestionModeChannel or config.IngestionModeRedis.
+	Mode string
+	// QueueSize bounds the keystrokes queued, and those the replica's workers are debouncing. In Redis mode the queue
+	// is shared by all replicas, while each replica debounces up to QueueSize keystrokes.
+	QueueSize int
+	Workers   int
+	// ReplicaID names the replica's processing list in Redis mode. A random one is used when it is empty.
+	ReplicaID string
+}
+
+// IngestionConfigFromEnv builds an IngestionConfig from the INGESTION_* environment variables.
+func IngestionConfigFromEnv() IngestionConfig {
+	return IngestionConfig{
+		Mode:      config.GetIngestionMode(),
+		QueueSize: config.GetIngestionQueueSize(),
+		Workers:   config.GetIngestionWorkers(),
+		ReplicaID: config.GetReplicaID(),
+	}
+}
+
+// IngestionService accepts keystrokes from the API and logs them with SearchLogService in the background.
+type IngestionService interface {
+	// Ingest accepts a keystroke, with its result count when the caller knows it. The tenant, ClientInfo and
+	// KeystrokeOrder on ctx go with it. It returns ErrIngestionQueueFull when the queue cannot take more keystrokes,
+	// and ErrIngestionStopped once Drain was called.
+	Ingest(ctx context.Context, clientIdentifier, queryText string, resultCount *int) error
+	// Drain stops accepting keystrokes and waits until the accepted ones are logged, or ctx is done. Keystrokes the
+	// workers are debouncing are finalized right away. In Redis mode, keystrokes still queued in Redis are left to the
+	// other replicas, or to the next start.
+	Drain(ctx context.Context) error
+}
+
+type ingestionService struct {
+	srv    SearchLogService
+	queue  cache.SearchEventQueueCacheRepository
+	cfg    IngestionConfig
+	logger *slog.Logger
+
+	// mu guards stopped, and closing events, against concurrent sends.
+	mu      *sync.RWMutex
+	stopped bool
+	events  chan *models.SearchEvent
+	stop    chan struct{}
+	pending *sync.WaitGroup
+
+	// debouncing holds the keystrokes the workers recorded until they are due, soonest first. receiving counts the
+	// workers waiting for an event, which is debounced next.
+	debounceMu *sync.Mutex
+	debouncing debouncingKeystrokes
+	receiving  int
+	// receivers counts the workers still taking events. Keystrokes still debouncing are finalized once there are none,
+	// so that none is finalized before a later keystroke of the client that is still queued.
+	receivers *sync.WaitGroup
+}
+
+// debouncingKeystroke is a keystroke recorded by a worker, with the event it was popped as in Redis mode.
+type debouncingKeystroke struct {
+	keystroke *PendingKeystroke
+	queued    *cache.QueuedSearchEvent
+}
+
+// debouncingKeystrokes is a min-heap of keystrokes by due time.
+type debouncingKeystrokes []debouncingKeystroke
+
+func (h debouncingKeystrokes) Len() int { return len(h) }
+func (h debouncingKeystrokes) Less(i, j int) bool {
+	return h[i].keystroke.DueAt.Before(h[j].keystroke.DueAt)
+}
+func (h debouncingKeystrokes) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *debouncingKeystrokes) Push(x any) {
+	*h = append(*h, x.(debouncingKeystroke))
+}
+
+func (h *debouncingKeystrokes) Pop() any {
+	old := *h
+	keystroke := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return keystroke
+}
+
+// NewIngestionService starts the worker pool of the configured mode. queue is only used in Redis mode.
+func NewIngestionService(srv SearchLogService, queue cache.SearchEventQueueCacheRepository, cfg IngestionConfig, logger *slog.Logger) IngestionService {
+	if cfg.ReplicaID == "" {
+		cfg.ReplicaID = uuid.New().String()
+	}
+	is := &ingestionService{
+		srv:        srv,
+		queue:      queue,
+		cfg:        cfg,
+		logger:     logger,
+		mu:         &sync.RWMutex{},
+		stop:       make(chan struct{}),
+		pending:    &sync.WaitGroup{},
+		debounceMu: &sync.Mutex{},
+		receivers:  &sync.WaitGroup{},
+	}
+
+	switch cfg.Mode {
+	case config.IngestionModeChannel:
+		is.events = make(chan *models.SearchEvent, cfg.QueueSize)
+		for i := 0; i < cfg.Workers; i++ {
+			is.pending.Add(1)
+			is.receivers.Add(1)
+			go is.work(is.receiveChannel)
+		}
+	case config.IngestionModeRedis:
+		// The heartbeat is up before the workers pop anything, so the other replicas do not requeue their events.
+		is.beat()
+		is.pending.Add(1)
+		go is.heartbeat()
+		for i := 0; i < cfg.Workers; i++ {
+			is.pending.Add(1)
+			is.receivers.Add(1)
+			go is.work(is.receiveRedis)
+		}
+	}
+	return is
+}
+
+func (is *ingestionService) Ingest(ctx context.Context, clientIdentifier, queryText string, resultCount *int) error {
+	event := &models.SearchEvent{
+		TenantID:         tenant.FromContext(ctx),
+		ClientIdentifier: clientIdentifier,
+		QueryText:        queryText,
+		ResultCount:      resultCount,
+		ReceivedAt:       time.Now(),
+		RequestID:        correlation.RequestIDFromContext(ctx),
+	}
+	if traceParent, ok := correlation.TraceParentFromContext(ctx); ok {
+		event.TraceParent = traceParent.String()
+	}
+	if clientInfo, ok := ClientInfoFromContext(ctx); ok {
+		event.Client = &models.SearchEventClient{UserAgent: clientInfo.UserAgent, IP: clientInfo.IP}
+	}
+	if order, ok := KeystrokeOrderFromContext(ctx); ok {
+		event.ClientSequence = order.Sequence
+		event.ClientTimestamp = order.ClientTimestamp
+	}
+
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	if is.stopped {
+		return ErrIngestionStopped
+	}
+
+	switch is.cfg.Mode {
+	case config.IngestionModeChannel:
+		if len(is.events)+is.debouncingLen() >= is.cfg.QueueSize {
+			return ErrIngestionQueueFull
+		}
+		select {
+		case is.events <- event:
+			return nil
+		default:
+			return ErrIngestionQueueFull
+		}
+	case config.IngestionModeRedis:
+		pushed, err := is.queue.Push(ctx, event, is.cfg.QueueSize)
+		if err != nil {
+			return err
+		}
+		if !pushed {
+			return ErrIngestionQueueFull
+		}
+		return nil
+	default:
+		is.pending.Add(1)
+		go func() {
+			defer is.pending.Done()
+			is.process(event)
+		}()
+		return nil
+	}
+}
+
+// work runs a worker of the pool. It records the keystrokes of the events receive returns, and finalizes them once
+// they are due, soonest first. It stops taking events while QueueSize keystrokes are debouncing, unless the ingestion
+// is stopping, when it makes room by finalizing the soonest due keystroke right away. Once no worker takes events
+// anymore, the keystrokes still debouncing are finalized right away.
+func (is *ingestionService) work(receive func(wait time.Duration) (*cache.QueuedSearchEvent, bool)) {
+	defer is.pending.Done()
+	for {
+		if due, ok := is.popDebouncing(false); ok {
+			is.finalize(due)
+			continue
+		}
+
+		wait, reserved := is.reserve()
+		if !reserved {
+			select {
+			case <-time.After(wait):
+			case <-is.stop:
+				if due, ok := is.popDebouncing(true); ok {
+					is.finalize(due)
+				}
+			}
+			continue
+		}
+		queued, ok := receive(wait)
+		if ok && queued != nil {
+			is.record(queued)
+		}
+		is.debounceMu.Lock()
+		is.receiving--
+		is.debounceMu.Unlock()
+		if !ok {
+			break
+		}
+	}
+
+	is.receivers.Done()
+	is.receivers.Wait()
+	for {
+		due, ok := is.popDebouncing(true)
+		if !ok {
+			return
+		}
+		is.finalize(due)
+	}
+}
+
+// receiveChannel waits up to wait for an event of the in-memory queue, and reports false once it is closed.
+func (is *ingestionService) receiveChannel(wait time.Duration) (*cache.QueuedSearchEvent, bool) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case event, ok := <-is.events:
+		if !ok {
+			return nil, false
+		}
+		return &cache.QueuedSearchEvent{Event: event}, true
+	case <-timer.C:
+		return nil, true
+	}
+}
+
+// receiveRedis waits up to wait for an event of the Redis queue, and reports false once the ingestion is stopping.
+// Redis cannot block for less than a second, so a shorter wait is spent after checking the queue once.
+func (is *ingestionService) receiveRedis(wait time.Duration) (*cache.QueuedSearchEvent, bool) {
+	if is.isStopping() {
+		return nil, false
+	}
+
+	timeout := ingestionPollTimeout
+	if wait < ingestionPollTimeout {
+		timeout = 0
+	}
+	queued, err := is.queue.Pop(context.Background(), is.cfg.ReplicaID, timeout)
+	if err != nil {
+		is.logger.Error("Error popping search event", "error", err)
+		// Avoid spinning while Redis is unavailable.
+		wait = ingestionPollTimeout
+	}
+	if queued == nil && timeout == 0 {
+		select {
+		case <-is.stop:
+		case <-time.After(wait):
+		}
+	}
+	return queued, true
+}
+
+// record records the keystroke of a queued event and leaves it to debounce. Events whose keystroke is not pending are
+// done with right away.
+func (is *ingestionService) record(queued *cache.QueuedSearchEvent) {
+	event := queued.Event
+	ctx := eventContext(event)
+	keystroke, err := is.srv.RecordKeystroke(ctx, event.ClientIdentifier, event.QueryText)
+	if err != nil {
+		is.logger.ErrorContext(ctx, "Error logging queued search", "error", err, "clientIdentifier", event.ClientIdentifier)
+		is.ack(queued)
+		return
+	}
+	if event.ResultCount != nil {
+		if err := is.srv.ReportResultCount(ctx, event.ClientIdentifier, event.QueryText, *event.ResultCount); err != nil {
+			is.logger.ErrorContext(ctx, "Error reporting queued result count", "error", err, "clientIdentifier", event.ClientIdentifier)
+		}
+	}
+	if keystroke == nil {
+		is.ack(queued)
+		return
+	}
+
+	is.debounceMu.Lock()
+	heap.Push(&is.debouncing, debouncingKeystroke{keystroke: keystroke, queued: queued})
+	is.debounceMu.Unlock()
+}
+
+func (is *ingestionService) finalize(due debouncingKeystroke) {
+	is.srv.FinalizeKeystroke(due.keystroke)
+	is.ack(due.queued)
+}
+
+// ack removes a Redis event from the replica's processing list once its keystroke was logged, or dropped.
+func (is *ingestionService) ack(queued *cache.QueuedSearchEvent) {
+	if is.cfg.Mode != config.IngestionModeRedis {
+		return
+	}
+	if err := is.queue.Ack(context.Background(), is.cfg.ReplicaID, queued); err != nil {
+		is.logger.Error("Error acknowledging search event", "error", err, "clientIdentifier", queued.Event.ClientIdentifier)
+	}
+}
+
+// popDebouncing removes the soonest due keystroke if it is due, or whenever it is when early is set.
+func (is *ingestionService) popDebouncing(early bool) (debouncingKeystroke, bool) {
+	is.debounceMu.Lock()
+	defer is.debounceMu.Unlock()
+	if len(is.debouncing) == 0 {
+		return debouncingKeystroke{}, false
+	}
+	if !early && time.Now().Before(is.debouncing[0].keystroke.DueAt) {
+		return debouncingKeystroke{}, false
+	}
+	return heap.Pop(&is.debouncing).(debouncingKeystroke), true
+}
+
+// reserve returns how long until the soonest keystroke is due, and reserves room to debounce one more keystroke
+// unless the workers already have as many as they can.
+func (is *ingestionService) reserve() (time.Duration, bool) {
+	is.debounceMu.Lock()
+	defer is.debounceMu.Unlock()
+	wait := ingestionPollTimeout
+	if len(is.debouncing) > 0 {
+		wait = max(time.Until(is.debouncing[0].keystroke.DueAt), 0)
+	}
+	if len(is.debouncing)+is.receiving >= is.cfg.QueueSize {
+		return wait, false
+	}
+	is.receiving++
+	return wait, true
+}
+
+func (is *ingestionService) debouncingLen() int {
+	is.debounceMu.Lock()
+	defer is.debounceMu.Unlock()
+	return len(is.debouncing)
+}
+
+func (is *ingestionService) isStopping() bool {
+	select {
+	case <-is.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// heartbeat keeps the replica's heartbeat up until the ingestion stops, and requeues the events of stopped replicas.
+func (is *ingestionService) heartbeat() {
+	defer is.pending.Done()
+	ticker := time.NewTicker(ingestionHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-is.stop:
+			return
+		case <-ticker.C:
+			is.beat()
+		}
+	}
+}
+
+func (is *ingestionService) beat() {
+	ctx := context.Background()
+	if err := is.queue.Heartbeat(ctx, is.cfg.ReplicaID, ingestionHeartbeatMisses*ingestionHeartbeatInterval); err != nil {
+		is.logger.Error("Error sending ingestion heartbeat", "error", err)
+		return
+	}
+	requeued, err := is.queue.RequeueAbandoned(ctx)
+	if err != nil {
+		is.logger.Error("Error requeuing search events of stopped replicas", "error", err)
+	} else if requeued > 0 {
+		is.logger.Warn("Requeued search events of stopped replicas", "events", requeued)
+	}
+}
+
+// process logs a keystroke like the API did before ingestion was queued.
+func (is *ingestionService) process(event *models.SearchEvent) {
+	ctx := eventContext(event)
+	if err := is.srv.LogSearch(ctx, event.ClientIdentifier, event.QueryText); err != nil {
+		is.logger.ErrorContext(ctx, "Error logging queued search", "error", err, "clientIdentifier", event.ClientIdentifier)
+		return
+	}
+	if event.ResultCount != nil {
+		if err := is.srv.ReportResultCount(ctx, event.ClientIdentifier, event.QueryText, *event.ResultCount); err != nil {
+			is.logger.ErrorContext(ctx, "Error reporting queued result count", "error", err, "clientIdentifier", event.ClientIdentifier)
+		}
+	}
+}
+
+// eventContext rebuilds the request context of a queued keystroke.
+func eventContext(event *models.SearchEvent) context.Context {
+	ctx := tenant.WithTenant(context.Background(), event.TenantID)
+	if event.Client != nil {
+		ctx = WithClientInfo(ctx, ClientInfo{UserAgent: event.Client.UserAgent, IP: event.Client.IP})
+	}
+	ctx = WithKeystrokeOrder(ctx, KeystrokeOrder{
+		Sequence:        event.ClientSequence,
+		ClientTimestamp: event.ClientTimestamp,
+		ReceivedAt:      event.ReceivedAt,
+	})
+	if event.RequestID != "" {
+		ctx = correlation.WithRequestID(ctx, event.RequestID)
+	}
+	if traceParent, err := correlation.ParseTraceParent(event.TraceParent); err == nil {
+		ctx = correlation.WithTraceParent(ctx, traceParent)
+	}
+	return ctx
+}
+
+func (is *ingestionService) Drain(ctx context.Context) error {
+	is.mu.Lock()
+	if !is.stopped {
+		is.stopped = true
+		close(is.stop)
+		if is.events != nil {
+			close(is.events)
+		}
+	}
+	is.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		is.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/correlation"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/storage_util"
+	"search-logger/tenant"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type loggedSearch struct {
+	tenantID    string
+	clientInfo  ClientInfo
+	order       KeystrokeOrder
+	requestID   string
+	traceParent correlation.TraceParent
+	queryText   string
+	resultCount *int
+}
+
+// recordingSearchLogService records keystrokes instead of caching them, optionally blocking until release is closed.
+// Keystrokes it records for workers are due after delay.
+type recordingSearchLogService struct {
+	SearchLogService
+	release chan struct{}
+	delay   time.Duration
+
+	mu        sync.Mutex
+	blocked   int
+	searches  []loggedSearch
+	finalized []string
+}
+
+func (s *recordingSearchLogService) LogSearch(ctx context.Context, _ string, queryText string) error {
+	s.record(ctx, queryText)
+	return nil
+}
+
+func (s *recordingSearchLogService) RecordKeystroke(ctx context.Context, clientIdentifier, queryText string) (*PendingKeystroke, error) {
+	s.record(ctx, queryText)
+	return &PendingKeystroke{ClientIdentifier: clientIdentifier, QueryText: queryText, DueAt: time.Now().Add(s.delay)}, nil
+}
+
+func (s *recordingSearchLogService) FinalizeKeystroke(keystroke *PendingKeystroke) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finalized = append(s.finalized, keystroke.QueryText)
+}
+
+func (s *recordingSearchLogService) record(ctx context.Context, queryText string) {
+	if s.release != nil {
+		s.mu.Lock()
+		s.blocked++
+		s.mu.Unlock()
+		<-s.release
+	}
+	clientInfo, _ := ClientInfoFromContext(ctx)
+	order, _ := KeystrokeOrderFromContext(ctx)
+	traceParent, _ := correlation.TraceParentFromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searches = append(s.searches, loggedSearch{
+		tenantID:    tenant.FromContext(ctx),
+		clientInfo:  clientInfo,
+		order:       order,
+		requestID:   correlation.RequestIDFromContext(ctx),
+		traceParent: traceParent,
+		queryText:   queryText,
+	})
+}
+
+func (s *recordingSearchLogService) ReportResultCount(_ context.Context, _ string, queryText string, resultCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.searches {
+		if s.searches[i].queryText == queryText {
+			s.searches[i].resultCount = &resultCount
+		}
+	}
+	return nil
+}
+
+func (s *recordingSearchLogService) blockedSearches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocked
+}
+
+func (s *recordingSearchLogService) logged() []loggedSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]loggedSearch(nil), s.searches...)
+}
+
+func (s *recordingSearchLogService) finalizedQueries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.finalized...)
+}
+
+func TestIngestionService_Ingest(t *testing.T) {
+	for _, mode := range []string{config.IngestionModeDirect, config.IngestionModeChannel, config.IngestionModeRedis} {
+		t.Run("Keystrokes keep their request context in "+mode+" mode", func(t *testing.T) {
+			// ARRANGE
+			srv := &recordingSearchLogService{}
+			queue := cache.NewSearchEventQueueCacheRepository(storage_util.InitRedis())
+			ingestion := NewIngestionService(srv, queue, IngestionConfig{Mode: mode, QueueSize: 10, Workers: 2}, slog.Default())
+			sequence, resultCount := int64(7), 0
+			ctx := tenant.WithTenant(context.Background(), "acme")
+			ctx = WithClientInfo(ctx, ClientInfo{UserAgent: "Mozilla/5.0", IP: "10.0.0.1"})
+			ctx = WithKeystrokeOrder(ctx, KeystrokeOrder{Sequence: &sequence})
+			ctx = correlation.FromIncoming(ctx, "request-"+mode, "")
+			traceParent, _ := correlation.TraceParentFromContext(ctx)
+
+			// ACT
+			assert.NoError(t, ingestion.Ingest(ctx, "ip:10.0.0.1", "laptop", &resultCount))
+			assert.Eventually(t, func() bool { return len(srv.logged()) == 1 }, 3*time.Second, 10*time.Millisecond)
+			drainCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NoError(t, ingestion.Drain(drainCtx))
+
+			// ASSERT
+			logged := srv.logged()
+			if assert.Len(t, logged, 1) {
+				assert.Equal(t, "acme", logged[0].tenantID)
+				assert.Equal(t, ClientInfo{UserAgent: "Mozilla/5.0", IP: "10.0.0.1"}, logged[0].clientInfo)
+				assert.Equal(t, &sequence, logged[0].order.Sequence)
+				assert.False(t, logged[0].order.ReceivedAt.IsZero())
+				assert.Equal(t, "request-"+mode, logged[0].requestID)
+				assert.Equal(t, traceParent.TraceID, logged[0].traceParent.TraceID)
+				assert.Equal(t, traceParent.SpanID, logged[0].traceParent.SpanID)
+				assert.Equal(t, "laptop", logged[0].queryText)
+				assert.Equal(t, &resultCount, logged[0].resultCount)
+			}
+			assert.ErrorIs(t, ingestion.Ingest(ctx, "ip:10.0.0.1", "laptop", nil), ErrIngestionStopped)
+		})
+	}
+}
+
+func TestIngestionService_Backpressure(t *testing.T) {
+	for _, mode := range []string{config.IngestionModeChannel, config.IngestionModeRedis} {
+		t.Run("Full queue refuses keystrokes in "+mode+" mode, and draining logs the queued ones", func(t *testing.T) {
+			// ARRANGE
+			srv := &recordingSearchLogService{release: make(chan struct{})}
+			queue := cache.NewSearchEventQueueCacheRepository(storage_util.InitRedis())
+			ingestion := NewIngestionService(srv, queue, IngestionConfig{Mode: mode, QueueSize: 2, Workers: 1}, slog.Default())
+			ctx := context.Background()
+
+			// ACT
+			// The only worker takes the first keystroke and blocks on it, so the next two fill the queue.
+			assert.NoError(t, ingestion.Ingest(ctx, "client", "query-0", nil))
+			assert.Eventually(t, func() bool { return srv.blockedSearches() == 1 }, 3*time.Second, 10*time.Millisecond)
+			var refused int
+			for i := 1; i <= 4; i++ {
+				err := ingestion.Ingest(ctx, "client", "query-"+strconv.Itoa(i), nil)
+				if err != nil {
+					assert.ErrorIs(t, err, ErrIngestionQueueFull)
+					refused++
+				}
+			}
+			close(srv.release)
+			drainCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			assert.NoError(t, ingestion.Drain(drainCtx))
+
+			// ASSERT
+			assert.Equal(t, 2, refused)
+			if mode == config.IngestionModeChannel {
+				assert.Len(t, srv.logged(), 3)
+			}
+		})
+	}
+
+	t.Run("Drain gives up at the deadline", func(t *testing.T) {
+		srv := &recordingSearchLogService{release: make(chan struct{})}
+		defer close(srv.release)
+		ingestion := NewIngestionService(srv, nil, IngestionConfig{Mode: config.IngestionModeChannel, QueueSize: 2, Workers: 1}, slog.Default())
+		assert.NoError(t, ingestion.Ingest(context.Background(), "client", "laptop", nil))
+
+		drainCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, ingestion.Drain(drainCtx), context.DeadlineExceeded)
+	})
+}
+
+func TestIngestionService_Debounce(t *testing.T) {
+	for _, mode := range []string{config.IngestionModeChannel, config.IngestionModeRedis} {
+		t.Run("Workers finalize keystrokes once they are due in "+mode+" mode", func(t *testing.T) {
+			srv := &recordingSearchLogService{delay: 50 * time.Millisecond}
+			queue := cache.NewSearchEventQueueCacheRepository(storage_util.InitRedis())
+			ingestion := NewIngestionService(srv, queue, IngestionConfig{Mode: mode, QueueSize: 10, Workers: 1}, slog.Default())
+			defer ingestion.Drain(context.Background())
+
+			for _, queryText := range []string{"lap", "laptop"} {
+				assert.NoError(t, ingestion.Ingest(context.Background(), "client", queryText, nil))
+			}
+
+			assert.Eventually(t, func() bool { return len(srv.finalizedQueries()) == 2 }, 3*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []string{"lap", "laptop"}, srv.finalizedQueries())
+		})
+
+		t.Run("Keystrokes being debounced count against the queue size in "+mode+" mode", func(t *testing.T) {
+			// ARRANGE
+			srv := &recordingSearchLogService{delay: time.Hour}
+			redisClient := storage_util.InitRedis()
+			queue := cache.NewSearchEventQueueCacheRepository(redisClient)
+			ingestion := NewIngestionService(srv, queue, IngestionConfig{Mode: mode, QueueSize: 2, Workers: 2, ReplicaID: "replica-1"}, slog.Default())
+			ctx := context.Background()
+
+			// ACT
+			for i := 0; i < 2; i++ {
+				assert.NoError(t, ingestion.Ingest(ctx, "client-"+strconv.Itoa(i), "laptop", nil))
+			}
+			assert.Eventually(t, func() bool { return len(srv.logged()) == 2 }, 3*time.Second, 10*time.Millisecond)
+			var refused int
+			for i := 2; i < 6; i++ {
+				if err := ingestion.Ingest(ctx, "client-"+strconv.Itoa(i), "laptop", nil); err != nil {
+					assert.ErrorIs(t, err, ErrIngestionQueueFull)
+					refused++
+				}
+			}
+			processing, err := redisClient.LLen(ctx, "search_events:processing:replica-1").Result()
+			assert.NoError(t, err)
+			drainCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			assert.NoError(t, ingestion.Drain(drainCtx))
+
+			// ASSERT
+			if mode == config.IngestionModeChannel {
+				assert.Equal(t, 4, refused)
+				assert.Len(t, srv.finalizedQueries(), 2, "debouncing keystrokes are finalized on drain")
+				return
+			}
+			// Redis holds two more keystrokes while the workers hold off.
+			assert.Equal(t, 2, refused)
+			assert.Equal(t, int64(2), processing, "keystrokes stay in the processing list while they debounce")
+			assert.Len(t, srv.finalizedQueries(), 2)
+			processing, err = redisClient.LLen(ctx, "search_events:processing:replica-1").Result()
+			assert.NoError(t, err)
+			assert.Zero(t, processing)
+		})
+	}
+
+	t.Run("Events of a stopped replica are requeued", func(t *testing.T) {
+		// ARRANGE
+		queue := cache.NewSearchEventQueueCacheRepository(storage_util.InitRedis())
+		ctx := context.Background()
+		pushed, err := queue.Push(ctx, &models.SearchEvent{TenantID: "acme", ClientIdentifier: "client", QueryText: "laptop"}, 10)
+		assert.NoError(t, err)
+		assert.True(t, pushed)
+		_, err = queue.Pop(ctx, "crashed", 0)
+		assert.NoError(t, err)
+
+		// ACT
+		srv := &recordingSearchLogService{}
+		ingestion := NewIngestionService(srv, queue, IngestionConfig{Mode: config.IngestionModeRedis, QueueSize: 10, Workers: 1}, slog.Default())
+		defer ingestion.Drain(ctx)
+
+		// ASSERT
+		assert.Eventually(t, func() bool { return len(srv.finalizedQueries()) == 1 }, 3*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestIngestionService_LogsFinalQuery(t *testing.T) {
+	for _, mode := range []string{config.IngestionModeChannel, config.IngestionModeRedis} {
+		t.Run("Only the final keystroke is counted in "+mode+" mode", func(t *testing.T) {
+			// ARRANGE
+			srv := NewSearchLogService(setupTestDatabase(t), setupTestRedis(t), slog.Default())
+			queue := cache.NewSearchEventQueueCacheRepository(storage_util.InitRedis())
+			ingestion := NewIngestionService(srv, queue, IngestionConfig{Mode: mode, QueueSize: 10, Workers: 2}, slog.Default())
+			ctx := context.Background()
+			for i, queryText := range []string{"lap", "lapt", "laptop"} {
+				sequence := int64(i)
+				assert.NoError(t, ingestion.Ingest(WithKeystrokeOrder(ctx, KeystrokeOrder{Sequence: &sequence}), "typing-client", queryText, nil))
+			}
+			assert.Eventually(t, func() bool {
+				queued, err := queue.Len(ctx)
+				return err == nil && queued == 0
+			}, 3*time.Second, 10*time.Millisecond)
+
+			// ACT
+			drainCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			assert.NoError(t, ingestion.Drain(drainCtx))
+
+			// ASSERT
+			for queryText, expected := range map[string]int{"lap": 0, "lapt": 0, "laptop": 1} {
+				count, err := srv.GetSearchLogCountByQueryText(ctx, queryText)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, count, queryText)
+			}
+		})
+	}
+}
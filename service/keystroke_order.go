@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// KeystrokeOrder is how the client ordered a keystroke, when it says so. Requests handled by different replicas can
+// reach the cache in a different order than they were typed, so these take precedence over server time.
+type KeystrokeOrder struct {
+	// Sequence must increase with every keystroke of a client identifier.
+	Sequence        *int64
+	ClientTimestamp *time.Time
+	// ReceivedAt is when the server accepted a keystroke logged later, e.g. from a queue. Zero means now.
+	ReceivedAt time.Time
+}
+
+type keystrokeOrderContextKey struct{}
+
+func WithKeystrokeOrder(ctx context.Context, order KeystrokeOrder) context.Context {
+	return context.WithValue(ctx, keystrokeOrderContextKey{}, order)
+}
+
+// KeystrokeOrderFromContext returns the keystroke order attached to ctx, and false when there is none.
+func KeystrokeOrderFromContext(ctx context.Context) (KeystrokeOrder, bool) {
+	order, ok := ctx.Value(keystrokeOrderContextKey{}).(KeystrokeOrder)
+	return order, ok
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+)
+
+type QuarantineService interface {
+	// ListPending returns unreleased searches, newest first, optionally restricted to one client.
+	ListPending(ctx context.Context, clientIdentifier string, limit int) ([]models.QuarantinedSearch, error)
+	// Release counts a quarantined search in search_logs through SearchLogService.LogReleasedSearch. It returns false
+	// when the search does not exist or was already released.
+	Release(ctx context.Context, id string) (bool, error)
+	// ReleaseClient releases every pending search of a client, oldest first, and clears the client's bot flag so its
+	// next searches are counted. It returns how many searches were released.
+	ReleaseClient(ctx context.Context, clientIdentifier string) (int, error)
+}
+
+type quarantineService struct {
+	repo       database.QuarantineRepository
+	activity   cache.ClientActivityCacheRepository
+	searchLogs SearchLogService
+}
+
+func NewQuarantineService(repo database.QuarantineRepository, activity cache.ClientActivityCacheRepository, searchLogs SearchLogService) QuarantineService {
+	return &quarantineService{
+		repo:       repo,
+		activity:   activity,
+		searchLogs: searchLogs,
+	}
+}
+
+func (qs quarantineService) ListPending(ctx context.Context, clientIdentifier string, limit int) ([]models.QuarantinedSearch, error) {
+	return qs.repo.ListPending(ctx, clientIdentifier, limit)
+}
+
+func (qs quarantineService) Release(ctx context.Context, id string) (bool, error) {
+	search, err := qs.repo.Release(ctx, id)
+	if err != nil || search == nil {
+		return false, err
+	}
+	if err := qs.searchLogs.LogReleasedSearch(ctx, search); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (qs quarantineService) ReleaseClient(ctx context.Context, clientIdentifier string) (int, error) {
+	// Unflagged first, so the client is not flagged again while its searches are being released.
+	if err := qs.activity.Unflag(ctx, clientIdentifier); err != nil {
+		return 0, err
+	}
+
+	searches, err := qs.repo.ReleaseClient(ctx, clientIdentifier)
+	released := 0
+	for idx := range searches {
+		// The searches returned are marked released, so they are counted even if releasing the others failed.
+		if logErr := qs.searchLogs.LogReleasedSearch(ctx, &searches[idx]); logErr != nil {
+			err = errors.Join(err, logErr)
+			continue
+		}
+		released++
+	}
+	return released, err
+}
+
+type releasedSearchContextKey struct{}
+
+// withReleasedSearch marks ctx as counting a search released from quarantine, which the bot filter lets through.
+func withReleasedSearch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, releasedSearchContextKey{}, true)
+}
+
+func isReleasedSearch(ctx context.Context) bool {
+	released, _ := ctx.Value(releasedSearchContextKey{}).(bool)
+	return released
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/storage_util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantineService_Release(t *testing.T) {
+	db := setupTestDB(t)
+	redisClient := storage_util.InitRedis()
+	searchLogs := database.NewSearchLogDatabaseRepository(db)
+	quarantine := database.NewQuarantineDatabaseRepository(db)
+	activity := cache.NewClientActivityCacheRepository(redisClient)
+	botFilter := NewBotFilterService(activity, quarantine, testBotFilterConfig(config.BotFilterModeQuarantine), slog.Default())
+	blocklist := NewBlocklistService(database.NewBlocklistDatabaseRepository(db), BlocklistConfig{RefreshInterval: time.Minute}, slog.Default())
+	listener := &recordingPersistedListener{}
+	searchLogSrv := NewSearchLogService(searchLogs, cache.NewLatestClientQueryCacheRepository(redisClient), slog.Default(),
+		WithPersistFilters(blocklist, botFilter), WithPersistedListeners(listener))
+	quarantineSrv := NewQuarantineService(quarantine, activity, searchLogSrv)
+
+	t.Run("Released searches are counted and reach the listeners", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		assert.NoError(t, activity.Flag(ctx, "flagged-client", BotReasonVelocity, time.Hour))
+		search := models.NewQuarantinedSearch("flagged-client", "released query", BotReasonVelocity, "")
+		assert.NoError(t, quarantine.Create(ctx, search))
+
+		// ACT
+		released, err := quarantineSrv.Release(ctx, search.ID)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.True(t, released)
+		searchLog, err := searchLogs.GetByQueryText(ctx, "released query")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, searchLog.Count)
+		listener.mu.Lock()
+		assert.Len(t, listener.searchLogs, 1)
+		listener.mu.Unlock()
+
+		released, err = quarantineSrv.Release(ctx, search.ID)
+		assert.NoError(t, err)
+		assert.False(t, released)
+	})
+
+	t.Run("Released searches go through the other filters", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		search := models.NewQuarantinedSearch("spamming-client", "casino", BotReasonVelocity, "")
+		assert.NoError(t, quarantine.Create(ctx, search))
+		_, err := blocklist.AddRule(ctx, models.BlocklistRuleExact, "casino")
+		assert.NoError(t, err)
+
+		// ACT
+		released, err := quarantineSrv.Release(ctx, search.ID)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.True(t, released)
+		searchLog, err := searchLogs.GetByQueryText(ctx, "casino")
+		assert.NoError(t, err)
+		assert.Nil(t, searchLog, "the query was blocked since it was quarantined")
+	})
+
+	t.Run("Releasing a client clears its flag", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		assert.NoError(t, activity.Flag(ctx, "reviewed-client", BotReasonDistinctQueryBurst, time.Hour))
+		for _, queryText := range []string{"first query", "second query"} {
+			assert.NoError(t, quarantine.Create(ctx, models.NewQuarantinedSearch("reviewed-client", queryText, BotReasonDistinctQueryBurst, "")))
+		}
+
+		// ACT
+		released, err := quarantineSrv.ReleaseClient(ctx, "reviewed-client")
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, 2, released)
+		reason, err := activity.GetFlag(ctx, "reviewed-client")
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+		for _, queryText := range []string{"first query", "second query"} {
+			searchLog, err := searchLogs.GetByQueryText(ctx, queryText)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, searchLog.Count)
+		}
+		pending, err := quarantine.ListPending(ctx, "reviewed-client", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"math"
+	"search-logger/repository/cache"
+	"sync"
+	"time"
+)
+
+const (
+	// maxInMemoryBuckets bounds the fallback limiter's memory. Once reached, the bucket of the client seen least
+	// recently is dropped.
+	maxInMemoryBuckets = 10000
+	// rateLimitCircuitOpenFor is how long the limiter stops calling Redis after a call failed, so that an outage does
+	// not add the Redis timeout to every request.
+	rateLimitCircuitOpenFor = 5 * time.Second
+	// rateLimitWarnInterval is how often an outage of Redis is logged while it lasts.
+	rateLimitWarnInterval = time.Minute
+)
+
+type RateLimitService interface {
+	// Allow takes a token for the client. When the client is limited it returns false and how long it should wait.
+	Allow(ctx context.Context, clientIdentifier string) (bool, time.Duration)
+}
+
+type rateLimitService struct {
+	cache           cache.RateLimitCacheRepository
+	fallback        *inMemoryTokenBuckets
+	circuit         *rateLimitCircuit
+	burst           int
+	refillPerSecond float64
+	logger          *slog.Logger
+	now             func() time.Time
+}
+
+// rateLimitCircuit tracks the failures of Redis.
+type rateLimitCircuit struct {
+	mu        sync.Mutex
+	openUntil time.Time
+	warnedAt  time.Time
+	failures  int
+}
+
+// NewRateLimitService returns a token bucket limiter backed by Redis. When Redis cannot be reached, each replica
+// falls back to limiting with its own in-memory buckets rather than failing open or rejecting every request, and only
+// tries Redis again after rateLimitCircuitOpenFor.
+func NewRateLimitService(cache cache.RateLimitCacheRepository, burst int, refillPerSecond float64, logger *slog.Logger) RateLimitService {
+	return &rateLimitService{
+		cache:           cache,
+		fallback:        newInMemoryTokenBuckets(),
+		circuit:         &rateLimitCircuit{},
+		burst:           burst,
+		refillPerSecond: refillPerSecond,
+		logger:          logger,
+		now:             time.Now,
+	}
+}
+
+func (rls rateLimitService) Allow(ctx context.Context, clientIdentifier string) (bool, time.Duration) {
+	now := rls.now()
+	rls.circuit.mu.Lock()
+	open := now.Before(rls.circuit.openUntil)
+	rls.circuit.mu.Unlock()
+	if open {
+		return rls.fallback.take(clientIdentifier, rls.burst, rls.refillPerSecond, now)
+	}
+
+	allowed, retryAfter, err := rls.cache.Take(ctx, clientIdentifier, rls.burst, rls.refillPerSecond)
+	if err == nil {
+		rls.closeCircuit(ctx)
+		return allowed, retryAfter
+	}
+
+	rls.openCircuit(ctx, err, now)
+	return rls.fallback.take(clientIdentifier, rls.burst, rls.refillPerSecond, now)
+}
+
+// openCircuit stops calling Redis for rateLimitCircuitOpenFor, logging the failure unless one was logged within
+// rateLimitWarnInterval.
+func (rls rateLimitService) openCircuit(ctx context.Context, err error, now time.Time) {
+	rls.circuit.mu.Lock()
+	rls.circuit.openUntil = now.Add(rateLimitCircuitOpenFor)
+	rls.circuit.failures++
+	failures := rls.circuit.failures
+	warn := now.Sub(rls.circuit.warnedAt) >= rateLimitWarnInterval
+	if warn {
+		rls.circuit.warnedAt = now
+		rls.circuit.failures = 0
+	}
+	rls.circuit.mu.Unlock()
+
+	if warn {
+		rls.logger.WarnContext(ctx, "Rate limiter cache unavailable, using in-memory fallback", "error", err, "failures", failures, "retryIn", rateLimitCircuitOpenFor)
+	}
+}
+
+func (rls rateLimitService) closeCircuit(ctx context.Context) {
+	rls.circuit.mu.Lock()
+	recovered := !rls.circuit.warnedAt.IsZero()
+	rls.circuit.openUntil = time.Time{}
+	rls.circuit.warnedAt = time.Time{}
+	rls.circuit.failures = 0
+	rls.circuit.mu.Unlock()
+
+	if recovered {
+		rls.logger.InfoContext(ctx, "Rate limiter cache available again")
+	}
+}
+
+type tokenBucket struct {
+	key      string
+	tokens   float64
+	lastFill time.Time
+}
+
+type inMemoryTokenBuckets struct {
+	mu sync.Mutex
+	// buckets holds the elements of recent, whose front is the bucket of the client seen most recently.
+	buckets map[string]*list.Element
+	recent  *list.List
+}
+
+func newInMemoryTokenBuckets() *inMemoryTokenBuckets {
+	return &inMemoryTokenBuckets{buckets: make(map[string]*list.Element), recent: list.New()}
+}
+
+func (b *inMemoryTokenBuckets) take(key string, burst int, refillPerSecond float64, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var bucket *tokenBucket
+	if element, ok := b.buckets[key]; ok {
+		b.recent.MoveToFront(element)
+		bucket = element.Value.(*tokenBucket)
+	} else {
+		bucket = &tokenBucket{key: key, tokens: float64(burst), lastFill: now}
+		b.buckets[key] = b.recent.PushFront(bucket)
+		for b.recent.Len() > maxInMemoryBuckets {
+			oldest := b.recent.Remove(b.recent.Back()).(*tokenBucket)
+			delete(b.buckets, oldest.key)
+		}
+	}
+
+	bucket.refill(burst, refillPerSecond, now)
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := math.Ceil((1 - bucket.tokens) * 1000 / refillPerSecond)
+	return false, time.Duration(wait) * time.Millisecond
+}
+
+func (t *tokenBucket) refill(burst int, refillPerSecond float64, now time.Time) {
+	elapsed := now.Sub(t.lastFill).Seconds()
+	if elapsed > 0 {
+		t.tokens = math.Min(float64(burst), t.tokens+elapsed*refillPerSecond)
+		t.lastFill = now
+	}
+}
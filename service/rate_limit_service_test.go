@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"search-logger/repository/cache"
+	"search-logger/storage_util"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingRateLimitCache counts the calls made to a Redis that is down.
+type failingRateLimitCache struct {
+	cache.RateLimitCacheRepository
+	calls *int
+}
+
+func (c failingRateLimitCache) Take(context.Context, string, int, float64) (bool, time.Duration, error) {
+	*c.calls++
+	return false, 0, errors.New("redis is down")
+}
+
+func TestRateLimitService_Allow(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Limits a client once its burst is used", func(t *testing.T) {
+		// ARRANGE
+		limiter := NewRateLimitService(cache.NewRateLimitCacheRepository(storage_util.InitRedis()), 2, 1, slog.Default())
+
+		// ACT
+		allowed1, _ := limiter.Allow(ctx, "scraper")
+		allowed2, _ := limiter.Allow(ctx, "scraper")
+		allowed3, retryAfter := limiter.Allow(ctx, "scraper")
+		otherAllowed, _ := limiter.Allow(ctx, "human")
+
+		// ASSERT
+		assert.True(t, allowed1)
+		assert.True(t, allowed2)
+		assert.False(t, allowed3)
+		assert.Greater(t, retryAfter, time.Duration(0))
+		assert.True(t, otherAllowed)
+	})
+
+	t.Run("Falls back to in-memory buckets when Redis is unavailable", func(t *testing.T) {
+		// ARRANGE
+		unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+		limiter := NewRateLimitService(cache.NewRateLimitCacheRepository(unreachable), 2, 1, slog.Default())
+
+		// ACT
+		allowed1, _ := limiter.Allow(ctx, "scraper")
+		allowed2, _ := limiter.Allow(ctx, "scraper")
+		allowed3, retryAfter := limiter.Allow(ctx, "scraper")
+
+		// ASSERT
+		assert.True(t, allowed1)
+		assert.True(t, allowed2)
+		assert.False(t, allowed3)
+		assert.Greater(t, retryAfter, time.Duration(0))
+	})
+
+	t.Run("Skips Redis for a while after it failed", func(t *testing.T) {
+		// ARRANGE
+		calls := 0
+		limiter := NewRateLimitService(failingRateLimitCache{calls: &calls}, 20, 1, slog.Default())
+		now := time.Now()
+		limiter.(*rateLimitService).now = func() time.Time { return now }
+
+		// ACT
+		for i := 0; i < 5; i++ {
+			allowed, _ := limiter.Allow(ctx, "human")
+			assert.True(t, allowed)
+		}
+		callsWhileOpen := calls
+		now = now.Add(rateLimitCircuitOpenFor)
+		limiter.Allow(ctx, "human")
+
+		// ASSERT
+		assert.Equal(t, 1, callsWhileOpen)
+		assert.Equal(t, 2, calls)
+	})
+}
+
+func TestInMemoryTokenBuckets_Take(t *testing.T) {
+	buckets := newInMemoryTokenBuckets()
+	now := time.Now()
+
+	allowed, _ := buckets.take("client", 1, 2, now)
+	assert.True(t, allowed)
+	allowed, retryAfter := buckets.take("client", 1, 2, now)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	allowed, _ = buckets.take("client", 1, 2, now.Add(500*time.Millisecond))
+	assert.True(t, allowed)
+}
+
+func TestInMemoryTokenBuckets_Bounded(t *testing.T) {
+	// ARRANGE
+	buckets := newInMemoryTokenBuckets()
+	now := time.Now()
+	buckets.take("limited", 1, 1, now)
+
+	// ACT
+	for i := 0; i < maxInMemoryBuckets; i++ {
+		buckets.take("client-"+strconv.Itoa(i), 2, 1, now)
+	}
+
+	// ASSERT
+	assert.Len(t, buckets.buckets, maxInMemoryBuckets)
+	assert.NotContains(t, buckets.buckets, "limited")
+	assert.Contains(t, buckets.buckets, "client-0")
+}
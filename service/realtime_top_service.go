@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/tenant"
+	"sync"
+	"time"
+)
+
+var ErrInvalidRealtimeWindow = errors.New("window must be positive and within the real-time retention")
+
+// RealtimeTopConfig tunes the sketches of recently finalized queries. Each replica keeps one sketch of Capacity
+// queries per tenant and Bucket, drops them after Retention, and shares them every PublishInterval.
+type RealtimeTopConfig struct {
+	Capacity        int
+	Bucket          time.Duration
+	Retention       time.Duration
+	PublishInterval time.Duration
+	ReplicaID       string
+}
+
+// RealtimeTopConfigFromEnv builds a RealtimeTopConfig from REPLICA_ID and the REALTIME_TOP_* environment variables.
+func RealtimeTopConfigFromEnv() RealtimeTopConfig {
+	return RealtimeTopConfig{
+		Capacity:        config.GetRealtimeTopCapacity(),
+		Bucket:          config.GetRealtimeTopBucket(),
+		Retention:       config.GetRealtimeTopRetention(),
+		PublishInterval: config.GetRealtimeTopPublishInterval(),
+		ReplicaID:       config.GetReplicaID(),
+	}
+}
+
+// RealtimeTopService estimates the most finalized queries over the last minutes, in bounded memory, with Space-Saving
+// sketches that every replica feeds with the queries it counts and shares through Redis.
+type RealtimeTopService interface {
+	SearchLogPersistedListener
+	// TopQueries ranks the queries of the tenant on ctx finalized by any replica over the last window, rounded up to
+	// whole buckets, leaving out those the blocklist blocks now. The sketches of the other replicas are as recent as
+	// their last publish.
+	TopQueries(ctx context.Context, window time.Duration, limit int) (*models.RealtimeTopQueries, error)
+	// Publish shares the sketches that changed since the last publish with the other replicas.
+	Publish(ctx context.Context) error
+	// Stop stops publishing periodically, then publishes one last time.
+	Stop(ctx context.Context) error
+}
+
+type realtimeTopService struct {
+	sketches  cache.RealtimeSketchCacheRepository
+	blocklist BlocklistService
+	cfg       RealtimeTopConfig
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu *sync.Mutex
+	// buckets holds the sketches of each tenant by bucket start in Unix seconds.
+	buckets  map[string]map[int64]*realtimeBucket
+	stop     chan struct{}
+	stopOnce *sync.Once
+	stopped  chan struct{}
+}
+
+type realtimeBucket struct {
+	sketch *spaceSaving
+	// dirty is set when the sketch changed since it was last published.
+	dirty bool
+}
+
+// NewRealtimeTopService publishes the sketches of this replica every cfg.PublishInterval, unless it is zero. Queries
+// are not checked against a blocklist when it is nil.
+func NewRealtimeTopService(sketches cache.RealtimeSketchCacheRepository, blocklist BlocklistService, cfg RealtimeTopConfig, logger *slog.Logger) RealtimeTopService {
+	rts := &realtimeTopService{
+		sketches:  sketches,
+		blocklist: blocklist,
+		cfg:       cfg,
+		logger:    logger,
+		now:       time.Now,
+		mu:        &sync.Mutex{},
+		buckets:   make(map[string]map[int64]*realtimeBucket),
+		stop:      make(chan struct{}),
+		stopOnce:  &sync.Once{},
+		stopped:   make(chan struct{}),
+	}
+	if cfg.PublishInterval > 0 {
+		go rts.publishPeriodically()
+	} else {
+		close(rts.stopped)
+	}
+	return rts
+}
+
+func (rts *realtimeTopService) OnSearchLogPersisted(ctx context.Context, _ string, searchLog *models.SearchLog) {
+	if searchLog == nil {
+		return
+	}
+	tenantID := tenant.FromContext(ctx)
+	bucketStart := rts.now().Truncate(rts.cfg.Bucket)
+
+	rts.mu.Lock()
+	defer rts.mu.Unlock()
+	buckets := rts.buckets[tenantID]
+	if buckets == nil {
+		buckets = make(map[int64]*realtimeBucket)
+		rts.buckets[tenantID] = buckets
+	}
+	bucket := buckets[bucketStart.Unix()]
+	if bucket == nil {
+		bucket = &realtimeBucket{sketch: newSpaceSaving(rts.cfg.Capacity)}
+		buckets[bucketStart.Unix()] = bucket
+		rts.dropExpiredBuckets(bucketStart)
+	}
+	bucket.sketch.add(searchLog.QueryText)
+	bucket.dirty = true
+}
+
+// dropExpiredBuckets forgets the sketches that no window can reach anymore. rts.mu must be held.
+func (rts *realtimeTopService) dropExpiredBuckets(now time.Time) {
+	oldest := now.Add(-rts.cfg.Retention).Truncate(rts.cfg.Bucket).Unix()
+	for tenantID, buckets := range rts.buckets {
+		for bucketStart := range buckets {
+			if bucketStart < oldest {
+				delete(buckets, bucketStart)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(rts.buckets, tenantID)
+		}
+	}
+}
+
+func (rts *realtimeTopService) TopQueries(ctx context.Context, window time.Duration, limit int) (*models.RealtimeTopQueries, error) {
+	if window <= 0 || window > rts.cfg.Retention {
+		return nil, ErrInvalidRealtimeWindow
+	}
+	tenantID := tenant.FromContext(ctx)
+	to := rts.now()
+	from := to.Add(-window).Truncate(rts.cfg.Bucket)
+	var bucketStarts []time.Time
+	for bucketStart := from; !bucketStart.After(to); bucketStart = bucketStart.Add(rts.cfg.Bucket) {
+		bucketStarts = append(bucketStarts, bucketStart)
+	}
+
+	var sketches []*spaceSaving
+	replicas := map[string]bool{rts.cfg.ReplicaID: true}
+	rts.mu.Lock()
+	for _, bucketStart := range bucketStarts {
+		if bucket := rts.buckets[tenantID][bucketStart.Unix()]; bucket != nil {
+			// Copied so merging does not race with queries added meanwhile.
+			sketches = append(sketches, spaceSavingFromSnapshot(bucket.sketch.snapshot()))
+		}
+	}
+	rts.mu.Unlock()
+
+	partial := false
+	published, err := rts.sketches.List(ctx, bucketStarts)
+	if err != nil {
+		rts.logger.WarnContext(ctx, "Error reading the sketches of other replicas, ranking this replica's queries only", "error", err)
+		partial = true
+	}
+	for _, byReplica := range published {
+		for replicaID, snapshot := range byReplica {
+			// This replica's own sketch is fresher in memory than as last published.
+			if replicaID == rts.cfg.ReplicaID {
+				continue
+			}
+			replicas[replicaID] = true
+			sketches = append(sketches, spaceSavingFromSnapshot(snapshot))
+		}
+	}
+
+	merged := mergeSpaceSaving(rts.cfg.Capacity, sketches...)
+	return &models.RealtimeTopQueries{
+		Window:     window.String(),
+		From:       from.UTC(),
+		To:         to.UTC(),
+		Total:      merged.total,
+		ErrorBound: merged.untrackedBound(),
+		Replicas:   len(replicas),
+		Partial:    partial,
+		Queries:    rts.topAllowed(ctx, merged, limit),
+	}, nil
+}
+
+// topAllowed returns the limit most counted queries of sketch that the blocklist does not block. Sketches can hold
+// queries counted before a rule blocking them was added, or purged since, until their buckets expire.
+func (rts *realtimeTopService) topAllowed(ctx context.Context, sketch *spaceSaving, limit int) []models.HeavyHitter {
+	if rts.blocklist == nil {
+		return sketch.top(limit)
+	}
+	allowed := make([]models.HeavyHitter, 0, limit)
+	for _, hitter := range sketch.top(len(sketch.counters)) {
+		if len(allowed) == limit {
+			break
+		}
+		ruleID, err := rts.blocklist.Match(ctx, hitter.QueryText)
+		if err != nil {
+			// Fail open like the blocklist filter does.
+			rts.logger.WarnContext(ctx, "Error matching blocklist, ranking every query", "error", err)
+			return sketch.top(limit)
+		}
+		if ruleID == "" {
+			allowed = append(allowed, hitter)
+		}
+	}
+	return allowed
+}
+
+func (rts *realtimeTopService) Publish(ctx context.Context) error {
+	type pendingSketch struct {
+		tenantID    string
+		bucketStart int64
+		snapshot    *models.SketchSnapshot
+	}
+	var pending []pendingSketch
+	rts.mu.Lock()
+	rts.dropExpiredBuckets(rts.now())
+	for tenantID, buckets := range rts.buckets {
+		for bucketStart, bucket := range buckets {
+			if bucket.dirty {
+				pending = append(pending, pendingSketch{tenantID: tenantID, bucketStart: bucketStart, snapshot: bucket.sketch.snapshot()})
+				bucket.dirty = false
+			}
+		}
+	}
+	rts.mu.Unlock()
+
+	// A bucket stays readable as long as a window can reach it.
+	ttl := rts.cfg.Retention + rts.cfg.Bucket
+	var errs []error
+	for _, p := range pending {
+		tenantCtx := tenant.WithTenant(ctx, p.tenantID)
+		if err := rts.sketches.Publish(tenantCtx, time.Unix(p.bucketStart, 0), rts.cfg.ReplicaID, p.snapshot, ttl); err != nil {
+			errs = append(errs, fmt.Errorf("publishing sketch of tenant %q: %w", p.tenantID, err))
+			rts.markDirty(p.tenantID, p.bucketStart)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// markDirty publishes a sketch again next time, after publishing it failed.
+func (rts *realtimeTopService) markDirty(tenantID string, bucketStart int64) {
+	rts.mu.Lock()
+	defer rts.mu.Unlock()
+	if bucket := rts.buckets[tenantID][bucketStart]; bucket != nil {
+		bucket.dirty = true
+	}
+}
+
+func (rts *realtimeTopService) publishPeriodically() {
+	defer close(rts.stopped)
+	ticker := time.NewTicker(rts.cfg.PublishInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := rts.Publish(context.Background()); err != nil {
+				rts.logger.Error("Error publishing real-time sketches", "error", err)
+			}
+		case <-rts.stop:
+			return
+		}
+	}
+}
+
+func (rts *realtimeTopService) Stop(ctx context.Context) error {
+	rts.stopOnce.Do(func() { close(rts.stop) })
+	select {
+	case <-rts.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return rts.Publish(ctx)
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/storage_util"
+	"search-logger/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zipfStream returns n queries drawn from a skewed distribution, with how many times each was drawn.
+func zipfStream(seed int64, n int) ([]string, map[string]int64) {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(seed)), 1.2, 1, 5000)
+	queries := make([]string, 0, n)
+	counts := make(map[string]int64)
+	for i := 0; i < n; i++ {
+		queryText := fmt.Sprintf("query-%d", zipf.Uint64())
+		queries = append(queries, queryText)
+		counts[queryText]++
+	}
+	return queries, counts
+}
+
+// assertSketchBounds checks that every tracked count bounds the true count within its error, and that no untracked
+// query occurred more than untrackedBound times.
+func assertSketchBounds(t *testing.T, sketch *spaceSaving, counts map[string]int64) {
+	t.Helper()
+	for queryText, trueCount := range counts {
+		counter, ok := sketch.counters[queryText]
+		if !ok {
+			assert.LessOrEqual(t, trueCount, sketch.untrackedBound(), queryText)
+			continue
+		}
+		assert.GreaterOrEqual(t, counter.count, trueCount, queryText)
+		assert.LessOrEqual(t, counter.count-counter.error, trueCount, queryText)
+	}
+}
+
+func TestSpaceSaving(t *testing.T) {
+	t.Run("Counts exactly while queries fit", func(t *testing.T) {
+		sketch := newSpaceSaving(3)
+		for _, queryText := range []string{"laptop", "phone", "laptop", "tv", "laptop", "phone"} {
+			sketch.add(queryText)
+		}
+
+		assert.Equal(t, []models.HeavyHitter{
+			{QueryText: "laptop", Count: 3, MinCount: 3},
+			{QueryText: "phone", Count: 2, MinCount: 2},
+		}, sketch.top(2))
+		assert.Equal(t, int64(6), sketch.total)
+		assert.Equal(t, int64(1), sketch.untrackedBound())
+	})
+
+	t.Run("Bounds counts once queries are evicted", func(t *testing.T) {
+		// ARRANGE
+		queries, counts := zipfStream(1, 20000)
+		sketch := newSpaceSaving(100)
+
+		// ACT
+		for _, queryText := range queries {
+			sketch.add(queryText)
+		}
+
+		// ASSERT
+		assertSketchBounds(t, sketch, counts)
+		assert.LessOrEqual(t, sketch.untrackedBound(), sketch.total/100)
+		assert.Equal(t, "query-0", sketch.top(1)[0].QueryText)
+	})
+
+	t.Run("Merged sketches bound the counts of the combined streams", func(t *testing.T) {
+		// ARRANGE
+		first, firstCounts := zipfStream(2, 10000)
+		second, secondCounts := zipfStream(3, 10000)
+		firstSketch, secondSketch := newSpaceSaving(100), newSpaceSaving(100)
+		for _, queryText := range first {
+			firstSketch.add(queryText)
+		}
+		for _, queryText := range second {
+			secondSketch.add(queryText)
+		}
+		counts := firstCounts
+		for queryText, count := range secondCounts {
+			counts[queryText] += count
+		}
+
+		// ACT
+		merged := mergeSpaceSaving(100, spaceSavingFromSnapshot(firstSketch.snapshot()), secondSketch)
+
+		// ASSERT
+		assert.Equal(t, int64(20000), merged.total)
+		assert.Len(t, merged.counters, 100)
+		assertSketchBounds(t, merged, counts)
+	})
+}
+
+// failingRealtimeSketchRepository stands in for Redis being unreachable.
+type failingRealtimeSketchRepository struct {
+	cache.RealtimeSketchCacheRepository
+}
+
+func (failingRealtimeSketchRepository) List(context.Context, []time.Time) (map[int64]map[string]*models.SketchSnapshot, error) {
+	return nil, errors.New("connection refused")
+}
+
+func newTestRealtimeTopService(sketches cache.RealtimeSketchCacheRepository, replicaID string, now time.Time) *realtimeTopService {
+	srv := NewRealtimeTopService(sketches, nil, RealtimeTopConfig{
+		Capacity:  100,
+		Bucket:    time.Minute,
+		Retention: time.Hour,
+		ReplicaID: replicaID,
+	}, slog.Default()).(*realtimeTopService)
+	srv.now = func() time.Time { return now }
+	return srv
+}
+
+func persistQueries(srv RealtimeTopService, ctx context.Context, queries ...string) {
+	for _, queryText := range queries {
+		srv.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: queryText})
+	}
+}
+
+func TestRealtimeTopService_TopQueries(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 30, 30, 0, time.UTC)
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	t.Run("Merges the sketches every replica published", func(t *testing.T) {
+		// ARRANGE
+		sketches := cache.NewRealtimeSketchCacheRepository(storage_util.InitRedis())
+		replicaA := newTestRealtimeTopService(sketches, "replica-a", now)
+		replicaB := newTestRealtimeTopService(sketches, "replica-b", now)
+		persistQueries(replicaA, ctx, "laptop", "laptop", "phone")
+		persistQueries(replicaB, ctx, "laptop", "tv")
+
+		// ACT
+		assert.NoError(t, replicaA.Publish(ctx))
+		top, err := replicaB.TopQueries(ctx, 5*time.Minute, 10)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, &models.RealtimeTopQueries{
+			Window:   "5m0s",
+			From:     time.Date(2026, 10, 19, 10, 25, 0, 0, time.UTC),
+			To:       now,
+			Total:    5,
+			Replicas: 2,
+			Queries: []models.HeavyHitter{
+				{QueryText: "laptop", Count: 3, MinCount: 3},
+				{QueryText: "phone", Count: 1, MinCount: 1},
+				{QueryText: "tv", Count: 1, MinCount: 1},
+			},
+		}, top)
+	})
+
+	t.Run("Leaves out queries finalized before the window", func(t *testing.T) {
+		// ARRANGE
+		srv := newTestRealtimeTopService(cache.NewRealtimeSketchCacheRepository(storage_util.InitRedis()), "replica-a", now.Add(-10*time.Minute))
+		persistQueries(srv, ctx, "laptop", "laptop")
+		srv.now = func() time.Time { return now }
+		persistQueries(srv, ctx, "phone")
+
+		// ACT
+		recent, err := srv.TopQueries(ctx, 5*time.Minute, 10)
+		assert.NoError(t, err)
+		longer, err := srv.TopQueries(ctx, 15*time.Minute, 10)
+		assert.NoError(t, err)
+
+		// ASSERT
+		assert.Equal(t, []models.HeavyHitter{{QueryText: "phone", Count: 1, MinCount: 1}}, recent.Queries)
+		assert.Equal(t, int64(3), longer.Total)
+		assert.Equal(t, "laptop", longer.Queries[0].QueryText)
+	})
+
+	t.Run("Tenants are ranked apart", func(t *testing.T) {
+		srv := newTestRealtimeTopService(cache.NewRealtimeSketchCacheRepository(storage_util.InitRedis()), "replica-a", now)
+		persistQueries(srv, ctx, "laptop")
+
+		top, err := srv.TopQueries(tenant.WithTenant(context.Background(), "globex"), time.Minute, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, top.Queries)
+	})
+
+	t.Run("Ranks this replica's queries when Redis cannot be read", func(t *testing.T) {
+		srv := newTestRealtimeTopService(failingRealtimeSketchRepository{}, "replica-a", now)
+		persistQueries(srv, ctx, "laptop")
+
+		top, err := srv.TopQueries(ctx, time.Minute, 10)
+		assert.NoError(t, err)
+		assert.True(t, top.Partial)
+		assert.Equal(t, []models.HeavyHitter{{QueryText: "laptop", Count: 1, MinCount: 1}}, top.Queries)
+	})
+
+	t.Run("Leaves out queries the blocklist blocks now", func(t *testing.T) {
+		// ARRANGE
+		blocklist := NewBlocklistService(database.NewBlocklistDatabaseRepository(setupTestDB(t)), BlocklistConfig{RefreshInterval: time.Minute}, slog.Default())
+		srv := newTestRealtimeTopService(cache.NewRealtimeSketchCacheRepository(storage_util.InitRedis()), "replica-a", now)
+		srv.blocklist = blocklist
+		persistQueries(srv, ctx, "casino", "casino", "laptop")
+		_, err := blocklist.AddRule(ctx, models.BlocklistRuleExact, "casino")
+		assert.NoError(t, err)
+
+		// ACT
+		top, err := srv.TopQueries(ctx, time.Minute, 1)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, []models.HeavyHitter{{QueryText: "laptop", Count: 1, MinCount: 1}}, top.Queries)
+	})
+
+	t.Run("Window must be within the retention", func(t *testing.T) {
+		srv := newTestRealtimeTopService(failingRealtimeSketchRepository{}, "replica-a", now)
+
+		_, err := srv.TopQueries(ctx, 2*time.Hour, 10)
+		assert.ErrorIs(t, err, ErrInvalidRealtimeWindow)
+		_, err = srv.TopQueries(ctx, 0, 10)
+		assert.ErrorIs(t, err, ErrInvalidRealtimeWindow)
+	})
+}
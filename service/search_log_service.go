@@ -2,97 +2,290 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"search-logger/config"
+	"search-logger/models"
 	"search-logger/repository/cache"
 	"search-logger/repository/database"
+	"search-logger/tenant"
+	"search-logger/tracing"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ErrInvalidSearch is returned for searches a caller reported with invalid fields.
+var ErrInvalidSearch = errors.New("invalid search")
+
 type SearchLogService interface {
+	// LogSearch records a keystroke of the client and counts it once the client stops typing. A KeystrokeOrder on
+	// ctx orders it against the client's other keystrokes; a keystroke older than the client's latest is ignored.
 	LogSearch(ctx context.Context, clientIdentifier, queryText string) error
+	// RecordKeystroke records a keystroke of the client like LogSearch, but leaves counting it to the caller: the
+	// returned keystroke is passed to FinalizeKeystroke once it is due. It returns nil when a later keystroke of the
+	// client was already recorded.
+	RecordKeystroke(ctx context.Context, clientIdentifier, queryText string) (*PendingKeystroke, error)
+	// FinalizeKeystroke counts a recorded keystroke if it is still the client's latest, and was not counted yet by
+	// any replica. Finalizing it before it is due ends its debounce early.
+	FinalizeKeystroke(keystroke *PendingKeystroke)
+	// LogReleasedSearch counts a search released from quarantine right away, in the hour it was searched. It goes
+	// through the persist filters, except the bot filter, and the listeners like a finalized keystroke.
+	LogReleasedSearch(ctx context.Context, search *models.QuarantinedSearch) error
+	// ReportResultCount records how many results a client's search returned. Zero-result searches are counted
+	// when the query is finalized, or immediately if it already was. It returns ErrInvalidSearch for an empty query
+	// text or a negative count.
+	ReportResultCount(ctx context.Context, clientIdentifier, queryText string, resultCount int) error
 	GetSearchLogCountByQueryText(ctx context.Context, queryText string) (int, error)
+	// TopQueries ranks queries by their all-time count.
+	TopQueries(ctx context.Context, limit int) ([]models.SearchLog, error)
+	// Flush ends the debounce of every pending keystroke, and of any logged afterwards, finalizing them right away,
+	// then waits until they have been counted or ctx is done. It is meant to be called once, on shutdown.
+	Flush(ctx context.Context) error
+}
+
+// SearchLogPersistedListener is notified after a finalized query has been counted in the database.
+type SearchLogPersistedListener interface {
+	OnSearchLogPersisted(ctx context.Context, clientIdentifier string, searchLog *models.SearchLog)
+}
+
+// PersistFilter runs before a finalized query is counted. It may rewrite the query text, or return false to keep
+// the query out of search_logs entirely.
+type PersistFilter interface {
+	BeforePersist(ctx context.Context, clientIdentifier, queryText string) (string, bool)
+}
+
+// PendingKeystroke is a keystroke recorded by RecordKeystroke that waits for the client to stop typing.
+type PendingKeystroke struct {
+	ClientIdentifier string
+	QueryText        string
+	// DueAt is when the debounce delay of the keystroke is over.
+	DueAt time.Time
+
+	// ctx is detached from the request, and traces the debounce and what follows it as children of the keystroke's
+	// search.LogSearch span.
+	ctx          context.Context
+	value        *cache.ClientQueryValue
+	debounceSpan *tracing.Span
+}
+
+type Option func(*searchLogService)
+
+// WithPersistFilters registers filters that run, in order, before every IncrementSearchLog.
+// The first filter to reject a query stops the chain.
+func WithPersistFilters(filters ...PersistFilter) Option {
+	return func(sls *searchLogService) {
+		sls.persistFilters = append(sls.persistFilters, filters...)
+	}
+}
+
+// WithPersistedListeners registers listeners that run, in order, after every successful IncrementSearchLog.
+func WithPersistedListeners(listeners ...SearchLogPersistedListener) Option {
+	return func(sls *searchLogService) {
+		sls.persistedListeners = append(sls.persistedListeners, listeners...)
+	}
 }
 
 type searchLogService struct {
-	db     database.SearchLogRepository
-	cache  cache.LatestClientQueryCacheRepository
-	logger *slog.Logger
+	db                 database.SearchLogRepository
+	cache              cache.LatestClientQueryCacheRepository
+	logger             *slog.Logger
+	persistFilters     []PersistFilter
+	persistedListeners []SearchLogPersistedListener
+	debounces          *sync.WaitGroup
+	flushing           chan struct{}
+	flushOnce          *sync.Once
 }
 
-func NewSearchLogService(db database.SearchLogRepository, cache cache.LatestClientQueryCacheRepository, logger *slog.Logger) SearchLogService {
-	return &searchLogService{
-		db:     db,
-		cache:  cache,
-		logger: logger,
+func NewSearchLogService(db database.SearchLogRepository, cache cache.LatestClientQueryCacheRepository, logger *slog.Logger, opts ...Option) SearchLogService {
+	sls := &searchLogService{
+		db:        db,
+		cache:     cache,
+		logger:    logger,
+		debounces: &sync.WaitGroup{},
+		flushing:  make(chan struct{}),
+		flushOnce: &sync.Once{},
 	}
+	for _, opt := range opts {
+		opt(sls)
+	}
+	return sls
 }
 
 func (sls searchLogService) LogSearch(ctx context.Context, clientIdentifier, queryText string) error {
+	keystroke, err := sls.RecordKeystroke(ctx, clientIdentifier, queryText)
+	if err != nil || keystroke == nil {
+		return err
+	}
+
+	// Attempt to persist the search log in the background, once the client stopped typing, unless the service is
+	// flushing. Ideally, the front end would do some debouncing too.
+	sls.debounces.Add(1)
+	go func() {
+		defer sls.debounces.Done()
+		timer := time.NewTimer(time.Until(keystroke.DueAt))
+		select {
+		case <-timer.C:
+		case <-sls.flushing:
+			timer.Stop()
+		}
+		sls.FinalizeKeystroke(keystroke)
+	}()
+	return nil
+}
+
+func (sls searchLogService) RecordKeystroke(ctx context.Context, clientIdentifier, queryText string) (_ *PendingKeystroke, err error) {
+	ctx, span := tracing.Start(ctx, "search.LogSearch", tracing.WithAttributes(tracing.String("tenant", tenant.FromContext(ctx))))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	currentNormalizedQueryText := strings.TrimSpace(strings.ToLower(queryText))
 
-	// Immediately set the latest client search in cache.
-	// I think a possible improvement could be to use a client timestamp instead of server generated,
-	// in the event that multiple requests from the same user are processed at the exact same time by different servers. Or perhaps,
-	// LogSearch requests could be processed from a queue.
+	// Immediately set the latest client search in cache, unless a later keystroke of the client is already there.
+	// Keystrokes are ordered by the client's sequence number or timestamp when it sends them, since requests handled
+	// by different servers can arrive out of order, and by server time otherwise.
 	currentQueryTimeUnix := time.Now().UnixMilli()
+	order, hasOrder := KeystrokeOrderFromContext(ctx)
+	if hasOrder && !order.ReceivedAt.IsZero() {
+		currentQueryTimeUnix = order.ReceivedAt.UnixMilli()
+	}
 	clientQueryValue := cache.NewClientQueryValue(currentNormalizedQueryText, currentQueryTimeUnix)
-	if err := sls.cache.Set(ctx, clientIdentifier, clientQueryValue); err != nil {
-		sls.logger.Error("Error setting latest client query in cache", "error", err, "clientIdentifier", clientIdentifier, "queryText", currentNormalizedQueryText)
-		return err
+	if hasOrder {
+		clientQueryValue.Sequence = order.Sequence
+		if order.ClientTimestamp != nil {
+			clientTimestamp := order.ClientTimestamp.UnixMilli()
+			clientQueryValue.ClientTimestampUnixMilliseconds = &clientTimestamp
+		}
+	}
+	written, err := sls.cache.SetIfNewer(ctx, clientIdentifier, clientQueryValue)
+	if err != nil {
+		sls.logger.ErrorContext(ctx, "Error setting latest client query in cache", "error", err, "clientIdentifier", clientIdentifier, "queryText", currentNormalizedQueryText)
+		return nil, err
+	}
+	if !written {
+		span.SetAttributes(tracing.Bool("keystroke.outdated", true))
+		sls.logger.DebugContext(ctx, "Ignoring keystroke older than the client's latest", "clientIdentifier", clientIdentifier, "queryText", currentNormalizedQueryText)
+		return nil, nil
 	}
 
-	// Attempt to persist the search log in the background
-	go func() {
-		backgroundContext := context.Background()
+	// The debounce, and what follows it, are traced as children of the search.LogSearch span, which has ended by then.
+	delay := config.GetLogSearchDebounceDelayForTenant(tenant.FromContext(ctx))
+	backgroundContext := detachContext(ctx)
+	_, debounceSpan := tracing.Start(backgroundContext, "search.debounce", tracing.WithAttributes(tracing.Int64("debounce.delay_ms", delay.Milliseconds())))
+	return &PendingKeystroke{
+		ClientIdentifier: clientIdentifier,
+		QueryText:        currentNormalizedQueryText,
+		DueAt:            time.Now().Add(delay),
+		ctx:              backgroundContext,
+		value:            clientQueryValue,
+		debounceSpan:     debounceSpan,
+	}, nil
+}
 
-		// Debounce before attempting to log to DB, in case client is still typing
-		// Ideally, the front end would do some debouncing too.
-		time.Sleep(config.GetLogSearchDebounceDelaySeconds())
+func (sls searchLogService) FinalizeKeystroke(keystroke *PendingKeystroke) {
+	ctx := keystroke.ctx
+	if time.Now().Before(keystroke.DueAt) {
+		keystroke.debounceSpan.SetAttributes(tracing.Bool("debounce.flushed", true))
+	}
+	keystroke.debounceSpan.End()
 
-		// Get latest user client query from cache
-		latestClientQueryValue, err := sls.cache.Get(backgroundContext, clientIdentifier)
-		if err != nil {
-			sls.logger.Error("Error getting latest client query from cache", "error", err)
-			return
-		}
+	// Only the keystroke still cached for the client is counted, and only once across replicas.
+	latestClientQueryValue, err := sls.cache.Finalize(ctx, keystroke.ClientIdentifier, keystroke.value)
+	if err != nil {
+		sls.logger.ErrorContext(ctx, "Error finalizing client query", "error", err, "clientIdentifier", keystroke.ClientIdentifier)
+		return
+	}
+	if latestClientQueryValue == nil {
+		return
+	}
 
-		if !shouldPersistQuery(currentQueryTimeUnix, currentNormalizedQueryText, latestClientQueryValue) {
-			return
-		}
+	_ = sls.persist(ctx, keystroke.ClientIdentifier, keystroke.QueryText, latestClientQueryValue.ResultCount, time.Now())
+}
 
-		if _, err := sls.db.IncrementSearchLog(backgroundContext, currentNormalizedQueryText); err != nil {
-			sls.logger.Error("Error logging search", "error", err, "queryText", currentNormalizedQueryText)
-		}
+func (sls searchLogService) LogReleasedSearch(ctx context.Context, search *models.QuarantinedSearch) error {
+	return sls.persist(withReleasedSearch(ctx), search.ClientIdentifier, search.QueryText, nil, search.CreatedAt)
+}
 
-		if err := sls.cache.Delete(backgroundContext, clientIdentifier); err != nil {
-			sls.logger.Error("Error deleting client query from cache", "error", err, "clientIdentifier", clientIdentifier)
-		}
+func (sls searchLogService) Flush(ctx context.Context) error {
+	sls.flushOnce.Do(func() { close(sls.flushing) })
+
+	done := make(chan struct{})
+	go func() {
+		sls.debounces.Wait()
+		close(done)
 	}()
-	return nil
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error flushing pending searches: %w", ctx.Err())
+	}
 }
 
-func shouldPersistQuery(currentQueryTimeUnix int64, normalizedQueryText string, latestClientQueryValue *cache.ClientQueryValue) bool {
-	if latestClientQueryValue != nil {
-		// If queryTimeUnix is less than latestClientQueryValue.CreatedAtUnixMilliseconds, do not persist
-		if currentQueryTimeUnix < latestClientQueryValue.CreatedAtUnixMilliseconds {
-			return false
+// persist counts a finalized query, running it through the persist filters first and notifying listeners after. The
+// error of counting it is logged and returned.
+func (sls searchLogService) persist(ctx context.Context, clientIdentifier, queryText string, resultCount *int, searchedAt time.Time) error {
+	for _, filter := range sls.persistFilters {
+		var keep bool
+		if queryText, keep = filter.BeforePersist(ctx, clientIdentifier, queryText); !keep {
+			return nil
 		}
+	}
 
-		// If the current query is a prefix of the latest client query, do not persist
-		if len(normalizedQueryText) < len(latestClientQueryValue.QueryText) && strings.HasPrefix(latestClientQueryValue.QueryText, normalizedQueryText) {
-			return false
+	searchLog, err := sls.db.IncrementSearchLogAt(ctx, queryText, searchedAt)
+	if err != nil {
+		sls.logger.ErrorContext(ctx, "Error logging search", "error", err, "queryText", queryText)
+		return err
+	}
+
+	if resultCount != nil && *resultCount == 0 {
+		if err := sls.db.IncrementZeroResult(ctx, queryText, time.Now()); err != nil {
+			sls.logger.ErrorContext(ctx, "Error logging zero-result search", "error", err, "queryText", queryText)
 		}
 	}
-	return true
+
+	for _, listener := range sls.persistedListeners {
+		listener.OnSearchLogPersisted(ctx, clientIdentifier, searchLog)
+	}
+	return nil
+}
+
+func (sls searchLogService) ReportResultCount(ctx context.Context, clientIdentifier, queryText string, resultCount int) error {
+	if resultCount < 0 {
+		return fmt.Errorf("%w: result count cannot be negative", ErrInvalidSearch)
+	}
+	normalizedQueryText := strings.TrimSpace(strings.ToLower(queryText))
+	if normalizedQueryText == "" {
+		return fmt.Errorf("%w: query text cannot be empty", ErrInvalidSearch)
+	}
+
+	// While the query is still being debounced, attach the count to it so it is recorded only if the query is finalized.
+	attached, err := sls.cache.SetResultCount(ctx, clientIdentifier, normalizedQueryText, resultCount)
+	if err != nil {
+		return fmt.Errorf("error attaching result count to latest client query: %w", err)
+	}
+	if attached {
+		return nil
+	}
+
+	// Otherwise the query was already finalized, or was never logged, in which case there is nothing to attribute it to.
+	if resultCount != 0 {
+		return nil
+	}
+	err = sls.db.IncrementZeroResult(ctx, normalizedQueryText, time.Now())
+	if err != nil && !errors.Is(err, database.ErrSearchLogNotFound) {
+		return fmt.Errorf("error logging zero-result search: %w", err)
+	}
+	return nil
 }
 
 // Used for testing
 func (sls searchLogService) GetSearchLogCountByQueryText(ctx context.Context, queryText string) (int, error) {
 	if queryText == "" {
-		return 0, fmt.Errorf("query text cannot be empty")
+		return 0, fmt.Errorf("%w: query text cannot be empty", ErrInvalidSearch)
 	}
 
 	normalizedQueryText := strings.TrimSpace(strings.ToLower(queryText))
@@ -106,3 +299,11 @@ func (sls searchLogService) GetSearchLogCountByQueryText(ctx context.Context, qu
 
 	return 0, nil
 }
+
+func (sls searchLogService) TopQueries(ctx context.Context, limit int) ([]models.SearchLog, error) {
+	searchLogs, err := sls.db.ListQueryCounts(ctx, 1, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing top queries: %w", err)
+	}
+	return searchLogs, nil
+}
@@ -4,23 +4,31 @@ import (
 	"context"
 	"log/slog"
 	"search-logger/config"
+	"search-logger/correlation"
 	"search-logger/models"
 	"search-logger/repository/cache"
 	"search-logger/repository/database"
 	"search-logger/storage_util"
+	"search-logger/tracing"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
-func setupTestDatabase(t *testing.T) database.SearchLogRepository {
+// setupTestDB returns a new in-memory database with every table.
+func setupTestDB(t *testing.T) *gorm.DB {
 	db := storage_util.InitDB()
 	assert.NotNil(t, db)
-	err := db.AutoMigrate(&models.SearchLog{})
-	assert.NoError(t, err)
+	assert.NoError(t, storage_util.Migrate(db))
+	return db
+}
 
-	return database.NewSearchLogDatabaseRepository(db)
+func setupTestDatabase(t *testing.T) database.SearchLogRepository {
+	return database.NewSearchLogDatabaseRepository(setupTestDB(t))
 }
 
 func setupTestRedis(t *testing.T) cache.LatestClientQueryCacheRepository {
@@ -217,6 +225,134 @@ func TestSearchLogService_LogSearch(t *testing.T) {
 	})
 }
 
+func TestSearchLogService_LogSearchAcrossReplicas(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	replicas := []SearchLogService{
+		NewSearchLogService(dbRepo, cacheRepo, slog.Default()),
+		NewSearchLogService(dbRepo, cacheRepo, slog.Default()),
+	}
+
+	t.Run("Keystrokes spread over replicas count only the final query, once", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		var wg sync.WaitGroup
+
+		// ACT
+		for i := 0; i < 10; i++ {
+			clientKey := "replica-client-" + strconv.Itoa(i)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k, keystroke := range []string{"w", "wi", "wire", "wireless", "wireless mouse"} {
+					assert.NoError(t, replicas[k%len(replicas)].LogSearch(ctx, clientKey, keystroke))
+				}
+			}()
+		}
+		wg.Wait()
+
+		// ASSERT
+		time.Sleep(config.GetLogSearchDebounceDelaySeconds() + time.Second)
+		searchLog, err := dbRepo.GetByQueryText(ctx, "wireless mouse")
+		assert.NoError(t, err)
+		assert.Equal(t, 10, searchLog.Count)
+		for _, prefix := range []string{"w", "wi", "wire", "wireless"} {
+			searchLog, err := dbRepo.GetByQueryText(ctx, prefix)
+			assert.NoError(t, err)
+			assert.Nil(t, searchLog, prefix)
+		}
+	})
+}
+
+func TestSearchLogService_LogSearchKeystrokeOrder(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default())
+	withSequence := func(sequence int64) context.Context {
+		return WithKeystrokeOrder(context.Background(), KeystrokeOrder{Sequence: &sequence})
+	}
+	withClientTimestamp := func(clientTimestamp time.Time) context.Context {
+		return WithKeystrokeOrder(context.Background(), KeystrokeOrder{ClientTimestamp: &clientTimestamp})
+	}
+
+	t.Run("Keystrokes arriving out of order are ordered by client sequence", func(t *testing.T) {
+		// ACT
+		assert.NoError(t, service.LogSearch(withSequence(3), "ordered-client-1", "keyboard"))
+		assert.NoError(t, service.LogSearch(withSequence(2), "ordered-client-1", "keyb"))
+		assert.NoError(t, service.LogSearch(withSequence(1), "ordered-client-1", "ke"))
+
+		// ASSERT
+		time.Sleep(config.GetLogSearchDebounceDelaySeconds() + time.Second)
+		searchLog, err := dbRepo.GetByQueryText(context.Background(), "keyboard")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, searchLog.Count)
+		for _, prefix := range []string{"keyb", "ke"} {
+			searchLog, err := dbRepo.GetByQueryText(context.Background(), prefix)
+			assert.NoError(t, err)
+			assert.Nil(t, searchLog, prefix)
+		}
+	})
+
+	t.Run("Keystrokes arriving out of order are ordered by client timestamp", func(t *testing.T) {
+		// ACT
+		typedAt := time.Now()
+		assert.NoError(t, service.LogSearch(withClientTimestamp(typedAt.Add(time.Second)), "ordered-client-2", "webcam"))
+		assert.NoError(t, service.LogSearch(withClientTimestamp(typedAt), "ordered-client-2", "web"))
+
+		// ASSERT
+		time.Sleep(config.GetLogSearchDebounceDelaySeconds() + time.Second)
+		searchLog, err := dbRepo.GetByQueryText(context.Background(), "webcam")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, searchLog.Count)
+		searchLog, err = dbRepo.GetByQueryText(context.Background(), "web")
+		assert.NoError(t, err)
+		assert.Nil(t, searchLog)
+	})
+}
+
+func TestSearchLogService_Flush(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default())
+
+	t.Run("Pending keystrokes are finalized without waiting for the debounce delay", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		assert.NoError(t, service.LogSearch(ctx, "flush-client-1", "flushed query"))
+		assert.NoError(t, service.LogSearch(ctx, "flush-client-2", "flushed"))
+		assert.NoError(t, service.LogSearch(ctx, "flush-client-2", "flushed query"))
+
+		// ACT
+		start := time.Now()
+		err := service.Flush(ctx)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), config.GetLogSearchDebounceDelaySeconds())
+		count, err := service.GetSearchLogCountByQueryText(ctx, "flushed query")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		count, err = service.GetSearchLogCountByQueryText(ctx, "flushed")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("Keystrokes logged after flushing are finalized right away", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+
+		// ACT
+		assert.NoError(t, service.LogSearch(ctx, "flush-client-3", "late query"))
+		err := service.Flush(ctx)
+
+		// ASSERT
+		assert.NoError(t, err)
+		count, err := service.GetSearchLogCountByQueryText(ctx, "late query")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
 func TestSearchLogService_GetSearchLogCountByQueryText(t *testing.T) {
 	dbRepo := setupTestDatabase(t)
 	cacheRepo := setupTestRedis(t)
@@ -255,3 +391,277 @@ func TestSearchLogService_GetSearchLogCountByQueryText(t *testing.T) {
 		assert.Equal(t, 0, count)
 	})
 }
+
+func TestSearchLogService_TopQueries(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default())
+	ctx := context.Background()
+
+	t.Run("Rank queries by count", func(t *testing.T) {
+		// ARRANGE
+		for queryText, count := range map[string]int{"top laptop": 3, "top monitor": 1, "top keyboard": 2} {
+			for i := 0; i < count; i++ {
+				_, _ = dbRepo.IncrementSearchLog(ctx, queryText)
+			}
+		}
+
+		// ACT
+		searchLogs, err := service.TopQueries(ctx, 2)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Len(t, searchLogs, 2)
+		assert.Equal(t, "top laptop", searchLogs[0].QueryText)
+		assert.Equal(t, 3, searchLogs[0].Count)
+		assert.Equal(t, "top keyboard", searchLogs[1].QueryText)
+	})
+
+	t.Run("Reject non-positive limits", func(t *testing.T) {
+		_, err := service.TopQueries(ctx, 0)
+		assert.Error(t, err)
+	})
+}
+
+type recordingPersistedListener struct {
+	mu         sync.Mutex
+	searchLogs []*models.SearchLog
+}
+
+func (l *recordingPersistedListener) OnSearchLogPersisted(_ context.Context, _ string, searchLog *models.SearchLog) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.searchLogs = append(l.searchLogs, searchLog)
+}
+
+func TestSearchLogService_PersistedListeners(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	listener := &recordingPersistedListener{}
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default(), WithPersistedListeners(listener))
+
+	t.Run("Listeners are notified only for the persisted query", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		clientKey := "listener-client-key"
+
+		// ACT
+		for _, queryText := range []string{"ru", "rus", "rust"} {
+			err := service.LogSearch(ctx, clientKey, queryText)
+			assert.NoError(t, err)
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		// ASSERT
+		time.Sleep(config.GetLogSearchDebounceDelaySeconds() + time.Second)
+		listener.mu.Lock()
+		defer listener.mu.Unlock()
+		assert.Len(t, listener.searchLogs, 1)
+		assert.Equal(t, "rust", listener.searchLogs[0].QueryText)
+		assert.Equal(t, 1, listener.searchLogs[0].Count)
+	})
+}
+
+type requestIDRecordingListener struct {
+	mu         sync.Mutex
+	requestIDs []string
+}
+
+func (l *requestIDRecordingListener) OnSearchLogPersisted(ctx context.Context, _ string, _ *models.SearchLog) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requestIDs = append(l.requestIDs, correlation.RequestIDFromContext(ctx))
+}
+
+func TestSearchLogService_Correlation(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	listener := &requestIDRecordingListener{}
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default(), WithPersistedListeners(listener))
+
+	t.Run("Background finalization keeps the request ID of the keystroke", func(t *testing.T) {
+		// ARRANGE
+		ctx := correlation.FromIncoming(context.Background(), "correlated-request", "")
+
+		// ACT
+		err := service.LogSearch(ctx, "correlation-client", "correlated query")
+		assert.NoError(t, err)
+		assert.NoError(t, service.Flush(context.Background()))
+
+		// ASSERT
+		listener.mu.Lock()
+		defer listener.mu.Unlock()
+		assert.Equal(t, []string{"correlated-request"}, listener.requestIDs)
+	})
+}
+
+type recordingSpanExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingSpanExporter) Export(_ context.Context, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingSpanExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestSearchLogService_Tracing(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default())
+
+	t.Run("Debounced persistence is traced in the trace of the keystroke", func(t *testing.T) {
+		// ARRANGE
+		exporter := &recordingSpanExporter{}
+		tracer := tracing.NewTracer(exporter, tracing.Config{SampleRatio: 1, QueueSize: 100, BatchSize: 100, BatchTimeout: time.Hour}, slog.Default())
+		previous := tracing.Default()
+		tracing.SetDefault(tracer)
+		defer tracing.SetDefault(previous)
+		ctx, requestSpan := tracer.Start(context.Background(), "request")
+
+		// ACT
+		err := service.LogSearch(ctx, "tracing-client", "traced query")
+		requestSpan.End()
+		assert.NoError(t, err)
+		assert.NoError(t, service.Flush(context.Background()))
+		assert.NoError(t, tracer.Shutdown(context.Background()))
+
+		// ASSERT
+		spans := make(map[string]tracing.SpanData)
+		exporter.mu.Lock()
+		for _, span := range exporter.spans {
+			if span.TraceID == requestSpan.TraceParent().TraceID {
+				spans[span.Name] = span
+			}
+		}
+		exporter.mu.Unlock()
+		logSearch := spans["search.LogSearch"]
+		assert.Equal(t, requestSpan.TraceParent().SpanID, logSearch.ParentSpanID)
+		assert.Equal(t, logSearch.SpanID, spans["cache.SetIfNewer"].ParentSpanID)
+		assert.Equal(t, logSearch.SpanID, spans["search.debounce"].ParentSpanID)
+		assert.Contains(t, spans["search.debounce"].Attributes, tracing.Bool("debounce.flushed", true))
+		assert.Equal(t, logSearch.SpanID, spans["cache.Finalize"].ParentSpanID)
+		assert.Equal(t, logSearch.SpanID, spans["db.IncrementSearchLog"].ParentSpanID)
+		assert.Empty(t, spans["db.IncrementSearchLog"].Error)
+	})
+}
+
+type rejectingPersistFilter struct {
+	rejected string
+}
+
+func (f rejectingPersistFilter) BeforePersist(_ context.Context, _ string, queryText string) (string, bool) {
+	return queryText, queryText != f.rejected
+}
+
+func TestSearchLogService_PersistFilters(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	listener := &recordingPersistedListener{}
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default(),
+		WithPersistFilters(rejectingPersistFilter{rejected: "filtered query"}),
+		WithPersistedListeners(listener),
+	)
+
+	t.Run("Rejected queries are not persisted and listeners are not notified", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+
+		// ACT
+		err := service.LogSearch(ctx, "filter-client-1", "filtered query")
+		assert.NoError(t, err)
+		err = service.LogSearch(ctx, "filter-client-2", "allowed query")
+		assert.NoError(t, err)
+
+		// ASSERT
+		time.Sleep(config.GetLogSearchDebounceDelaySeconds() + time.Second)
+		searchLog, err := dbRepo.GetByQueryText(ctx, "filtered query")
+		assert.NoError(t, err)
+		assert.Nil(t, searchLog)
+		searchLog, err = dbRepo.GetByQueryText(ctx, "allowed query")
+		assert.NoError(t, err)
+		assert.NotNil(t, searchLog)
+
+		listener.mu.Lock()
+		defer listener.mu.Unlock()
+		assert.Len(t, listener.searchLogs, 1)
+	})
+}
+
+func TestSearchLogService_ReportResultCount(t *testing.T) {
+	dbRepo := setupTestDatabase(t)
+	cacheRepo := setupTestRedis(t)
+	service := NewSearchLogService(dbRepo, cacheRepo, slog.Default())
+
+	t.Run("Zero results reported while debouncing are recorded when the query is finalized", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		clientKey := "zero-result-client-1"
+
+		// ACT
+		err := service.LogSearch(ctx, clientKey, "no such thing")
+		assert.NoError(t, err)
+		err = service.ReportResultCount(ctx, clientKey, "No Such Thing", 0)
+		assert.NoError(t, err)
+
+		// ASSERT
+		time.Sleep(config.GetLogSearchDebounceDelaySeconds() + time.Second)
+		searchLog, err := dbRepo.GetByQueryText(ctx, "no such thing")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, searchLog.Count)
+		assert.Equal(t, 1, searchLog.ZeroResultCount)
+	})
+
+	t.Run("Zero results reported for a superseded keystroke are ignored", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		clientKey := "zero-result-client-2"
+
+		// ACT
+		err := service.LogSearch(ctx, clientKey, "hea")
+		assert.NoError(t, err)
+		err = service.ReportResultCount(ctx, clientKey, "hea", 0)
+		assert.NoError(t, err)
+		err = service.LogSearch(ctx, clientKey, "headphones")
+		assert.NoError(t, err)
+		err = service.ReportResultCount(ctx, clientKey, "headphones", 12)
+		assert.NoError(t, err)
+
+		// ASSERT
+		time.Sleep(config.GetLogSearchDebounceDelaySeconds() + time.Second)
+		searchLog, err := dbRepo.GetByQueryText(ctx, "headphones")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, searchLog.Count)
+		assert.Equal(t, 0, searchLog.ZeroResultCount)
+		searchLog, err = dbRepo.GetByQueryText(ctx, "hea")
+		assert.NoError(t, err)
+		assert.Nil(t, searchLog)
+	})
+
+	t.Run("Zero results reported after finalization are recorded immediately", func(t *testing.T) {
+		// ARRANGE
+		ctx := context.Background()
+		_, err := dbRepo.IncrementSearchLog(ctx, "finalized query")
+		assert.NoError(t, err)
+
+		// ACT
+		err = service.ReportResultCount(ctx, "zero-result-client-3", "finalized query", 0)
+		assert.NoError(t, err)
+
+		// ASSERT
+		searchLog, err := dbRepo.GetByQueryText(ctx, "finalized query")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, searchLog.ZeroResultCount)
+	})
+
+	t.Run("Negative result counts are rejected", func(t *testing.T) {
+		err := service.ReportResultCount(context.Background(), "zero-result-client-4", "query", -1)
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+	})
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"container/heap"
+	"search-logger/models"
+	"sort"
+)
+
+// spaceSaving is a Space-Saving sketch: it counts at most capacity queries, and a query it does not track replaces
+// the least counted one, inheriting its count as error. A tracked query's count overestimates its true count by at
+// most its error, and any query not tracked occurs at most untrackedBound times.
+type spaceSaving struct {
+	capacity int
+	total    int64
+	// floor bounds the count of the queries a merged sketch does not track, which its smallest count may not.
+	floor    int64
+	counters map[string]*spaceSavingCounter
+	heap     spaceSavingHeap
+}
+
+type spaceSavingCounter struct {
+	queryText string
+	count     int64
+	error     int64
+	index     int
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		counters: make(map[string]*spaceSavingCounter, capacity),
+		heap:     make(spaceSavingHeap, 0, capacity),
+	}
+}
+
+func (s *spaceSaving) add(queryText string) {
+	s.total++
+	if counter, ok := s.counters[queryText]; ok {
+		counter.count++
+		heap.Fix(&s.heap, counter.index)
+		return
+	}
+	if len(s.heap) < s.capacity {
+		counter := &spaceSavingCounter{queryText: queryText, count: 1}
+		s.counters[queryText] = counter
+		heap.Push(&s.heap, counter)
+		return
+	}
+
+	evicted := s.heap[0]
+	delete(s.counters, evicted.queryText)
+	evicted.queryText = queryText
+	evicted.error = evicted.count
+	evicted.count++
+	s.counters[queryText] = evicted
+	heap.Fix(&s.heap, 0)
+}
+
+// untrackedBound is the most times a query the sketch does not track may have occurred.
+func (s *spaceSaving) untrackedBound() int64 {
+	bound := s.floor
+	if len(s.heap) == s.capacity && len(s.heap) > 0 && s.heap[0].count > bound {
+		bound = s.heap[0].count
+	}
+	return bound
+}
+
+// top returns the limit most counted queries, most counted first.
+func (s *spaceSaving) top(limit int) []models.HeavyHitter {
+	counters := s.sortedCounters()
+	if len(counters) > limit {
+		counters = counters[:limit]
+	}
+	hitters := make([]models.HeavyHitter, 0, len(counters))
+	for _, counter := range counters {
+		hitters = append(hitters, models.HeavyHitter{
+			QueryText: counter.queryText,
+			Count:     counter.count,
+			MinCount:  counter.count - counter.error,
+			Error:     counter.error,
+		})
+	}
+	return hitters
+}
+
+func (s *spaceSaving) sortedCounters() []*spaceSavingCounter {
+	counters := make([]*spaceSavingCounter, 0, len(s.counters))
+	for _, counter := range s.counters {
+		counters = append(counters, counter)
+	}
+	sortSpaceSavingCounters(counters)
+	return counters
+}
+
+func (s *spaceSaving) snapshot() *models.SketchSnapshot {
+	snapshot := &models.SketchSnapshot{Capacity: s.capacity, Total: s.total, Counters: make([]models.SketchCounter, 0, len(s.heap))}
+	for _, counter := range s.heap {
+		snapshot.Counters = append(snapshot.Counters, models.SketchCounter{QueryText: counter.queryText, Count: counter.count, Error: counter.error})
+	}
+	return snapshot
+}
+
+func spaceSavingFromSnapshot(snapshot *models.SketchSnapshot) *spaceSaving {
+	s := newSpaceSaving(max(snapshot.Capacity, len(snapshot.Counters)))
+	s.total = snapshot.Total
+	for _, c := range snapshot.Counters {
+		counter := &spaceSavingCounter{queryText: c.QueryText, count: c.Count, error: c.Error, index: len(s.heap)}
+		s.counters[c.QueryText] = counter
+		s.heap = append(s.heap, counter)
+	}
+	heap.Init(&s.heap)
+	return s
+}
+
+// mergeSpaceSaving combines sketches fed with disjoint streams into a sketch of capacity queries. A sketch that does
+// not track a query contributes its untrackedBound to both the query's count and error, so the merged counts keep
+// bounding the true ones.
+func mergeSpaceSaving(capacity int, sketches ...*spaceSaving) *spaceSaving {
+	merged := newSpaceSaving(capacity)
+	var boundsSum int64
+	trackedBounds := make(map[string]int64)
+	union := make(map[string]*spaceSavingCounter)
+	for _, sketch := range sketches {
+		bound := sketch.untrackedBound()
+		boundsSum += bound
+		merged.total += sketch.total
+		for queryText, counter := range sketch.counters {
+			mergedCounter, ok := union[queryText]
+			if !ok {
+				mergedCounter = &spaceSavingCounter{queryText: queryText}
+				union[queryText] = mergedCounter
+			}
+			mergedCounter.count += counter.count
+			mergedCounter.error += counter.error
+			trackedBounds[queryText] += bound
+		}
+	}
+	for queryText, counter := range union {
+		missing := boundsSum - trackedBounds[queryText]
+		counter.count += missing
+		counter.error += missing
+	}
+
+	counters := make([]*spaceSavingCounter, 0, len(union))
+	for _, counter := range union {
+		counters = append(counters, counter)
+	}
+	sortSpaceSavingCounters(counters)
+	merged.floor = boundsSum
+	if len(counters) > capacity {
+		// A dropped query occurred at most as many times as its merged count.
+		merged.floor = max(merged.floor, counters[capacity].count)
+		counters = counters[:capacity]
+	}
+	for _, counter := range counters {
+		counter.index = len(merged.heap)
+		merged.counters[counter.queryText] = counter
+		merged.heap = append(merged.heap, counter)
+	}
+	heap.Init(&merged.heap)
+	return merged
+}
+
+// sortSpaceSavingCounters orders counters by count, most counted first, then by query.
+func sortSpaceSavingCounters(counters []*spaceSavingCounter) {
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].count != counters[j].count {
+			return counters[i].count > counters[j].count
+		}
+		return counters[i].queryText < counters[j].queryText
+	})
+}
+
+// spaceSavingHeap is a min-heap of counters by count, so the least counted query is found in constant time.
+type spaceSavingHeap []*spaceSavingCounter
+
+func (h spaceSavingHeap) Len() int           { return len(h) }
+func (h spaceSavingHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h spaceSavingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *spaceSavingHeap) Push(x any) {
+	counter := x.(*spaceSavingCounter)
+	counter.index = len(*h)
+	*h = append(*h, counter)
+}
+
+func (h *spaceSavingHeap) Pop() any {
+	old := *h
+	counter := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return counter
+}
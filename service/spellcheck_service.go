@@ -0,0 +1,230 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log/slog"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/tenant"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// SpellcheckConfig tunes the spelling dictionary. MinCount is the number of searches a query needs to be suggested,
+// which keeps one-off typos out of the dictionary. MaxCachedTerms bounds how many terms the dictionaries kept in
+// memory hold across tenants, since tenants are named by clients, and is unbounded when zero.
+type SpellcheckConfig struct {
+	MaxEditDistance int
+	MinCount        int
+	MaxTerms        int
+	MaxCachedTerms  int
+	RebuildInterval time.Duration
+}
+
+// SpellcheckConfigFromEnv builds a SpellcheckConfig from the SPELLCHECK_* environment variables.
+func SpellcheckConfigFromEnv() SpellcheckConfig {
+	return SpellcheckConfig{
+		MaxEditDistance: config.GetSpellcheckMaxEditDistance(),
+		MinCount:        config.GetSpellcheckMinCount(),
+		MaxTerms:        config.GetSpellcheckMaxTerms(),
+		MaxCachedTerms:  config.GetSpellcheckMaxCachedTerms(),
+		RebuildInterval: config.GetSpellcheckRebuildInterval(),
+	}
+}
+
+type SpellcheckService interface {
+	// Suggest returns logged queries close to queryText, closest first, then most searched first.
+	Suggest(ctx context.Context, queryText string, limit int) ([]models.SpellingSuggestion, error)
+	// Rebuild reloads the dictionary of the tenant on the context from search_logs.
+	Rebuild(ctx context.Context) error
+	// Stop interrupts the background rebuilds and waits for them to return until ctx is done. Stale dictionaries are
+	// no longer rebuilt in the background afterwards.
+	Stop(ctx context.Context) error
+}
+
+type spellcheckService struct {
+	repo   database.SearchLogRepository
+	cfg    SpellcheckConfig
+	logger *slog.Logger
+
+	builds *singleflight.Group
+	mu     *sync.Mutex
+	// indexes holds the elements of recent, whose front is the most recently used dictionary.
+	indexes map[string]*list.Element
+	recent  *list.List
+	// cachedTerms counts the terms of the dictionaries in recent.
+	cachedTerms *int
+
+	rebuilds *sync.WaitGroup
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+type tenantSpellingIndex struct {
+	tenantID   string
+	index      *spellingIndex
+	builtAt    time.Time
+	rebuilding bool
+}
+
+func NewSpellcheckService(repo database.SearchLogRepository, cfg SpellcheckConfig, logger *slog.Logger) SpellcheckService {
+	return &spellcheckService{
+		repo:    repo,
+		cfg:     cfg,
+		logger:  logger,
+		builds:  &singleflight.Group{},
+		mu:      &sync.Mutex{},
+		indexes: make(map[string]*list.Element),
+		recent:  list.New(),
+
+		cachedTerms: new(int),
+
+		rebuilds: &sync.WaitGroup{},
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+	}
+}
+
+// Suggest builds the tenant's dictionary on first use, once for the concurrent callers. Once it is older than the
+// rebuild interval it keeps serving it while a single background rebuild replaces it. The least recently used
+// dictionaries are dropped once the kept ones hold more than MaxCachedTerms terms.
+func (ss spellcheckService) Suggest(ctx context.Context, queryText string, limit int) ([]models.SpellingSuggestion, error) {
+	queryText = strings.ToLower(strings.TrimSpace(queryText))
+	if queryText == "" {
+		return nil, errors.New("query text cannot be empty")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	var index *spellingIndex
+	ss.mu.Lock()
+	element, ok := ss.indexes[tenantID]
+	if ok {
+		ss.recent.MoveToFront(element)
+		current := element.Value.(*tenantSpellingIndex)
+		index = current.index
+		if !current.rebuilding && !ss.isStopped() && time.Since(current.builtAt) >= ss.cfg.RebuildInterval {
+			current.rebuilding = true
+			ss.rebuilds.Add(1)
+			go ss.rebuildInBackground(ctx, tenantID)
+		}
+	}
+	ss.mu.Unlock()
+
+	if !ok {
+		var err error
+		if index, err = ss.rebuild(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return index.Suggest(queryText, limit), nil
+}
+
+func (ss spellcheckService) rebuildInBackground(ctx context.Context, tenantID string) {
+	defer ss.rebuilds.Done()
+	rebuildCtx, cancel := context.WithCancel(detachContext(ctx))
+	defer cancel()
+	go func() {
+		select {
+		case <-ss.stop:
+			cancel()
+		case <-rebuildCtx.Done():
+		}
+	}()
+	if err := ss.Rebuild(rebuildCtx); err != nil && !errors.Is(err, context.Canceled) {
+		ss.logger.ErrorContext(ctx, "Error rebuilding spelling index", "error", err, "tenantID", tenantID)
+	}
+}
+
+func (ss spellcheckService) isStopped() bool {
+	select {
+	case <-ss.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ss spellcheckService) Stop(ctx context.Context) error {
+	ss.mu.Lock()
+	ss.stopOnce.Do(func() { close(ss.stop) })
+	ss.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ss.rebuilds.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ss spellcheckService) Rebuild(ctx context.Context) error {
+	_, err := ss.rebuild(ctx)
+	return err
+}
+
+// rebuild loads the dictionary of the tenant on ctx, sharing the load with the concurrent callers for that tenant.
+func (ss spellcheckService) rebuild(ctx context.Context) (*spellingIndex, error) {
+	tenantID := tenant.FromContext(ctx)
+	index, err, _ := ss.builds.Do(tenantID, func() (interface{}, error) {
+		return ss.build(ctx, tenantID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index.(*spellingIndex), nil
+}
+
+func (ss spellcheckService) build(ctx context.Context, tenantID string) (*spellingIndex, error) {
+	searchLogs, err := ss.repo.ListQueryCounts(ctx, ss.cfg.MinCount, ss.cfg.MaxTerms)
+	if err != nil {
+		ss.mu.Lock()
+		if element, ok := ss.indexes[tenantID]; ok {
+			element.Value.(*tenantSpellingIndex).rebuilding = false
+		}
+		ss.mu.Unlock()
+		return nil, err
+	}
+
+	start := time.Now()
+	index := newSpellingIndex(ss.cfg.MaxEditDistance, searchLogs)
+	ss.logger.DebugContext(ctx, "Rebuilt spelling index", "tenantID", tenantID, "terms", index.Len(), "duration", time.Since(start))
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	current := &tenantSpellingIndex{tenantID: tenantID, index: index, builtAt: time.Now()}
+	if element, ok := ss.indexes[tenantID]; ok {
+		*ss.cachedTerms -= element.Value.(*tenantSpellingIndex).cachedTerms()
+		element.Value = current
+		ss.recent.MoveToFront(element)
+	} else {
+		ss.indexes[tenantID] = ss.recent.PushFront(current)
+	}
+	*ss.cachedTerms += current.cachedTerms()
+	// The dictionary just built is kept even if it is larger than the bound on its own.
+	for ss.cfg.MaxCachedTerms > 0 && *ss.cachedTerms > ss.cfg.MaxCachedTerms && ss.recent.Len() > 1 {
+		oldest := ss.recent.Remove(ss.recent.Back()).(*tenantSpellingIndex)
+		delete(ss.indexes, oldest.tenantID)
+		*ss.cachedTerms -= oldest.cachedTerms()
+	}
+	return index, nil
+}
+
+// cachedTerms counts an empty dictionary as one term, so that the bound also limits how many tenants are kept.
+func (tsi *tenantSpellingIndex) cachedTerms() int {
+	return max(tsi.index.Len(), 1)
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"search-logger/tenant"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"laptop", "laptop", 0},
+		{"laptop", "laptops", 1},
+		{"laptop", "lapto", 1},
+		{"laptop", "lapdop", 1},
+		{"laptop", "lpatop", 1},
+		{"laptop", "lpatpo", 2},
+		{"", "ab", 2},
+		{"café", "cafe", 1},
+		{"laptop", "monitor", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, editDistance([]rune(tt.a), []rune(tt.b), 2))
+		})
+	}
+}
+
+func TestSpellcheckService_Suggest(t *testing.T) {
+	// ARRANGE
+	repo := setupTestDatabase(t)
+	ctx := context.Background()
+	counts := map[string]int{
+		"laptop":                       5,
+		"laptops":                      3,
+		"lapdog":                       2,
+		"wireless mechanical keyboard": 4,
+		"laptpo":                       1,
+	}
+	for queryText, count := range counts {
+		for i := 0; i < count; i++ {
+			_, err := repo.IncrementSearchLog(ctx, queryText)
+			assert.NoError(t, err)
+		}
+	}
+	cfg := SpellcheckConfig{MaxEditDistance: 2, MinCount: 2, MaxTerms: 100, RebuildInterval: time.Hour}
+	spellcheck := NewSpellcheckService(repo, cfg, slog.Default())
+
+	t.Run("Suggestions are ranked by distance, then count", func(t *testing.T) {
+		// ACT
+		suggestions, err := spellcheck.Suggest(ctx, " Lpatop ", 10)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, []models.SpellingSuggestion{
+			{QueryText: "laptop", Distance: 1, Count: 5},
+			{QueryText: "laptops", Distance: 2, Count: 3},
+		}, suggestions)
+	})
+
+	t.Run("Queries below the minimum count are not suggested", func(t *testing.T) {
+		suggestions, err := spellcheck.Suggest(ctx, "laptpo", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, "laptop", suggestions[0].QueryText)
+		for _, suggestion := range suggestions {
+			assert.NotEqual(t, "laptpo", suggestion.QueryText)
+		}
+	})
+
+	t.Run("Long queries are corrected past the indexed prefix", func(t *testing.T) {
+		suggestions, err := spellcheck.Suggest(ctx, "wirelss mechanical keybaord", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.SpellingSuggestion{
+			{QueryText: "wireless mechanical keyboard", Distance: 2, Count: 4},
+		}, suggestions)
+	})
+
+	t.Run("Limit suggestions", func(t *testing.T) {
+		suggestions, err := spellcheck.Suggest(ctx, "laptop", 1)
+		assert.NoError(t, err)
+		assert.Len(t, suggestions, 1)
+		assert.Equal(t, 0, suggestions[0].Distance)
+	})
+
+	t.Run("Nothing close enough", func(t *testing.T) {
+		suggestions, err := spellcheck.Suggest(ctx, "monitor", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, suggestions)
+	})
+
+	t.Run("Dictionary only changes after a rebuild", func(t *testing.T) {
+		// ARRANGE
+		for i := 0; i < 2; i++ {
+			_, err := repo.IncrementSearchLog(ctx, "monitors")
+			assert.NoError(t, err)
+		}
+
+		// ACT
+		before, err := spellcheck.Suggest(ctx, "monitor", 10)
+		assert.NoError(t, err)
+		assert.NoError(t, spellcheck.Rebuild(ctx))
+		after, err := spellcheck.Suggest(ctx, "monitor", 10)
+		assert.NoError(t, err)
+
+		// ASSERT
+		assert.Empty(t, before)
+		assert.Equal(t, []models.SpellingSuggestion{{QueryText: "monitors", Distance: 1, Count: 2}}, after)
+	})
+
+	t.Run("Stale dictionaries are rebuilt in the background", func(t *testing.T) {
+		// ARRANGE
+		stale := NewSpellcheckService(repo, SpellcheckConfig{MaxEditDistance: 2, MinCount: 2, MaxTerms: 100}, slog.Default())
+		_, err := stale.Suggest(ctx, "keyboard", 10)
+		assert.NoError(t, err)
+		for i := 0; i < 2; i++ {
+			_, err := repo.IncrementSearchLog(ctx, "keyboards")
+			assert.NoError(t, err)
+		}
+
+		// ACT & ASSERT
+		assert.Eventually(t, func() bool {
+			suggestions, err := stale.Suggest(ctx, "keyboard", 10)
+			return err == nil && len(suggestions) == 1 && suggestions[0].QueryText == "keyboards"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Dictionaries are scoped to tenants", func(t *testing.T) {
+		suggestions, err := spellcheck.Suggest(tenant.WithTenant(ctx, "acme"), "lpatop", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, suggestions)
+	})
+
+	t.Run("Reject empty queries", func(t *testing.T) {
+		_, err := spellcheck.Suggest(ctx, "  ", 10)
+		assert.Error(t, err)
+	})
+
+	t.Run("Concurrent requests build a cold dictionary once", func(t *testing.T) {
+		// ARRANGE
+		slow := &slowSearchLogRepository{SearchLogRepository: repo, release: make(chan struct{})}
+		cold := NewSpellcheckService(slow, cfg, slog.Default())
+		var wg sync.WaitGroup
+
+		// ACT
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				suggestions, err := cold.Suggest(ctx, "laptpo", 1)
+				assert.NoError(t, err)
+				assert.Len(t, suggestions, 1)
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(slow.release)
+		wg.Wait()
+
+		// ASSERT
+		assert.Equal(t, int32(1), slow.loads.Load())
+	})
+
+	t.Run("Least recently used dictionaries are dropped past the cached terms", func(t *testing.T) {
+		// ARRANGE
+		counting := &loadCountingSearchLogRepository{SearchLogRepository: repo, loads: make(map[string]int)}
+		bounded := NewSpellcheckService(counting, SpellcheckConfig{MaxEditDistance: 2, MinCount: 2, MaxTerms: 100, MaxCachedTerms: 2, RebuildInterval: time.Hour}, slog.Default())
+
+		// ACT
+		for _, tenantID := range []string{"acme", "globex", "acme", "initech", "acme", "globex"} {
+			_, err := bounded.Suggest(tenant.WithTenant(ctx, tenantID), "laptop", 10)
+			assert.NoError(t, err)
+		}
+
+		// ASSERT
+		assert.Equal(t, map[string]int{"acme": 1, "globex": 2, "initech": 1}, counting.loads)
+		assert.Len(t, bounded.(*spellcheckService).indexes, 2)
+	})
+}
+
+// loadCountingSearchLogRepository counts the dictionaries loaded per tenant.
+type loadCountingSearchLogRepository struct {
+	database.SearchLogRepository
+	loads map[string]int
+}
+
+func (r *loadCountingSearchLogRepository) ListQueryCounts(ctx context.Context, minCount, limit int) ([]models.SearchLog, error) {
+	r.loads[tenant.FromContext(ctx)]++
+	return r.SearchLogRepository.ListQueryCounts(ctx, minCount, limit)
+}
+
+// slowSearchLogRepository counts the dictionaries loaded, each once release is closed.
+type slowSearchLogRepository struct {
+	database.SearchLogRepository
+	loads   atomic.Int32
+	release chan struct{}
+}
+
+func (r *slowSearchLogRepository) ListQueryCounts(ctx context.Context, minCount, limit int) ([]models.SearchLog, error) {
+	r.loads.Add(1)
+	<-r.release
+	return r.SearchLogRepository.ListQueryCounts(ctx, minCount, limit)
+}
+
+// blockingSearchLogRepository loads the first dictionary, then blocks later loads until their context is canceled.
+type blockingSearchLogRepository struct {
+	database.SearchLogRepository
+	loads   int
+	blocked chan struct{}
+}
+
+func (r *blockingSearchLogRepository) ListQueryCounts(ctx context.Context, minCount, limit int) ([]models.SearchLog, error) {
+	r.loads++
+	if r.loads == 1 {
+		return nil, nil
+	}
+	close(r.blocked)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSpellcheckService_Stop(t *testing.T) {
+	// ARRANGE
+	repo := &blockingSearchLogRepository{blocked: make(chan struct{})}
+	spellcheck := NewSpellcheckService(repo, SpellcheckConfig{MaxEditDistance: 2, MinCount: 2, MaxTerms: 100}, slog.Default())
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := spellcheck.Suggest(ctx, "laptop", 10)
+		assert.NoError(t, err)
+	}
+	<-repo.blocked
+
+	// ACT
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	err := spellcheck.Stop(stopCtx)
+
+	// ASSERT
+	assert.NoError(t, err, "the background rebuild was interrupted")
+	_, err = spellcheck.Suggest(ctx, "laptop", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, repo.loads, "stale dictionaries are no longer rebuilt")
+}
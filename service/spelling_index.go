@@ -0,0 +1,157 @@
+package service
+
+import (
+	"search-logger/models"
+	"sort"
+)
+
+// spellingPrefixLength bounds the deletes generated per term: only the first runes of a term are indexed and
+// candidates are verified against the full term, which keeps the index small for long queries.
+const spellingPrefixLength = 7
+
+// spellingIndex is a SymSpell style index: every term is stored under each string obtainable by deleting up to
+// maxDistance runes from its prefix, so the terms close to an input are found by generating the input's deletes
+// instead of comparing it against the whole dictionary.
+type spellingIndex struct {
+	maxDistance int
+	terms       [][]rune
+	counts      []int
+	deletes     map[string][]int
+}
+
+func newSpellingIndex(maxDistance int, searchLogs []models.SearchLog) *spellingIndex {
+	index := &spellingIndex{
+		maxDistance: maxDistance,
+		terms:       make([][]rune, 0, len(searchLogs)),
+		counts:      make([]int, 0, len(searchLogs)),
+		deletes:     make(map[string][]int),
+	}
+	for _, searchLog := range searchLogs {
+		if searchLog.QueryText == "" {
+			continue
+		}
+		termIndex := len(index.terms)
+		index.terms = append(index.terms, []rune(searchLog.QueryText))
+		index.counts = append(index.counts, searchLog.Count)
+		for deleted := range spellingDeletes(spellingPrefix(searchLog.QueryText), maxDistance) {
+			index.deletes[deleted] = append(index.deletes[deleted], termIndex)
+		}
+	}
+	return index
+}
+
+func (index *spellingIndex) Len() int {
+	return len(index.terms)
+}
+
+// Suggest returns the terms within the index's max distance of queryText, closest first, then most searched first.
+func (index *spellingIndex) Suggest(queryText string, limit int) []models.SpellingSuggestion {
+	input := []rune(queryText)
+	seen := make(map[int]bool)
+	suggestions := make([]models.SpellingSuggestion, 0)
+	for deleted := range spellingDeletes(spellingPrefix(queryText), index.maxDistance) {
+		for _, termIndex := range index.deletes[deleted] {
+			if seen[termIndex] {
+				continue
+			}
+			seen[termIndex] = true
+
+			term := index.terms[termIndex]
+			if abs(len(term)-len(input)) > index.maxDistance {
+				continue
+			}
+			distance := editDistance(input, term, index.maxDistance)
+			if distance > index.maxDistance {
+				continue
+			}
+			suggestions = append(suggestions, models.SpellingSuggestion{
+				QueryText: string(term),
+				Distance:  distance,
+				Count:     index.counts[termIndex],
+			})
+		}
+	}
+
+	sort.Slice(suggestions, func(a, b int) bool {
+		if suggestions[a].Distance != suggestions[b].Distance {
+			return suggestions[a].Distance < suggestions[b].Distance
+		}
+		if suggestions[a].Count != suggestions[b].Count {
+			return suggestions[a].Count > suggestions[b].Count
+		}
+		return suggestions[a].QueryText < suggestions[b].QueryText
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+func spellingPrefix(term string) string {
+	runes := []rune(term)
+	if len(runes) > spellingPrefixLength {
+		runes = runes[:spellingPrefixLength]
+	}
+	return string(runes)
+}
+
+// spellingDeletes returns term and every string obtainable by deleting up to maxDistance of its runes.
+func spellingDeletes(term string, maxDistance int) map[string]bool {
+	deletes := map[string]bool{term: true}
+	frontier := []string{term}
+	for distance := 0; distance < maxDistance; distance++ {
+		var next []string
+		for _, candidate := range frontier {
+			runes := []rune(candidate)
+			for i := range runes {
+				deleted := string(runes[:i]) + string(runes[i+1:])
+				if !deletes[deleted] {
+					deletes[deleted] = true
+					next = append(next, deleted)
+				}
+			}
+		}
+		frontier = next
+	}
+	return deletes
+}
+
+// editDistance is the optimal string alignment distance between a and b: the number of insertions, deletions,
+// substitutions and adjacent transpositions needed to turn one into the other. It returns maxDistance+1 as soon as
+// the distance is known to exceed maxDistance.
+func editDistance(a, b []rune, maxDistance int) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	beforePrevious := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > maxDistance {
+			return maxDistance + 1
+		}
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+	return min(previous[len(b)], maxDistance+1)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
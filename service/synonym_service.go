@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"strings"
+)
+
+// SynonymService counts variants of a query, e.g. "tvs", as their canonical query, e.g. "tv".
+type SynonymService interface {
+	PersistFilter
+	// Canonicalize returns the canonical query of queryText, or queryText when it is not a known variant.
+	Canonicalize(ctx context.Context, queryText string) (string, error)
+}
+
+type synonymService struct {
+	repo   database.SynonymRepository
+	logger *slog.Logger
+}
+
+func NewSynonymService(repo database.SynonymRepository, logger *slog.Logger) SynonymService {
+	return &synonymService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (ss synonymService) BeforePersist(ctx context.Context, clientIdentifier, queryText string) (string, bool) {
+	canonical, err := ss.Canonicalize(ctx, queryText)
+	if err != nil {
+		// Counting the variant is better than losing the search.
+		ss.logger.ErrorContext(ctx, "Error looking up synonym", "error", err, "clientIdentifier", clientIdentifier, "query", queryText)
+		return queryText, true
+	}
+	return canonical, true
+}
+
+func (ss synonymService) Canonicalize(ctx context.Context, queryText string) (string, error) {
+	synonym, err := ss.repo.Get(ctx, queryText)
+	if err != nil {
+		return "", err
+	}
+	if synonym == nil {
+		return queryText, nil
+	}
+	return synonym.Canonical, nil
+}
+
+// ParseSynonyms reads mappings in the Solr synonyms format. "tvs, televisions => tv" maps each variant on the left
+// to the canonical query on the right, and "tv, tvs, televisions" maps every term to the first one. Blank lines and
+// lines starting with # are ignored.
+func ParseSynonyms(r io.Reader) ([]models.QuerySynonym, error) {
+	var synonyms []models.QuerySynonym
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var variants []string
+		var canonical string
+		if left, right, ok := strings.Cut(line, "=>"); ok {
+			variants = splitSynonymTerms(left)
+			canonical = strings.TrimSpace(right)
+			if strings.Contains(canonical, ",") {
+				return nil, fmt.Errorf("line %d: expected a single canonical query after =>", lineNumber)
+			}
+		} else {
+			terms := splitSynonymTerms(line)
+			if len(terms) > 0 {
+				canonical, variants = terms[0], terms[1:]
+			}
+		}
+		if canonical == "" || len(variants) == 0 {
+			return nil, fmt.Errorf("line %d: expected at least one variant and a canonical query", lineNumber)
+		}
+
+		for _, variant := range variants {
+			synonyms = append(synonyms, models.QuerySynonym{Variant: variant, Canonical: canonical})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return synonyms, nil
+}
+
+func splitSynonymTerms(s string) []string {
+	var terms []string
+	for _, term := range strings.Split(s, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
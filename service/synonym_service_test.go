@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingSynonymRepository struct {
+	database.SynonymRepository
+}
+
+func (failingSynonymRepository) Get(context.Context, string) (*models.QuerySynonym, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestSynonymService_BeforePersist(t *testing.T) {
+	db := setupTestDB(t)
+	repo := database.NewSynonymDatabaseRepository(db)
+	ctx := context.Background()
+	_, err := repo.Upsert(ctx, "tvs", "tv")
+	assert.NoError(t, err)
+
+	t.Run("Variants are rewritten to their canonical query", func(t *testing.T) {
+		queryText, ok := NewSynonymService(repo, slog.Default()).BeforePersist(ctx, "client", "tvs")
+		assert.True(t, ok)
+		assert.Equal(t, "tv", queryText)
+	})
+
+	t.Run("Other queries are kept", func(t *testing.T) {
+		queryText, ok := NewSynonymService(repo, slog.Default()).BeforePersist(ctx, "client", "laptop")
+		assert.True(t, ok)
+		assert.Equal(t, "laptop", queryText)
+	})
+
+	t.Run("Lookup errors keep the original query", func(t *testing.T) {
+		queryText, ok := NewSynonymService(failingSynonymRepository{}, slog.Default()).BeforePersist(ctx, "client", "tvs")
+		assert.True(t, ok)
+		assert.Equal(t, "tvs", queryText)
+	})
+}
+
+func TestParseSynonyms(t *testing.T) {
+	t.Run("Parse explicit mappings and equivalence lists", func(t *testing.T) {
+		synonyms, err := ParseSynonyms(strings.NewReader("# TVs\ntvs, televisions => tv\n\nlaptop,notebook\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, []models.QuerySynonym{
+			{Variant: "tvs", Canonical: "tv"},
+			{Variant: "televisions", Canonical: "tv"},
+			{Variant: "notebook", Canonical: "laptop"},
+		}, synonyms)
+	})
+
+	t.Run("Reject lines without a variant", func(t *testing.T) {
+		_, err := ParseSynonyms(strings.NewReader("tv\n"))
+		assert.EqualError(t, err, "line 1: expected at least one variant and a canonical query")
+	})
+
+	t.Run("Reject several canonical queries", func(t *testing.T) {
+		_, err := ParseSynonyms(strings.NewReader("# comment\ntvs => tv, television\n"))
+		assert.EqualError(t, err, "line 2: expected a single canonical query after =>")
+	})
+}
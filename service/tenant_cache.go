@@ -0,0 +1,120 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"search-logger/tenant"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// maxCachedTenants bounds how many tenants a tenantCache keeps, since tenants are named by clients.
+const maxCachedTenants = 10000
+
+// tenantCache keeps a value per tenant, such as its rules, loaded from the database. A value is loaded on first use
+// and reloaded once it is older than the refresh interval, by a single caller per tenant while the others wait for it.
+// If reloading fails, the previous value keeps being used. The least recently used value is dropped once more than
+// maxTenants are kept.
+type tenantCache[T any] struct {
+	load            func(ctx context.Context) (T, error)
+	refreshInterval time.Duration
+	maxTenants      int
+
+	loads *singleflight.Group
+	mu    *sync.Mutex
+	// entries holds the elements of recent, whose front is the most recently used value.
+	entries map[string]*list.Element
+	recent  *list.List
+	// invalidations counts the calls to Invalidate, so that a load started before one is not cached.
+	invalidations uint64
+}
+
+type tenantCacheEntry[T any] struct {
+	tenantID string
+	value    T
+	loadedAt time.Time
+}
+
+func newTenantCache[T any](load func(ctx context.Context) (T, error), refreshInterval time.Duration, maxTenants int) *tenantCache[T] {
+	return &tenantCache[T]{
+		load:            load,
+		refreshInterval: refreshInterval,
+		maxTenants:      maxTenants,
+		loads:           &singleflight.Group{},
+		mu:              &sync.Mutex{},
+		entries:         make(map[string]*list.Element),
+		recent:          list.New(),
+	}
+}
+
+// Get returns the value of the tenant on ctx. stale is true when reloading it failed and the previous value was
+// returned along with the error.
+func (tc *tenantCache[T]) Get(ctx context.Context) (value T, stale bool, err error) {
+	tenantID := tenant.FromContext(ctx)
+	tc.mu.Lock()
+	element, ok := tc.entries[tenantID]
+	var current *tenantCacheEntry[T]
+	if ok {
+		tc.recent.MoveToFront(element)
+		current = element.Value.(*tenantCacheEntry[T])
+	}
+	tc.mu.Unlock()
+	if ok && time.Since(current.loadedAt) < tc.refreshInterval {
+		return current.value, false, nil
+	}
+
+	loaded, err, _ := tc.loads.Do(tenantID, func() (interface{}, error) {
+		return tc.reload(ctx, tenantID)
+	})
+	if err != nil {
+		if ok {
+			return current.value, true, err
+		}
+		return value, false, err
+	}
+	return loaded.(T), false, nil
+}
+
+func (tc *tenantCache[T]) reload(ctx context.Context, tenantID string) (T, error) {
+	tc.mu.Lock()
+	invalidations := tc.invalidations
+	tc.mu.Unlock()
+
+	value, err := tc.load(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.invalidations != invalidations {
+		return value, nil
+	}
+	current := &tenantCacheEntry[T]{tenantID: tenantID, value: value, loadedAt: time.Now()}
+	if element, ok := tc.entries[tenantID]; ok {
+		element.Value = current
+		tc.recent.MoveToFront(element)
+		return value, nil
+	}
+	tc.entries[tenantID] = tc.recent.PushFront(current)
+	for tc.maxTenants > 0 && tc.recent.Len() > tc.maxTenants {
+		oldest := tc.recent.Remove(tc.recent.Back()).(*tenantCacheEntry[T])
+		delete(tc.entries, oldest.tenantID)
+	}
+	return value, nil
+}
+
+// Invalidate makes the next Get of the tenant on ctx load its value again.
+func (tc *tenantCache[T]) Invalidate(ctx context.Context) {
+	tenantID := tenant.FromContext(ctx)
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.invalidations++
+	if element, ok := tc.entries[tenantID]; ok {
+		tc.recent.Remove(element)
+		delete(tc.entries, tenantID)
+	}
+	tc.loads.Forget(tenantID)
+}
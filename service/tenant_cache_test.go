@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"search-logger/tenant"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantCache(t *testing.T) {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	t.Run("Concurrent misses load once", func(t *testing.T) {
+		// ARRANGE
+		var loads atomic.Int32
+		release := make(chan struct{})
+		cache := newTenantCache(func(ctx context.Context) (string, error) {
+			loads.Add(1)
+			<-release
+			return tenant.FromContext(ctx), nil
+		}, time.Minute, 0)
+
+		// ACT
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, _, err := cache.Get(acme)
+				assert.NoError(t, err)
+				assert.Equal(t, "acme", value)
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		_, _, err := cache.Get(acme)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), loads.Load())
+	})
+
+	t.Run("Values are reloaded once stale or invalidated", func(t *testing.T) {
+		// ARRANGE
+		var loads atomic.Int32
+		cache := newTenantCache(func(ctx context.Context) (int32, error) {
+			return loads.Add(1), nil
+		}, time.Minute, 0)
+		first, _, _ := cache.Get(acme)
+
+		// ACT
+		cached, _, _ := cache.Get(acme)
+		cache.Invalidate(acme)
+		invalidated, _, _ := cache.Get(acme)
+		cache.refreshInterval = 0
+		stale, _, _ := cache.Get(acme)
+
+		// ASSERT
+		assert.Equal(t, int32(1), first)
+		assert.Equal(t, int32(1), cached)
+		assert.Equal(t, int32(2), invalidated)
+		assert.Equal(t, int32(3), stale)
+	})
+
+	t.Run("The previous value is kept when reloading fails", func(t *testing.T) {
+		// ARRANGE
+		failing := false
+		cache := newTenantCache(func(ctx context.Context) (string, error) {
+			if failing {
+				return "", errors.New("database is down")
+			}
+			return "rules", nil
+		}, 0, 0)
+		_, _, err := cache.Get(acme)
+		assert.NoError(t, err)
+		failing = true
+
+		// ACT
+		value, stale, err := cache.Get(acme)
+		_, globexStale, globexErr := cache.Get(globex)
+
+		// ASSERT
+		assert.Error(t, err)
+		assert.True(t, stale)
+		assert.Equal(t, "rules", value)
+		assert.Error(t, globexErr)
+		assert.False(t, globexStale)
+	})
+
+	t.Run("The least recently used tenant is dropped", func(t *testing.T) {
+		// ARRANGE
+		cache := newTenantCache(func(ctx context.Context) (string, error) {
+			return tenant.FromContext(ctx), nil
+		}, time.Minute, 2)
+
+		// ACT
+		for _, id := range []string{"a", "b", "a", "c"} {
+			_, _, err := cache.Get(tenant.WithTenant(context.Background(), id))
+			assert.NoError(t, err)
+		}
+
+		// ASSERT
+		assert.Len(t, cache.entries, 2)
+		assert.Contains(t, cache.entries, "a")
+		assert.Contains(t, cache.entries, "c")
+	})
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"strings"
+	"time"
+)
+
+// maxTimeSeriesPoints bounds the buckets of a time series, e.g. a bit over a year by hour.
+const maxTimeSeriesPoints = 10000
+
+var (
+	ErrInvalidGranularity = errors.New("granularity must be hour, day or week")
+	ErrTooManyPoints      = fmt.Errorf("time series cannot have more than %d points", maxTimeSeriesPoints)
+)
+
+type TimeSeriesService interface {
+	// QueryTimeSeries counts queryText in each bucket of granularity in loc, from the bucket containing "from" through
+	// the one containing "to", including empty buckets. Buckets are rolled up from hourly counts, so in time zones
+	// whose offset is not a whole number of hours, each UTC hour is counted in the bucket its start falls in. The series
+	// carries the query's distinct clients, and so do the points of daily series that are UTC days.
+	QueryTimeSeries(ctx context.Context, queryText string, granularity models.TimeSeriesGranularity, from, to time.Time, loc *time.Location) (*models.QueryTimeSeries, error)
+}
+
+type timeSeriesService struct {
+	db database.SearchLogRepository
+}
+
+func NewTimeSeriesService(db database.SearchLogRepository) TimeSeriesService {
+	return &timeSeriesService{db: db}
+}
+
+func (tss timeSeriesService) QueryTimeSeries(ctx context.Context, queryText string, granularity models.TimeSeriesGranularity, from, to time.Time, loc *time.Location) (*models.QueryTimeSeries, error) {
+	queryText = strings.ToLower(strings.TrimSpace(queryText))
+	if queryText == "" {
+		return nil, errors.New("query text cannot be empty")
+	}
+	if !granularity.IsValid() {
+		return nil, ErrInvalidGranularity
+	}
+	if from.After(to) {
+		return nil, errors.New("from must not be after to")
+	}
+
+	series := &models.QueryTimeSeries{
+		QueryText:   queryText,
+		Granularity: granularity,
+		Timezone:    loc.String(),
+		From:        from.In(loc),
+		To:          to.In(loc),
+		Points:      []models.TimeSeriesPoint{},
+	}
+	first, last := bucketStart(from.In(loc), granularity), bucketStart(to.In(loc), granularity)
+	pointIndex := make(map[int64]int)
+	for start := first; !start.After(last); start = nextBucket(start, granularity) {
+		if len(series.Points) == maxTimeSeriesPoints {
+			return nil, ErrTooManyPoints
+		}
+		pointIndex[start.Unix()] = len(series.Points)
+		series.Points = append(series.Points, models.TimeSeriesPoint{Start: start})
+	}
+
+	hourly, err := tss.db.ListHourlyCounts(ctx, queryText, first, nextBucket(last, granularity))
+	if err != nil {
+		return nil, fmt.Errorf("error listing hourly counts: %w", err)
+	}
+	for _, hour := range hourly {
+		if i, ok := pointIndex[bucketStart(hour.Hour.In(loc), granularity).Unix()]; ok {
+			series.Points[i].Count += hour.Count
+			series.Total += hour.Count
+		}
+	}
+
+	searchLog, err := tss.db.GetByQueryText(ctx, queryText)
+	if err != nil {
+		return nil, fmt.Errorf("error getting search log: %w", err)
+	}
+	if searchLog != nil {
+		series.UniqueClients = searchLog.UniqueClients
+	}
+	if granularity == models.TimeSeriesGranularityDay {
+		if err := tss.addDailyUniqueClients(ctx, series, first, nextBucket(last, granularity)); err != nil {
+			return nil, err
+		}
+	}
+	return series, nil
+}
+
+// addDailyUniqueClients sets the distinct clients of the points of a daily series that are UTC days. Distinct clients
+// of other buckets cannot be added up from those of UTC days.
+func (tss timeSeriesService) addDailyUniqueClients(ctx context.Context, series *models.QueryTimeSeries, from, to time.Time) error {
+	daily, err := tss.db.ListDailyUniqueClients(ctx, series.QueryText, from, to)
+	if err != nil {
+		return fmt.Errorf("error listing daily distinct clients: %w", err)
+	}
+	byDay := make(map[int64]int64, len(daily))
+	for _, day := range daily {
+		byDay[day.Day.Unix()] = day.UniqueClients
+	}
+	for i, point := range series.Points {
+		if !point.Start.Equal(point.Start.UTC().Truncate(24*time.Hour)) || !nextBucket(point.Start, models.TimeSeriesGranularityDay).Equal(point.Start.Add(24*time.Hour)) {
+			continue
+		}
+		uniqueClients := byDay[point.Start.Unix()]
+		series.Points[i].UniqueClients = &uniqueClients
+	}
+	return nil
+}
+
+// bucketStart returns the start of the bucket containing t, in t's location. Weeks start on Mondays.
+func bucketStart(t time.Time, granularity models.TimeSeriesGranularity) time.Time {
+	switch granularity {
+	case models.TimeSeriesGranularityHour:
+		// Truncating the wall clock rather than t keeps hours aligned in zones with fractional offsets.
+		_, offset := t.Zone()
+		wallClock := time.Duration(offset) * time.Second
+		return t.Add(wallClock).Truncate(time.Hour).Add(-wallClock)
+	case models.TimeSeriesGranularityWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// nextBucket returns the start of the bucket after the one starting at start. Days and weeks follow the calendar,
+// so they last 23 or 25 hours across daylight saving time changes.
+func nextBucket(start time.Time, granularity models.TimeSeriesGranularity) time.Time {
+	switch granularity {
+	case models.TimeSeriesGranularityHour:
+		return start.Add(time.Hour)
+	case models.TimeSeriesGranularityWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"search-logger/models"
+	"search-logger/repository/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hourlyCountsRepository serves fixed hourly counts and distinct clients, filtered like the database repository.
+type hourlyCountsRepository struct {
+	database.SearchLogRepository
+	hourly        []models.QueryHourlyCount
+	searchLogs    []models.SearchLog
+	uniqueClients []models.QueryDailyUniqueClients
+}
+
+func (r hourlyCountsRepository) GetByQueryText(_ context.Context, queryText string) (*models.SearchLog, error) {
+	for _, searchLog := range r.searchLogs {
+		if searchLog.QueryText == queryText {
+			return &searchLog, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r hourlyCountsRepository) ListDailyUniqueClients(_ context.Context, queryText string, from, to time.Time) ([]models.QueryDailyUniqueClients, error) {
+	var daily []models.QueryDailyUniqueClients
+	for _, day := range r.uniqueClients {
+		if day.QueryText == queryText && !day.Day.Before(from) && day.Day.Before(to) {
+			daily = append(daily, day)
+		}
+	}
+	return daily, nil
+}
+
+func (r hourlyCountsRepository) ListHourlyCounts(_ context.Context, queryText string, from, to time.Time) ([]models.QueryHourlyCount, error) {
+	var hourly []models.QueryHourlyCount
+	for _, hour := range r.hourly {
+		if hour.QueryText == queryText && !hour.Hour.Before(from) && hour.Hour.Before(to) {
+			hourly = append(hourly, hour)
+		}
+	}
+	return hourly, nil
+}
+
+func hourlyCount(queryText, hour string, count int) models.QueryHourlyCount {
+	parsed, _ := time.Parse(time.RFC3339, hour)
+	return models.QueryHourlyCount{QueryText: queryText, Hour: parsed, Count: count}
+}
+
+func TestTimeSeriesService_QueryTimeSeries(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+
+	service := NewTimeSeriesService(hourlyCountsRepository{hourly: []models.QueryHourlyCount{
+		hourlyCount("black friday", "2026-11-26T03:00:00Z", 1),
+		hourlyCount("black friday", "2026-11-26T05:00:00Z", 2),
+		hourlyCount("black friday", "2026-11-27T15:00:00Z", 4),
+		hourlyCount("black friday", "2026-11-30T12:00:00Z", 8),
+		hourlyCount("cyber monday", "2026-11-27T15:00:00Z", 100),
+	}, searchLogs: []models.SearchLog{
+		{QueryText: "black friday", Count: 15, UniqueClients: 9},
+	}, uniqueClients: []models.QueryDailyUniqueClients{
+		{QueryText: "black friday", Day: time.Date(2026, 11, 26, 0, 0, 0, 0, time.UTC), UniqueClients: 2},
+		{QueryText: "black friday", Day: time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC), UniqueClients: 3},
+	}})
+	ctx := context.Background()
+	at := func(value string) time.Time {
+		parsed, _ := time.Parse(time.RFC3339, value)
+		return parsed
+	}
+
+	t.Run("Daily series in UTC are zero-filled", func(t *testing.T) {
+		// ACT
+		series, err := service.QueryTimeSeries(ctx, " Black Friday ", models.TimeSeriesGranularityDay, at("2026-11-25T00:00:00Z"), at("2026-11-29T00:00:00Z"), time.UTC)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, "black friday", series.QueryText)
+		assert.Equal(t, 7, series.Total)
+		counts := make([]int, 0, len(series.Points))
+		for _, point := range series.Points {
+			counts = append(counts, point.Count)
+		}
+		assert.Equal(t, []int{0, 3, 4, 0, 0}, counts)
+		assert.Equal(t, at("2026-11-25T00:00:00Z"), series.Points[0].Start)
+	})
+
+	t.Run("Daily series in UTC carry distinct clients per day", func(t *testing.T) {
+		// ACT
+		series, err := service.QueryTimeSeries(ctx, "black friday", models.TimeSeriesGranularityDay, at("2026-11-25T00:00:00Z"), at("2026-11-27T00:00:00Z"), time.UTC)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Equal(t, int64(9), series.UniqueClients)
+		uniqueClients := make([]int64, 0, len(series.Points))
+		for _, point := range series.Points {
+			if assert.NotNil(t, point.UniqueClients) {
+				uniqueClients = append(uniqueClients, *point.UniqueClients)
+			}
+		}
+		assert.Equal(t, []int64{0, 2, 3}, uniqueClients)
+	})
+
+	t.Run("Days follow the requested time zone", func(t *testing.T) {
+		// ACT
+		series, err := service.QueryTimeSeries(ctx, "black friday", models.TimeSeriesGranularityDay, at("2026-11-25T12:00:00Z"), at("2026-11-27T12:00:00Z"), newYork)
+
+		// ASSERT
+		// 03:00 UTC on the 26th is still the 25th in New York, and 05:00 UTC is the 26th.
+		assert.NoError(t, err)
+		assert.Equal(t, "America/New_York", series.Timezone)
+		if assert.Len(t, series.Points, 3) {
+			assert.Equal(t, time.Date(2026, 11, 25, 0, 0, 0, 0, newYork), series.Points[0].Start)
+			assert.Equal(t, []int{1, 2, 4}, []int{series.Points[0].Count, series.Points[1].Count, series.Points[2].Count})
+			// New York days are not UTC days, whose distinct clients cannot be split.
+			assert.Nil(t, series.Points[1].UniqueClients)
+		}
+	})
+
+	t.Run("Weeks start on Mondays", func(t *testing.T) {
+		// ACT
+		series, err := service.QueryTimeSeries(ctx, "black friday", models.TimeSeriesGranularityWeek, at("2026-11-26T00:00:00Z"), at("2026-12-01T00:00:00Z"), time.UTC)
+
+		// ASSERT
+		assert.NoError(t, err)
+		if assert.Len(t, series.Points, 2) {
+			assert.Equal(t, at("2026-11-23T00:00:00Z"), series.Points[0].Start)
+			assert.Equal(t, 7, series.Points[0].Count)
+			assert.Equal(t, at("2026-11-30T00:00:00Z"), series.Points[1].Start)
+			assert.Equal(t, 8, series.Points[1].Count)
+		}
+	})
+
+	t.Run("Hours align to the local clock in zones with fractional offsets", func(t *testing.T) {
+		// ACT
+		series, err := service.QueryTimeSeries(ctx, "black friday", models.TimeSeriesGranularityHour, at("2026-11-26T02:45:00Z"), at("2026-11-26T05:15:00Z"), kolkata)
+
+		// ASSERT
+		// Kolkata is 5:30 ahead of UTC, so hours start at half past in UTC.
+		assert.NoError(t, err)
+		if assert.Len(t, series.Points, 3) {
+			assert.Equal(t, at("2026-11-26T02:30:00Z"), series.Points[0].Start.UTC())
+			assert.Equal(t, []int{1, 0, 2}, []int{series.Points[0].Count, series.Points[1].Count, series.Points[2].Count})
+		}
+	})
+
+	t.Run("Days last 25 hours when daylight saving time ends", func(t *testing.T) {
+		// ACT
+		series, err := service.QueryTimeSeries(ctx, "black friday", models.TimeSeriesGranularityDay, at("2026-10-31T12:00:00Z"), at("2026-11-02T12:00:00Z"), newYork)
+
+		// ASSERT
+		assert.NoError(t, err)
+		if assert.Len(t, series.Points, 3) {
+			assert.Equal(t, 25*time.Hour, series.Points[2].Start.Sub(series.Points[1].Start))
+		}
+	})
+
+	t.Run("Invalid requests are rejected", func(t *testing.T) {
+		_, err := service.QueryTimeSeries(ctx, "black friday", "month", at("2026-11-25T00:00:00Z"), at("2026-11-29T00:00:00Z"), time.UTC)
+		assert.ErrorIs(t, err, ErrInvalidGranularity)
+
+		_, err = service.QueryTimeSeries(ctx, "black friday", models.TimeSeriesGranularityHour, at("2020-01-01T00:00:00Z"), at("2026-01-01T00:00:00Z"), time.UTC)
+		assert.ErrorIs(t, err, ErrTooManyPoints)
+
+		_, err = service.QueryTimeSeries(ctx, "black friday", models.TimeSeriesGranularityDay, at("2026-11-29T00:00:00Z"), at("2026-11-25T00:00:00Z"), time.UTC)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"time"
+)
+
+// TransitionService records which query a client finalized next, within the session window, to build the
+// reformulation graph.
+type TransitionService interface {
+	SearchLogPersistedListener
+}
+
+type transitionService struct {
+	transitions   database.TransitionRepository
+	sessions      cache.ClientSessionCacheRepository
+	sessionWindow time.Duration
+	logger        *slog.Logger
+}
+
+func NewTransitionService(transitions database.TransitionRepository, sessions cache.ClientSessionCacheRepository, sessionWindow time.Duration, logger *slog.Logger) TransitionService {
+	return &transitionService{
+		transitions:   transitions,
+		sessions:      sessions,
+		sessionWindow: sessionWindow,
+		logger:        logger,
+	}
+}
+
+func (ts transitionService) OnSearchLogPersisted(ctx context.Context, clientIdentifier string, searchLog *models.SearchLog) {
+	value := &cache.FinalizedQueryValue{
+		QueryText:                   searchLog.QueryText,
+		FinalizedAtUnixMilliseconds: time.Now().UnixMilli(),
+	}
+	previous, err := ts.sessions.SwapTransitionQuery(ctx, clientIdentifier, value, ts.sessionWindow)
+	if err != nil {
+		ts.logger.ErrorContext(ctx, "Error swapping transition query", "error", err, "clientIdentifier", clientIdentifier)
+		return
+	}
+
+	// Searching the same query again is not a reformulation.
+	if previous == nil || previous.QueryText == searchLog.QueryText {
+		return
+	}
+
+	if err := ts.transitions.RecordTransition(ctx, previous.QueryText, searchLog.QueryText); err != nil {
+		ts.logger.ErrorContext(ctx, "Error recording query transition", "error", err, "fromQuery", previous.QueryText, "toQuery", searchLog.QueryText)
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/storage_util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransitionService_OnSearchLogPersisted(t *testing.T) {
+	db := setupTestDB(t)
+	searchLogRepo := database.NewSearchLogDatabaseRepository(db)
+	transitionRepo := database.NewTransitionDatabaseRepository(db)
+	sessions := cache.NewClientSessionCacheRepository(storage_util.InitRedis())
+	transitions := NewTransitionService(transitionRepo, sessions, time.Minute, slog.Default())
+	ctx := context.Background()
+
+	finalize := func(clientIdentifier, queryText string) {
+		searchLog, err := searchLogRepo.IncrementSearchLog(ctx, queryText)
+		assert.NoError(t, err)
+		transitions.OnSearchLogPersisted(ctx, clientIdentifier, searchLog)
+	}
+
+	t.Run("Consecutive queries of a client are recorded as transitions", func(t *testing.T) {
+		// ACT
+		finalize("transition-client-1", "laptop")
+		finalize("transition-client-1", "gaming laptop")
+		finalize("transition-client-1", "rtx laptop")
+		finalize("transition-client-2", "laptop")
+		finalize("transition-client-2", "gaming laptop")
+
+		// ASSERT
+		stats, err := transitionRepo.GetReformulations(ctx, "laptop", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stats.Reformulations)
+		assert.Equal(t, "gaming laptop", stats.NextQueries[0].QueryText)
+		assert.Equal(t, 0.0, stats.AbandonmentRate)
+
+		stats, err = transitionRepo.GetReformulations(ctx, "gaming laptop", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stats.Reformulations)
+		assert.Equal(t, "rtx laptop", stats.NextQueries[0].QueryText)
+		assert.Equal(t, 0.5, stats.AbandonmentRate)
+	})
+
+	t.Run("Repeating the same query is not a transition", func(t *testing.T) {
+		// ACT
+		finalize("transition-client-3", "monitor")
+		finalize("transition-client-3", "monitor")
+
+		// ASSERT
+		stats, err := transitionRepo.GetReformulations(ctx, "monitor", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, stats.Reformulations)
+		assert.Equal(t, 1.0, stats.AbandonmentRate)
+	})
+}
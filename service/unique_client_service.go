@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/tenant"
+	"sync"
+	"time"
+)
+
+// UniqueClientService estimates how many distinct clients searched each query, all-time and per UTC day, with Redis
+// HyperLogLogs fed on finalization, and periodically snapshots the estimates to the database for analytics.
+type UniqueClientService interface {
+	SearchLogPersistedListener
+	QueriesPurgedListener
+	// Snapshot saves the latest estimates of the queries counted since the last snapshot.
+	Snapshot(ctx context.Context) error
+	// Stop stops snapshotting periodically, then snapshots one last time.
+	Stop(ctx context.Context) error
+}
+
+type uniqueClientService struct {
+	db               database.SearchLogRepository
+	cache            cache.UniqueClientCacheRepository
+	snapshotInterval time.Duration
+	logger           *slog.Logger
+
+	mu *sync.Mutex
+	// pending holds the latest estimates not snapshotted yet.
+	pending  map[uniqueClientsKey]uniqueClientsEstimate
+	stop     chan struct{}
+	stopOnce *sync.Once
+	stopped  chan struct{}
+}
+
+type uniqueClientsKey struct {
+	tenantID  string
+	queryText string
+	day       time.Time
+}
+
+type uniqueClientsEstimate struct {
+	total int64
+	daily int64
+}
+
+// NewUniqueClientService snapshots estimates every snapshotInterval, unless it is zero.
+func NewUniqueClientService(db database.SearchLogRepository, cache cache.UniqueClientCacheRepository, snapshotInterval time.Duration, logger *slog.Logger) UniqueClientService {
+	ucs := &uniqueClientService{
+		db:               db,
+		cache:            cache,
+		snapshotInterval: snapshotInterval,
+		logger:           logger,
+		mu:               &sync.Mutex{},
+		pending:          make(map[uniqueClientsKey]uniqueClientsEstimate),
+		stop:             make(chan struct{}),
+		stopOnce:         &sync.Once{},
+		stopped:          make(chan struct{}),
+	}
+	if snapshotInterval > 0 {
+		go ucs.snapshotPeriodically()
+	} else {
+		close(ucs.stopped)
+	}
+	return ucs
+}
+
+func (ucs *uniqueClientService) OnSearchLogPersisted(ctx context.Context, clientIdentifier string, searchLog *models.SearchLog) {
+	if searchLog == nil {
+		return
+	}
+
+	now := time.Now()
+	total, daily, err := ucs.cache.Add(ctx, searchLog.QueryText, clientIdentifier, now)
+	if err != nil {
+		ucs.logger.ErrorContext(ctx, "Error counting distinct clients", "error", err, "queryText", searchLog.QueryText)
+		return
+	}
+
+	key := uniqueClientsKey{tenantID: tenant.FromContext(ctx), queryText: searchLog.QueryText, day: now.UTC().Truncate(24 * time.Hour)}
+	ucs.mu.Lock()
+	defer ucs.mu.Unlock()
+	// Estimates only grow, so the latest one replaces those pending.
+	ucs.pending[key] = uniqueClientsEstimate{total: total, daily: daily}
+}
+
+// OnQueriesPurged forgets the clients of the purged queries, so that they do not carry over if the queries are
+// unblocked later.
+func (ucs *uniqueClientService) OnQueriesPurged(ctx context.Context, queryTexts []string) {
+	if err := ucs.cache.Delete(ctx, queryTexts, time.Now()); err != nil {
+		ucs.logger.ErrorContext(ctx, "Error forgetting distinct clients of purged queries", "error", err, "queries", len(queryTexts))
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	purged := make(map[string]bool, len(queryTexts))
+	for _, queryText := range queryTexts {
+		purged[queryText] = true
+	}
+	ucs.mu.Lock()
+	defer ucs.mu.Unlock()
+	for key := range ucs.pending {
+		if key.tenantID == tenantID && purged[key.queryText] {
+			delete(ucs.pending, key)
+		}
+	}
+}
+
+func (ucs *uniqueClientService) Snapshot(ctx context.Context) error {
+	ucs.mu.Lock()
+	pending := ucs.pending
+	ucs.pending = make(map[uniqueClientsKey]uniqueClientsEstimate)
+	ucs.mu.Unlock()
+
+	var errs []error
+	for key, estimate := range pending {
+		tenantCtx := tenant.WithTenant(ctx, key.tenantID)
+		if err := ucs.db.SaveUniqueClients(tenantCtx, key.queryText, key.day, estimate.total, estimate.daily); err != nil {
+			errs = append(errs, fmt.Errorf("saving distinct clients of %q for tenant %q: %w", key.queryText, key.tenantID, err))
+			ucs.retry(key, estimate)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retry snapshots an estimate again next time, after saving it failed, unless a newer one is already pending.
+func (ucs *uniqueClientService) retry(key uniqueClientsKey, estimate uniqueClientsEstimate) {
+	ucs.mu.Lock()
+	defer ucs.mu.Unlock()
+	if _, ok := ucs.pending[key]; !ok {
+		ucs.pending[key] = estimate
+	}
+}
+
+func (ucs *uniqueClientService) snapshotPeriodically() {
+	defer close(ucs.stopped)
+	ticker := time.NewTicker(ucs.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := ucs.Snapshot(context.Background()); err != nil {
+				ucs.logger.Error("Error snapshotting distinct clients", "error", err)
+			}
+		case <-ucs.stop:
+			return
+		}
+	}
+}
+
+func (ucs *uniqueClientService) Stop(ctx context.Context) error {
+	ucs.stopOnce.Do(func() { close(ucs.stop) })
+	select {
+	case <-ucs.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return ucs.Snapshot(ctx)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/storage_util"
+	"search-logger/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingSnapshotRepository fails to save snapshots until healed.
+type failingSnapshotRepository struct {
+	database.SearchLogRepository
+	healed *bool
+}
+
+func (r failingSnapshotRepository) SaveUniqueClients(ctx context.Context, queryText string, day time.Time, total, daily int64) error {
+	if !*r.healed {
+		return errors.New("database is down")
+	}
+	return r.SearchLogRepository.SaveUniqueClients(ctx, queryText, day, total, daily)
+}
+
+func TestUniqueClientService_Snapshot(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	t.Run("Repeat searches by a client count once", func(t *testing.T) {
+		// ARRANGE
+		dbRepo := setupTestDatabase(t)
+		srv := NewUniqueClientService(dbRepo, cache.NewUniqueClientCacheRepository(storage_util.InitRedis()), 0, slog.Default())
+		for _, clientIdentifier := range []string{"client:a", "client:a", "client:a", "client:b"} {
+			searchLog, err := dbRepo.IncrementSearchLog(ctx, "refund")
+			assert.NoError(t, err)
+			srv.OnSearchLogPersisted(ctx, clientIdentifier, searchLog)
+		}
+
+		// ACT
+		assert.NoError(t, srv.Snapshot(ctx))
+
+		// ASSERT
+		searchLog, err := dbRepo.GetByQueryText(ctx, "refund")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, searchLog.Count)
+		assert.Equal(t, int64(2), searchLog.UniqueClients)
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		daily, err := dbRepo.ListDailyUniqueClients(ctx, "refund", today, today.AddDate(0, 0, 1))
+		assert.NoError(t, err)
+		if assert.Len(t, daily, 1) {
+			assert.Equal(t, int64(2), daily[0].UniqueClients)
+		}
+	})
+
+	t.Run("Failed snapshots are retried", func(t *testing.T) {
+		// ARRANGE
+		dbRepo := setupTestDatabase(t)
+		healed := false
+		srv := NewUniqueClientService(failingSnapshotRepository{SearchLogRepository: dbRepo, healed: &healed}, cache.NewUniqueClientCacheRepository(storage_util.InitRedis()), 0, slog.Default())
+		searchLog, err := dbRepo.IncrementSearchLog(ctx, "refund")
+		assert.NoError(t, err)
+		srv.OnSearchLogPersisted(ctx, "client:a", searchLog)
+
+		// ACT
+		assert.Error(t, srv.Snapshot(ctx))
+		healed = true
+		assert.NoError(t, srv.Stop(ctx))
+
+		// ASSERT
+		searchLog, err = dbRepo.GetByQueryText(ctx, "refund")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), searchLog.UniqueClients)
+	})
+
+	t.Run("Purged queries are counted from scratch", func(t *testing.T) {
+		// ARRANGE
+		dbRepo := setupTestDatabase(t)
+		srv := NewUniqueClientService(dbRepo, cache.NewUniqueClientCacheRepository(storage_util.InitRedis()), 0, slog.Default())
+		for _, clientIdentifier := range []string{"client:a", "client:b"} {
+			searchLog, err := dbRepo.IncrementSearchLog(ctx, "casino")
+			assert.NoError(t, err)
+			srv.OnSearchLogPersisted(ctx, clientIdentifier, searchLog)
+		}
+
+		// ACT
+		srv.OnQueriesPurged(ctx, []string{"casino"})
+		searchLog, err := dbRepo.IncrementSearchLog(ctx, "casino")
+		assert.NoError(t, err)
+		srv.OnSearchLogPersisted(ctx, "client:c", searchLog)
+		assert.NoError(t, srv.Snapshot(ctx))
+
+		// ASSERT
+		searchLog, err = dbRepo.GetByQueryText(ctx, "casino")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), searchLog.UniqueClients)
+	})
+}
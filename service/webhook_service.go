@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"search-logger/config"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/tenant"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventIDHeader   = "X-Webhook-Event-Id"
+)
+
+type WebhookEvent struct {
+	ID         string                 `json:"id"`
+	TenantID   string                 `json:"tenant_id"`
+	Type       models.WebhookRuleType `json:"type"`
+	RuleID     string                 `json:"rule_id"`
+	QueryText  string                 `json:"query_text"`
+	Count      int                    `json:"count"`
+	Threshold  int                    `json:"threshold,omitempty"`
+	HourCount  int64                  `json:"hour_count,omitempty"`
+	PrevHour   int64                  `json:"previous_hour_count,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+type WebhookService interface {
+	SearchLogPersistedListener
+	// InvalidateRules makes the next search of the tenant on ctx reload its rules and endpoints, after they changed.
+	InvalidateRules(ctx context.Context)
+	// Wait blocks until every in-flight delivery, including its retries, has finished.
+	Wait()
+	// Drain waits for the in-flight deliveries like Wait until ctx is done, then interrupts those still running and
+	// waits for them to return, so they do not use the database once it is closed.
+	Drain(ctx context.Context) error
+}
+
+type webhookService struct {
+	repo       database.WebhookRepository
+	rates      cache.QueryRateCacheRepository
+	rules      *tenantCache[*webhookRules]
+	httpClient *http.Client
+	logger     *slog.Logger
+	inFlight   *sync.WaitGroup
+	stop       chan struct{}
+	stopOnce   *sync.Once
+}
+
+// webhookRules holds a tenant's rules with the active endpoints they deliver to.
+type webhookRules struct {
+	rules     []models.WebhookRule
+	endpoints map[string]models.WebhookEndpoint
+}
+
+func NewWebhookService(repo database.WebhookRepository, rates cache.QueryRateCacheRepository, logger *slog.Logger) WebhookService {
+	loadRules := func(ctx context.Context) (*webhookRules, error) {
+		rules, endpoints, err := repo.ListActiveRules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &webhookRules{rules: rules, endpoints: endpoints}, nil
+	}
+	return &webhookService{
+		repo:       repo,
+		rates:      rates,
+		rules:      newTenantCache(loadRules, config.GetWebhookRulesRefreshInterval(), maxCachedTenants),
+		httpClient: &http.Client{Timeout: config.GetWebhookRequestTimeout()},
+		logger:     logger,
+		inFlight:   &sync.WaitGroup{},
+		stop:       make(chan struct{}),
+		stopOnce:   &sync.Once{},
+	}
+}
+
+func (ws webhookService) OnSearchLogPersisted(ctx context.Context, _ string, searchLog *models.SearchLog) {
+	if searchLog == nil {
+		return
+	}
+
+	// The rules are cached, so tenants without webhooks cost no query.
+	loaded, stale, err := ws.rules.Get(ctx)
+	if err != nil {
+		ws.logger.ErrorContext(ctx, "Error loading webhook rules", "error", err)
+		if !stale {
+			return
+		}
+	}
+	rules, endpoints := loaded.rules, loaded.endpoints
+
+	now := time.Now()
+	var hourCount, prevHourCount int64
+	if hasRuleType(rules, models.WebhookRuleRateSpike) {
+		hourCount, prevHourCount, err = ws.rates.IncrementHourly(ctx, searchLog.QueryText, now)
+		if err != nil {
+			ws.logger.ErrorContext(ctx, "Error incrementing hourly query rate", "error", err, "queryText", searchLog.QueryText)
+		}
+	}
+
+	for _, rule := range rules {
+		event := &WebhookEvent{
+			ID:         uuid.New().String(),
+			TenantID:   tenant.FromContext(ctx),
+			Type:       rule.Type,
+			RuleID:     rule.ID,
+			QueryText:  searchLog.QueryText,
+			Count:      searchLog.Count,
+			OccurredAt: now.UTC(),
+		}
+
+		switch rule.Type {
+		case models.WebhookRuleFirstSeen:
+			if searchLog.PreviousCount != 0 {
+				continue
+			}
+		case models.WebhookRuleCountThreshold:
+			// Counts can grow by more than one at once, so the rule fires when the increment crossed the threshold.
+			if rule.Threshold <= 0 || searchLog.PreviousCount >= rule.Threshold || searchLog.Count < rule.Threshold {
+				continue
+			}
+			event.Threshold = rule.Threshold
+		case models.WebhookRuleRateSpike:
+			if !isRateSpike(hourCount, prevHourCount, rule.SpikeFactor) {
+				continue
+			}
+			hour := now.UTC().Truncate(time.Hour).Unix()
+			first, err := ws.rates.MarkOnce(ctx, fmt.Sprintf("%s:%s:%d", rule.ID, searchLog.QueryText, hour), time.Hour)
+			if err != nil {
+				ws.logger.ErrorContext(ctx, "Error marking rate spike", "error", err, "ruleID", rule.ID)
+				continue
+			}
+			if !first {
+				continue
+			}
+			event.HourCount = hourCount
+			event.PrevHour = prevHourCount
+		default:
+			continue
+		}
+
+		endpoint, ok := endpoints[rule.EndpointID]
+		if !ok {
+			continue
+		}
+
+		ws.inFlight.Add(1)
+		go func() {
+			defer ws.inFlight.Done()
+			deliveryCtx, cancel := context.WithCancel(detachContext(ctx))
+			defer cancel()
+			go func() {
+				select {
+				case <-ws.stop:
+					cancel()
+				case <-deliveryCtx.Done():
+				}
+			}()
+			ws.deliver(deliveryCtx, endpoint, event)
+		}()
+	}
+}
+
+func (ws webhookService) InvalidateRules(ctx context.Context) {
+	ws.rules.Invalidate(ctx)
+}
+
+func (ws webhookService) Wait() {
+	ws.inFlight.Wait()
+}
+
+func (ws webhookService) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		ws.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	ws.stopOnce.Do(func() { close(ws.stop) })
+	<-done
+	return ctx.Err()
+}
+
+func hasRuleType(rules []models.WebhookRule, ruleType models.WebhookRuleType) bool {
+	for _, rule := range rules {
+		if rule.Type == ruleType {
+			return true
+		}
+	}
+	return false
+}
+
+func isRateSpike(hourCount, prevHourCount int64, spikeFactor float64) bool {
+	if hourCount < int64(config.GetWebhookRateSpikeMinPerHour()) || spikeFactor <= 0 {
+		return false
+	}
+	baseline := math.Max(float64(prevHourCount), 1)
+	return float64(hourCount) >= baseline*spikeFactor
+}
+
+// deliver posts the event to the endpoint, retrying with exponential backoff, and records every attempt. It gives up
+// without recording the attempt once ctx is canceled by Drain.
+func (ws webhookService) deliver(ctx context.Context, endpoint models.WebhookEndpoint, event *WebhookEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		ws.logger.ErrorContext(ctx, "Error marshalling webhook event", "error", err, "eventID", event.ID)
+		return
+	}
+
+	backoff := config.GetWebhookInitialBackoff()
+	maxAttempts := config.GetWebhookMaxAttempts()
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		delivery := models.NewWebhookDelivery(endpoint.ID, event.RuleID, event.ID, attempt, string(payload))
+		statusCode, err := ws.post(ctx, endpoint, event.ID, payload)
+		if ctx.Err() != nil {
+			ws.logger.WarnContext(ctx, "Webhook delivery interrupted by shutdown", "eventID", event.ID, "endpointID", endpoint.ID, "attempt", attempt)
+			return
+		}
+		delivery.StatusCode = statusCode
+		delivery.Success = err == nil
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if err := ws.repo.CreateDelivery(ctx, delivery); err != nil {
+			ws.logger.ErrorContext(ctx, "Error recording webhook delivery", "error", err, "eventID", event.ID)
+		}
+
+		if delivery.Success || !isRetryableStatus(statusCode) {
+			return
+		}
+		if attempt < maxAttempts {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				ws.logger.WarnContext(ctx, "Webhook delivery interrupted by shutdown", "eventID", event.ID, "endpointID", endpoint.ID, "attempt", attempt)
+				return
+			}
+			backoff *= 2
+		}
+	}
+
+	ws.logger.WarnContext(ctx, "Giving up on webhook delivery", "eventID", event.ID, "endpointID", endpoint.ID, "attempts", maxAttempts)
+}
+
+func (ws webhookService) post(ctx context.Context, endpoint models.WebhookEndpoint, eventID string, payload []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventIDHeader, eventID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(endpoint.Secret, timestamp, payload))
+
+	resp, err := ws.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// isRetryableStatus reports whether a failed attempt is worth retrying. Status 0 means the request never got a response.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
+}
+
+// SignWebhookPayload returns the signature header value for a payload: the hex HMAC-SHA256 of "<timestamp>.<payload>"
+// keyed with the endpoint secret. Receivers recompute it to verify the sender and reject replays using the timestamp.
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"search-logger/models"
+	"search-logger/repository/cache"
+	"search-logger/repository/database"
+	"search-logger/storage_util"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestWebhookRepository(t *testing.T) database.WebhookRepository {
+	return database.NewWebhookDatabaseRepository(setupTestDB(t))
+}
+
+type recordedWebhook struct {
+	body      []byte
+	signature string
+	timestamp string
+}
+
+// newWebhookReceiver starts a server that records requests and answers with the given status codes in order,
+// repeating the last one once they run out.
+func newWebhookReceiver(t *testing.T, statusCodes ...int) (*httptest.Server, func() []recordedWebhook) {
+	var mu sync.Mutex
+	var received []recordedWebhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, recordedWebhook{
+			body:      body,
+			signature: r.Header.Get(WebhookSignatureHeader),
+			timestamp: r.Header.Get(WebhookTimestampHeader),
+		})
+		status := statusCodes[min(len(received), len(statusCodes))-1]
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []recordedWebhook {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedWebhook(nil), received...)
+	}
+}
+
+func TestWebhookService_OnSearchLogPersisted(t *testing.T) {
+	ctx := context.Background()
+	rates := cache.NewQueryRateCacheRepository(storage_util.InitRedis())
+
+	t.Run("First seen rule delivers a signed payload", func(t *testing.T) {
+		// ARRANGE
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, http.StatusOK)
+		endpoint := models.NewWebhookEndpoint(server.URL, "top-secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleFirstSeen, 0, 0)))
+		webhooks := NewWebhookService(repo, rates, slog.Default())
+
+		// ACT
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "new query", Count: 1})
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "new query", Count: 2, PreviousCount: 1})
+		webhooks.Wait()
+
+		// ASSERT
+		requests := received()
+		assert.Len(t, requests, 1)
+		assert.Contains(t, string(requests[0].body), `"query_text":"new query"`)
+		assert.Contains(t, string(requests[0].body), `"type":"first_seen"`)
+		assert.Equal(t, SignWebhookPayload("top-secret", requests[0].timestamp, requests[0].body), requests[0].signature)
+
+		deliveries, err := repo.ListDeliveries(ctx, endpoint.ID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.True(t, deliveries[0].Success)
+		assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	})
+
+	t.Run("Count threshold rule fires only when the threshold is reached", func(t *testing.T) {
+		// ARRANGE
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, http.StatusOK)
+		endpoint := models.NewWebhookEndpoint(server.URL, "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleCountThreshold, 3, 0)))
+		webhooks := NewWebhookService(repo, rates, slog.Default())
+
+		// ACT
+		for count := 1; count <= 5; count++ {
+			webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "popular", Count: count, PreviousCount: count - 1})
+		}
+		webhooks.Wait()
+
+		// ASSERT
+		requests := received()
+		assert.Len(t, requests, 1)
+		assert.Contains(t, string(requests[0].body), `"count":3`)
+		assert.Contains(t, string(requests[0].body), `"threshold":3`)
+	})
+
+	t.Run("Count threshold rule fires when the count jumps over the threshold", func(t *testing.T) {
+		// ARRANGE
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, http.StatusOK)
+		endpoint := models.NewWebhookEndpoint(server.URL, "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleCountThreshold, 3, 0)))
+		webhooks := NewWebhookService(repo, rates, slog.Default())
+
+		// ACT
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "merged", Count: 5, PreviousCount: 1})
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "merged", Count: 6, PreviousCount: 5})
+		webhooks.Wait()
+
+		// ASSERT
+		requests := received()
+		assert.Len(t, requests, 1)
+		assert.Contains(t, string(requests[0].body), `"count":5`)
+	})
+
+	t.Run("Rules are cached until they change", func(t *testing.T) {
+		// ARRANGE
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, http.StatusOK)
+		endpoint := models.NewWebhookEndpoint(server.URL, "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		webhooks := NewWebhookService(repo, rates, slog.Default())
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "before", Count: 1})
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleFirstSeen, 0, 0)))
+
+		// ACT
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "cached", Count: 1})
+		webhooks.InvalidateRules(ctx)
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "reloaded", Count: 1})
+		webhooks.Wait()
+
+		// ASSERT
+		requests := received()
+		assert.Len(t, requests, 1)
+		assert.Contains(t, string(requests[0].body), `"query_text":"reloaded"`)
+	})
+
+	t.Run("Rate spike rule fires once per hour", func(t *testing.T) {
+		// ARRANGE
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, http.StatusOK)
+		endpoint := models.NewWebhookEndpoint(server.URL, "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleRateSpike, 0, 2)))
+		webhooks := NewWebhookService(repo, rates, slog.Default())
+
+		// ACT
+		for count := 1; count <= 15; count++ {
+			webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "spiking query", Count: count})
+		}
+		webhooks.Wait()
+
+		// ASSERT
+		requests := received()
+		assert.Len(t, requests, 1)
+		assert.Contains(t, string(requests[0].body), `"type":"rate_spike"`)
+	})
+
+	t.Run("Failed deliveries are retried and every attempt is logged", func(t *testing.T) {
+		// ARRANGE
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK)
+		endpoint := models.NewWebhookEndpoint(server.URL, "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleFirstSeen, 0, 0)))
+		webhooks := NewWebhookService(repo, rates, slog.Default())
+
+		// ACT
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "flaky", Count: 1})
+		webhooks.Wait()
+
+		// ASSERT
+		assert.Len(t, received(), 3)
+		deliveries, err := repo.ListDeliveries(ctx, endpoint.ID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 3)
+		successes := 0
+		for _, delivery := range deliveries {
+			if delivery.Success {
+				successes++
+			}
+		}
+		assert.Equal(t, 1, successes)
+	})
+
+	t.Run("Client errors are not retried", func(t *testing.T) {
+		// ARRANGE
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, http.StatusBadRequest)
+		endpoint := models.NewWebhookEndpoint(server.URL, "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleFirstSeen, 0, 0)))
+		webhooks := NewWebhookService(repo, rates, slog.Default())
+
+		// ACT
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "rejected", Count: 1})
+		webhooks.Wait()
+
+		// ASSERT
+		assert.Len(t, received(), 1)
+	})
+}
+
+func TestWebhookService_Drain(t *testing.T) {
+	ctx := context.Background()
+	rates := cache.NewQueryRateCacheRepository(storage_util.InitRedis())
+	newWebhooks := func(t *testing.T, statusCodes ...int) (WebhookService, database.WebhookRepository, *models.WebhookEndpoint, func() []recordedWebhook) {
+		repo := setupTestWebhookRepository(t)
+		server, received := newWebhookReceiver(t, statusCodes...)
+		endpoint := models.NewWebhookEndpoint(server.URL, "secret")
+		assert.NoError(t, repo.CreateEndpoint(ctx, endpoint))
+		assert.NoError(t, repo.CreateRule(ctx, models.NewWebhookRule(endpoint.ID, models.WebhookRuleFirstSeen, 0, 0)))
+		return NewWebhookService(repo, rates, slog.Default()), repo, endpoint, received
+	}
+
+	t.Run("Retries finish before the deadline", func(t *testing.T) {
+		// ARRANGE
+		webhooks, repo, endpoint, received := newWebhooks(t, http.StatusServiceUnavailable, http.StatusOK)
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "retried", Count: 1})
+
+		// ACT
+		drainCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		err := webhooks.Drain(drainCtx)
+
+		// ASSERT
+		assert.NoError(t, err)
+		assert.Len(t, received(), 2)
+		deliveries, err := repo.ListDeliveries(ctx, endpoint.ID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+	})
+
+	t.Run("Deliveries still backing off are interrupted at the deadline", func(t *testing.T) {
+		// ARRANGE
+		webhooks, repo, endpoint, received := newWebhooks(t, http.StatusServiceUnavailable)
+		webhooks.OnSearchLogPersisted(ctx, "client", &models.SearchLog{QueryText: "unavailable", Count: 1})
+
+		// ACT
+		drainCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := webhooks.Drain(drainCtx)
+
+		// ASSERT
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Len(t, received(), 1)
+		deliveries, err := repo.ListDeliveries(ctx, endpoint.ID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+	})
+}
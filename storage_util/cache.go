@@ -17,7 +17,7 @@ var RedisClient *redis.Client
 func InitRedis() *redis.Client {
 	addr := os.Getenv("REDIS_ADDR")
 	if addr == "" {
-		InitMockRedis()
+		return InitMockRedis()
 	}
 
 	password := os.Getenv("REDIS_PASSWORD") // optional
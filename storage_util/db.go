@@ -1,14 +1,20 @@
 package storage_util
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// InitDB connects to the database of DB_DIALECT, postgres or sqlite (the default). SQLite stores its data in the file
+// at SQLITE_PATH, or in memory when it is empty, as tests do.
 func InitDB() *gorm.DB {
 	var db *gorm.DB
 	var err error
@@ -24,9 +30,19 @@ func InitDB() *gorm.DB {
 		if dsn == "" {
 			dsn = "host=localhost user=postgres dbname=search_logs password=secret sslmode=disable"
 		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: newGormLogger()})
+		if err == nil {
+			err = configurePool(db, getEnvInt("DB_MAX_OPEN_CONNS", 20), getEnvInt("DB_MAX_IDLE_CONNS", 10), connMaxLifetime())
+		}
 	case "sqlite":
-		db, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		path := os.Getenv("SQLITE_PATH")
+		db, err = gorm.Open(sqlite.Open(sqliteDSN(path)), &gorm.Config{Logger: newGormLogger()})
+		if err == nil && path == "" {
+			// Every connection to :memory: opens a database of its own, so the pool keeps a single connection forever.
+			err = configurePool(db, 1, 1, 0)
+		} else if err == nil {
+			err = configurePool(db, getEnvInt("DB_MAX_OPEN_CONNS", 4), getEnvInt("DB_MAX_IDLE_CONNS", 4), connMaxLifetime())
+		}
 
 	default:
 		log.Fatal("Unsupported DB dialect")
@@ -37,3 +53,52 @@ func InitDB() *gorm.DB {
 	}
 	return db
 }
+
+// IsInMemory reports whether InitDB keeps the data in memory, where it is lost when the process exits.
+func IsInMemory() bool {
+	dialect := os.Getenv("DB_DIALECT")
+	return (dialect == "" || dialect == "sqlite") && os.Getenv("SQLITE_PATH") == ""
+}
+
+// sqliteDSN opens the database file at path in WAL mode, so reads do not wait for writes, and makes connections wait
+// SQLITE_BUSY_TIMEOUT_MS for the write lock instead of failing right away. Transactions take the write lock when they
+// begin, since a transaction that reads first could not wait for it to be upgraded.
+func sqliteDSN(path string) string {
+	if path == "" {
+		return ":memory:"
+	}
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", strconv.Itoa(getEnvInt("SQLITE_BUSY_TIMEOUT_MS", 5000)))
+	params.Set("_txlock", "immediate")
+	return fmt.Sprintf("file:%s?%s", path, params.Encode())
+}
+
+// configurePool bounds the connections to the database, and recycles them after maxLifetime unless it is zero.
+func configurePool(db *gorm.DB, maxOpenConns, maxIdleConns int, maxLifetime time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(maxLifetime)
+	return nil
+}
+
+func connMaxLifetime() time.Duration {
+	return time.Duration(getEnvInt("DB_CONN_MAX_LIFETIME_SECONDS", 1800)) * time.Second
+}
+
+func getEnvInt(name string, defaultValue int) int {
+	str := os.Getenv(name)
+	if str == "" {
+		return defaultValue
+	}
+	val, err := strconv.Atoi(str)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return val
+}
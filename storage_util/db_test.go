@@ -0,0 +1,76 @@
+package storage_util
+
+import (
+	"path/filepath"
+	"search-logger/models"
+	"search-logger/tenant"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitDB_SQLiteFile(t *testing.T) {
+	// ARRANGE
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "search-logger.db"))
+	assert.False(t, IsInMemory())
+	db := InitDB()
+	assert.NoError(t, Migrate(db))
+
+	// ACT
+	searchLog := models.NewSearchLog("laptop", 1)
+	assert.NoError(t, db.Create(searchLog).Error)
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Close())
+
+	// ASSERT
+	reopened := InitDB()
+	var journalMode string
+	assert.NoError(t, reopened.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	assert.Equal(t, "wal", journalMode)
+	var count int64
+	assert.NoError(t, reopened.Model(&models.SearchLog{}).Where("query_text = ?", "laptop").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	assert.NoError(t, Migrate(reopened), "migrating twice is a no-op")
+}
+
+func TestInitDB_InMemory(t *testing.T) {
+	t.Setenv("SQLITE_PATH", "")
+	assert.True(t, IsInMemory())
+	db := InitDB()
+	assert.NoError(t, Migrate(db))
+
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+	assert.True(t, db.Migrator().HasTable(&models.APIKey{}))
+}
+
+// legacySearchLog is the search_logs table as it was before search logs were scoped to tenants.
+type legacySearchLog struct {
+	ID        string `gorm:"type:uuid;primaryKey"`
+	QueryText string `gorm:"unique"`
+	Count     int
+}
+
+func (*legacySearchLog) TableName() string {
+	return "search_logs"
+}
+
+func TestMigrate_BackfillsDefaultTenant(t *testing.T) {
+	// ARRANGE
+	t.Setenv("SQLITE_PATH", "")
+	db := InitDB()
+	assert.NoError(t, db.AutoMigrate(&legacySearchLog{}))
+	assert.NoError(t, db.Create(&legacySearchLog{ID: "3f1c4c8e-6b8a-4c59-9a57-0e8f0f4b2d11", QueryText: "laptop", Count: 3}).Error)
+
+	// ACT
+	err := Migrate(db)
+
+	// ASSERT
+	assert.NoError(t, err)
+	var searchLog models.SearchLog
+	assert.NoError(t, db.Where("query_text = ?", "laptop").First(&searchLog).Error)
+	assert.Equal(t, tenant.DefaultTenantID, searchLog.TenantID)
+	assert.Equal(t, 3, searchLog.Count)
+}
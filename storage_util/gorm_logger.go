@@ -0,0 +1,67 @@
+package storage_util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold matches gorm's default logger.
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger writes gorm's logs through slog.Default with the context of each query, so a failed or slow query is
+// logged with the request ID of the request that ran it. Every query is logged at debug level.
+type gormLogger struct {
+	level logger.LogLevel
+}
+
+func newGormLogger() logger.Interface {
+	return gormLogger{level: logger.Warn}
+}
+
+func (l gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return gormLogger{level: level}
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	// Repositories report missing rows as nil results, so they are not errors here.
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "Database query failed", "error", err, "sql", sql, "rows", rows, "duration_ms", float64(elapsed.Microseconds())/1000)
+	case elapsed > slowQueryThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Slow database query", "sql", sql, "rows", rows, "duration_ms", float64(elapsed.Microseconds())/1000)
+	case slog.Default().Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		slog.DebugContext(ctx, "Database query", "sql", sql, "rows", rows, "duration_ms", float64(elapsed.Microseconds())/1000)
+	}
+}
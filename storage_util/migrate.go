@@ -0,0 +1,29 @@
+package storage_util
+
+import (
+	"search-logger/models"
+
+	"gorm.io/gorm"
+)
+
+// Migrate creates the tables of every model, and adds the columns and indexes missing from existing ones. It never
+// drops columns or changes their types.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.SearchLog{},
+		&models.ZeroResultCount{},
+		&models.QueryHourlyCount{},
+		&models.QueryDailyUniqueClients{},
+		&models.QueryResultClick{},
+		&models.QueryTransition{},
+		&models.QuerySynonym{},
+		&models.QuarantinedSearch{},
+		&models.BlocklistRule{},
+		&models.BlockedQueryCount{},
+		&models.WebhookEndpoint{},
+		&models.WebhookRule{},
+		&models.WebhookDelivery{},
+		&models.APIKey{},
+		&models.APIKeyDailyUsage{},
+	)
+}
@@ -0,0 +1,62 @@
+package tenant
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid bearer token")
+	ErrTenantMismatch = errors.New("tenant header does not match token")
+	ErrInvalidID      = errors.New("invalid tenant id")
+)
+
+// Resolve returns the tenant a request is made for, from the tenant named in its header and its Authorization value.
+// A tenant claim in a verified HS256 bearer token wins over the header, and a header naming a different tenant than
+// the token is rejected. Requests naming neither use DefaultTenantID. Tokens are ignored when jwtSecret is empty.
+func Resolve(headerTenant, authorization, jwtSecret, jwtClaim string) (string, error) {
+	headerTenant = strings.TrimSpace(headerTenant)
+
+	tokenTenant, err := fromBearerToken(authorization, jwtSecret, jwtClaim)
+	if err != nil {
+		return "", err
+	}
+
+	tenantID := headerTenant
+	if tokenTenant != "" {
+		if headerTenant != "" && headerTenant != tokenTenant {
+			return "", ErrTenantMismatch
+		}
+		tenantID = tokenTenant
+	}
+
+	if tenantID == "" {
+		tenantID = DefaultTenantID
+	}
+	if !IsValidID(tenantID) {
+		return "", ErrInvalidID
+	}
+	return tenantID, nil
+}
+
+// fromBearerToken returns the tenant claim of a bearer token, or an empty string when there is no token,
+// no secret to verify it with, or no tenant claim in it.
+func fromBearerToken(authorization, jwtSecret, jwtClaim string) (string, error) {
+	rawToken, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || jwtSecret == "" {
+		return "", nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(rawToken), claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	tenantID, _ := claims[jwtClaim].(string)
+	return tenantID, nil
+}
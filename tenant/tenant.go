@@ -0,0 +1,35 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// DefaultTenantID is used for requests that do not identify a tenant, so single-storefront deployments keep working unchanged.
+const DefaultTenantID = "default"
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type tenantContextKey struct{}
+
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// FromContext returns the tenant attached to ctx, or DefaultTenantID when there is none.
+func FromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantContextKey{}).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenantID
+}
+
+// IsValidID reports whether tenantID is safe to use in database rows and cache keys.
+func IsValidID(tenantID string) bool {
+	return tenantIDPattern.MatchString(tenantID)
+}
+
+// Key prefixes a cache key with the tenant from ctx so tenants never share cache entries.
+func Key(ctx context.Context, key string) string {
+	return "tenant:" + FromContext(ctx) + ":" + key
+}
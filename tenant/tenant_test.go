@@ -0,0 +1,83 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	t.Run("Default tenant when none is set", func(t *testing.T) {
+		assert.Equal(t, DefaultTenantID, FromContext(context.Background()))
+	})
+
+	t.Run("Tenant set on the context", func(t *testing.T) {
+		ctx := WithTenant(context.Background(), "acme")
+		assert.Equal(t, "acme", FromContext(ctx))
+		assert.Equal(t, "tenant:acme:client", Key(ctx, "client"))
+	})
+}
+
+func TestIsValidID(t *testing.T) {
+	assert.True(t, IsValidID("acme"))
+	assert.True(t, IsValidID("store-2_eu"))
+	assert.False(t, IsValidID(""))
+	assert.False(t, IsValidID("Acme"))
+	assert.False(t, IsValidID("acme:other"))
+	assert.False(t, IsValidID("-acme"))
+}
+
+func TestResolve(t *testing.T) {
+	const secret = "test-secret"
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		assert.NoError(t, err)
+		return "Bearer " + token
+	}
+
+	t.Run("Default tenant when none is named", func(t *testing.T) {
+		tenantID, err := Resolve("", "", secret, "tenant_id")
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultTenantID, tenantID)
+	})
+
+	t.Run("Tenant header", func(t *testing.T) {
+		tenantID, err := Resolve(" acme ", "", secret, "tenant_id")
+		assert.NoError(t, err)
+		assert.Equal(t, "acme", tenantID)
+	})
+
+	t.Run("Token claim wins over a missing or matching header", func(t *testing.T) {
+		authorization := sign(jwt.MapClaims{"tenant_id": "globex"})
+		tenantID, err := Resolve("", authorization, secret, "tenant_id")
+		assert.NoError(t, err)
+		assert.Equal(t, "globex", tenantID)
+
+		tenantID, err = Resolve("globex", authorization, secret, "tenant_id")
+		assert.NoError(t, err)
+		assert.Equal(t, "globex", tenantID)
+	})
+
+	t.Run("Header naming another tenant than the token", func(t *testing.T) {
+		_, err := Resolve("acme", sign(jwt.MapClaims{"tenant_id": "globex"}), secret, "tenant_id")
+		assert.ErrorIs(t, err, ErrTenantMismatch)
+	})
+
+	t.Run("Token signed with another secret", func(t *testing.T) {
+		_, err := Resolve("", sign(jwt.MapClaims{"tenant_id": "globex"}), "other-secret", "tenant_id")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Tokens are ignored without a secret", func(t *testing.T) {
+		tenantID, err := Resolve("acme", "Bearer not-a-token", "", "tenant_id")
+		assert.NoError(t, err)
+		assert.Equal(t, "acme", tenantID)
+	})
+
+	t.Run("Invalid tenant id", func(t *testing.T) {
+		_, err := Resolve("Acme:other", "", secret, "tenant_id")
+		assert.ErrorIs(t, err, ErrInvalidID)
+	})
+}
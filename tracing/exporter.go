@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Exporter sends ended spans to a tracing backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+type stdoutSpan struct {
+	Name         string         `json:"name"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Kind         string         `json:"kind"`
+	StartTime    time.Time      `json:"start_time"`
+	DurationMs   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Links        []stdoutLink   `json:"links,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+type stdoutLink struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+}
+
+type stdoutExporter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutExporter writes every span to w as a line of JSON, for development.
+func NewStdoutExporter(w io.Writer) Exporter {
+	return &stdoutExporter{mu: &sync.Mutex{}, w: w}
+}
+
+func (e *stdoutExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		out := stdoutSpan{
+			Name:         span.Name,
+			TraceID:      span.TraceID,
+			SpanID:       span.SpanID,
+			ParentSpanID: span.ParentSpanID,
+			Kind:         span.Kind.String(),
+			StartTime:    span.StartTime,
+			DurationMs:   float64(span.EndTime.Sub(span.StartTime).Microseconds()) / 1000,
+			Error:        span.Error,
+		}
+		if len(span.Attributes) > 0 {
+			out.Attributes = make(map[string]any, len(span.Attributes))
+			for _, attr := range span.Attributes {
+				out.Attributes[attr.Key] = attr.Value
+			}
+		}
+		for _, link := range span.Links {
+			out.Links = append(out.Links, stdoutLink{TraceID: link.TraceID, SpanID: link.SpanID})
+		}
+		if err := encoder.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *stdoutExporter) Shutdown(context.Context) error {
+	return nil
+}
@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// The OTLP span kinds and status codes, which count from unspecified and unset.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+	otlpStatusCodeError  = 2
+)
+
+// instrumentationScope names the code that produced the spans.
+const instrumentationScope = "search-logger"
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue sets exactly one of its fields. 64-bit integers are strings in OTLP JSON.
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpHTTPExporter struct {
+	endpoint    string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+}
+
+// NewOTLPHTTPExporter posts spans to an OpenTelemetry collector's OTLP/HTTP traces endpoint, such as
+// http://localhost:4318/v1/traces, in the JSON encoding. headers are added to every request, e.g. for authentication.
+func NewOTLPHTTPExporter(endpoint, serviceName string, headers map[string]string, client *http.Client) Exporter {
+	return &otlpHTTPExporter{endpoint: endpoint, serviceName: serviceName, headers: headers, client: client}
+}
+
+func (e *otlpHTTPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.exportRequest(spans))
+	if err != nil {
+		return fmt.Errorf("error encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending spans: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpHTTPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *otlpHTTPExporter) exportRequest(spans []SpanData) otlpExportRequest {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		out := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              otlpSpanKind(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		for _, link := range span.Links {
+			out.Links = append(out.Links, otlpLink{TraceID: link.TraceID, SpanID: link.SpanID})
+		}
+		if span.Error != "" {
+			out.Status = &otlpStatus{Code: otlpStatusCodeError, Message: span.Error}
+		}
+		otlpSpans = append(otlpSpans, out)
+	}
+
+	return otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes([]Attribute{String("service.name", e.serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: otlpSpans}},
+	}}}
+}
+
+func otlpSpanKind(kind SpanKind) int {
+	switch kind {
+	case SpanKindServer:
+		return otlpSpanKindServer
+	case SpanKindClient:
+		return otlpSpanKindClient
+	default:
+		return otlpSpanKindInternal
+	}
+}
+
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	keyValues := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpAnyValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			intValue := strconv.FormatInt(v, 10)
+			value.IntValue = &intValue
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			stringValue := fmt.Sprint(v)
+			value.StringValue = &stringValue
+		}
+		keyValues = append(keyValues, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return keyValues
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collectorStandIn accepts OTLP/HTTP JSON export requests like an OpenTelemetry collector.
+type collectorStandIn struct {
+	mu       sync.Mutex
+	requests []map[string]any
+	headers  []http.Header
+	status   int
+}
+
+func (c *collectorStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, body)
+	c.headers = append(c.headers, r.Header.Clone())
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{}`))
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	t.Run("Spans are exported to the collector in OTLP JSON", func(t *testing.T) {
+		// ARRANGE
+		collector := &collectorStandIn{}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+		exporter := NewOTLPHTTPExporter(server.URL+"/v1/traces", "search-logger-test", map[string]string{"Authorization": "Bearer token"}, server.Client())
+		tracer := NewTracer(exporter, Config{SampleRatio: 1, QueueSize: 10, BatchSize: 10, BatchTimeout: time.Hour}, slog.Default())
+
+		// ACT
+		ctx, parent := tracer.Start(context.Background(), "HTTP POST /search", WithKind(SpanKindServer), WithAttributes(Int("http.response.status_code", 200)))
+		_, child := tracer.Start(ctx, "cache.Get", WithLinks(Link{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}))
+		child.RecordError(errors.New("connection refused"))
+		child.End()
+		parent.End()
+		assert.NoError(t, tracer.Shutdown(context.Background()))
+
+		// ASSERT
+		collector.mu.Lock()
+		defer collector.mu.Unlock()
+		if !assert.Len(t, collector.requests, 1) {
+			return
+		}
+		assert.Equal(t, "Bearer token", collector.headers[0].Get("Authorization"))
+		resourceSpans := collector.requests[0]["resourceSpans"].([]any)[0].(map[string]any)
+		assert.Equal(t, []any{map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "search-logger-test"}}}, resourceSpans["resource"].(map[string]any)["attributes"])
+		spans := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+		if !assert.Len(t, spans, 2) {
+			return
+		}
+
+		childSpan, parentSpan := spans[0].(map[string]any), spans[1].(map[string]any)
+		assert.Equal(t, "cache.Get", childSpan["name"])
+		assert.Equal(t, float64(otlpSpanKindInternal), childSpan["kind"])
+		assert.Equal(t, parentSpan["spanId"], childSpan["parentSpanId"])
+		assert.Equal(t, parentSpan["traceId"], childSpan["traceId"])
+		assert.Equal(t, map[string]any{"code": float64(otlpStatusCodeError), "message": "connection refused"}, childSpan["status"])
+		assert.Equal(t, []any{map[string]any{"traceId": "4bf92f3577b34da6a3ce929d0e0e4736", "spanId": "00f067aa0ba902b7"}}, childSpan["links"])
+
+		assert.Equal(t, float64(otlpSpanKindServer), parentSpan["kind"])
+		assert.NotContains(t, parentSpan, "parentSpanId")
+		assert.Equal(t, []any{map[string]any{"key": "http.response.status_code", "value": map[string]any{"intValue": "200"}}}, parentSpan["attributes"])
+		assert.IsType(t, "", parentSpan["startTimeUnixNano"])
+	})
+
+	t.Run("Collector errors are reported", func(t *testing.T) {
+		// ARRANGE
+		collector := &collectorStandIn{status: http.StatusServiceUnavailable}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+		exporter := NewOTLPHTTPExporter(server.URL+"/v1/traces", "search-logger-test", nil, server.Client())
+
+		// ACT
+		err := exporter.Export(context.Background(), []SpanData{{Name: "span", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}})
+
+		// ASSERT
+		assert.ErrorContains(t, err, "503")
+	})
+}
@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"search-logger/correlation"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Attribute is a key and a string, int64, float64 or bool value describing a span.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Float64(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Link points at a span of another trace, or of the same trace but not an ancestor, that caused this one.
+type Link struct {
+	TraceID string
+	SpanID  string
+}
+
+// SpanData is an ended span, as exporters receive it.
+type SpanData struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Kind         SpanKind
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   []Attribute
+	Links        []Link
+	// Error is the message of the error the span failed with, if any.
+	Error string
+}
+
+// Span is an operation being timed. Spans that were not sampled only carry their trace context, and record nothing.
+// A Span can be used from several goroutines.
+type Span struct {
+	traceParent correlation.TraceParent
+	recorder    func(SpanData)
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// TraceParent returns the trace context of the span, to propagate to the work it causes.
+func (s *Span) TraceParent() correlation.TraceParent {
+	return s.traceParent
+}
+
+// IsRecording reports whether the span was sampled and has not ended, so attributes set on it are exported.
+func (s *Span) IsRecording() bool {
+	if s.recorder == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.ended
+}
+
+// SetName renames the span, for names only known once the operation has progressed, such as the route of a request.
+func (s *Span) SetName(name string) {
+	if s.recorder == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s.recorder == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// RecordError marks the span as failed with err. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s.recorder == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End ends the span and hands it to the exporter. Only the first call has an effect.
+func (s *Span) End() {
+	if s.recorder == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.recorder(data)
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"search-logger/correlation"
+	"sync"
+	"time"
+)
+
+// Config tunes how a tracer samples and exports spans.
+type Config struct {
+	// SampleRatio is the share of new traces that are recorded, from 0 to 1. Traces started by a caller keep the
+	// caller's decision.
+	SampleRatio float64
+	// QueueSize is how many ended spans can wait for export before new ones are dropped.
+	QueueSize int
+	// BatchSize is how many spans are exported at once, at most.
+	BatchSize int
+	// BatchTimeout is how long an ended span waits for its batch to fill before being exported anyway.
+	BatchTimeout time.Duration
+}
+
+type tracer struct {
+	exporter Exporter
+	cfg      Config
+	logger   *slog.Logger
+
+	// mu guards stopped, and closing spans, against spans ending concurrently.
+	mu       *sync.RWMutex
+	stopped  bool
+	spans    chan SpanData
+	exported chan struct{}
+}
+
+// NewTracer starts exporting the sampled spans it starts through exporter, in batches, until Shutdown.
+func NewTracer(exporter Exporter, cfg Config, logger *slog.Logger) Tracer {
+	t := &tracer{
+		exporter: exporter,
+		cfg:      cfg,
+		logger:   logger,
+		mu:       &sync.RWMutex{},
+		spans:    make(chan SpanData, cfg.QueueSize),
+		exported: make(chan struct{}),
+	}
+	go t.export()
+	return t
+}
+
+func (t *tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	cfg := newSpanConfig(opts)
+	traceParent, _ := spanTraceParent(ctx, cfg, t.cfg.SampleRatio)
+	return correlation.WithTraceParent(ctx, traceParent), newSpan(name, traceParent, cfg, t.record)
+}
+
+// record queues an ended span for export, dropping it rather than slowing down the traced operation when the
+// queue is full.
+func (t *tracer) record(span SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.stopped {
+		return
+	}
+	select {
+	case t.spans <- span:
+	default:
+		t.logger.Warn("Dropping span, export queue is full", "span", span.Name)
+	}
+}
+
+func (t *tracer) export() {
+	defer close(t.exported)
+	batch := make([]SpanData, 0, t.cfg.BatchSize)
+	ticker := time.NewTicker(t.cfg.BatchTimeout)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(context.Background(), batch); err != nil {
+			t.logger.Error("Error exporting spans", "error", err, "spans", len(batch))
+		}
+		batch = make([]SpanData, 0, t.cfg.BatchSize)
+	}
+
+	for {
+		select {
+		case span, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= t.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (t *tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.stopped {
+		t.stopped = true
+		close(t.spans)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.exported:
+	case <-ctx.Done():
+		return fmt.Errorf("error exporting remaining spans: %w", ctx.Err())
+	}
+	return t.exporter.Shutdown(ctx)
+}
@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"search-logger/correlation"
+	"sync/atomic"
+	"time"
+)
+
+type Tracer interface {
+	// Start starts a span, as a child of the span on ctx or as the root of a new trace, and returns ctx carrying the
+	// new span's trace context. The span must be ended with End.
+	Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span)
+	// Shutdown exports the spans that already ended and stops exporting, giving up when ctx is done.
+	Shutdown(ctx context.Context) error
+}
+
+type spanConfig struct {
+	kind          SpanKind
+	attributes    []Attribute
+	links         []Link
+	isRequestSpan bool
+}
+
+type SpanOption func(*spanConfig)
+
+func WithKind(kind SpanKind) SpanOption {
+	return func(cfg *spanConfig) {
+		cfg.kind = kind
+	}
+}
+
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(cfg *spanConfig) {
+		cfg.attributes = append(cfg.attributes, attrs...)
+	}
+}
+
+func WithLinks(links ...Link) SpanOption {
+	return func(cfg *spanConfig) {
+		cfg.links = append(cfg.links, links...)
+	}
+}
+
+// AsRequestSpan makes the span the one correlation.FromIncoming already put on ctx for the request being served,
+// instead of a child of it, so the span IDs of the request's logs and of its span match.
+func AsRequestSpan() SpanOption {
+	return func(cfg *spanConfig) {
+		cfg.isRequestSpan = true
+	}
+}
+
+var defaultTracer atomic.Value
+
+func init() {
+	SetDefault(noopTracer{})
+}
+
+// SetDefault makes tracer the one used by Start, e.g. the one NewTracer returns for the configured exporter.
+func SetDefault(tracer Tracer) {
+	defaultTracer.Store(&tracer)
+}
+
+func Default() Tracer {
+	return *defaultTracer.Load().(*Tracer)
+}
+
+// Start starts a span with the default tracer. See Tracer.Start.
+func Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	return Default().Start(ctx, name, opts...)
+}
+
+// noopTracer records nothing, but still gives every span its own trace context, so logs keep telling spans apart.
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, opts ...SpanOption) (context.Context, *Span) {
+	traceParent, _ := spanTraceParent(ctx, newSpanConfig(opts), 1)
+	return correlation.WithTraceParent(ctx, traceParent), &Span{traceParent: traceParent}
+}
+
+func (noopTracer) Shutdown(context.Context) error {
+	return nil
+}
+
+func newSpanConfig(opts []SpanOption) spanConfig {
+	var cfg spanConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// spanTraceParent returns the trace context of a new span, and whether the span has a parent. The sampling decision
+// of the parent is kept, and only root spans are sampled with sampleRatio.
+func spanTraceParent(ctx context.Context, cfg spanConfig, sampleRatio float64) (correlation.TraceParent, bool) {
+	parent, hasParent := correlation.TraceParentFromContext(ctx)
+	switch {
+	case hasParent && cfg.isRequestSpan:
+		if parent.ParentSpanID != "" {
+			return parent, true
+		}
+		// The request started a new trace, whose sampling is decided here rather than by correlation.
+		parent.Sampled = isSampled(parent.TraceID, sampleRatio)
+		return parent, false
+	case hasParent:
+		return parent.Child(), true
+	default:
+		root := correlation.NewTraceParent()
+		root.Sampled = isSampled(root.TraceID, sampleRatio)
+		return root, false
+	}
+}
+
+// isSampled decides from the trace ID alone, so every replica and process taking part in a trace decides the same.
+func isSampled(traceID string, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	id, err := hex.DecodeString(traceID)
+	if err != nil || len(id) != 16 {
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:])>>1 < uint64(ratio*math.MaxInt64)
+}
+
+func newSpan(name string, traceParent correlation.TraceParent, cfg spanConfig, recorder func(SpanData)) *Span {
+	span := &Span{traceParent: traceParent}
+	if !traceParent.Sampled || recorder == nil {
+		return span
+	}
+	span.recorder = recorder
+	span.data = SpanData{
+		Name:         name,
+		TraceID:      traceParent.TraceID,
+		SpanID:       traceParent.SpanID,
+		ParentSpanID: traceParent.ParentSpanID,
+		Kind:         cfg.kind,
+		StartTime:    time.Now(),
+		Attributes:   cfg.attributes,
+		Links:        cfg.links,
+	}
+	return span
+}
@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"search-logger/correlation"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *recordingExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func (e *recordingExporter) exported() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+func newTestTracer(exporter Exporter, sampleRatio float64) Tracer {
+	return NewTracer(exporter, Config{SampleRatio: sampleRatio, QueueSize: 100, BatchSize: 10, BatchTimeout: 10 * time.Millisecond}, slog.Default())
+}
+
+func TestTracer_Start(t *testing.T) {
+	t.Run("Child spans share the trace of their parent and are exported once ended", func(t *testing.T) {
+		// ARRANGE
+		exporter := &recordingExporter{}
+		tracer := newTestTracer(exporter, 1)
+
+		// ACT
+		ctx, parent := tracer.Start(context.Background(), "parent", WithKind(SpanKindServer))
+		_, child := tracer.Start(ctx, "child", WithAttributes(String("key", "value")))
+		child.RecordError(errors.New("boom"))
+		child.End()
+		parent.End()
+		parent.End()
+		assert.NoError(t, tracer.Shutdown(context.Background()))
+
+		// ASSERT
+		spans := exporter.exported()
+		if assert.Len(t, spans, 2) {
+			assert.Equal(t, "child", spans[0].Name)
+			assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+			assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+			assert.Equal(t, []Attribute{String("key", "value")}, spans[0].Attributes)
+			assert.Equal(t, "boom", spans[0].Error)
+			assert.Equal(t, SpanKindServer, spans[1].Kind)
+			assert.Empty(t, spans[1].ParentSpanID)
+		}
+		traceParent, _ := correlation.TraceParentFromContext(ctx)
+		assert.Equal(t, parent.TraceParent(), traceParent)
+	})
+
+	t.Run("Request spans keep the span ID of the request and the trace of the caller", func(t *testing.T) {
+		// ARRANGE
+		exporter := &recordingExporter{}
+		tracer := newTestTracer(exporter, 0)
+		ctx := correlation.FromIncoming(context.Background(), "", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		requestTraceParent, _ := correlation.TraceParentFromContext(ctx)
+
+		// ACT
+		_, span := tracer.Start(ctx, "request", AsRequestSpan())
+		span.End()
+		assert.NoError(t, tracer.Shutdown(context.Background()))
+
+		// ASSERT
+		// The caller sampled the trace, which wins over the sample ratio.
+		spans := exporter.exported()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, requestTraceParent.SpanID, spans[0].SpanID)
+			assert.Equal(t, "00f067aa0ba902b7", spans[0].ParentSpanID)
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID)
+		}
+	})
+
+	t.Run("Unsampled traces are propagated but not exported", func(t *testing.T) {
+		// ARRANGE
+		exporter := &recordingExporter{}
+		tracer := newTestTracer(exporter, 0)
+
+		// ACT
+		ctx, parent := tracer.Start(context.Background(), "parent")
+		ctx, child := tracer.Start(ctx, "child")
+		child.SetAttributes(String("key", "value"))
+		child.End()
+		parent.End()
+		assert.NoError(t, tracer.Shutdown(context.Background()))
+
+		// ASSERT
+		assert.Empty(t, exporter.exported())
+		assert.False(t, parent.IsRecording())
+		traceParent, _ := correlation.TraceParentFromContext(ctx)
+		assert.False(t, traceParent.Sampled)
+		assert.Equal(t, parent.TraceParent().TraceID, traceParent.TraceID)
+	})
+}
+
+func TestIsSampled(t *testing.T) {
+	t.Run("Sample ratio applies to trace IDs", func(t *testing.T) {
+		var sampled int
+		for i := 0; i < 1000; i++ {
+			if isSampled(correlation.NewTraceParent().TraceID, 0.25) {
+				sampled++
+			}
+		}
+		assert.InDelta(t, 250, sampled, 75)
+	})
+
+	t.Run("Decision only depends on the trace ID", func(t *testing.T) {
+		traceID := correlation.NewTraceParent().TraceID
+		assert.Equal(t, isSampled(traceID, 0.5), isSampled(traceID, 0.5))
+		assert.True(t, isSampled(traceID, 1))
+		assert.False(t, isSampled(traceID, 0))
+	})
+}
+
+func TestStdoutExporter(t *testing.T) {
+	t.Run("Spans are written as lines of JSON", func(t *testing.T) {
+		// ARRANGE
+		var out bytes.Buffer
+		start := time.Now()
+		span := SpanData{
+			Name:       "span",
+			TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:     "00f067aa0ba902b7",
+			StartTime:  start,
+			EndTime:    start.Add(1500 * time.Microsecond),
+			Attributes: []Attribute{Int("count", 3)},
+		}
+
+		// ACT
+		err := NewStdoutExporter(&out).Export(context.Background(), []SpanData{span, span})
+
+		// ASSERT
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, 2)
+		var written map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &written))
+		assert.Equal(t, "span", written["name"])
+		assert.Equal(t, "internal", written["kind"])
+		assert.Equal(t, 1.5, written["duration_ms"])
+		assert.Equal(t, map[string]any{"count": float64(3)}, written["attributes"])
+	})
+}